@@ -0,0 +1,151 @@
+package avssync
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+
+	sdklogging "github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+type balanceBackend interface {
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+}
+
+// AdminServer serves an HTTP API to trigger, pause and inspect the syncs of an AvsSync at runtime.
+// It listens on its own address, separate from the metrics server, and requires a bearer token if one is configured.
+type AdminServer struct {
+	logger    sdklogging.Logger
+	avsSync   *AvsSync
+	client    balanceBackend
+	sender    common.Address
+	authToken string
+}
+
+// NewAdminServer creates an admin server for avsSync.
+// An empty authToken means the API is unauthenticated, which should only be used on a private listener.
+func NewAdminServer(logger sdklogging.Logger, avsSync *AvsSync, client balanceBackend, sender common.Address, authToken string) *AdminServer {
+	return &AdminServer{
+		logger:    logger,
+		avsSync:   avsSync,
+		client:    client,
+		sender:    sender,
+		authToken: authToken,
+	}
+}
+
+// SyncRequest is the optional body of POST /sync
+type SyncRequest struct {
+	Quorums   []int            `json:"quorums"`
+	Operators []common.Address `json:"operators"`
+}
+
+// AdminStatus is the body of GET /status
+type AdminStatus struct {
+	Status
+	Sender           common.Address `json:"sender"`
+	SenderBalanceWei *big.Int       `json:"senderBalanceWei"`
+	BalanceError     string         `json:"balanceError,omitempty"`
+}
+
+func (s *AdminServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /sync", s.handleSync)
+	mux.HandleFunc("POST /pause", s.handlePause)
+	mux.HandleFunc("POST /resume", s.handleResume)
+	mux.HandleFunc("GET /status", s.handleStatus)
+	mux.HandleFunc("GET /config", s.handleConfig)
+	return s.authenticate(mux)
+}
+
+// Start serves the admin API on addr until ctx is done
+func (s *AdminServer) Start(ctx context.Context, addr string) error {
+	s.logger.Info("Starting admin server", "addr", addr, "authenticated", s.authToken != "")
+	return listenAndServe(ctx, addr, s.Handler())
+}
+
+func (s *AdminServer) authenticate(next http.Handler) http.Handler {
+	if s.authToken == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.authToken)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *AdminServer) handleSync(w http.ResponseWriter, r *http.Request) {
+	var req SyncRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid request body: %v", err)})
+			return
+		}
+	}
+	var quorums []byte
+	for _, quorum := range req.Quorums {
+		if quorum < 0 || quorum > 255 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid quorum %d", quorum)})
+			return
+		}
+		quorums = append(quorums, byte(quorum))
+	}
+
+	err := s.avsSync.RequestSync(quorums, req.Operators)
+	switch {
+	case errors.Is(err, ErrSyncsPaused), errors.Is(err, ErrSyncAlreadyRequested), errors.Is(err, ErrNotLeader):
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+	case err != nil:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+	default:
+		s.logger.Info("Sync requested through admin API", "quorums", req.Quorums, "operators", req.Operators, "remoteAddr", r.RemoteAddr)
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "sync requested"})
+	}
+}
+
+func (s *AdminServer) handlePause(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Pause requested through admin API", "remoteAddr", r.RemoteAddr)
+	s.avsSync.Pause()
+	writeJSON(w, http.StatusOK, map[string]bool{"paused": true})
+}
+
+func (s *AdminServer) handleResume(w http.ResponseWriter, r *http.Request) {
+	s.logger.Info("Resume requested through admin API", "remoteAddr", r.RemoteAddr)
+	s.avsSync.Resume()
+	writeJSON(w, http.StatusOK, map[string]bool{"paused": false})
+}
+
+func (s *AdminServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := AdminStatus{Status: s.avsSync.Status(), Sender: s.sender}
+	if s.client != nil {
+		timeoutCtx, cancel := context.WithTimeout(r.Context(), s.avsSync.readerTimeout())
+		defer cancel()
+		balance, err := s.client.BalanceAt(timeoutCtx, s.sender, nil)
+		if err != nil {
+			status.BalanceError = err.Error()
+		} else {
+			status.SenderBalanceWei = balance
+		}
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *AdminServer) handleConfig(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.avsSync.Config().Redacted())
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,106 @@
+package avssync
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestAvsSync(quorums []byte, operators []common.Address) *AvsSync {
+	logger := logging.NewTextSLogger(os.Stderr, &logging.SLoggerOptions{})
+	var quorumInts []int
+	for _, quorum := range quorums {
+		quorumInts = append(quorumInts, int(quorum))
+	}
+	avsSync, err := NewAvsSync(logger, Config{
+		SyncInterval:        time.Hour,
+		Operators:           operators,
+		Quorums:             quorumInts,
+		RetrySyncNTimes:     1,
+		ReaderTimeout:       time.Second,
+		WriterTimeout:       time.Second,
+		ShutdownGracePeriod: time.Second,
+	}, nil, nil, nil, nil, prometheus.NewRegistry())
+	if err != nil {
+		panic(err)
+	}
+	return avsSync
+}
+
+func TestAdminServer(t *testing.T) {
+	avsSync := newTestAvsSync([]byte{0, 1}, nil)
+	avsSync.config.EcdsaPrivateKey = "0x1234"
+	avsSync.updateStakeAttemptDone(1, UpdateStakeStatusSucceed)
+	server := httptest.NewServer(NewAdminServer(avsSync.logger, avsSync, nil, common.HexToAddress("0x1"), "secret").Handler())
+	defer server.Close()
+
+	do := func(method, path, token, body string) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	require.Equal(t, http.StatusUnauthorized, do("GET", "/status", "", "").StatusCode)
+	require.Equal(t, http.StatusUnauthorized, do("GET", "/status", "wrong", "").StatusCode)
+
+	resp := do("GET", "/status", "secret", "")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var status AdminStatus
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&status))
+	require.Equal(t, []int{0, 1}, status.Quorums)
+	require.Equal(t, UpdateStakeStatusSucceed, status.QuorumSyncs["1"].LastSyncStatus)
+	require.Equal(t, common.HexToAddress("0x1"), status.Sender)
+
+	getConfig := func() Config {
+		resp := do("GET", "/config", "secret", "")
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		var config Config
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&config))
+		return config
+	}
+	require.Equal(t, redacted, getConfig().EcdsaPrivateKey)
+	require.Equal(t, 1, getConfig().RetrySyncNTimes)
+	// the reloads are served once applied
+	reloadedConfig := avsSync.Config()
+	reloadedConfig.RetrySyncNTimes = 5
+	require.NoError(t, avsSync.Reload(reloadedConfig))
+	avsSync.applyPendingConfig()
+	require.Equal(t, 5, getConfig().RetrySyncNTimes)
+	require.Equal(t, redacted, getConfig().EcdsaPrivateKey)
+
+	// the sync loop isn't running, so the first request stays queued
+	require.Equal(t, http.StatusAccepted, do("POST", "/sync", "secret", `{"quorums": [1]}`).StatusCode)
+	require.Equal(t, syncRequest{quorums: []byte{1}}, <-avsSync.syncRequests)
+	require.Equal(t, http.StatusAccepted, do("POST", "/sync", "secret", "").StatusCode)
+	require.Equal(t, http.StatusConflict, do("POST", "/sync", "secret", "").StatusCode)
+	require.Equal(t, http.StatusBadRequest, do("POST", "/sync", "secret", `{"quorums": [256]}`).StatusCode)
+	require.Equal(t, http.StatusMethodNotAllowed, do("GET", "/sync", "secret", "").StatusCode)
+
+	require.Equal(t, http.StatusOK, do("POST", "/pause", "secret", "").StatusCode)
+	require.True(t, avsSync.Paused())
+	<-avsSync.syncRequests
+	require.Equal(t, http.StatusConflict, do("POST", "/sync", "secret", "").StatusCode)
+	require.Equal(t, http.StatusOK, do("POST", "/resume", "secret", "").StatusCode)
+	require.False(t, avsSync.Paused())
+}
+
+func TestRequestSyncValidation(t *testing.T) {
+	avsSync := newTestAvsSync(nil, []common.Address{common.HexToAddress("0x1")})
+	require.Error(t, avsSync.RequestSync([]byte{0}, nil))
+	require.NoError(t, avsSync.RequestSync(nil, []common.Address{common.HexToAddress("0x2")}))
+}
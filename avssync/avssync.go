@@ -2,11 +2,17 @@ package avssync
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
 	"sort"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/Layr-Labs/eigensdk-go/chainio/clients/avsregistry"
 	sdklogging "github.com/Layr-Labs/eigensdk-go/logging"
 	"github.com/Layr-Labs/eigensdk-go/types"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -15,146 +21,420 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
+var errTxReverted = errors.New("transaction reverted")
+
 type AvsSync struct {
-	AvsReader       *avsregistry.ChainReader
-	AvsWriter       *avsregistry.ChainWriter
+	AvsReader       AvsReader
+	AvsWriter       AvsWriter
 	RetrySyncNTimes int
 
+	config                       Config // guarded by statusMu, the fields below are derived from it
 	logger                       sdklogging.Logger
 	sleepBeforeFirstSyncDuration time.Duration
 	syncInterval                 time.Duration
+	schedule                     Schedule         // nil means we sync every syncInterval, starting after sleepBeforeFirstSyncDuration
 	operators                    []common.Address // empty means we update all operators
 	quorums                      []byte
 	fetchQuorumsDynamically      bool
+	operatorSetReader            OperatorSetReader // nil means we sync quorums, otherwise the operator sets of avsAddr
+	avsAddr                      common.Address
+	retryPolicy                  RetryPolicy           // MaxAttempts is RetrySyncNTimes
+	stakeDriftThresholds         *StakeDriftThresholds // nil means we update every quorum at every sync
+	eventWatcher                 *EventWatcher         // nil means we only sync on schedule
+	gasEstimator                 *GasEstimator         // nil disables falling back to chunked updates
+	maxOperatorsPerTx            int                   // chunk size when an entire operator set update doesn't fit in a block, 0 disables chunking
+	gasPriceDeferConfig          GasPriceDeferConfig   // syncs wait for the gas price to come down, needs gasEstimator
+	gasBudget                    GasBudget             // caps the cost of the stake updates over a rolling period
+	gasSpends                    []GasSpend            // costs of the transactions of the current budget period, oldest first
+	lowBalanceThreshold          *big.Int              // nil means no threshold
+	dryRun                       bool                  // print what syncs would do instead of sending transactions
+	healthConfig                 HealthConfig
+	readinessChecks              []namedHealthCheck
+	stateStore                   *StateStore    // nil means nothing is persisted across restarts
+	leaderElector                *LeaderElector // nil means this replica runs every sync
 
 	readerTimeoutDuration time.Duration
 	writerTimeoutDuration time.Duration
+	shutdownGracePeriod   time.Duration
 	prometheusServerAddr  string
 	Metrics               *Metrics
+
+	syncRequests  chan syncRequest
+	configReloads chan struct{}
+	paused        atomic.Bool
+
+	// protects the fields below, as well as the config and the fields derived from it, which are read by Status
+	// and the health checks, and replaced by config reloads
+	statusMu       sync.Mutex
+	pendingConfig  *Config // passed to Reload, applied by the loop before the next sync
+	syncing        bool
+	syncFailed     bool // some update of the current (or last) sync failed
+	syncStartTime  time.Time
+	lastSyncTime   time.Time
+	nextSyncTime   time.Time
+	loopStartTime  time.Time
+	activeSchedule Schedule
+	quorumSyncs    map[byte]QuorumSyncStatus
+	operatorSets   []OperatorSetStatus
 }
 
-// NewAvsSync creates a new AvsSync object
+// NewAvsSync creates a new AvsSync object from config, which should have been validated (see Config.Validate).
 //
-//	operators - list of operators to update. If empty, update all operators (must give a list of quorums, or set fetchQuorumsDynamically to true)
-//	quorums - list of quorums to update (only needed if operators is not empty)
-//	fetchQuorumsDynamically - if true, fetch the list of quorums registered in the contract and update all of them (only needed if operators is not empty)
+//	avsReader, avsWriter - the eigensdk avsregistry clients, or the in-memory fake of the avssynctest package in tests
+//	  avsWriter can be nil in dry run mode, where syncs only print what they would do (computed with eth_call/eth_estimateGas)
+//	eventWatcher - if not nil, also sync the quorums affected by stake changing events in between scheduled syncs
+//	gasEstimator - if not nil, quorums whose entire operator set update doesn't fit in a block are updated max-operators-per-tx operators at a time
 func NewAvsSync(
 	logger sdklogging.Logger,
-	avsReader *avsregistry.ChainReader, avsWriter *avsregistry.ChainWriter,
-	sleepBeforeFirstSyncDuration time.Duration, syncInterval time.Duration, operators []common.Address,
-	quorums []byte, fetchQuorumsDynamically bool, retrySyncNTimes int,
-	readerTimeoutDuration time.Duration, writerTimeoutDuration time.Duration,
-	prometheusServerAddr string,
+	config Config,
+	avsReader AvsReader, avsWriter AvsWriter,
+	eventWatcher *EventWatcher, gasEstimator *GasEstimator,
 	prometheusRegistry *prometheus.Registry,
-) *AvsSync {
-	metrics := NewMetrics(prometheusRegistry)
+) (*AvsSync, error) {
+	schedule, sleepBeforeFirstSyncDuration, err := config.schedule(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if schedule != nil && config.FirstSyncTime != "" {
+		logger.Warn("Both schedule and first-sync-time are set, ignoring first-sync-time")
+	}
+	stakeDriftThresholds, err := config.stakeDriftThresholds()
+	if err != nil {
+		return nil, err
+	}
+	var quorums []byte
+	for _, quorum := range config.Quorums {
+		quorums = append(quorums, byte(quorum))
+	}
+
+	metrics := NewMetrics(prometheusRegistry, config.AvsName)
+	metrics.SenderLowBalanceThresholdSet(ethToWei(config.LowBalanceThresholdEth))
 
 	return &AvsSync{
 		AvsReader:                    avsReader,
 		AvsWriter:                    avsWriter,
-		RetrySyncNTimes:              retrySyncNTimes,
+		RetrySyncNTimes:              config.RetrySyncNTimes,
+		config:                       config,
 		logger:                       logger,
 		sleepBeforeFirstSyncDuration: sleepBeforeFirstSyncDuration,
-		syncInterval:                 syncInterval,
-		operators:                    operators,
+		syncInterval:                 config.SyncInterval,
+		schedule:                     schedule,
+		operators:                    config.Operators,
 		quorums:                      quorums,
-		fetchQuorumsDynamically:      fetchQuorumsDynamically,
-		readerTimeoutDuration:        readerTimeoutDuration,
-		writerTimeoutDuration:        writerTimeoutDuration,
-		prometheusServerAddr:         prometheusServerAddr,
-		Metrics:                      metrics,
-	}
+		fetchQuorumsDynamically:      config.FetchQuorumsDynamically,
+		retryPolicy:                  config.retryPolicy(),
+		stakeDriftThresholds:         stakeDriftThresholds,
+		eventWatcher:                 eventWatcher,
+		gasEstimator:                 gasEstimator,
+		maxOperatorsPerTx:            config.MaxOperatorsPerTx,
+		gasPriceDeferConfig:          config.gasPriceDeferConfig(),
+		gasBudget:                    config.gasBudget(),
+		lowBalanceThreshold:          ethToWei(config.LowBalanceThresholdEth),
+		dryRun:                       config.DryRun,
+		healthConfig: HealthConfig{
+			StuckThreshold: config.HealthStuckThreshold,
+			MaxMissedSyncs: config.ReadinessMaxMissedSyncs,
+		},
+		readerTimeoutDuration: config.ReaderTimeout,
+		writerTimeoutDuration: config.WriterTimeout,
+		shutdownGracePeriod:   config.ShutdownGracePeriod,
+		prometheusServerAddr:  config.MetricsAddr,
+		Metrics:               metrics,
+		syncRequests:          make(chan syncRequest, 1),
+		configReloads:         make(chan struct{}, 1),
+		quorumSyncs:           make(map[byte]QuorumSyncStatus),
+	}, nil
 }
 
-func (a *AvsSync) Start(ctx context.Context) {
-	// TODO: should prob put all of these in a config struct, to make sure we don't forget to print any of them
-	//       when we add new ones.
-	a.logger.Info("Avssync config",
-		"sleepBeforeFirstSyncDuration", a.sleepBeforeFirstSyncDuration,
-		"syncInterval", a.syncInterval,
-		"operators", a.operators,
-		"quorums", a.quorums,
-		"fetchQuorumsDynamically", a.fetchQuorumsDynamically,
-		"readerTimeoutDuration", a.readerTimeoutDuration,
-		"writerTimeoutDuration", a.writerTimeoutDuration,
-		"prometheusServerAddr", a.prometheusServerAddr,
-	)
+// Config returns the current config, including the reloads already applied
+func (a *AvsSync) Config() Config {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	return a.config
+}
+
+// Start runs syncs on schedule (and on events and requests) until ctx is done or no more syncs are scheduled.
+// It returns an error if the last sync failed, so that the process can exit with a status reflecting it.
+func (a *AvsSync) Start(ctx context.Context) error {
+	a.logger.Info("Avssync config", "config", a.Config().Redacted())
+
+	now := time.Now()
+	schedule := a.schedule
+	if schedule == nil {
+		// we first sleep some amount of time before the first sync, which allows the syncs to happen at some preferred time
+		// for eg midnight every night, without needing to schedule the start of avssync outside of this program
+		schedule = NewIntervalSchedule(now.Add(a.sleepBeforeFirstSyncDuration), a.syncInterval)
+	}
+	nextSyncTime := schedule.Next(now)
+	if overdue, lastSuccess := a.syncOverdue(now); overdue {
+		// e.g. avssync was down when the last sync was scheduled, so we don't wait for the next one
+		a.logger.Info("A scheduled sync was missed since the last successful sync, syncing now", "lastSuccessTime", lastSuccess)
+		nextSyncTime = now
+	}
+	a.statusMu.Lock()
+	a.loopStartTime = now
+	a.activeSchedule = schedule
+	a.statusMu.Unlock()
 
 	if a.prometheusServerAddr != "" {
-		a.Metrics.Start(a.prometheusServerAddr)
+		go a.startMetricsServer(ctx)
 	} else {
 		a.logger.Info("Prometheus server address not set, not starting metrics server")
 	}
 
-	// ticker doesn't tick immediately, so we send a first updateStakes here
-	// see https://github.com/golang/go/issues/17601
-	// we first sleep some amount of time before the first sync, which allows the syncs to happen at some preferred time
-	// for eg midnight every night, without needing to schedule the start of avssync outside of this program
-	time.Sleep(a.sleepBeforeFirstSyncDuration)
-	a.updateStakes()
-
-	if a.syncInterval == 0 {
-		a.logger.Infof("Sync interval is 0, running updateStakes once and exiting")
-		return // only run once
+	if a.leaderElector != nil {
+		// campaign before the first sync, so that a replica starting alone doesn't skip it
+		a.leaderElector.tryAcquireOrRenew(ctx)
+		go a.leaderElector.Run(ctx)
 	}
 
-	// update stakes every syncInterval
-	ticker := time.NewTicker(a.syncInterval)
-	defer ticker.Stop()
+	// the schedule stays in place as a safety net when syncing on events, in case some events are missed
+	var eventTriggered <-chan struct{}
+	if a.eventWatcher != nil {
+		watcherCtx, stopWatcher := context.WithCancel(ctx)
+		watcherDone := make(chan struct{})
+		go func() {
+			a.eventWatcher.Start(watcherCtx)
+			close(watcherDone)
+		}()
+		// the watcher persists the last synced event block, so it must be stopped before the state store is closed
+		defer func() {
+			stopWatcher()
+			<-watcherDone
+		}()
+		eventTriggered = a.eventWatcher.Triggered()
+	}
 
+	// the next sync time is always recomputed from the schedule after a sync completes (instead of using a ticker),
+	// so that a long sync doesn't delay every subsequent one
 	for {
+		if nextSyncTime.IsZero() {
+			a.logger.Info("No more syncs scheduled, exiting")
+			return a.lastSyncError()
+		}
+		a.setNextSyncTime(nextSyncTime)
+		sleepDuration := time.Until(nextSyncTime)
+		a.logger.Infof("Sleeping for %v, next sync scheduled at %v", sleepDuration, nextSyncTime)
+
+		// scheduled, event triggered and requested syncs all run from this loop, so that they never run concurrently
+		timer := time.NewTimer(sleepDuration)
 		select {
 		case <-ctx.Done():
+			timer.Stop()
 			a.logger.Info("Context done, exiting")
-			return
-		case <-ticker.C:
-			a.updateStakes()
-			a.logger.Infof("Sleeping for %s", a.syncInterval)
+			return a.lastSyncError()
+		case <-timer.C:
+			if a.paused.Load() {
+				a.logger.Info("Syncs paused, skipping scheduled sync")
+				break
+			}
+			a.sync(ctx, syncRequest{})
+		case <-eventTriggered:
+			timer.Stop()
+			if a.paused.Load() {
+				// the quorums are left in the event watcher, which triggers again on resume
+				a.logger.Info("Syncs paused, not syncing quorums affected by stake changing events")
+				break
+			}
+			if !a.isLeader() {
+				// the leader syncs them, they are left in the event watcher which triggers again if this replica becomes the leader
+				a.logger.Info("Not the leader, not syncing quorums affected by stake changing events")
+				break
+			}
+			quorums, upToBlock := a.eventWatcher.TakeTriggeredQuorums()
+			if quorums == nil {
+				a.logger.Info("Syncing all quorums after stake changing events", "upToBlock", upToBlock)
+			} else {
+				a.logger.Info("Syncing quorums affected by stake changing events", "quorums", convertQuorumsBytesToInts(quorums), "upToBlock", upToBlock)
+			}
+			if a.sync(ctx, syncRequest{quorums: quorums}) {
+				a.eventWatcher.MarkSynced(upToBlock)
+				break
+			}
+			if ctx.Err() != nil {
+				// the sync was interrupted, so the events need to be processed again after a restart
+				break
+			}
+			// e.g. the sync was deferred or some update failed, the quorums are synced again like on resume
+			a.logger.Warn("Sync of quorums affected by stake changing events didn't complete, retrying", "upToBlock", upToBlock)
+			a.eventWatcher.Requeue(quorums, upToBlock)
+		case req := <-a.syncRequests:
+			timer.Stop()
+			if a.paused.Load() {
+				a.logger.Info("Syncs paused, dropping requested sync")
+				break
+			}
+			a.logger.Info("Running requested sync", "quorums", convertQuorumsBytesToInts(req.quorums), "operators", req.operators)
+			a.sync(ctx, req)
+		case <-a.configReloads:
+			timer.Stop()
+			a.applyPendingConfig()
 		}
+		nextSyncTime = schedule.Next(time.Now())
+	}
+}
+
+// Close closes the state store, if any. It must be called after Start returns.
+func (a *AvsSync) Close() error {
+	if a.stateStore == nil {
+		return nil
 	}
+	return a.stateStore.Close()
 }
 
-func (a *AvsSync) updateStakes() {
-	if len(a.operators) == 0 {
-		a.logger.Info("Updating stakes of entire operator set")
-		a.maybeUpdateQuorumSet()
-		a.logger.Infof("Current quorum set: %v", convertQuorumsBytesToInts(a.quorums))
+// startMetricsServer serves /metrics, as well as the /healthz and /readyz probes
+func (a *AvsSync) startMetricsServer(ctx context.Context) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", a.Metrics.Handler())
+	mux.Handle("/healthz", a.healthHandler(a.livenessChecks))
+	mux.Handle("/readyz", a.healthHandler(a.allReadinessChecks))
+	server := &http.Server{Addr: a.prometheusServerAddr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+	a.logger.Info("Starting metrics server", "addr", a.prometheusServerAddr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		a.logger.Error("Metrics server failed, /metrics, /healthz and /readyz are unavailable", "err", err, "addr", a.prometheusServerAddr)
+	}
+}
 
-		// we update one quorum at a time, just to make sure we don't run into any gas limit issues
-		// in case there are a lot of operators in a given quorum
-		for _, quorum := range a.quorums {
-			a.tryNTimesUpdateStakesOfEntireOperatorSetForQuorum(quorum, a.RetrySyncNTimes)
-		}
-		a.logger.Info("Completed stake update. Check logs to make sure every quorum update succeeded successfully.")
+// sync runs the sync of req, and returns whether it ran to completion with every update succeeding
+func (a *AvsSync) sync(ctx context.Context, req syncRequest) bool {
+	// a reload accepted while the previous sync was running applies to this one
+	a.applyPendingConfig()
+	if !a.isLeader() {
+		// the leader runs the same syncs, followers only take over if it goes away
+		a.logger.Info("Not the leader, skipping sync")
+		return false
+	}
+	a.setSyncing(true)
+	defer a.setSyncing(false)
+	if !a.dryRun && !a.waitForGasPrice(ctx) {
+		a.syncNotRun(req, UpdateStakeStatusDeferred)
+		return false
+	}
+	if !a.dryRun && len(req.operators) == 0 && len(a.operators) == 0 {
+		// before checking the balance, so that the cost of the quorums created since the last sync is estimated too
+		a.maybeUpdateQuorumSet(ctx)
+	}
+	if !a.dryRun && !a.senderCanAfford(ctx, req) {
+		a.syncNotRun(req, UpdateStakeStatusInsufficientFunds)
+		return false
+	}
+	if len(req.operators) > 0 {
+		a.updateStakesOfOperatorSubset(ctx, req.operators)
 	} else {
-		a.logger.Infof("Updating stakes of operators: %v", a.operators)
-		timeoutCtx, cancel := context.WithTimeout(context.Background(), a.writerTimeoutDuration)
-		defer cancel()
-		// this one we update all quorums at once, since we're only updating a subset of operators (which should be a small number)
-		receipt, err := a.AvsWriter.UpdateStakesOfOperatorSubsetForAllQuorums(timeoutCtx, a.operators, true)
-		if err != nil {
-			// no quorum label means we are updating all quorums
-			for _, quorum := range a.quorums {
-				a.Metrics.UpdateStakeAttemptInc(UpdateStakeStatusError, strconv.Itoa(int(quorum)))
-			}
-			a.logger.Error("Error updating stakes of operator subset for all quorums", err)
-			return
-		} else if receipt.Status == gethtypes.ReceiptStatusFailed {
-			a.Metrics.TxRevertedTotalInc()
-			a.logger.Error("Update stakes of operator subset for all quorums reverted")
+		a.updateStakes(ctx, req.quorums)
+	}
+	// failed updates, including those refused by the gas budget, mark the sync as failed
+	return ctx.Err() == nil && a.lastSyncError() == nil
+}
+
+// updateStakes updates the stakes of the configured operators, or of the entire operator set of every quorum of the
+// quorum set refreshed by sync. onlyQuorums restricts the entire operator set update to these quorums, nil means every quorum.
+func (a *AvsSync) updateStakes(ctx context.Context, onlyQuorums []byte) {
+	if len(a.operators) > 0 {
+		if a.operatorSetReader != nil {
+			// only to report the operator sets, the update of the operators covers all their quorums
+			a.updateOperatorSets(ctx)
+		}
+		a.updateStakesOfOperatorSubset(ctx, a.operators)
+		return
+	}
+	if a.dryRun {
+		a.dryRunSync(ctx, onlyQuorums, nil)
+		return
+	}
+	a.logger.Info("Updating stakes of entire operator set")
+	a.logger.Infof("Current quorum set: %v", convertQuorumsBytesToInts(a.quorums))
+
+	// we update one quorum at a time, just to make sure we don't run into any gas limit issues
+	// in case there are a lot of operators in a given quorum (quorums that still don't fit in a block are updated in chunks)
+	for _, quorum := range a.quorums {
+		if onlyQuorums != nil && !slices.Contains(onlyQuorums, quorum) {
+			continue
+		}
+		if ctx.Err() != nil {
+			a.logger.Warn("Shutting down, not updating remaining quorums", "quorum", int(quorum))
+			a.markSyncFailed()
 			return
 		}
-		a.logger.Info("Completed stake update successfully")
+		if !a.shouldUpdateQuorum(ctx, quorum) {
+			a.logger.Info("Stake drift of quorum below thresholds, skipping update", "quorum", int(quorum))
+			continue
+		}
+		a.updateStakesOfEntireOperatorSetForQuorum(ctx, quorum)
+	}
+	a.logger.Info("Completed stake update. Check logs to make sure every quorum update succeeded successfully.")
+}
+
+// updateStakesOfOperatorSubset updates the stakes of the given operators in all the quorums they are registered in
+func (a *AvsSync) updateStakesOfOperatorSubset(ctx context.Context, operators []common.Address) {
+	if a.dryRun {
+		a.dryRunSync(ctx, nil, operators)
+		return
+	}
+	a.logger.Infof("Updating stakes of operators: %v", operators)
+	receipt, err := a.updateStakesOfOperators(ctx, "", operators)
+	if err != nil {
+		// no quorum label means we are updating all quorums
+		status := failedUpdateStatus(err)
+		for _, quorum := range a.quorums {
+			a.updateStakeAttemptDone(quorum, status)
+		}
+		a.markSyncFailed()
+		a.logger.Error("Error updating stakes of operator subset for all quorums", "err", err, "status", status)
 		return
 	}
+	for _, quorum := range a.quorums {
+		a.updateStakeTxLanded(quorum, receipt)
+		a.updateStakeAttemptDone(quorum, UpdateStakeStatusSucceed)
+	}
+	a.logger.Info("Completed stake update successfully")
+}
+
+// updateStakesOfOperators updates the stakes of operators in all the quorums they are registered in,
+// retrying failed attempts according to the retry policy. The cost of the transactions is accounted to quorum,
+// which is empty when updating all quorums.
+func (a *AvsSync) updateStakesOfOperators(ctx context.Context, quorum string, operators []common.Address) (*gethtypes.Receipt, error) {
+	var receipt *gethtypes.Receipt
+	err := a.retry(ctx, func(ctx context.Context) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := a.checkGasBudget(); err != nil {
+			return err
+		}
+		writeCtx, cancel := a.writerContext(ctx)
+		defer cancel()
+		var err error
+		receipt, err = a.AvsWriter.UpdateStakesOfOperatorSubsetForAllQuorums(writeCtx, operators, true)
+		if err != nil {
+			return fmt.Errorf("cannot update stakes of operator subset: %w", err)
+		}
+		a.recordGasSpend(receipt, quorum, CallTypeOperatorSubset)
+		if receipt.Status == gethtypes.ReceiptStatusFailed {
+			reason := a.revertReason(ctx, receipt)
+			a.Metrics.TxRevertedTotalInc(a.revertLabel(reason))
+			a.logger.Error("Update stakes of operator subset for all quorums reverted", "txHash", receipt.TxHash.Hex(), "reason", reason)
+			return revertedUpdateError(receipt, reason)
+		}
+		return nil
+	}, "operators", len(operators))
+	return receipt, err
 }
 
-func (a *AvsSync) maybeUpdateQuorumSet() {
+func (a *AvsSync) maybeUpdateQuorumSet(ctx context.Context) {
+	if a.operatorSetReader != nil {
+		a.updateOperatorSets(ctx)
+		return
+	}
 	if !a.fetchQuorumsDynamically {
 		return
 	}
 	a.logger.Info("Fetching quorum set dynamically")
-	timeoutCtx, cancel := context.WithTimeout(context.Background(), a.readerTimeoutDuration)
+	timeoutCtx, cancel := context.WithTimeout(ctx, a.readerTimeoutDuration)
 	defer cancel()
 	quorumCount, err := a.AvsReader.GetQuorumCount(&bind.CallOpts{Context: timeoutCtx})
 	if err != nil {
@@ -167,51 +447,104 @@ func (a *AvsSync) maybeUpdateQuorumSet() {
 	for i := 0; i < int(quorumCount); i++ {
 		quorums = append(quorums, byte(i))
 	}
-	a.quorums = quorums
+	a.setQuorums(quorums)
 }
 
-func (a *AvsSync) tryNTimesUpdateStakesOfEntireOperatorSetForQuorum(quorum byte, retryNTimes int) {
-	for i := 0; i < retryNTimes; i++ {
-		a.logger.Debug("tryNTimesUpdateStakesOfEntireOperatorSetForQuorum", "quorum", int(quorum), "retryNTimes", retryNTimes, "try", i+1)
-
-		timeoutCtx, cancel := context.WithTimeout(context.Background(), a.readerTimeoutDuration)
-		defer cancel()
+// updateStakesOfEntireOperatorSetForQuorum updates the stakes of every operator of quorum, retrying failed attempts
+// according to the retry policy
+func (a *AvsSync) updateStakesOfEntireOperatorSetForQuorum(ctx context.Context, quorum byte) {
+	var receipt *gethtypes.Receipt
+	var operators []common.Address
+	chunked := false
+	err := a.retry(ctx, func(ctx context.Context) error {
+		timeoutCtx, cancel := context.WithTimeout(ctx, a.readerTimeoutDuration)
 		// we need to refetch the operator set because one reason for update stakes failing is that the operator set has changed
 		// in between us fetching it and trying to update it (the contract makes sure the entire operator set is updated and reverts if not)
 		operatorAddrsPerQuorum, err := a.AvsReader.GetOperatorAddrsInQuorumsAtCurrentBlock(&bind.CallOpts{Context: timeoutCtx}, types.QuorumNums{types.QuorumNum(quorum)})
+		cancel()
 		if err != nil {
-			a.logger.Warn("Error fetching operator addresses in quorums", "err", err, "quorum", quorum, "retryNTimes", retryNTimes, "try", i+1)
-			continue
+			return fmt.Errorf("cannot fetch operator addresses in quorum: %w", err)
 		}
-		var operators []common.Address
-		operators = append(operators, operatorAddrsPerQuorum[0]...)
+		operators = append([]common.Address(nil), operatorAddrsPerQuorum[0]...)
 		sort.Slice(operators, func(i, j int) bool {
 			return operators[i].Big().Cmp(operators[j].Big()) < 0
 		})
+		if a.exceedsGasLimit(ctx, quorum, operators) {
+			a.updateStakesOfQuorumInChunks(ctx, quorum, operators)
+			chunked = true
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := a.checkGasBudget(); err != nil {
+			return err
+		}
 		a.logger.Infof("Updating stakes of operators in quorum %d: %v", int(quorum), operators)
-		timeoutCtx, cancel = context.WithTimeout(context.Background(), a.writerTimeoutDuration)
-		defer cancel()
-		receipt, err := a.AvsWriter.UpdateStakesOfEntireOperatorSetForQuorums(timeoutCtx, [][]common.Address{operators}, types.QuorumNums{types.QuorumNum(quorum)}, true)
+		writeCtx, cancel := a.writerContext(ctx)
+		receipt, err = a.AvsWriter.UpdateStakesOfEntireOperatorSetForQuorums(writeCtx, [][]common.Address{operators}, types.QuorumNums{types.QuorumNum(quorum)}, true)
+		cancel()
 		if err != nil {
-			a.logger.Warn("Error updating stakes of entire operator set for quorum", "err", err, "quorum", int(quorum), "retryNTimes", retryNTimes, "try", i+1)
-			continue
+			return fmt.Errorf("cannot update stakes of entire operator set for quorum: %w", err)
 		}
+		a.recordGasSpend(receipt, strconv.Itoa(int(quorum)), CallTypeEntireOperatorSet)
 		if receipt.Status == gethtypes.ReceiptStatusFailed {
-			a.Metrics.TxRevertedTotalInc()
-			a.logger.Error("Update stakes of entire operator set for quorum reverted", "quorum", int(quorum))
-			continue
+			reason := a.revertReason(ctx, receipt)
+			a.Metrics.TxRevertedTotalInc(a.revertLabel(reason))
+			a.logger.Error("Update stakes of entire operator set for quorum reverted", "quorum", int(quorum), "txHash", receipt.TxHash.Hex(), "reason", reason)
+			if reason == RevertReasonOutOfGas && a.chunkingEnabled() {
+				a.logger.Warn("Update stakes of entire operator set ran out of gas, falling back to chunked updates", "quorum", int(quorum), "txHash", receipt.TxHash.Hex())
+				a.updateStakesOfQuorumInChunks(ctx, quorum, operators)
+				chunked = true
+				return nil
+			}
+			if reason == RevertReasonUnknown {
+				return errStaleOperatorSet
+			}
+			return revertedUpdateError(receipt, reason)
 		}
-
+		return nil
+	}, "quorum", int(quorum))
+	if chunked {
+		// the chunked update records its own outcome
+		return
+	}
+	if err == nil {
 		// Update metrics on success
-		a.Metrics.UpdateStakeAttemptInc(UpdateStakeStatusSucceed, strconv.Itoa(int(quorum)))
+		a.updateStakeTxLanded(quorum, receipt)
+		a.updateStakeAttemptDone(quorum, UpdateStakeStatusSucceed)
 		a.Metrics.OperatorsUpdatedSet(strconv.Itoa(int(quorum)), len(operators))
-
 		return
 	}
 
 	// Update metrics on failure
-	a.Metrics.UpdateStakeAttemptInc(UpdateStakeStatusError, strconv.Itoa(int(quorum)))
-	a.logger.Error("Giving up after retrying", "retryNTimes", retryNTimes)
+	status := failedUpdateStatus(err)
+	a.updateStakeAttemptDone(quorum, status)
+	switch {
+	case ctx.Err() != nil:
+		a.logger.Error("Giving up on updating quorum, shutting down", "quorum", int(quorum))
+	case status == UpdateStakeStatusFatal:
+		a.logger.Error("Giving up on updating quorum, fatal error", "quorum", int(quorum), "err", err)
+	default:
+		a.logger.Error("Giving up after retrying", "quorum", int(quorum), "maxAttempts", a.retryPolicy.MaxAttempts, "err", err)
+	}
+}
+
+// writerContext returns the context of a transaction, which waits for its receipt for at most writerTimeoutDuration.
+// When ctx is done (i.e. on shutdown), the transaction keeps waiting for its receipt for at most shutdownGracePeriod,
+// so that we know whether it landed.
+func (a *AvsSync) writerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	graceCtx, cancelGrace := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		a.logger.Info("Shutting down, waiting for in-flight transaction", "gracePeriod", a.shutdownGracePeriod)
+		time.AfterFunc(a.shutdownGracePeriod, cancelGrace)
+	})
+	timeoutCtx, cancelTimeout := context.WithTimeout(graceCtx, a.writerTimeoutDuration)
+	return timeoutCtx, func() {
+		stop()
+		cancelTimeout()
+		cancelGrace()
+	}
 }
 
 func convertQuorumsBytesToInts(quorums []byte) []int {
@@ -0,0 +1,43 @@
+package avssync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterContextOutlivesShutdownByGracePeriod(t *testing.T) {
+	avsSync := newTestAvsSync([]byte{0}, nil)
+	avsSync.writerTimeoutDuration = time.Minute
+	avsSync.shutdownGracePeriod = 50 * time.Millisecond
+
+	ctx, shutdown := context.WithCancel(context.Background())
+	writeCtx, cancel := avsSync.writerContext(ctx)
+	defer cancel()
+
+	shutdown()
+	require.NoError(t, writeCtx.Err(), "an in-flight transaction keeps waiting for its receipt after shutdown")
+	select {
+	case <-writeCtx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("writer context not done after the grace period")
+	}
+}
+
+func TestLastSyncError(t *testing.T) {
+	avsSync := newTestAvsSync([]byte{0, 1}, nil)
+	require.NoError(t, avsSync.lastSyncError())
+
+	avsSync.setSyncing(true)
+	avsSync.updateStakeAttemptDone(0, UpdateStakeStatusSucceed)
+	avsSync.updateStakeAttemptDone(1, UpdateStakeStatusError)
+	avsSync.setSyncing(false)
+	require.ErrorIs(t, avsSync.lastSyncError(), ErrLastSyncFailed)
+
+	avsSync.setSyncing(true)
+	avsSync.updateStakeAttemptDone(1, UpdateStakeStatusSkipped)
+	avsSync.setSyncing(false)
+	require.NoError(t, avsSync.lastSyncError())
+}
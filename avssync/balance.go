@@ -0,0 +1,110 @@
+package avssync
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"slices"
+	"sort"
+
+	"github.com/Layr-Labs/eigensdk-go/types"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SenderBalance returns the balance of the address sending the transactions, which is the vault account address
+// for Fireblocks wallets
+func (g *GasEstimator) SenderBalance(ctx context.Context) (*big.Int, error) {
+	return g.client.BalanceAt(ctx, g.sender, nil)
+}
+
+// senderCanAfford exports the balance of the sender, and returns false if it doesn't cover the estimated cost of the
+// stake updates of req, in which case sending them would only fail with insufficient funds.
+// If the balance or the cost can't be fetched, the sync proceeds.
+func (a *AvsSync) senderCanAfford(ctx context.Context, req syncRequest) bool {
+	if a.gasEstimator == nil {
+		return true
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, a.readerTimeoutDuration)
+	balance, err := a.gasEstimator.SenderBalance(timeoutCtx)
+	cancel()
+	if err != nil {
+		a.logger.Warn("Error fetching sender balance, not checking it", "sender", a.gasEstimator.sender.Hex(), "err", err)
+		return true
+	}
+	a.Metrics.SenderBalanceSet(balance)
+	if a.lowBalanceThreshold != nil && balance.Cmp(a.lowBalanceThreshold) < 0 {
+		a.logger.Warn("Sender balance below low balance threshold, top it up",
+			"sender", a.gasEstimator.sender.Hex(), "balanceEth", weiToEth(balance), "lowBalanceThresholdEth", weiToEth(a.lowBalanceThreshold))
+	}
+
+	timeoutCtx, cancel = context.WithTimeout(ctx, a.readerTimeoutDuration)
+	cost, complete, err := a.estimateSyncCost(timeoutCtx, req)
+	cancel()
+	if err != nil {
+		a.logger.Warn("Error estimating cost of sync, not checking sender balance", "err", err)
+		return true
+	}
+	if balance.Cmp(cost) < 0 {
+		a.logger.Error("Sender balance doesn't cover the estimated cost of the sync, skipping it",
+			"sender", a.gasEstimator.sender.Hex(), "balanceEth", weiToEth(balance), "estimatedCostEth", weiToEth(cost), "lowerBound", !complete)
+		return false
+	}
+	a.logger.Debug("Sender balance covers the estimated cost of the sync",
+		"balanceEth", weiToEth(balance), "estimatedCostEth", weiToEth(cost), "lowerBound", !complete)
+	return true
+}
+
+// estimateSyncCost estimates the cost of the transactions the sync of req would send, in wei, from their gas estimates only:
+// contrary to Plan, the stake drift isn't computed (so quorums below the drift thresholds are included) and the quorum set
+// isn't refetched (the sync refreshes it before checking the balance). It is a lower bound when the gas of some update is
+// unknown (e.g. it would be chunked).
+func (a *AvsSync) estimateSyncCost(ctx context.Context, req syncRequest) (cost *big.Int, complete bool, err error) {
+	gasPrice, err := a.gasEstimator.GasPrice(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	operators := req.operators
+	if len(operators) == 0 {
+		operators = a.operators
+	}
+	if len(operators) > 0 {
+		gas, err := a.gasEstimator.EstimateUpdateOperators(ctx, operators)
+		if err != nil {
+			return nil, false, fmt.Errorf("cannot estimate gas of operator subset update: %w", err)
+		}
+		return new(big.Int).Mul(new(big.Int).SetUint64(gas), gasPrice), true, nil
+	}
+
+	var quorums types.QuorumNums
+	for _, quorum := range a.quorums {
+		if req.quorums == nil || slices.Contains(req.quorums, quorum) {
+			quorums = append(quorums, types.QuorumNum(quorum))
+		}
+	}
+	if len(quorums) == 0 {
+		return new(big.Int), true, nil
+	}
+	operatorsPerQuorum, err := a.AvsReader.GetOperatorAddrsInQuorumsAtCurrentBlock(&bind.CallOpts{Context: ctx}, quorums)
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot fetch operator addresses in quorums: %w", err)
+	}
+	gas := new(big.Int)
+	complete = true
+	for i, quorum := range quorums {
+		operators := append([]common.Address(nil), operatorsPerQuorum[i]...)
+		sort.Slice(operators, func(i, j int) bool {
+			return operators[i].Big().Cmp(operators[j].Big()) < 0
+		})
+		quorumGas, err := a.gasEstimator.EstimateUpdateOperatorsForQuorum(ctx, operators, byte(quorum))
+		if err != nil {
+			if !isGasLimitError(err) {
+				return nil, false, fmt.Errorf("cannot estimate gas of entire operator set update of quorum %d: %w", quorum, err)
+			}
+			complete = false
+			continue
+		}
+		gas.Add(gas, new(big.Int).SetUint64(quorumGas))
+	}
+	return gas.Mul(gas, gasPrice), complete, nil
+}
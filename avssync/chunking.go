@@ -0,0 +1,133 @@
+package avssync
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ChunkResult is the outcome of updating the stakes of one chunk of a quorum's operator set
+type ChunkResult struct {
+	Index     int
+	Operators []common.Address
+	TxHash    common.Hash
+	Err       error
+}
+
+func (r ChunkResult) Succeeded() bool {
+	return r.Err == nil
+}
+
+// chunkOperators splits operators in chunks of at most chunkSize operators
+func chunkOperators(operators []common.Address, chunkSize int) [][]common.Address {
+	var chunks [][]common.Address
+	for start := 0; start < len(operators); start += chunkSize {
+		end := min(start+chunkSize, len(operators))
+		chunks = append(chunks, operators[start:end])
+	}
+	return chunks
+}
+
+// exceedsGasLimit returns whether updating the entire operator set of the quorum in a single transaction
+// would not fit in a block, in which case the quorum should be updated in chunks instead.
+// If chunking is disabled or the estimation fails for another reason (e.g. the operator set changed), it returns false
+// so that the normal path (and its retries) handles it.
+func (a *AvsSync) exceedsGasLimit(ctx context.Context, quorum byte, operators []common.Address) bool {
+	if !a.chunkingEnabled() || len(operators) <= a.maxOperatorsPerTx {
+		return false
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, a.readerTimeoutDuration)
+	defer cancel()
+	gasEstimate, err := a.gasEstimator.EstimateUpdateOperatorsForQuorum(timeoutCtx, operators, quorum)
+	if err != nil {
+		if isGasLimitError(err) {
+			a.logger.Warn("Updating entire operator set of quorum doesn't fit in a block", "quorum", int(quorum), "operators", len(operators), "err", err)
+			return true
+		}
+		a.logger.Warn("Error estimating gas of entire operator set update", "quorum", int(quorum), "err", err)
+		return false
+	}
+	fits, blockGasLimit, err := a.gasEstimator.FitsInBlock(timeoutCtx, gasEstimate)
+	if err != nil {
+		a.logger.Warn("Error checking block gas limit", "quorum", int(quorum), "err", err)
+		return false
+	}
+	a.logger.Debug("Estimated gas of entire operator set update", "quorum", int(quorum), "gasEstimate", gasEstimate, "blockGasLimit", blockGasLimit)
+	if !fits {
+		a.logger.Warn("Updating entire operator set of quorum doesn't fit in a block",
+			"quorum", int(quorum), "operators", len(operators), "gasEstimate", gasEstimate, "blockGasLimit", blockGasLimit)
+	}
+	return !fits
+}
+
+func (a *AvsSync) chunkingEnabled() bool {
+	return a.gasEstimator != nil && a.maxOperatorsPerTx > 0
+}
+
+// updateStakesOfQuorumInChunks updates the stakes of the operators of a quorum maxOperatorsPerTx operators at a time,
+// via the operator subset path (which updates each operator in all the quorums it is registered in).
+// Note that contrary to the entire operator set path, this doesn't bump the quorum's last update block number in the RegistryCoordinator.
+// Once ctx is done, the remaining chunks are not sent.
+func (a *AvsSync) updateStakesOfQuorumInChunks(ctx context.Context, quorum byte, operators []common.Address) []ChunkResult {
+	quorumStr := strconv.Itoa(int(quorum))
+	chunks := chunkOperators(operators, a.maxOperatorsPerTx)
+	a.logger.Info("Updating stakes of quorum in chunks", "quorum", int(quorum), "operators", len(operators), "chunks", len(chunks), "maxOperatorsPerTx", a.maxOperatorsPerTx)
+
+	results := make([]ChunkResult, 0, len(chunks))
+	updatedOperators := 0
+	// a fatal error (e.g. insufficient funds) would fail the remaining chunks too, so they are not sent
+	var fatalErr error
+	for i, chunk := range chunks {
+		result := ChunkResult{Index: i, Operators: chunk}
+		if err := ctx.Err(); err != nil || fatalErr != nil {
+			result.Err = errors.Join(err, fatalErr)
+			a.Metrics.ChunkUpdateAttemptInc(failedUpdateStatus(result.Err), quorumStr)
+			results = append(results, result)
+			continue
+		}
+		receipt, err := a.updateStakesOfOperators(ctx, quorumStr, chunk)
+		if receipt != nil {
+			result.TxHash = receipt.TxHash
+		}
+		result.Err = err
+
+		if result.Succeeded() {
+			a.updateStakeTxLanded(quorum, receipt)
+			updatedOperators += len(chunk)
+			a.Metrics.ChunkUpdateAttemptInc(UpdateStakeStatusSucceed, quorumStr)
+			a.logger.Info("Updated stakes of chunk", "quorum", int(quorum), "chunk", i+1, "chunks", len(chunks), "operators", chunk, "txHash", result.TxHash.Hex())
+		} else {
+			if failedUpdateStatus(result.Err) == UpdateStakeStatusFatal {
+				fatalErr = result.Err
+			}
+			a.Metrics.ChunkUpdateAttemptInc(failedUpdateStatus(result.Err), quorumStr)
+			a.logger.Error("Error updating stakes of chunk", "quorum", int(quorum), "chunk", i+1, "chunks", len(chunks), "operators", chunk, "txHash", result.TxHash.Hex(), "err", result.Err)
+		}
+		results = append(results, result)
+	}
+
+	var failedChunks []int
+	for _, result := range results {
+		if !result.Succeeded() {
+			failedChunks = append(failedChunks, result.Index+1)
+		}
+	}
+	a.logger.Info("Chunked stake update report",
+		"quorum", int(quorum),
+		"succeededChunks", len(chunks)-len(failedChunks),
+		"chunks", len(chunks),
+		"failedChunks", failedChunks,
+		"updatedOperators", updatedOperators,
+	)
+	a.Metrics.OperatorsUpdatedSet(quorumStr, updatedOperators)
+	if len(failedChunks) == 0 {
+		a.updateStakeAttemptDone(quorum, UpdateStakeStatusSucceed)
+	} else if fatalErr != nil {
+		a.updateStakeAttemptDone(quorum, UpdateStakeStatusFatal)
+	} else {
+		a.updateStakeAttemptDone(quorum, UpdateStakeStatusError)
+	}
+	return results
+}
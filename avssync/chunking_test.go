@@ -0,0 +1,25 @@
+package avssync
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkOperators(t *testing.T) {
+	var operators []common.Address
+	for i := 1; i <= 5; i++ {
+		operators = append(operators, common.BigToAddress(big.NewInt(int64(i))))
+	}
+
+	chunks := chunkOperators(operators, 2)
+	require.Len(t, chunks, 3)
+	require.Equal(t, operators[0:2], chunks[0])
+	require.Equal(t, operators[2:4], chunks[1])
+	require.Equal(t, operators[4:5], chunks[2])
+
+	require.Len(t, chunkOperators(operators, 5), 1)
+	require.Empty(t, chunkOperators(nil, 5))
+}
@@ -0,0 +1,509 @@
+package avssync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ethereum/go-ethereum/common"
+	"gopkg.in/yaml.v3"
+)
+
+const redacted = "[redacted]"
+
+// Config is the configuration of AvsSync. Keys of the config file are the names of the corresponding flags.
+type Config struct {
+	RegistryCoordinatorAddr    common.Address `yaml:"registry-coordinator-addr" toml:"registry-coordinator-addr" json:"registry-coordinator-addr"`
+	OperatorStateRetrieverAddr common.Address `yaml:"operator-state-retriever-addr" toml:"operator-state-retriever-addr" json:"operator-state-retriever-addr"`
+	ServiceManagerAddr         common.Address `yaml:"service-manager-addr" toml:"service-manager-addr" json:"service-manager-addr"`
+	DontUseAllocationManager   bool           `yaml:"dont-use-allocation-manager" toml:"dont-use-allocation-manager" json:"dont-use-allocation-manager"`
+	UseOperatorSets            bool           `yaml:"use-operator-sets" toml:"use-operator-sets" json:"use-operator-sets"`
+	AvsName                    string         `yaml:"avs-name" toml:"avs-name" json:"avs-name"`
+
+	EthHttpUrls         []string      `yaml:"eth-http-url" toml:"eth-http-url" json:"eth-http-url"`
+	EthWriteHttpUrls    []string      `yaml:"eth-write-http-url" toml:"eth-write-http-url" json:"eth-write-http-url"`
+	ChainId             uint64        `yaml:"chain-id" toml:"chain-id" json:"chain-id"`
+	RpcEndpointCooldown time.Duration `yaml:"rpc-endpoint-cooldown" toml:"rpc-endpoint-cooldown" json:"rpc-endpoint-cooldown"`
+	RpcAttemptTimeout   time.Duration `yaml:"rpc-attempt-timeout" toml:"rpc-attempt-timeout" json:"rpc-attempt-timeout"`
+
+	SyncInterval            time.Duration    `yaml:"sync-interval" toml:"sync-interval" json:"sync-interval"`
+	FirstSyncTime           string           `yaml:"first-sync-time" toml:"first-sync-time" json:"first-sync-time"`
+	Schedule                []string         `yaml:"schedule" toml:"schedule" json:"schedule"`
+	ScheduleTimezone        string           `yaml:"schedule-timezone" toml:"schedule-timezone" json:"schedule-timezone"`
+	Operators               []common.Address `yaml:"operators" toml:"operators" json:"operators"`
+	Quorums                 []int            `yaml:"quorums" toml:"quorums" json:"quorums"`
+	FetchQuorumsDynamically bool             `yaml:"fetch-quorums-dynamically" toml:"fetch-quorums-dynamically" json:"fetch-quorums-dynamically"`
+	RetrySyncNTimes         int              `yaml:"retry-sync-n-times" toml:"retry-sync-n-times" json:"retry-sync-n-times"`
+	RetryBaseBackoff        time.Duration    `yaml:"retry-base-backoff" toml:"retry-base-backoff" json:"retry-base-backoff"`
+	RetryMaxBackoff         time.Duration    `yaml:"retry-max-backoff" toml:"retry-max-backoff" json:"retry-max-backoff"`
+	RetryJitter             float64          `yaml:"retry-jitter" toml:"retry-jitter" json:"retry-jitter"`
+	RetryDeadline           time.Duration    `yaml:"retry-deadline" toml:"retry-deadline" json:"retry-deadline"`
+
+	OnlyUpdateOnStakeDrift bool    `yaml:"only-update-on-stake-drift" toml:"only-update-on-stake-drift" json:"only-update-on-stake-drift"`
+	StakeDriftThresholdAbs string  `yaml:"stake-drift-threshold-abs" toml:"stake-drift-threshold-abs" json:"stake-drift-threshold-abs"`
+	StakeDriftThresholdPct float64 `yaml:"stake-drift-threshold-pct" toml:"stake-drift-threshold-pct" json:"stake-drift-threshold-pct"`
+
+	EventDrivenSync    bool          `yaml:"event-driven-sync" toml:"event-driven-sync" json:"event-driven-sync"`
+	EventPollInterval  time.Duration `yaml:"event-poll-interval" toml:"event-poll-interval" json:"event-poll-interval"`
+	EventDebounce      time.Duration `yaml:"event-debounce" toml:"event-debounce" json:"event-debounce"`
+	EventConfirmations uint64        `yaml:"event-confirmations" toml:"event-confirmations" json:"event-confirmations"`
+	EventMaxBlockRange uint64        `yaml:"event-max-block-range" toml:"event-max-block-range" json:"event-max-block-range"`
+	EventStartBlock    uint64        `yaml:"event-start-block" toml:"event-start-block" json:"event-start-block"`
+	EventStateFile     string        `yaml:"event-state-file" toml:"event-state-file" json:"event-state-file"`
+
+	MaxOperatorsPerTx int            `yaml:"max-operators-per-tx" toml:"max-operators-per-tx" json:"max-operators-per-tx"`
+	DryRun            bool           `yaml:"dry-run" toml:"dry-run" json:"dry-run"`
+	DryRunSenderAddr  common.Address `yaml:"dry-run-sender-addr" toml:"dry-run-sender-addr" json:"dry-run-sender-addr"`
+
+	MaxFeePerGasGwei         float64       `yaml:"max-fee-per-gas-gwei" toml:"max-fee-per-gas-gwei" json:"max-fee-per-gas-gwei"`
+	MaxPriorityFeePerGasGwei float64       `yaml:"max-priority-fee-per-gas-gwei" toml:"max-priority-fee-per-gas-gwei" json:"max-priority-fee-per-gas-gwei"`
+	GasPriceDeferWindow      time.Duration `yaml:"gas-price-defer-window" toml:"gas-price-defer-window" json:"gas-price-defer-window"`
+	GasPriceRecheckInterval  time.Duration `yaml:"gas-price-recheck-interval" toml:"gas-price-recheck-interval" json:"gas-price-recheck-interval"`
+	GasPriceDeferAction      string        `yaml:"gas-price-defer-action" toml:"gas-price-defer-action" json:"gas-price-defer-action"`
+	GasBudgetEth             float64       `yaml:"gas-budget-eth" toml:"gas-budget-eth" json:"gas-budget-eth"`
+	GasBudgetPeriod          time.Duration `yaml:"gas-budget-period" toml:"gas-budget-period" json:"gas-budget-period"`
+	LowBalanceThresholdEth   float64       `yaml:"low-balance-threshold-eth" toml:"low-balance-threshold-eth" json:"low-balance-threshold-eth"`
+
+	StateFile string `yaml:"state-file" toml:"state-file" json:"state-file"`
+
+	LeaderElection               string        `yaml:"leader-election" toml:"leader-election" json:"leader-election"`
+	LeaderElectionLockFile       string        `yaml:"leader-election-lock-file" toml:"leader-election-lock-file" json:"leader-election-lock-file"`
+	LeaderElectionLeaseName      string        `yaml:"leader-election-lease-name" toml:"leader-election-lease-name" json:"leader-election-lease-name"`
+	LeaderElectionLeaseNamespace string        `yaml:"leader-election-lease-namespace" toml:"leader-election-lease-namespace" json:"leader-election-lease-namespace"`
+	LeaderElectionIdentity       string        `yaml:"leader-election-identity" toml:"leader-election-identity" json:"leader-election-identity"`
+	LeaderElectionLeaseDuration  time.Duration `yaml:"leader-election-lease-duration" toml:"leader-election-lease-duration" json:"leader-election-lease-duration"`
+
+	MetricsAddr             string        `yaml:"metrics-addr" toml:"metrics-addr" json:"metrics-addr"`
+	AdminAddr               string        `yaml:"admin-addr" toml:"admin-addr" json:"admin-addr"`
+	AdminAuthToken          string        `yaml:"admin-auth-token" toml:"admin-auth-token" json:"admin-auth-token"`
+	HealthStuckThreshold    time.Duration `yaml:"health-stuck-threshold" toml:"health-stuck-threshold" json:"health-stuck-threshold"`
+	ReadinessMaxMissedSyncs int           `yaml:"readiness-max-missed-syncs" toml:"readiness-max-missed-syncs" json:"readiness-max-missed-syncs"`
+
+	ReaderTimeout       time.Duration `yaml:"reader-timeout-duration" toml:"reader-timeout-duration" json:"reader-timeout-duration"`
+	WriterTimeout       time.Duration `yaml:"writer-timeout-duration" toml:"writer-timeout-duration" json:"writer-timeout-duration"`
+	ShutdownGracePeriod time.Duration `yaml:"shutdown-grace-period" toml:"shutdown-grace-period" json:"shutdown-grace-period"`
+
+	// the signer of the transactions, which named signers replace for the targets that use them
+	SignerConfig `yaml:",inline"`
+
+	// Targets are the AVSs synced by this process. When set, the registry addresses, operators, quorums and
+	// schedule above are the defaults of the targets (see Config.TargetConfig). Only set in the config file.
+	Targets []TargetConfig `yaml:"targets" toml:"targets" json:"targets"`
+	// Signers are the signers targets can use instead of the signer above, by name. Only set in the config file.
+	Signers map[string]SignerConfig `yaml:"signers" toml:"signers" json:"signers"`
+}
+
+// SignerConfig is the signer of the stake update transactions. Exactly one of its sources must be set.
+type SignerConfig struct {
+	UseFireblocks                        bool   `yaml:"use-fireblocks" toml:"use-fireblocks" json:"use-fireblocks"`
+	SecretManagerRegion                  string `yaml:"secret-manager-region" toml:"secret-manager-region" json:"secret-manager-region"`
+	SecretManagerEcdsaPrivateKeyName     string `yaml:"secret-manager-ecdsa-private-key-name" toml:"secret-manager-ecdsa-private-key-name" json:"secret-manager-ecdsa-private-key-name"`
+	EcdsaPrivateKey                      string `yaml:"ecdsa-private-key" toml:"ecdsa-private-key" json:"ecdsa-private-key"`
+	EcdsaPrivateKeyFile                  string `yaml:"ecdsa-private-key-file" toml:"ecdsa-private-key-file" json:"ecdsa-private-key-file"`
+	EcdsaKeystorePath                    string `yaml:"ecdsa-keystore-path" toml:"ecdsa-keystore-path" json:"ecdsa-keystore-path"`
+	EcdsaKeystorePasswordFile            string `yaml:"ecdsa-keystore-password-file" toml:"ecdsa-keystore-password-file" json:"ecdsa-keystore-password-file"`
+	SecretManagerFireblocksAPIKeyName    string `yaml:"secret-manager-fireblocks-api-key-name" toml:"secret-manager-fireblocks-api-key-name" json:"secret-manager-fireblocks-api-key-name"`
+	FireblocksAPIKey                     string `yaml:"fireblocks-api-key" toml:"fireblocks-api-key" json:"fireblocks-api-key"`
+	SecretManagerFireblocksAPISecretName string `yaml:"secret-manager-fireblocks-api-secret-name" toml:"secret-manager-fireblocks-api-secret-name" json:"secret-manager-fireblocks-api-secret-name"`
+	FireblocksAPISecretPath              string `yaml:"fireblocks-api-secret-path" toml:"fireblocks-api-secret-path" json:"fireblocks-api-secret-path"`
+	FireblocksBaseURL                    string `yaml:"fireblocks-api-url" toml:"fireblocks-api-url" json:"fireblocks-api-url"`
+	FireblocksVaultAccountName           string `yaml:"fireblocks-vault-account-name" toml:"fireblocks-vault-account-name" json:"fireblocks-vault-account-name"`
+
+	VaultAddr                string `yaml:"vault-addr" toml:"vault-addr" json:"vault-addr"`
+	VaultNamespace           string `yaml:"vault-namespace" toml:"vault-namespace" json:"vault-namespace"`
+	VaultAuthMethod          string `yaml:"vault-auth-method" toml:"vault-auth-method" json:"vault-auth-method"`
+	VaultAuthMount           string `yaml:"vault-auth-mount" toml:"vault-auth-mount" json:"vault-auth-mount"`
+	VaultToken               string `yaml:"vault-token" toml:"vault-token" json:"vault-token"`
+	VaultAppRoleID           string `yaml:"vault-approle-role-id" toml:"vault-approle-role-id" json:"vault-approle-role-id"`
+	VaultAppRoleSecretID     string `yaml:"vault-approle-secret-id" toml:"vault-approle-secret-id" json:"vault-approle-secret-id"`
+	VaultKubernetesRole      string `yaml:"vault-kubernetes-role" toml:"vault-kubernetes-role" json:"vault-kubernetes-role"`
+	VaultKubernetesTokenFile string `yaml:"vault-kubernetes-token-file" toml:"vault-kubernetes-token-file" json:"vault-kubernetes-token-file"`
+	VaultTransitMount        string `yaml:"vault-transit-mount" toml:"vault-transit-mount" json:"vault-transit-mount"`
+	VaultTransitKey          string `yaml:"vault-transit-key" toml:"vault-transit-key" json:"vault-transit-key"`
+
+	RemoteSignerURL         string         `yaml:"remote-signer-url" toml:"remote-signer-url" json:"remote-signer-url"`
+	RemoteSignerAddr        common.Address `yaml:"remote-signer-addr" toml:"remote-signer-addr" json:"remote-signer-addr"`
+	RemoteSignerTLSCertFile string         `yaml:"remote-signer-tls-cert-file" toml:"remote-signer-tls-cert-file" json:"remote-signer-tls-cert-file"`
+	RemoteSignerTLSKeyFile  string         `yaml:"remote-signer-tls-key-file" toml:"remote-signer-tls-key-file" json:"remote-signer-tls-key-file"`
+	RemoteSignerTLSCAFile   string         `yaml:"remote-signer-tls-ca-file" toml:"remote-signer-tls-ca-file" json:"remote-signer-tls-ca-file"`
+}
+
+// ReadConfigFile decodes the YAML (.yaml, .yml) or TOML (.toml) file at path into cfg.
+// Keys missing from the file leave the corresponding fields of cfg untouched, and unknown keys are rejected.
+func ReadConfigFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil {
+			return fmt.Errorf("cannot decode yaml config file %s: %w", path, err)
+		}
+	case ".toml":
+		metadata, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("cannot decode toml config file %s: %w", path, err)
+		}
+		if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("unknown keys %v in toml config file %s", undecoded, path)
+		}
+	default:
+		return fmt.Errorf("config file %s must have a .yaml, .yml or .toml extension", path)
+	}
+	return nil
+}
+
+// Validate checks that the config is complete and consistent, and returns every problem found
+func (c Config) Validate() error {
+	var errs []error
+	addErr := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if len(c.Targets) > 0 {
+		errs = append(errs, c.validateTargets()...)
+	} else {
+		errs = append(errs, c.validateTargetKeys()...)
+		if len(c.Signers) > 0 {
+			addErr("signers can only be used by targets")
+		}
+	}
+	if len(c.EthHttpUrls) == 0 {
+		addErr("eth-http-url is required")
+	}
+	for _, url := range append(append([]string{}, c.EthHttpUrls...), c.EthWriteHttpUrls...) {
+		if url == "" {
+			addErr("eth-http-url and eth-write-http-url cannot contain empty urls")
+			break
+		}
+	}
+	if c.RpcEndpointCooldown < 0 || c.RpcAttemptTimeout < 0 {
+		addErr("rpc-endpoint-cooldown and rpc-attempt-timeout cannot be negative")
+	}
+
+	if c.RetrySyncNTimes < 1 {
+		addErr("retry-sync-n-times must be at least 1")
+	}
+	if c.RetryBaseBackoff < 0 || c.RetryMaxBackoff < c.RetryBaseBackoff {
+		addErr("retry-base-backoff cannot be negative, and retry-max-backoff cannot be less than retry-base-backoff")
+	}
+	if c.RetryJitter < 0 || c.RetryJitter > 1 {
+		addErr("retry-jitter must be between 0 and 1")
+	}
+	if c.RetryDeadline < 0 {
+		addErr("retry-deadline cannot be negative")
+	}
+	if _, err := c.stakeDriftThresholds(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.EventDrivenSync && c.EventPollInterval <= 0 {
+		addErr("event-poll-interval must be positive")
+	}
+	if c.MaxOperatorsPerTx < 0 {
+		addErr("max-operators-per-tx cannot be negative")
+	}
+	errs = append(errs, c.validateGasPrice()...)
+	if c.GasBudgetEth < 0 {
+		addErr("gas-budget-eth cannot be negative")
+	}
+	if c.GasBudgetEth > 0 && c.GasBudgetPeriod <= 0 {
+		addErr("gas-budget-period must be positive")
+	}
+	if c.LowBalanceThresholdEth < 0 {
+		addErr("low-balance-threshold-eth cannot be negative")
+	}
+	switch c.LeaderElection {
+	case "":
+	case LeaderElectionFile, LeaderElectionKubernetes:
+		if c.LeaderElection == LeaderElectionFile && c.LeaderElectionLockFile == "" {
+			addErr("leader-election-lock-file is required with file leader election")
+		}
+		if c.LeaderElection == LeaderElectionKubernetes && c.LeaderElectionLeaseName == "" {
+			addErr("leader-election-lease-name is required with kubernetes leader election")
+		}
+		if c.LeaderElectionLeaseDuration < 5*time.Second {
+			addErr("leader-election-lease-duration must be at least 5s")
+		}
+	default:
+		addErr("leader-election must be %s or %s", LeaderElectionFile, LeaderElectionKubernetes)
+	}
+	if c.ReadinessMaxMissedSyncs < 0 {
+		addErr("readiness-max-missed-syncs cannot be negative")
+	}
+	if c.ReaderTimeout <= 0 {
+		addErr("reader-timeout-duration must be positive")
+	}
+	if c.WriterTimeout <= 0 {
+		addErr("writer-timeout-duration must be positive")
+	}
+	if c.ShutdownGracePeriod < 0 {
+		addErr("shutdown-grace-period cannot be negative")
+	}
+
+	// dry runs don't need a signer, and the signers of the targets are checked with them
+	if !c.DryRun && len(c.Targets) == 0 {
+		errs = append(errs, c.validateSigner()...)
+	}
+	return errors.Join(errs...)
+}
+
+// validateTargetKeys checks the keys that targets can override: the registry addresses, operators, quorums, operator sets
+// and schedule
+func (c Config) validateTargetKeys() []error {
+	var errs []error
+	addErr := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.RegistryCoordinatorAddr == (common.Address{}) {
+		addErr("registry-coordinator-addr is required")
+	}
+	if c.OperatorStateRetrieverAddr == (common.Address{}) {
+		addErr("operator-state-retriever-addr is required")
+	}
+	if c.ServiceManagerAddr == (common.Address{}) {
+		addErr("service-manager-addr is required")
+	}
+	if c.UseOperatorSets {
+		// the quorums are the operator sets of the AVS, enumerated at every sync
+		if len(c.Quorums) > 0 {
+			addErr("quorums can't be set with use-operator-sets, the operator sets of the AVS are synced")
+		}
+		if c.DontUseAllocationManager {
+			addErr("use-operator-sets requires the AllocationManager, it can't be used with dont-use-allocation-manager")
+		}
+	} else if len(c.Operators) == 0 && len(c.Quorums) == 0 && !c.FetchQuorumsDynamically {
+		addErr("quorums must be set when operators is empty and fetch-quorums-dynamically is false")
+	}
+	for _, quorum := range c.Quorums {
+		if quorum < 0 || quorum > 255 {
+			addErr("invalid quorum %d", quorum)
+		}
+	}
+	if _, _, err := c.schedule(time.Now()); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func (c Config) validateSigner() []error {
+	var errs []error
+	if c.UseFireblocks {
+		// the secret manager names take precedence over fireblocks-api-key and fireblocks-api-secret-path
+		if c.SecretManagerFireblocksAPIKeyName == "" && c.FireblocksAPIKey == "" {
+			errs = append(errs, errors.New("use-fireblocks requires fireblocks-api-key or secret-manager-fireblocks-api-key-name"))
+		}
+		if c.SecretManagerFireblocksAPISecretName == "" && c.FireblocksAPISecretPath == "" {
+			errs = append(errs, errors.New("use-fireblocks requires fireblocks-api-secret-path or secret-manager-fireblocks-api-secret-name"))
+		}
+		if c.FireblocksBaseURL == "" {
+			errs = append(errs, errors.New("use-fireblocks requires fireblocks-api-url"))
+		}
+		if c.FireblocksVaultAccountName == "" {
+			errs = append(errs, errors.New("use-fireblocks requires fireblocks-vault-account-name"))
+		}
+		if c.RemoteSignerURL != "" {
+			errs = append(errs, errors.New("use-fireblocks and remote-signer-url cannot both be set"))
+		}
+		if c.VaultTransitKey != "" {
+			errs = append(errs, errors.New("use-fireblocks and vault-transit-key cannot both be set"))
+		}
+	} else {
+		sources := c.ecdsaKeySources()
+		if c.RemoteSignerURL != "" {
+			sources = append(sources, "remote-signer-url")
+		}
+		if c.VaultTransitKey != "" {
+			sources = append(sources, "vault-transit-key")
+		}
+		switch len(sources) {
+		case 0:
+			errs = append(errs, errors.New("one of ecdsa-private-key, ecdsa-private-key-file, ecdsa-keystore-path, "+
+				"secret-manager-ecdsa-private-key-name, remote-signer-url or vault-transit-key is required when use-fireblocks is false"))
+		case 1:
+		default:
+			errs = append(errs, fmt.Errorf("only one signer can be set, got %s", strings.Join(sources, " and ")))
+		}
+		if c.EcdsaKeystorePath != "" && c.EcdsaKeystorePasswordFile == "" {
+			errs = append(errs, errors.New("ecdsa-keystore-path requires ecdsa-keystore-password-file"))
+		}
+	}
+	if c.RemoteSignerURL != "" {
+		if c.RemoteSignerAddr == (common.Address{}) {
+			errs = append(errs, errors.New("remote-signer-url requires remote-signer-addr"))
+		}
+		if (c.RemoteSignerTLSCertFile == "") != (c.RemoteSignerTLSKeyFile == "") {
+			errs = append(errs, errors.New("remote-signer-tls-cert-file and remote-signer-tls-key-file must be set together"))
+		}
+	}
+	secretRefErrs, usesVault := c.validateSecretRefs()
+	errs = append(errs, secretRefErrs...)
+	if !c.UseFireblocks && c.VaultTransitKey != "" {
+		usesVault = true
+		if c.VaultAddr == "" {
+			errs = append(errs, errors.New("vault-transit-key requires vault-addr"))
+		}
+	}
+	if usesVault {
+		errs = append(errs, c.validateVault()...)
+	}
+	return errs
+}
+
+// validateSecretRefs checks the references of the secrets the signer reads, and that the secret stores they point to
+// are configured. It also returns whether any of them is in vault.
+func (c Config) validateSecretRefs() ([]error, bool) {
+	var errs []error
+	usesVault := false
+	refs := map[string]string{}
+	if c.UseFireblocks {
+		refs["secret-manager-fireblocks-api-key-name"] = c.SecretManagerFireblocksAPIKeyName
+		refs["secret-manager-fireblocks-api-secret-name"] = c.SecretManagerFireblocksAPISecretName
+	} else {
+		refs["secret-manager-ecdsa-private-key-name"] = c.SecretManagerEcdsaPrivateKeyName
+	}
+	for _, flag := range []string{"secret-manager-ecdsa-private-key-name", "secret-manager-fireblocks-api-key-name", "secret-manager-fireblocks-api-secret-name"} {
+		if refs[flag] == "" {
+			continue
+		}
+		ref, err := ParseSecretRef(refs[flag])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", flag, err))
+			continue
+		}
+		switch ref.Scheme {
+		case SecretSchemeAWSSecretsManager:
+			if ref.Query().Get("region") == "" && c.SecretManagerRegion == "" {
+				errs = append(errs, fmt.Errorf("secret-manager-region is required to read %s from the AWS secret manager", flag))
+			}
+		case SecretSchemeVault:
+			usesVault = true
+			if c.VaultAddr == "" {
+				errs = append(errs, fmt.Errorf("vault-addr is required to read %s from vault", flag))
+			}
+		}
+	}
+	return errs, usesVault
+}
+
+// Redacted returns a copy of the config whose secrets (keys, tokens and rpc urls, which usually embed api keys) are redacted,
+// which can be logged or served by the admin API
+func (c Config) Redacted() Config {
+	redactStrings := func(strs []string) []string {
+		if strs == nil {
+			return nil
+		}
+		redactedStrs := make([]string, len(strs))
+		for i := range strs {
+			redactedStrs[i] = redacted
+		}
+		return redactedStrs
+	}
+	c.EthHttpUrls = redactStrings(c.EthHttpUrls)
+	c.EthWriteHttpUrls = redactStrings(c.EthWriteHttpUrls)
+	c.AdminAuthToken = redactString(c.AdminAuthToken)
+	c.SignerConfig = c.SignerConfig.redactSecrets()
+	if c.Signers != nil {
+		signers := make(map[string]SignerConfig, len(c.Signers))
+		for name, signer := range c.Signers {
+			signers[name] = signer.redactSecrets()
+		}
+		c.Signers = signers
+	}
+	return c
+}
+
+func (s SignerConfig) redactSecrets() SignerConfig {
+	s.EcdsaPrivateKey = redactString(s.EcdsaPrivateKey)
+	s.FireblocksAPIKey = redactString(s.FireblocksAPIKey)
+	s.VaultToken = redactString(s.VaultToken)
+	s.VaultAppRoleSecretID = redactString(s.VaultAppRoleSecretID)
+	return s
+}
+
+func redactString(s string) string {
+	if s == "" {
+		return s
+	}
+	return redacted
+}
+
+// schedule returns the cron schedule if one is configured, otherwise how long to sleep before the first sync
+// (after which syncs happen every SyncInterval)
+func (c Config) schedule(now time.Time) (Schedule, time.Duration, error) {
+	if len(c.Schedule) > 0 {
+		loc, err := time.LoadLocation(c.ScheduleTimezone)
+		if err != nil {
+			return nil, 0, fmt.Errorf("cannot load schedule timezone: %w", err)
+		}
+		schedule, err := ParseCronSchedule(c.Schedule, loc)
+		if err != nil {
+			return nil, 0, err
+		}
+		return schedule, 0, nil
+	}
+	if c.SyncInterval < 0 {
+		return nil, 0, errors.New("sync-interval cannot be negative")
+	}
+	sleepBeforeFirstSyncDuration, err := getSleepBeforeFirstSyncDuration(c.FirstSyncTime, now)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid first-sync-time: %w", err)
+	}
+	return nil, sleepBeforeFirstSyncDuration, nil
+}
+
+func (c Config) retryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: c.RetrySyncNTimes,
+		BaseBackoff: c.RetryBaseBackoff,
+		MaxBackoff:  c.RetryMaxBackoff,
+		Jitter:      c.RetryJitter,
+		Deadline:    c.RetryDeadline,
+	}
+}
+
+// stakeDriftThresholds returns nil if every quorum must be updated at every sync
+func (c Config) stakeDriftThresholds() (*StakeDriftThresholds, error) {
+	if !c.OnlyUpdateOnStakeDrift {
+		return nil, nil
+	}
+	absoluteThreshold, ok := new(big.Int).SetString(c.StakeDriftThresholdAbs, 10)
+	if !ok || absoluteThreshold.Sign() < 0 {
+		return nil, fmt.Errorf("invalid stake-drift-threshold-abs: %s", c.StakeDriftThresholdAbs)
+	}
+	if c.StakeDriftThresholdPct < 0 {
+		return nil, fmt.Errorf("invalid stake-drift-threshold-pct: %v", c.StakeDriftThresholdPct)
+	}
+	return &StakeDriftThresholds{
+		Absolute:   absoluteThreshold,
+		Percentage: c.StakeDriftThresholdPct,
+	}, nil
+}
+
+// getSleepBeforeFirstSyncDuration returns how long to wait from now until the next occurrence of firstSyncTimeStr,
+// a HH:MM:SS time of day in UTC. An empty firstSyncTimeStr means the first sync should happen right away.
+func getSleepBeforeFirstSyncDuration(firstSyncTimeStr string, now time.Time) (time.Duration, error) {
+	if firstSyncTimeStr == "" {
+		return 0 * time.Second, nil
+	}
+	firstSyncTime, err := time.Parse(time.TimeOnly, firstSyncTimeStr)
+	if err != nil {
+		return 0, err
+	}
+	now = now.UTC()
+	firstSyncTime = time.Date(now.Year(), now.Month(), now.Day(), firstSyncTime.Hour(), firstSyncTime.Minute(), firstSyncTime.Second(), 0, time.UTC)
+	if now.After(firstSyncTime) {
+		// If the set time is before the current time, add a day to the set time
+		firstSyncTime = firstSyncTime.Add(24 * time.Hour)
+	}
+	return firstSyncTime.Sub(now), nil
+}
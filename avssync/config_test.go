@@ -0,0 +1,247 @@
+package avssync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func validTestConfig() Config {
+	return Config{
+		RegistryCoordinatorAddr:    common.HexToAddress("0x1"),
+		OperatorStateRetrieverAddr: common.HexToAddress("0x2"),
+		ServiceManagerAddr:         common.HexToAddress("0x3"),
+		EthHttpUrls:                []string{"http://localhost:8545"},
+		FetchQuorumsDynamically:    true,
+		RetrySyncNTimes:            3,
+		ScheduleTimezone:           "UTC",
+		ReaderTimeout:              5 * time.Second,
+		WriterTimeout:              90 * time.Second,
+		SignerConfig:               SignerConfig{EcdsaPrivateKey: "0123"},
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`
+registry-coordinator-addr: "0x0000000000000000000000000000000000000001"
+eth-http-url: [http://a, http://b]
+quorums: [0, 1]
+sync-interval: 1h30m
+`), 0644))
+	tomlPath := filepath.Join(dir, "config.toml")
+	require.NoError(t, os.WriteFile(tomlPath, []byte(`
+registry-coordinator-addr = "0x0000000000000000000000000000000000000001"
+eth-http-url = ["http://a", "http://b"]
+quorums = [0, 1]
+sync-interval = "1h30m"
+`), 0644))
+
+	for _, path := range []string{yamlPath, tomlPath} {
+		// fields missing from the file keep their value
+		cfg := Config{ReaderTimeout: time.Second}
+		require.NoError(t, ReadConfigFile(path, &cfg), path)
+		require.Equal(t, Config{
+			RegistryCoordinatorAddr: common.HexToAddress("0x1"),
+			EthHttpUrls:             []string{"http://a", "http://b"},
+			Quorums:                 []int{0, 1},
+			SyncInterval:            90 * time.Minute,
+			ReaderTimeout:           time.Second,
+		}, cfg, path)
+	}
+
+	unknownKeyPath := filepath.Join(dir, "unknown.yml")
+	require.NoError(t, os.WriteFile(unknownKeyPath, []byte("eth-http-urls: [http://a]\n"), 0644))
+	require.Error(t, ReadConfigFile(unknownKeyPath, &Config{}))
+	jsonPath := filepath.Join(dir, "config.json")
+	require.NoError(t, os.WriteFile(jsonPath, []byte("{}"), 0644))
+	require.ErrorContains(t, ReadConfigFile(jsonPath, &Config{}), "must have a .yaml, .yml or .toml extension")
+}
+
+func TestConfigValidate(t *testing.T) {
+	require.NoError(t, validTestConfig().Validate())
+
+	testCases := map[string]struct {
+		modify      func(cfg *Config)
+		errContains string
+	}{
+		"missing registry coordinator": {
+			modify:      func(cfg *Config) { cfg.RegistryCoordinatorAddr = common.Address{} },
+			errContains: "registry-coordinator-addr is required",
+		},
+		"max backoff below base backoff": {
+			modify:      func(cfg *Config) { cfg.RetryBaseBackoff = time.Minute },
+			errContains: "retry-max-backoff cannot be less than retry-base-backoff",
+		},
+		"nothing to update": {
+			modify:      func(cfg *Config) { cfg.FetchQuorumsDynamically = false },
+			errContains: "quorums must be set",
+		},
+		"fireblocks without credentials": {
+			modify:      func(cfg *Config) { cfg.UseFireblocks = true },
+			errContains: "use-fireblocks requires fireblocks-api-key",
+		},
+		"zero reader timeout": {
+			modify:      func(cfg *Config) { cfg.ReaderTimeout = 0 },
+			errContains: "reader-timeout-duration must be positive",
+		},
+		"invalid schedule": {
+			modify:      func(cfg *Config) { cfg.Schedule = []string{"not a cron"} },
+			errContains: "not a cron",
+		},
+		"invalid stake drift threshold": {
+			modify: func(cfg *Config) {
+				cfg.OnlyUpdateOnStakeDrift = true
+				cfg.StakeDriftThresholdAbs = "-1"
+			},
+			errContains: "invalid stake-drift-threshold-abs",
+		},
+		"unknown leader election backend": {
+			modify:      func(cfg *Config) { cfg.LeaderElection = "etcd" },
+			errContains: "leader-election must be file or kubernetes",
+		},
+		"file leader election without lock file": {
+			modify: func(cfg *Config) {
+				cfg.LeaderElection = LeaderElectionFile
+				cfg.LeaderElectionLeaseDuration = 15 * time.Second
+			},
+			errContains: "leader-election-lock-file is required",
+		},
+		"several ecdsa private key sources": {
+			modify:      func(cfg *Config) { cfg.EcdsaPrivateKeyFile = "key" },
+			errContains: "only one signer can be set, got ecdsa-private-key and ecdsa-private-key-file",
+		},
+		"keystore without password file": {
+			modify: func(cfg *Config) {
+				cfg.EcdsaPrivateKey = ""
+				cfg.EcdsaKeystorePath = "keystore.json"
+			},
+			errContains: "ecdsa-keystore-path requires ecdsa-keystore-password-file",
+		},
+		"remote signer and private key": {
+			modify: func(cfg *Config) {
+				cfg.RemoteSignerURL = "https://signer:9000"
+				cfg.RemoteSignerAddr = common.HexToAddress("0x5e")
+			},
+			errContains: "only one signer can be set, got ecdsa-private-key and remote-signer-url",
+		},
+		"remote signer without address": {
+			modify: func(cfg *Config) {
+				cfg.EcdsaPrivateKey = ""
+				cfg.RemoteSignerURL = "https://signer:9000"
+				cfg.RemoteSignerTLSCertFile = "client.crt"
+			},
+			errContains: "remote-signer-url requires remote-signer-addr\nremote-signer-tls-cert-file and remote-signer-tls-key-file must be set together",
+		},
+		"secret manager without region": {
+			modify: func(cfg *Config) {
+				cfg.EcdsaPrivateKey = ""
+				cfg.SecretManagerEcdsaPrivateKeyName = "key"
+			},
+			errContains: "secret-manager-region is required",
+		},
+		"vault secret without vault address": {
+			modify: func(cfg *Config) {
+				cfg.EcdsaPrivateKey = ""
+				cfg.SecretManagerEcdsaPrivateKeyName = "vault://kv/avs-sync#key"
+			},
+			errContains: "vault-addr is required to read secret-manager-ecdsa-private-key-name from vault",
+		},
+		"unknown secret scheme": {
+			modify: func(cfg *Config) {
+				cfg.EcdsaPrivateKey = ""
+				cfg.SecretManagerEcdsaPrivateKeyName = "gcpsm://key"
+			},
+			errContains: "invalid secret-manager-ecdsa-private-key-name",
+		},
+		"vault transit key and private key": {
+			modify: func(cfg *Config) {
+				cfg.VaultAddr = "https://vault:8200"
+				cfg.VaultToken = "hvs.token"
+				cfg.VaultTransitKey = "avs-sync"
+			},
+			errContains: "only one signer can be set, got ecdsa-private-key and vault-transit-key",
+		},
+		"vault transit key without approle secret id": {
+			modify: func(cfg *Config) {
+				cfg.EcdsaPrivateKey = ""
+				cfg.VaultAddr = "https://vault:8200"
+				cfg.VaultAuthMethod = VaultAuthAppRole
+				cfg.VaultAppRoleID = "role-id"
+				cfg.VaultTransitKey = "avs-sync"
+			},
+			errContains: "vault-approle-role-id and vault-approle-secret-id are required with approle vault auth",
+		},
+		"vault transit key without vault address": {
+			modify: func(cfg *Config) {
+				cfg.EcdsaPrivateKey = ""
+				cfg.VaultTransitKey = "avs-sync"
+				cfg.VaultAuthMethod = "userpass"
+			},
+			errContains: "vault-transit-key requires vault-addr\nvault-auth-method must be token, approle or kubernetes",
+		},
+		"fireblocks without api secret": {
+			modify: func(cfg *Config) {
+				cfg.UseFireblocks = true
+				cfg.FireblocksBaseURL = "https://api.fireblocks.io"
+				cfg.FireblocksVaultAccountName = "avs-sync"
+				cfg.SecretManagerFireblocksAPIKeyName = "env://FIREBLOCKS_API_KEY"
+			},
+			errContains: "use-fireblocks requires fireblocks-api-secret-path or secret-manager-fireblocks-api-secret-name",
+		},
+		"operator sets with quorums": {
+			modify: func(cfg *Config) {
+				cfg.UseOperatorSets = true
+				cfg.Quorums = []int{0}
+			},
+			errContains: "quorums can't be set with use-operator-sets",
+		},
+		"operator sets without allocation manager": {
+			modify: func(cfg *Config) {
+				cfg.UseOperatorSets = true
+				cfg.DontUseAllocationManager = true
+			},
+			errContains: "use-operator-sets requires the AllocationManager",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cfg := validTestConfig()
+			tc.modify(&cfg)
+			require.ErrorContains(t, cfg.Validate(), tc.errContains)
+		})
+	}
+
+	// dry runs don't need a signer
+	cfg := validTestConfig()
+	cfg.EcdsaPrivateKey = ""
+	require.Error(t, cfg.Validate())
+	cfg.DryRun = true
+	require.NoError(t, cfg.Validate())
+
+	// the operator sets are the quorums
+	cfg = validTestConfig()
+	cfg.FetchQuorumsDynamically = false
+	cfg.UseOperatorSets = true
+	require.NoError(t, cfg.Validate())
+}
+
+func TestConfigRedacted(t *testing.T) {
+	cfg := validTestConfig()
+	redactedCfg := cfg.Redacted()
+	require.Equal(t, []string{redacted}, redactedCfg.EthHttpUrls)
+	require.Equal(t, redacted, redactedCfg.EcdsaPrivateKey)
+	require.Empty(t, redactedCfg.FireblocksAPIKey)
+	cfg.VaultToken = "hvs.token"
+	require.Equal(t, redacted, cfg.Redacted().VaultToken)
+	cfg.VaultAppRoleSecretID = "secret-id"
+	require.Equal(t, redacted, cfg.Redacted().VaultAppRoleSecretID)
+	require.Equal(t, cfg.RegistryCoordinatorAddr, redactedCfg.RegistryCoordinatorAddr)
+	// the original is untouched
+	require.Equal(t, "http://localhost:8545", cfg.EthHttpUrls[0])
+}
@@ -0,0 +1,191 @@
+package avssync
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+
+	"github.com/Layr-Labs/eigensdk-go/types"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// StakeDriftThresholds define how far the stakes recorded in the StakeRegistry are allowed to drift from the stakes
+// computed from the operators' current delegated shares before a quorum gets updated.
+// A quorum is updated if either its aggregate drift or the drift of any single operator exceeds one of the thresholds.
+// If both thresholds are zero, a quorum is updated as soon as any stake changed.
+type StakeDriftThresholds struct {
+	// Absolute drift, in stake (weight) units. Nil or 0 disables this threshold.
+	Absolute *big.Int
+	// Relative drift, in percent of the stake recorded in the StakeRegistry. 0 disables this threshold.
+	Percentage float64
+}
+
+// OperatorStakeDrift compares the stake recorded in the StakeRegistry for an operator with the stake
+// that would be recorded if the operator's stake was updated now.
+type OperatorStakeDrift struct {
+	Operator      common.Address
+	RegistryStake *big.Int
+	// CurrentStake is 0 if the operator's weight fell under the quorum's minimum stake, since updating its stake would
+	// then deregister it from the quorum
+	CurrentStake      *big.Int
+	BelowMinimumStake bool
+}
+
+func (d OperatorStakeDrift) Delta() *big.Int {
+	return new(big.Int).Sub(d.CurrentStake, d.RegistryStake)
+}
+
+// QuorumStakeDrift aggregates the OperatorStakeDrift of every operator registered in a quorum
+type QuorumStakeDrift struct {
+	Quorum        byte
+	RegistryStake *big.Int
+	CurrentStake  *big.Int
+	Operators     []OperatorStakeDrift
+}
+
+func (d *QuorumStakeDrift) Delta() *big.Int {
+	return new(big.Int).Sub(d.CurrentStake, d.RegistryStake)
+}
+
+// DriftedOperators returns the number of operators whose stake changed
+func (d *QuorumStakeDrift) DriftedOperators() int {
+	drifted := 0
+	for _, operator := range d.Operators {
+		if operator.CurrentStake.Cmp(operator.RegistryStake) != 0 {
+			drifted++
+		}
+	}
+	return drifted
+}
+
+// MaxOperatorDriftRatio returns the largest relative drift of a single operator (0.1 means 10%)
+func (d *QuorumStakeDrift) MaxOperatorDriftRatio() float64 {
+	maxRatio := 0.0
+	for _, operator := range d.Operators {
+		maxRatio = math.Max(maxRatio, driftRatio(operator.RegistryStake, operator.CurrentStake))
+	}
+	return maxRatio
+}
+
+// ExceededBy returns whether the drift of the quorum, or of any operator in it, exceeds the thresholds
+func (t *StakeDriftThresholds) ExceededBy(d *QuorumStakeDrift) bool {
+	if t.exceeded(d.RegistryStake, d.CurrentStake) {
+		return true
+	}
+	for _, operator := range d.Operators {
+		if t.exceeded(operator.RegistryStake, operator.CurrentStake) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *StakeDriftThresholds) exceeded(registryStake, currentStake *big.Int) bool {
+	delta := new(big.Int).Sub(currentStake, registryStake)
+	if delta.Sign() == 0 {
+		return false
+	}
+	absoluteThresholdSet := t.Absolute != nil && t.Absolute.Sign() > 0
+	percentageThresholdSet := t.Percentage > 0
+	if !absoluteThresholdSet && !percentageThresholdSet {
+		return true
+	}
+	if absoluteThresholdSet && delta.CmpAbs(t.Absolute) > 0 {
+		return true
+	}
+	return percentageThresholdSet && driftRatio(registryStake, currentStake)*100 > t.Percentage
+}
+
+// driftRatio returns |current-registry|/registry, or +Inf if the registry stake is 0 and the current stake isn't
+func driftRatio(registryStake, currentStake *big.Int) float64 {
+	delta := new(big.Int).Sub(currentStake, registryStake)
+	if delta.Sign() == 0 {
+		return 0
+	}
+	if registryStake.Sign() == 0 {
+		return math.Inf(1)
+	}
+	ratio, _ := new(big.Rat).SetFrac(delta.Abs(delta), registryStake).Float64()
+	return ratio
+}
+
+// getQuorumStakeDrift compares, for every operator in the quorum, the stake recorded in the StakeRegistry with
+// the weight the StakeRegistry computes from the operator's current delegated shares and the quorum's strategy multipliers.
+// Note that the reads are not pinned to a single block, so an operator set change in between them can
+// make the result slightly stale, which is fine since the update itself refetches the operator set.
+// Every read has its own timeout, since there is one per operator of the quorum.
+func (a *AvsSync) getQuorumStakeDrift(ctx context.Context, quorum byte) (*QuorumStakeDrift, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, a.readerTimeoutDuration)
+	operatorsPerQuorum, err := a.AvsReader.GetOperatorsStakeInQuorumsAtCurrentBlock(&bind.CallOpts{Context: timeoutCtx}, types.QuorumNums{types.QuorumNum(quorum)})
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch operator stakes in quorum %d: %w", quorum, err)
+	}
+	timeoutCtx, cancel = context.WithTimeout(ctx, a.readerTimeoutDuration)
+	minimumStake, err := a.AvsReader.GetMinimumStakeForQuorum(&bind.CallOpts{Context: timeoutCtx}, quorum)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch minimum stake for quorum %d: %w", quorum, err)
+	}
+
+	drift := &QuorumStakeDrift{
+		Quorum:        quorum,
+		RegistryStake: big.NewInt(0),
+		CurrentStake:  big.NewInt(0),
+	}
+	for _, operator := range operatorsPerQuorum[0] {
+		timeoutCtx, cancel := context.WithTimeout(ctx, a.readerTimeoutDuration)
+		weight, err := a.AvsReader.WeightOfOperatorForQuorum(&bind.CallOpts{Context: timeoutCtx}, quorum, operator.Operator)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("cannot compute weight of operator %s in quorum %d: %w", operator.Operator.Hex(), quorum, err)
+		}
+		operatorDrift := OperatorStakeDrift{
+			Operator:      operator.Operator,
+			RegistryStake: operator.Stake,
+			CurrentStake:  weight,
+		}
+		if weight.Cmp(minimumStake) < 0 {
+			operatorDrift.BelowMinimumStake = true
+			operatorDrift.CurrentStake = big.NewInt(0)
+		}
+		drift.RegistryStake.Add(drift.RegistryStake, operatorDrift.RegistryStake)
+		drift.CurrentStake.Add(drift.CurrentStake, operatorDrift.CurrentStake)
+		drift.Operators = append(drift.Operators, operatorDrift)
+	}
+	return drift, nil
+}
+
+// shouldUpdateQuorum returns whether the stake drift of the quorum exceeds the configured thresholds.
+// If no thresholds are configured, or the drift can't be computed, we always update.
+func (a *AvsSync) shouldUpdateQuorum(ctx context.Context, quorum byte) bool {
+	if a.stakeDriftThresholds == nil {
+		return true
+	}
+	drift, err := a.getQuorumStakeDrift(ctx, quorum)
+	if err != nil {
+		a.logger.Warn("Error computing stake drift, updating quorum anyway", "err", err, "quorum", int(quorum))
+		return true
+	}
+
+	quorumStr := strconv.Itoa(int(quorum))
+	a.Metrics.StakeDriftSet(quorumStr, drift)
+	exceeded := a.stakeDriftThresholds.ExceededBy(drift)
+	a.logger.Info("Stake drift of quorum",
+		"quorum", int(quorum),
+		"registryStake", drift.RegistryStake,
+		"currentStake", drift.CurrentStake,
+		"delta", drift.Delta(),
+		"driftedOperators", drift.DriftedOperators(),
+		"operators", len(drift.Operators),
+		"maxOperatorDriftRatio", drift.MaxOperatorDriftRatio(),
+		"thresholdsExceeded", exceeded,
+	)
+	if !exceeded {
+		a.updateStakeAttemptDone(quorum, UpdateStakeStatusSkipped)
+	}
+	return exceeded
+}
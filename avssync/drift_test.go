@@ -0,0 +1,86 @@
+package avssync
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func newQuorumStakeDrift(stakes ...[2]int64) *QuorumStakeDrift {
+	drift := &QuorumStakeDrift{RegistryStake: big.NewInt(0), CurrentStake: big.NewInt(0)}
+	for i, stake := range stakes {
+		drift.Operators = append(drift.Operators, OperatorStakeDrift{
+			Operator:      common.BigToAddress(big.NewInt(int64(i + 1))),
+			RegistryStake: big.NewInt(stake[0]),
+			CurrentStake:  big.NewInt(stake[1]),
+		})
+		drift.RegistryStake.Add(drift.RegistryStake, big.NewInt(stake[0]))
+		drift.CurrentStake.Add(drift.CurrentStake, big.NewInt(stake[1]))
+	}
+	return drift
+}
+
+func TestStakeDriftThresholds(t *testing.T) {
+	tests := []struct {
+		name       string
+		thresholds StakeDriftThresholds
+		drift      *QuorumStakeDrift
+		exceeded   bool
+	}{
+		{
+			name:       "no drift never exceeds",
+			thresholds: StakeDriftThresholds{},
+			drift:      newQuorumStakeDrift([2]int64{100, 100}, [2]int64{200, 200}),
+			exceeded:   false,
+		},
+		{
+			name:       "no thresholds means any drift exceeds",
+			thresholds: StakeDriftThresholds{},
+			drift:      newQuorumStakeDrift([2]int64{100, 101}),
+			exceeded:   true,
+		},
+		{
+			name:       "absolute threshold not exceeded",
+			thresholds: StakeDriftThresholds{Absolute: big.NewInt(10)},
+			drift:      newQuorumStakeDrift([2]int64{100, 110}, [2]int64{100, 95}),
+			exceeded:   false,
+		},
+		{
+			name:       "absolute threshold exceeded by a single operator",
+			thresholds: StakeDriftThresholds{Absolute: big.NewInt(10)},
+			drift:      newQuorumStakeDrift([2]int64{100, 111}, [2]int64{100, 89}),
+			exceeded:   true,
+		},
+		{
+			name:       "percentage threshold not exceeded",
+			thresholds: StakeDriftThresholds{Percentage: 5},
+			drift:      newQuorumStakeDrift([2]int64{100, 104}, [2]int64{100, 104}, [2]int64{10, 10}),
+			exceeded:   false,
+		},
+		{
+			name:       "percentage threshold exceeded by a single operator",
+			thresholds: StakeDriftThresholds{Percentage: 5},
+			drift:      newQuorumStakeDrift([2]int64{1000, 1000}, [2]int64{10, 11}),
+			exceeded:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.exceeded, tt.thresholds.ExceededBy(tt.drift))
+		})
+	}
+}
+
+func TestDriftRatio(t *testing.T) {
+	require.Equal(t, 0.0, driftRatio(big.NewInt(0), big.NewInt(0)))
+	require.Equal(t, 0.25, driftRatio(big.NewInt(100), big.NewInt(75)))
+	require.True(t, math.IsInf(driftRatio(big.NewInt(0), big.NewInt(1)), 1))
+
+	drift := newQuorumStakeDrift([2]int64{100, 150}, [2]int64{100, 100})
+	require.Equal(t, 0.5, drift.MaxOperatorDriftRatio())
+	require.Equal(t, 1, drift.DriftedOperators())
+	require.Equal(t, big.NewInt(50), drift.Delta())
+}
@@ -0,0 +1,560 @@
+package avssync
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Layr-Labs/eigensdk-go/chainio/clients/eth"
+	delegationmanager "github.com/Layr-Labs/eigensdk-go/contracts/bindings/DelegationManager"
+	regcoord "github.com/Layr-Labs/eigensdk-go/contracts/bindings/RegistryCoordinator"
+	stakeregistry "github.com/Layr-Labs/eigensdk-go/contracts/bindings/StakeRegistry"
+	sdklogging "github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// different node providers have different eth_getLogs range limits, this should work for most
+const defaultEventQueryBlockRange = 10_000
+
+// same as the default of --reader-timeout-duration
+const defaultEventReaderTimeout = 5 * time.Second
+
+type EventWatcherConfig struct {
+	RegistryCoordinatorAddr common.Address
+	StakeRegistryAddr       common.Address
+	DelegationManagerAddr   common.Address
+
+	// how often to poll for new logs
+	PollInterval time.Duration
+	// events are accumulated for this long after the first one is seen, before triggering a single sync for all of them
+	Debounce time.Duration
+	// number of blocks to wait for before processing the logs of a block, to avoid reacting to reorged logs
+	Confirmations uint64
+	// maximum number of blocks queried by a single eth_getLogs call. 0 means defaultEventQueryBlockRange
+	MaxBlockRange uint64
+	// timeout of each rpc call of the watcher, so that a hung call doesn't stall it. 0 means defaultEventReaderTimeout
+	ReaderTimeout time.Duration
+	// block to start processing events from, if no last processed block was persisted. 0 means the current block
+	StartBlock uint64
+	// file in which the last block whose events were synced is persisted, so that restarts don't miss events.
+	// Empty means the last processed block is only kept in memory, unless StateStore is set.
+	StateFilePath string
+	// if not nil, the last block whose events were synced is persisted in the state store instead of StateFilePath
+	StateStore *StateStore
+	// if not empty, only share changes and registrations of these operators trigger syncs
+	Operators []common.Address
+}
+
+// EventWatcher polls (over http) for events that change the stakes of operators in the StakeRegistry,
+// and notifies AvsSync of the quorums that need to be synced.
+type EventWatcher struct {
+	logger    sdklogging.Logger
+	ethClient eth.HttpBackend
+	avsReader AvsReader
+	config    EventWatcherConfig
+
+	delegationManager   *delegationmanager.ContractDelegationManagerFilterer
+	registryCoordinator *regcoord.ContractRegistryCoordinatorFilterer
+	stakeRegistry       *stakeregistry.ContractStakeRegistryFilterer
+	eventNames          map[common.Hash]string
+
+	// only accessed by the polling goroutine
+	lastPolledBlock  uint64
+	pending          quorumSet
+	pendingUpToBlock uint64
+
+	// set of quorums whose debounce period is over, waiting to be synced, and the watched operators
+	mu             sync.Mutex
+	operators      map[common.Address]bool
+	ready          quorumSet
+	readyUpToBlock uint64
+	syncInFlight   bool
+	triggered      chan struct{}
+
+	eventsTotal        *prometheus.CounterVec
+	lastProcessedBlock prometheus.Gauge
+}
+
+// quorumSet is a set of quorums, where all means every quorum (e.g. when the affected quorums can't be determined)
+type quorumSet struct {
+	all     bool
+	quorums map[byte]struct{}
+}
+
+func (s *quorumSet) add(quorums ...byte) {
+	if s.quorums == nil {
+		s.quorums = make(map[byte]struct{})
+	}
+	for _, quorum := range quorums {
+		s.quorums[quorum] = struct{}{}
+	}
+}
+
+func (s *quorumSet) merge(other quorumSet) {
+	s.all = s.all || other.all
+	for quorum := range other.quorums {
+		s.add(quorum)
+	}
+}
+
+func (s *quorumSet) empty() bool {
+	return !s.all && len(s.quorums) == 0
+}
+
+// list returns the sorted quorums of the set, or nil if the set contains all quorums
+func (s *quorumSet) list() []byte {
+	if s.all {
+		return nil
+	}
+	quorums := make([]byte, 0, len(s.quorums))
+	for quorum := range s.quorums {
+		quorums = append(quorums, quorum)
+	}
+	sort.Slice(quorums, func(i, j int) bool { return quorums[i] < quorums[j] })
+	return quorums
+}
+
+func NewEventWatcher(
+	logger sdklogging.Logger,
+	ethClient eth.HttpBackend,
+	avsReader AvsReader,
+	config EventWatcherConfig,
+	prometheusRegistry prometheus.Registerer,
+) (*EventWatcher, error) {
+	if config.MaxBlockRange == 0 {
+		config.MaxBlockRange = defaultEventQueryBlockRange
+	}
+	if config.ReaderTimeout == 0 {
+		config.ReaderTimeout = defaultEventReaderTimeout
+	}
+	delegationManager, err := delegationmanager.NewContractDelegationManagerFilterer(config.DelegationManagerAddr, ethClient)
+	if err != nil {
+		return nil, err
+	}
+	registryCoordinator, err := regcoord.NewContractRegistryCoordinatorFilterer(config.RegistryCoordinatorAddr, ethClient)
+	if err != nil {
+		return nil, err
+	}
+	stakeRegistry, err := stakeregistry.NewContractStakeRegistryFilterer(config.StakeRegistryAddr, ethClient)
+	if err != nil {
+		return nil, err
+	}
+
+	eventNames := make(map[common.Hash]string)
+	abis := []*bind.MetaData{
+		delegationmanager.ContractDelegationManagerMetaData,
+		regcoord.ContractRegistryCoordinatorMetaData,
+		stakeregistry.ContractStakeRegistryMetaData,
+	}
+	watchedEvents := [][]string{
+		{"OperatorSharesIncreased", "OperatorSharesDecreased", "OperatorSharesSlashed"},
+		{"OperatorRegistered", "OperatorDeregistered"},
+		{"StrategyAddedToQuorum", "StrategyRemovedFromQuorum", "StrategyMultiplierUpdated"},
+	}
+	for i, metaData := range abis {
+		parsed, err := metaData.GetAbi()
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range watchedEvents[i] {
+			event, ok := parsed.Events[name]
+			if !ok {
+				// e.g. OperatorSharesSlashed doesn't exist on pre-slashing deployments, which is fine
+				continue
+			}
+			eventNames[event.ID] = name
+		}
+	}
+
+	operators := make(map[common.Address]bool)
+	for _, operator := range config.Operators {
+		operators[operator] = true
+	}
+
+	w := &EventWatcher{
+		logger:              logger,
+		ethClient:           ethClient,
+		avsReader:           avsReader,
+		config:              config,
+		operators:           operators,
+		delegationManager:   delegationManager,
+		registryCoordinator: registryCoordinator,
+		stakeRegistry:       stakeRegistry,
+		eventNames:          eventNames,
+		triggered:           make(chan struct{}, 1),
+		eventsTotal: promauto.With(prometheusRegistry).NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "events_total",
+			Help:      "The total number of processed events that can change operator stakes, by event name",
+		}, []string{"event"}),
+		lastProcessedBlock: promauto.With(prometheusRegistry).NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "last_processed_event_block",
+			Help:      "The last block whose events were polled by the event watcher",
+		}),
+	}
+
+	lastSyncedBlock, found, err := w.readLastSyncedBlock()
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		w.lastPolledBlock = lastSyncedBlock
+		logger.Info("Resuming event processing from persisted block", "block", lastSyncedBlock)
+	} else if config.StartBlock > 0 {
+		w.lastPolledBlock = config.StartBlock - 1
+	}
+	return w, nil
+}
+
+// Triggered is notified every time quorums are ready to be synced, which can then be fetched with TakeTriggeredQuorums
+func (w *EventWatcher) Triggered() <-chan struct{} {
+	return w.triggered
+}
+
+// TakeTriggeredQuorums returns (and clears) the quorums affected by the events seen so far,
+// as well as the block up to which these events were processed, which should be passed to MarkSynced once the quorums are synced.
+// A nil quorums list means all quorums should be synced.
+func (w *EventWatcher) TakeTriggeredQuorums() ([]byte, uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	quorums := w.ready.list()
+	w.ready = quorumSet{}
+	w.syncInFlight = true
+	return quorums, w.readyUpToBlock
+}
+
+// MarkSynced persists that the events up to (and including) block have been synced,
+// so that they are not processed again after a restart
+func (w *EventWatcher) MarkSynced(block uint64) {
+	w.mu.Lock()
+	w.syncInFlight = false
+	w.mu.Unlock()
+	w.persistLastSyncedBlock(block)
+}
+
+// Requeue hands quorums taken with TakeTriggeredQuorums back to the watcher when their sync failed or didn't run,
+// so that they are synced again. Triggered is notified after the poll interval, so that a failing sync isn't
+// retried in a tight loop. A nil quorums list means all quorums.
+func (w *EventWatcher) Requeue(quorums []byte, upToBlock uint64) {
+	w.mu.Lock()
+	if quorums == nil {
+		w.ready.all = true
+	} else {
+		w.ready.add(quorums...)
+	}
+	// events seen since the quorums were taken are ready up to a later block
+	w.readyUpToBlock = max(w.readyUpToBlock, upToBlock)
+	w.syncInFlight = false
+	w.mu.Unlock()
+	time.AfterFunc(w.config.PollInterval, w.retrigger)
+}
+
+// retrigger notifies Triggered again if quorums are ready to be synced, e.g. after they were left untaken while syncs were paused
+func (w *EventWatcher) retrigger() {
+	w.mu.Lock()
+	ready := !w.ready.empty()
+	w.mu.Unlock()
+	if !ready {
+		return
+	}
+	select {
+	case w.triggered <- struct{}{}:
+	default:
+	}
+}
+
+func (w *EventWatcher) persistLastSyncedBlock(block uint64) {
+	if err := w.writeLastSyncedBlock(block); err != nil {
+		w.logger.Error("Error persisting last synced event block", "err", err, "block", block)
+	}
+}
+
+func (w *EventWatcher) Start(ctx context.Context) {
+	w.logger.Info("Starting event watcher",
+		"pollInterval", w.config.PollInterval,
+		"debounce", w.config.Debounce,
+		"confirmations", w.config.Confirmations,
+		"events", w.eventNames,
+	)
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+	var debounceTimer *time.Timer
+	var debounceC <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			if debounceTimer != nil {
+				debounceTimer.Stop()
+			}
+			return
+		case <-ticker.C:
+			affected, err := w.poll(ctx)
+			if err != nil {
+				w.logger.Warn("Error polling for events", "err", err)
+				continue
+			}
+			w.pending.merge(affected)
+			w.pendingUpToBlock = w.lastPolledBlock
+			if w.pending.empty() {
+				// if no events are waiting to be synced, it's safe to skip the polled blocks after a restart
+				w.mu.Lock()
+				idle := w.ready.empty() && !w.syncInFlight
+				w.mu.Unlock()
+				if idle {
+					w.persistLastSyncedBlock(w.lastPolledBlock)
+				}
+			} else if debounceC == nil {
+				debounceTimer = time.NewTimer(w.config.Debounce)
+				debounceC = debounceTimer.C
+			}
+		case <-debounceC:
+			debounceTimer, debounceC = nil, nil
+			w.mu.Lock()
+			w.ready.merge(w.pending)
+			w.readyUpToBlock = w.pendingUpToBlock
+			w.mu.Unlock()
+			w.pending = quorumSet{}
+			select {
+			case w.triggered <- struct{}{}:
+			default:
+				// a sync is already pending, it will pick up these quorums too
+			}
+		}
+	}
+}
+
+// poll processes the logs of the blocks between the last polled block and the latest confirmed block,
+// and returns the quorums affected by them
+func (w *EventWatcher) poll(ctx context.Context) (quorumSet, error) {
+	var affected quorumSet
+	timeoutCtx, cancel := context.WithTimeout(ctx, w.config.ReaderTimeout)
+	head, err := w.ethClient.BlockNumber(timeoutCtx)
+	cancel()
+	if err != nil {
+		return affected, fmt.Errorf("cannot get current block number: %w", err)
+	}
+	if head < w.config.Confirmations {
+		return affected, nil
+	}
+	confirmedHead := head - w.config.Confirmations
+	if w.lastPolledBlock == 0 && w.config.StartBlock == 0 {
+		// nothing persisted and no start block given, so we start from the current block
+		w.lastPolledBlock = confirmedHead
+		w.lastProcessedBlock.Set(float64(confirmedHead))
+		return affected, nil
+	}
+
+	topics := make([]common.Hash, 0, len(w.eventNames))
+	for id := range w.eventNames {
+		topics = append(topics, id)
+	}
+	for w.lastPolledBlock < confirmedHead {
+		fromBlock := w.lastPolledBlock + 1
+		toBlock := min(confirmedHead, fromBlock+w.config.MaxBlockRange-1)
+		timeoutCtx, cancel := context.WithTimeout(ctx, w.config.ReaderTimeout)
+		logs, err := w.ethClient.FilterLogs(timeoutCtx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(fromBlock),
+			ToBlock:   new(big.Int).SetUint64(toBlock),
+			Addresses: []common.Address{w.config.DelegationManagerAddr, w.config.RegistryCoordinatorAddr, w.config.StakeRegistryAddr},
+			Topics:    [][]common.Hash{topics},
+		})
+		cancel()
+		if err != nil {
+			return affected, fmt.Errorf("cannot filter logs from block %d to %d: %w", fromBlock, toBlock, err)
+		}
+		for _, log := range logs {
+			quorums, err := w.affectedQuorums(ctx, log)
+			if err != nil {
+				// we'd rather sync too much than miss an update
+				w.logger.Warn("Error determining quorums affected by event, syncing all quorums", "err", err, "txHash", log.TxHash.Hex())
+				quorums = quorumSet{all: true}
+			}
+			affected.merge(quorums)
+		}
+		w.lastPolledBlock = toBlock
+		w.lastProcessedBlock.Set(float64(toBlock))
+	}
+	if !affected.empty() {
+		w.logger.Info("Events affecting stakes found", "upToBlock", w.lastPolledBlock, "allQuorums", affected.all, "quorums", convertQuorumsBytesToInts(affected.list()))
+	}
+	return affected, nil
+}
+
+func (w *EventWatcher) affectedQuorums(ctx context.Context, log gethtypes.Log) (quorumSet, error) {
+	var affected quorumSet
+	if len(log.Topics) == 0 || log.Removed {
+		return affected, nil
+	}
+	name, ok := w.eventNames[log.Topics[0]]
+	if !ok {
+		return affected, nil
+	}
+
+	var operator common.Address
+	switch name {
+	case "OperatorSharesIncreased":
+		event, err := w.delegationManager.ParseOperatorSharesIncreased(log)
+		if err != nil {
+			return affected, err
+		}
+		operator = event.Operator
+	case "OperatorSharesDecreased":
+		event, err := w.delegationManager.ParseOperatorSharesDecreased(log)
+		if err != nil {
+			return affected, err
+		}
+		operator = event.Operator
+	case "OperatorSharesSlashed":
+		event, err := w.delegationManager.ParseOperatorSharesSlashed(log)
+		if err != nil {
+			return affected, err
+		}
+		operator = event.Operator
+	case "OperatorRegistered":
+		event, err := w.registryCoordinator.ParseOperatorRegistered(log)
+		if err != nil {
+			return affected, err
+		}
+		operator = event.Operator
+	case "OperatorDeregistered":
+		event, err := w.registryCoordinator.ParseOperatorDeregistered(log)
+		if err != nil {
+			return affected, err
+		}
+		if !w.isWatchedOperator(event.Operator) {
+			return affected, nil
+		}
+		w.eventsTotal.WithLabelValues(name).Inc()
+		// the operator might not be registered in any quorum anymore, so we can't tell which quorums it left
+		return quorumSet{all: true}, nil
+	case "StrategyAddedToQuorum":
+		event, err := w.stakeRegistry.ParseStrategyAddedToQuorum(log)
+		if err != nil {
+			return affected, err
+		}
+		affected.add(event.QuorumNumber)
+	case "StrategyRemovedFromQuorum":
+		event, err := w.stakeRegistry.ParseStrategyRemovedFromQuorum(log)
+		if err != nil {
+			return affected, err
+		}
+		affected.add(event.QuorumNumber)
+	case "StrategyMultiplierUpdated":
+		event, err := w.stakeRegistry.ParseStrategyMultiplierUpdated(log)
+		if err != nil {
+			return affected, err
+		}
+		affected.add(event.QuorumNumber)
+	}
+
+	if operator != (common.Address{}) {
+		if !w.isWatchedOperator(operator) {
+			return affected, nil
+		}
+		quorums, err := w.quorumsOfOperator(ctx, operator)
+		if err != nil {
+			return affected, err
+		}
+		// most share changes are for operators that are not registered with this avs
+		if len(quorums) == 0 {
+			return affected, nil
+		}
+		affected.add(quorums...)
+	}
+	w.eventsTotal.WithLabelValues(name).Inc()
+	return affected, nil
+}
+
+func (w *EventWatcher) isWatchedOperator(operator common.Address) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.operators) == 0 || w.operators[operator]
+}
+
+// SetOperators replaces the operators whose share changes and registrations trigger syncs, empty meaning all operators
+func (w *EventWatcher) SetOperators(operators []common.Address) {
+	operatorSet := make(map[common.Address]bool)
+	for _, operator := range operators {
+		operatorSet[operator] = true
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.operators = operatorSet
+}
+
+// quorumsOfOperator returns the quorums the operator is currently registered in
+func (w *EventWatcher) quorumsOfOperator(ctx context.Context, operator common.Address) ([]byte, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, w.config.ReaderTimeout)
+	defer cancel()
+	opts := &bind.CallOpts{Context: timeoutCtx}
+	operatorId, err := w.avsReader.GetOperatorId(opts, operator)
+	if err != nil {
+		return nil, err
+	}
+	if operatorId == [32]byte{} {
+		// operator never registered with this avs
+		return nil, nil
+	}
+	stakePerQuorum, err := w.avsReader.GetOperatorStakeInQuorumsOfOperatorAtCurrentBlock(opts, operatorId)
+	if err != nil {
+		return nil, err
+	}
+	var quorums []byte
+	for quorum := range stakePerQuorum {
+		quorums = append(quorums, byte(quorum))
+	}
+	return quorums, nil
+}
+
+func (w *EventWatcher) readLastSyncedBlock() (uint64, bool, error) {
+	if w.config.StateStore != nil {
+		block, found, err := w.config.StateStore.LastEventBlock()
+		if err != nil {
+			return 0, false, fmt.Errorf("cannot read last event block from state file: %w", err)
+		}
+		return block, found, nil
+	}
+	if w.config.StateFilePath == "" {
+		return 0, false, nil
+	}
+	data, err := os.ReadFile(w.config.StateFilePath)
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, fmt.Errorf("cannot read event state file: %w", err)
+	}
+	block, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid event state file %s: %w", w.config.StateFilePath, err)
+	}
+	return block, true, nil
+}
+
+func (w *EventWatcher) writeLastSyncedBlock(block uint64) error {
+	if w.config.StateStore != nil {
+		return w.config.StateStore.SetLastEventBlock(block)
+	}
+	if w.config.StateFilePath == "" {
+		return nil
+	}
+	// write to a temporary file first and then rename it, so that a crash never leaves a partially written file
+	tmpFile := filepath.Join(filepath.Dir(w.config.StateFilePath), "."+filepath.Base(w.config.StateFilePath)+".tmp")
+	if err := os.WriteFile(tmpFile, []byte(strconv.FormatUint(block, 10)), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, w.config.StateFilePath)
+}
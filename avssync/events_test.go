@@ -0,0 +1,337 @@
+package avssync
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Layr-Labs/eigensdk-go/chainio/clients/eth"
+	delegationmanager "github.com/Layr-Labs/eigensdk-go/contracts/bindings/DelegationManager"
+	regcoord "github.com/Layr-Labs/eigensdk-go/contracts/bindings/RegistryCoordinator"
+	stakeregistry "github.com/Layr-Labs/eigensdk-go/contracts/bindings/StakeRegistry"
+	"github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/Layr-Labs/eigensdk-go/types"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuorumSet(t *testing.T) {
+	var set quorumSet
+	require.True(t, set.empty())
+
+	set.add(2, 0)
+	set.merge(quorumSet{quorums: map[byte]struct{}{1: {}, 2: {}}})
+	require.Equal(t, []byte{0, 1, 2}, set.list())
+
+	set.merge(quorumSet{all: true})
+	require.False(t, set.empty())
+	require.Nil(t, set.list())
+}
+
+func TestEventWatcherLastSyncedBlockPersistence(t *testing.T) {
+	w := &EventWatcher{config: EventWatcherConfig{StateFilePath: filepath.Join(t.TempDir(), "events")}}
+
+	_, found, err := w.readLastSyncedBlock()
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, w.writeLastSyncedBlock(1234))
+	block, found, err := w.readLastSyncedBlock()
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, uint64(1234), block)
+}
+
+var (
+	testDelegationManagerAddr   = common.HexToAddress("0xd0")
+	testRegistryCoordinatorAddr = common.HexToAddress("0xc0")
+	testStakeRegistryAddr       = common.HexToAddress("0x5e")
+)
+
+// fakeEventBackend serves the calls of the event watcher: the logs of a chain, each emitted in its own block,
+// and the quorums the operators are registered in
+type fakeEventBackend struct {
+	eth.HttpBackend
+	AvsReader
+
+	mu      sync.Mutex
+	head    uint64
+	logs    []gethtypes.Log
+	quorums map[common.Address][]byte
+	// reads of the quorums of operators block until their context is done
+	hangReads bool
+}
+
+func newFakeEventBackend() *fakeEventBackend {
+	return &fakeEventBackend{head: 100, quorums: make(map[common.Address][]byte)}
+}
+
+func (b *fakeEventBackend) BlockNumber(ctx context.Context) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.head, nil
+}
+
+func (b *fakeEventBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]gethtypes.Log, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var logs []gethtypes.Log
+	for _, log := range b.logs {
+		if log.BlockNumber >= query.FromBlock.Uint64() && log.BlockNumber <= query.ToBlock.Uint64() &&
+			slices.Contains(query.Addresses, log.Address) && slices.Contains(query.Topics[0], log.Topics[0]) {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+func (b *fakeEventBackend) GetOperatorId(opts *bind.CallOpts, operator common.Address) ([32]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.quorums[operator]; !ok {
+		return [32]byte{}, nil
+	}
+	return crypto.Keccak256Hash(operator.Bytes()), nil
+}
+
+func (b *fakeEventBackend) GetOperatorStakeInQuorumsOfOperatorAtCurrentBlock(opts *bind.CallOpts, operatorId types.OperatorId) (map[types.QuorumNum]types.StakeAmount, error) {
+	b.mu.Lock()
+	hang := b.hangReads
+	b.mu.Unlock()
+	if hang {
+		<-opts.Context.Done()
+		return nil, opts.Context.Err()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stakes := make(map[types.QuorumNum]types.StakeAmount)
+	for operator, quorums := range b.quorums {
+		if types.OperatorId(crypto.Keccak256Hash(operator.Bytes())) != operatorId {
+			continue
+		}
+		for _, quorum := range quorums {
+			stakes[types.QuorumNum(quorum)] = big.NewInt(1000)
+		}
+	}
+	return stakes, nil
+}
+
+// emit mines a block with the event name of the contract at address, whose indexed arguments are topics
+func (b *fakeEventBackend) emit(address common.Address, metaData *bind.MetaData, name string, topics []common.Hash, args ...any) {
+	parsed, err := metaData.GetAbi()
+	if err != nil {
+		panic(err)
+	}
+	event := parsed.Events[name]
+	data, err := event.Inputs.NonIndexed().Pack(args...)
+	if err != nil {
+		panic(err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.head++
+	b.logs = append(b.logs, gethtypes.Log{
+		Address:     address,
+		Topics:      append([]common.Hash{event.ID}, topics...),
+		Data:        data,
+		BlockNumber: b.head,
+	})
+}
+
+func (b *fakeEventBackend) sharesIncreased(operator common.Address) {
+	b.emit(testDelegationManagerAddr, delegationmanager.ContractDelegationManagerMetaData, "OperatorSharesIncreased",
+		[]common.Hash{common.BytesToHash(operator.Bytes())}, operator, common.Address{}, big.NewInt(1))
+}
+
+func (b *fakeEventBackend) registerOperator(operator common.Address, quorums ...byte) {
+	b.mu.Lock()
+	b.quorums[operator] = append(b.quorums[operator], quorums...)
+	b.mu.Unlock()
+	b.emit(testRegistryCoordinatorAddr, regcoord.ContractRegistryCoordinatorMetaData, "OperatorRegistered",
+		[]common.Hash{common.BytesToHash(operator.Bytes()), crypto.Keccak256Hash(operator.Bytes())})
+}
+
+func (b *fakeEventBackend) deregisterOperator(operator common.Address) {
+	b.mu.Lock()
+	b.quorums[operator] = nil
+	b.mu.Unlock()
+	b.emit(testRegistryCoordinatorAddr, regcoord.ContractRegistryCoordinatorMetaData, "OperatorDeregistered",
+		[]common.Hash{common.BytesToHash(operator.Bytes()), crypto.Keccak256Hash(operator.Bytes())})
+}
+
+func (b *fakeEventBackend) strategyMultiplierUpdated(quorum byte) {
+	b.emit(testStakeRegistryAddr, stakeregistry.ContractStakeRegistryMetaData, "StrategyMultiplierUpdated",
+		[]common.Hash{common.BigToHash(big.NewInt(int64(quorum)))}, common.HexToAddress("0xa"), big.NewInt(2))
+}
+
+// newTestEventWatcher returns an event watcher of backend processing the events emitted from now on
+func newTestEventWatcher(t *testing.T, backend *fakeEventBackend, config EventWatcherConfig) *EventWatcher {
+	head, err := backend.BlockNumber(context.Background())
+	require.NoError(t, err)
+	config.DelegationManagerAddr = testDelegationManagerAddr
+	config.RegistryCoordinatorAddr = testRegistryCoordinatorAddr
+	config.StakeRegistryAddr = testStakeRegistryAddr
+	config.StartBlock = head + 1
+	config.PollInterval = 10 * time.Millisecond
+	logger := logging.NewTextSLogger(os.Stderr, &logging.SLoggerOptions{})
+	w, err := NewEventWatcher(logger, backend, backend, config, prometheus.NewRegistry())
+	require.NoError(t, err)
+	return w
+}
+
+func startEventWatcher(t *testing.T, w *EventWatcher) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go w.Start(ctx)
+}
+
+// requireTriggered waits for the event watcher to trigger, and takes the triggered quorums
+func requireTriggered(t *testing.T, w *EventWatcher) ([]byte, uint64) {
+	select {
+	case <-w.Triggered():
+		return w.TakeTriggeredQuorums()
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "event watcher didn't trigger")
+		return nil, 0
+	}
+}
+
+func requireNotTriggered(t *testing.T, w *EventWatcher) {
+	select {
+	case <-w.Triggered():
+		require.FailNow(t, "event watcher triggered")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestEventWatcher(t *testing.T) {
+	operator1 := common.HexToAddress("0x1")
+	operator2 := common.HexToAddress("0x2")
+	// operator1 is registered in quorum 0, and operator2 in quorums 1 and 2
+	newBackend := func() *fakeEventBackend {
+		backend := newFakeEventBackend()
+		backend.registerOperator(operator1, 0)
+		return backend
+	}
+
+	t.Run("share changes and registrations", func(t *testing.T) {
+		backend := newBackend()
+		// one eth_getLogs call per block
+		w := newTestEventWatcher(t, backend, EventWatcherConfig{MaxBlockRange: 1})
+		backend.sharesIncreased(operator1)
+		backend.registerOperator(operator2, 1, 2)
+		// share changes of operators that aren't registered with the avs don't affect any quorum
+		backend.sharesIncreased(common.HexToAddress("0x3"))
+		startEventWatcher(t, w)
+
+		quorums, upToBlock := requireTriggered(t, w)
+		require.Equal(t, []byte{0, 1, 2}, quorums)
+		require.Equal(t, backend.head, upToBlock)
+	})
+
+	t.Run("strategy multiplier", func(t *testing.T) {
+		backend := newBackend()
+		w := newTestEventWatcher(t, backend, EventWatcherConfig{})
+		backend.strategyMultiplierUpdated(3)
+		startEventWatcher(t, w)
+
+		quorums, _ := requireTriggered(t, w)
+		require.Equal(t, []byte{3}, quorums)
+	})
+
+	t.Run("deregistration syncs all quorums", func(t *testing.T) {
+		backend := newBackend()
+		w := newTestEventWatcher(t, backend, EventWatcherConfig{})
+		backend.deregisterOperator(operator1)
+		startEventWatcher(t, w)
+
+		quorums, _ := requireTriggered(t, w)
+		require.Nil(t, quorums)
+	})
+
+	t.Run("hung read of the quorums of an operator", func(t *testing.T) {
+		backend := newBackend()
+		backend.hangReads = true
+		w := newTestEventWatcher(t, backend, EventWatcherConfig{ReaderTimeout: 50 * time.Millisecond})
+		backend.sharesIncreased(operator1)
+		startEventWatcher(t, w)
+
+		// the read times out, and all quorums are synced rather than missing an update
+		quorums, _ := requireTriggered(t, w)
+		require.Nil(t, quorums)
+	})
+
+	t.Run("watched operators", func(t *testing.T) {
+		backend := newBackend()
+		w := newTestEventWatcher(t, backend, EventWatcherConfig{Operators: []common.Address{operator2}})
+		backend.sharesIncreased(operator1)
+		backend.registerOperator(operator2, 1)
+		startEventWatcher(t, w)
+
+		quorums, _ := requireTriggered(t, w)
+		require.Equal(t, []byte{1}, quorums)
+	})
+
+	t.Run("confirmations", func(t *testing.T) {
+		backend := newBackend()
+		w := newTestEventWatcher(t, backend, EventWatcherConfig{Confirmations: 1})
+		backend.sharesIncreased(operator1)
+		startEventWatcher(t, w)
+		requireNotTriggered(t, w)
+
+		// the next block confirms the share change, but isn't confirmed itself
+		backend.strategyMultiplierUpdated(3)
+		quorums, upToBlock := requireTriggered(t, w)
+		require.Equal(t, []byte{0}, quorums)
+		require.Equal(t, backend.head-1, upToBlock)
+	})
+
+	t.Run("debounce", func(t *testing.T) {
+		backend := newBackend()
+		w := newTestEventWatcher(t, backend, EventWatcherConfig{Debounce: 500 * time.Millisecond})
+		startEventWatcher(t, w)
+		backend.sharesIncreased(operator1)
+		time.Sleep(100 * time.Millisecond)
+		backend.strategyMultiplierUpdated(3)
+
+		// both events, polled separately, trigger a single sync
+		quorums, upToBlock := requireTriggered(t, w)
+		require.Equal(t, []byte{0, 3}, quorums)
+		require.Equal(t, backend.head, upToBlock)
+		requireNotTriggered(t, w)
+	})
+
+	t.Run("requeue and mark synced", func(t *testing.T) {
+		backend := newBackend()
+		stateFile := filepath.Join(t.TempDir(), "events")
+		w := newTestEventWatcher(t, backend, EventWatcherConfig{StateFilePath: stateFile})
+		backend.sharesIncreased(operator1)
+		startEventWatcher(t, w)
+
+		quorums, upToBlock := requireTriggered(t, w)
+		w.Requeue(quorums, upToBlock)
+		requeuedQuorums, requeuedUpToBlock := requireTriggered(t, w)
+		require.Equal(t, quorums, requeuedQuorums)
+		require.Equal(t, upToBlock, requeuedUpToBlock)
+		// the events of requeued quorums aren't synced yet, so the polled blocks aren't persisted
+		_, found, err := w.readLastSyncedBlock()
+		require.NoError(t, err)
+		require.False(t, found)
+
+		w.MarkSynced(upToBlock)
+		block, found, err := w.readLastSyncedBlock()
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, upToBlock, block)
+	})
+}
@@ -0,0 +1,146 @@
+package avssync
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/Layr-Labs/eigensdk-go/chainio/txmgr"
+	regcoord "github.com/Layr-Labs/eigensdk-go/contracts/bindings/RegistryCoordinator"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+type gasEstimatorBackend interface {
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+	EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error)
+	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*gethtypes.Header, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (tx *gethtypes.Transaction, isPending bool, err error)
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+}
+
+// GasEstimator estimates the gas needed by the stake update transactions sent to the RegistryCoordinator,
+// the same way the txmgr does before sending them.
+type GasEstimator struct {
+	client                  gasEstimatorBackend
+	registryCoordinatorAddr common.Address
+	sender                  common.Address
+	registryCoordinatorAbi  *abi.ABI
+	revertErrors            map[[4]byte]abi.Error
+}
+
+func NewGasEstimator(client gasEstimatorBackend, registryCoordinatorAddr common.Address, sender common.Address) (*GasEstimator, error) {
+	registryCoordinatorAbi, err := regcoord.ContractRegistryCoordinatorMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	revertErrors, err := revertErrors()
+	if err != nil {
+		return nil, err
+	}
+	return &GasEstimator{
+		client:                  client,
+		registryCoordinatorAddr: registryCoordinatorAddr,
+		sender:                  sender,
+		registryCoordinatorAbi:  registryCoordinatorAbi,
+		revertErrors:            revertErrors,
+	}, nil
+}
+
+// EstimateUpdateOperatorsForQuorum estimates the gas used by updating the stakes of the entire operator set of a quorum
+func (g *GasEstimator) EstimateUpdateOperatorsForQuorum(ctx context.Context, operators []common.Address, quorum byte) (uint64, error) {
+	data, err := g.registryCoordinatorAbi.Pack("updateOperatorsForQuorum", [][]common.Address{operators}, []byte{quorum})
+	if err != nil {
+		return 0, err
+	}
+	return g.estimate(ctx, data)
+}
+
+// EstimateUpdateOperators estimates the gas used by updating the stakes of a subset of operators in all their quorums
+func (g *GasEstimator) EstimateUpdateOperators(ctx context.Context, operators []common.Address) (uint64, error) {
+	data, err := g.registryCoordinatorAbi.Pack("updateOperators", operators)
+	if err != nil {
+		return 0, err
+	}
+	return g.estimate(ctx, data)
+}
+
+// SimulateUpdateOperatorsForQuorum runs the entire operator set update of a quorum with eth_call, without sending it,
+// and returns its gas estimate. It returns an error if the update would revert.
+func (g *GasEstimator) SimulateUpdateOperatorsForQuorum(ctx context.Context, operators []common.Address, quorum byte) (uint64, error) {
+	data, err := g.registryCoordinatorAbi.Pack("updateOperatorsForQuorum", [][]common.Address{operators}, []byte{quorum})
+	if err != nil {
+		return 0, err
+	}
+	return g.simulate(ctx, data)
+}
+
+// SimulateUpdateOperators runs the operator subset update with eth_call, without sending it,
+// and returns its gas estimate. It returns an error if the update would revert.
+func (g *GasEstimator) SimulateUpdateOperators(ctx context.Context, operators []common.Address) (uint64, error) {
+	data, err := g.registryCoordinatorAbi.Pack("updateOperators", operators)
+	if err != nil {
+		return 0, err
+	}
+	return g.simulate(ctx, data)
+}
+
+func (g *GasEstimator) callMsg(data []byte) ethereum.CallMsg {
+	return ethereum.CallMsg{
+		From: g.sender,
+		To:   &g.registryCoordinatorAddr,
+		Data: data,
+	}
+}
+
+func (g *GasEstimator) estimate(ctx context.Context, data []byte) (uint64, error) {
+	return g.client.EstimateGas(ctx, g.callMsg(data))
+}
+
+func (g *GasEstimator) simulate(ctx context.Context, data []byte) (uint64, error) {
+	if _, err := g.client.CallContract(ctx, g.callMsg(data), nil); err != nil {
+		return 0, fmt.Errorf("eth_call failed: %w", err)
+	}
+	return g.estimate(ctx, data)
+}
+
+// GasPrice returns the gas price a transaction sent now is expected to pay, i.e. the latest base fee plus the suggested tip.
+// Note that the txmgr sets a fee cap of twice the base fee plus the tip, so the actual price can be higher if the base fee rises.
+func (g *GasEstimator) GasPrice(ctx context.Context) (*big.Int, error) {
+	header, err := g.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch latest block header: %w", err)
+	}
+	gasTipCap, err := g.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch suggested gas tip cap: %w", err)
+	}
+	if header.BaseFee == nil {
+		return gasTipCap, nil
+	}
+	return new(big.Int).Add(header.BaseFee, gasTipCap), nil
+}
+
+// FitsInBlock returns whether a transaction with the given gas estimate fits in the latest block,
+// taking into account the buffer the txmgr adds on top of the estimate
+func (g *GasEstimator) FitsInBlock(ctx context.Context, gasEstimate uint64) (bool, uint64, error) {
+	header, err := g.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return false, 0, fmt.Errorf("cannot fetch latest block header: %w", err)
+	}
+	gasLimit := uint64(float64(gasEstimate) * txmgr.FallbackGasLimitMultiplier)
+	return gasLimit <= header.GasLimit, header.GasLimit, nil
+}
+
+// isGasLimitError returns whether the error returned by eth_estimateGas means that the transaction
+// can't fit in a block, as opposed to reverting for another reason
+func isGasLimitError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "out of gas") ||
+		strings.Contains(msg, "gas required exceeds") ||
+		strings.Contains(msg, "exceeds block gas limit")
+}
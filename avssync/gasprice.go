@@ -0,0 +1,212 @@
+package avssync
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	walletsdk "github.com/Layr-Labs/eigensdk-go/chainio/clients/wallet"
+	sdklogging "github.com/Layr-Labs/eigensdk-go/logging"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// once the defer window is over, send at the capped fees, even though the transaction may not be included right away
+	GasPriceDeferActionSendAtCap = "send-at-cap"
+	// once the defer window is over, give up on the sync, which is recorded with the deferred_due_to_gas status
+	GasPriceDeferActionSkip = "skip"
+)
+
+// GasFeeCaps caps the fees of the stake update transactions, a nil cap means no cap
+type GasFeeCaps struct {
+	MaxFeePerGas         *big.Int
+	MaxPriorityFeePerGas *big.Int
+}
+
+// GasPriceDeferConfig is how syncs wait for the gas price to come back under GasFeeCaps.MaxFeePerGas before sending
+type GasPriceDeferConfig struct {
+	MaxFeePerGas *big.Int // nil disables deferring
+	// how long to wait for the gas price to come down, 0 means the sync isn't deferred
+	Window          time.Duration
+	RecheckInterval time.Duration
+	// GasPriceDeferActionSendAtCap or GasPriceDeferActionSkip
+	Action string
+}
+
+// gweiToWei returns nil (no cap) for 0
+func gweiToWei(gwei float64) *big.Int {
+	if gwei == 0 {
+		return nil
+	}
+	wei, _ := new(big.Float).Mul(big.NewFloat(gwei), big.NewFloat(params.GWei)).Int(nil)
+	return wei
+}
+
+func weiToGwei(wei *big.Int) float64 {
+	gwei, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.GWei)).Float64()
+	return gwei
+}
+
+// feeCappingWallet caps the fees set by the txmgr on the transactions it sends, and exports the fees of the
+// transactions and the gas price they actually paid
+type feeCappingWallet struct {
+	walletsdk.Wallet
+	caps   GasFeeCaps
+	logger sdklogging.Logger
+
+	maxFeePerGas         prometheus.Gauge
+	maxPriorityFeePerGas prometheus.Gauge
+	effectiveGasPrice    prometheus.Gauge
+}
+
+// NewFeeCappingWallet wraps wallet so that the fees of the transactions it sends don't exceed caps.
+// The txmgr sets a fee cap of twice the base fee plus the suggested tip, which lowering only affects how much
+// the base fee can rise before the transaction stops being includable.
+func NewFeeCappingWallet(wallet walletsdk.Wallet, caps GasFeeCaps, logger sdklogging.Logger, prometheusRegistry prometheus.Registerer) walletsdk.Wallet {
+	return &feeCappingWallet{
+		Wallet: wallet,
+		caps:   caps,
+		logger: logger,
+		maxFeePerGas: promauto.With(prometheusRegistry).NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "tx_max_fee_per_gas_gwei",
+			Help:      "Max fee per gas of the last transaction sent, after applying the cap",
+		}),
+		maxPriorityFeePerGas: promauto.With(prometheusRegistry).NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "tx_max_priority_fee_per_gas_gwei",
+			Help:      "Max priority fee per gas of the last transaction sent, after applying the cap",
+		}),
+		effectiveGasPrice: promauto.With(prometheusRegistry).NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "tx_effective_gas_price_gwei",
+			Help:      "Gas price actually paid by the last transaction mined, from its receipt",
+		}),
+	}
+}
+
+func (w *feeCappingWallet) SendTransaction(ctx context.Context, tx *gethtypes.Transaction) (walletsdk.TxID, error) {
+	gasFeeCap, gasTipCap := tx.GasFeeCap(), tx.GasTipCap()
+	capped := false
+	if w.caps.MaxFeePerGas != nil && gasFeeCap.Cmp(w.caps.MaxFeePerGas) > 0 {
+		gasFeeCap, capped = w.caps.MaxFeePerGas, true
+	}
+	if w.caps.MaxPriorityFeePerGas != nil && gasTipCap.Cmp(w.caps.MaxPriorityFeePerGas) > 0 {
+		gasTipCap, capped = w.caps.MaxPriorityFeePerGas, true
+	}
+	if gasTipCap.Cmp(gasFeeCap) > 0 {
+		gasTipCap = gasFeeCap
+	}
+	if capped {
+		tx = gethtypes.NewTx(&gethtypes.DynamicFeeTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  gasTipCap,
+			GasFeeCap:  gasFeeCap,
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: tx.AccessList(),
+		})
+	}
+	w.logger.Info("Sending transaction", "maxFeePerGasGwei", weiToGwei(gasFeeCap), "maxPriorityFeePerGasGwei", weiToGwei(gasTipCap), "capped", capped)
+	w.maxFeePerGas.Set(weiToGwei(gasFeeCap))
+	w.maxPriorityFeePerGas.Set(weiToGwei(gasTipCap))
+	return w.Wallet.SendTransaction(ctx, tx)
+}
+
+func (w *feeCappingWallet) GetTransactionReceipt(ctx context.Context, txID walletsdk.TxID) (*gethtypes.Receipt, error) {
+	receipt, err := w.Wallet.GetTransactionReceipt(ctx, txID)
+	if err == nil && receipt != nil && receipt.EffectiveGasPrice != nil {
+		w.logger.Info("Transaction mined", "txHash", receipt.TxHash.Hex(), "effectiveGasPriceGwei", weiToGwei(receipt.EffectiveGasPrice), "gasUsed", receipt.GasUsed)
+		w.effectiveGasPrice.Set(weiToGwei(receipt.EffectiveGasPrice))
+	}
+	return receipt, err
+}
+
+// waitForGasPrice waits (for at most the defer window) until the gas price a transaction would pay is at most the max fee
+// per gas, and returns false if the sync must be given up because it isn't
+func (a *AvsSync) waitForGasPrice(ctx context.Context) bool {
+	config := a.gasPriceDeferConfig
+	if config.MaxFeePerGas == nil || config.Window <= 0 || a.gasEstimator == nil {
+		return true
+	}
+	deadline := time.Now().Add(config.Window)
+	for {
+		timeoutCtx, cancel := context.WithTimeout(ctx, a.readerTimeoutDuration)
+		gasPrice, err := a.gasEstimator.GasPrice(timeoutCtx)
+		cancel()
+		if err != nil {
+			// not knowing the gas price doesn't mean it is too high
+			a.logger.Warn("Error fetching gas price, not deferring sync", "err", err)
+			return true
+		}
+		if gasPrice.Cmp(config.MaxFeePerGas) <= 0 {
+			return true
+		}
+		if !time.Now().Add(config.RecheckInterval).Before(deadline) {
+			if config.Action == GasPriceDeferActionSendAtCap {
+				a.logger.Warn("Gas price still above max fee per gas at the end of the defer window, sending at the cap",
+					"gasPriceGwei", weiToGwei(gasPrice), "maxFeePerGasGwei", weiToGwei(config.MaxFeePerGas))
+				return true
+			}
+			a.logger.Warn("Gas price still above max fee per gas at the end of the defer window, giving up on sync",
+				"gasPriceGwei", weiToGwei(gasPrice), "maxFeePerGasGwei", weiToGwei(config.MaxFeePerGas))
+			return false
+		}
+		a.logger.Info("Gas price above max fee per gas, deferring sync",
+			"gasPriceGwei", weiToGwei(gasPrice), "maxFeePerGasGwei", weiToGwei(config.MaxFeePerGas),
+			"recheckIn", config.RecheckInterval, "deferUntil", deadline)
+		timer := time.NewTimer(config.RecheckInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
+// GasFeeCaps returns the fee caps of the transactions, whose fields are nil when there is no cap
+func (c Config) GasFeeCaps() GasFeeCaps {
+	return GasFeeCaps{
+		MaxFeePerGas:         gweiToWei(c.MaxFeePerGasGwei),
+		MaxPriorityFeePerGas: gweiToWei(c.MaxPriorityFeePerGasGwei),
+	}
+}
+
+func (c Config) gasPriceDeferConfig() GasPriceDeferConfig {
+	return GasPriceDeferConfig{
+		MaxFeePerGas:    gweiToWei(c.MaxFeePerGasGwei),
+		Window:          c.GasPriceDeferWindow,
+		RecheckInterval: c.GasPriceRecheckInterval,
+		Action:          c.GasPriceDeferAction,
+	}
+}
+
+func (c Config) validateGasPrice() []error {
+	var errs []error
+	if c.MaxFeePerGasGwei < 0 || c.MaxPriorityFeePerGasGwei < 0 {
+		errs = append(errs, fmt.Errorf("max-fee-per-gas-gwei and max-priority-fee-per-gas-gwei cannot be negative"))
+	}
+	if c.GasPriceDeferWindow < 0 {
+		errs = append(errs, fmt.Errorf("gas-price-defer-window cannot be negative"))
+	}
+	if c.GasPriceDeferWindow > 0 {
+		if c.MaxFeePerGasGwei == 0 {
+			errs = append(errs, fmt.Errorf("gas-price-defer-window requires max-fee-per-gas-gwei"))
+		}
+		if c.GasPriceRecheckInterval <= 0 {
+			errs = append(errs, fmt.Errorf("gas-price-recheck-interval must be positive"))
+		}
+		if c.GasPriceDeferAction != GasPriceDeferActionSendAtCap && c.GasPriceDeferAction != GasPriceDeferActionSkip {
+			errs = append(errs, fmt.Errorf("gas-price-defer-action must be %s or %s", GasPriceDeferActionSendAtCap, GasPriceDeferActionSkip))
+		}
+	}
+	return errs
+}
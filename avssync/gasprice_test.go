@@ -0,0 +1,86 @@
+package avssync
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"testing"
+
+	walletsdk "github.com/Layr-Labs/eigensdk-go/chainio/clients/wallet"
+	"github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+type sentTxsWallet struct {
+	fakeWallet
+	sent []*gethtypes.Transaction
+}
+
+func (w *sentTxsWallet) SendTransaction(ctx context.Context, tx *gethtypes.Transaction) (walletsdk.TxID, error) {
+	w.sent = append(w.sent, tx)
+	return w.fakeWallet.SendTransaction(ctx, tx)
+}
+
+func gwei(n int64) *big.Int {
+	return new(big.Int).Mul(big.NewInt(n), big.NewInt(params.GWei))
+}
+
+func TestFeeCappingWallet(t *testing.T) {
+	logger := logging.NewTextSLogger(os.Stderr, &logging.SLoggerOptions{})
+	ctx := context.Background()
+	to := common.HexToAddress("0xc0")
+	newTx := func(gasFeeCap, gasTipCap *big.Int) *gethtypes.Transaction {
+		return gethtypes.NewTx(&gethtypes.DynamicFeeTx{Nonce: 7, GasFeeCap: gasFeeCap, GasTipCap: gasTipCap, Gas: 100_000, To: &to, Data: []byte{1, 2}})
+	}
+
+	wallet := &sentTxsWallet{fakeWallet: fakeWallet{receipts: make(map[string]*gethtypes.Receipt)}}
+	reg := prometheus.NewRegistry()
+	cappingWallet := NewFeeCappingWallet(wallet, Config{MaxFeePerGasGwei: 50, MaxPriorityFeePerGasGwei: 2}.GasFeeCaps(), logger, reg)
+
+	// under the caps, the transaction is sent as is
+	tx := newTx(gwei(30), gwei(1))
+	_, err := cappingWallet.SendTransaction(ctx, tx)
+	require.NoError(t, err)
+	require.Equal(t, tx.Hash(), wallet.sent[0].Hash())
+
+	// over the caps, only the fees change
+	_, err = cappingWallet.SendTransaction(ctx, newTx(gwei(100), gwei(5)))
+	require.NoError(t, err)
+	sent := wallet.sent[1]
+	require.Equal(t, gwei(50), sent.GasFeeCap())
+	require.Equal(t, gwei(2), sent.GasTipCap())
+	require.Equal(t, uint64(7), sent.Nonce())
+	require.Equal(t, uint64(100_000), sent.Gas())
+	require.Equal(t, &to, sent.To())
+	require.Equal(t, []byte{1, 2}, sent.Data())
+	require.Equal(t, 50.0, testutil.ToFloat64(cappingWallet.(*feeCappingWallet).maxFeePerGas))
+	require.Equal(t, 2.0, testutil.ToFloat64(cappingWallet.(*feeCappingWallet).maxPriorityFeePerGas))
+
+	// the tip never exceeds the fee cap
+	cappingWallet = NewFeeCappingWallet(wallet, GasFeeCaps{MaxFeePerGas: gwei(3)}, logger, prometheus.NewRegistry())
+	_, err = cappingWallet.SendTransaction(ctx, newTx(gwei(10), gwei(5)))
+	require.NoError(t, err)
+	require.Equal(t, gwei(3), wallet.sent[2].GasTipCap())
+
+	// the effective gas price is exported once the transaction is mined
+	wallet.receipts["0x1"] = &gethtypes.Receipt{EffectiveGasPrice: gwei(12)}
+	_, err = cappingWallet.GetTransactionReceipt(ctx, "0x1")
+	require.NoError(t, err)
+	require.Equal(t, 12.0, testutil.ToFloat64(cappingWallet.(*feeCappingWallet).effectiveGasPrice))
+}
+
+func TestValidateGasPrice(t *testing.T) {
+	require.Empty(t, Config{}.validateGasPrice())
+	require.Empty(t, Config{MaxFeePerGasGwei: 50, GasPriceDeferWindow: 1, GasPriceRecheckInterval: 1, GasPriceDeferAction: GasPriceDeferActionSkip}.validateGasPrice())
+	require.Len(t, Config{MaxFeePerGasGwei: -1}.validateGasPrice(), 1)
+	// deferring needs a max fee per gas, a recheck interval and a valid action
+	require.Len(t, Config{GasPriceDeferWindow: 1, GasPriceDeferAction: "wait"}.validateGasPrice(), 3)
+	require.Equal(t, gwei(1), gweiToWei(1))
+	require.Equal(t, big.NewInt(1_500_000_000), gweiToWei(1.5))
+	require.Nil(t, gweiToWei(0))
+}
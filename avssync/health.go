@@ -0,0 +1,197 @@
+package avssync
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// HealthConfig configures the /healthz and /readyz checks
+type HealthConfig struct {
+	// the loop is considered stuck if a sync runs for longer than this, or if it is this late to start a scheduled sync
+	StuckThreshold time.Duration
+	// not ready if a quorum wasn't synced successfully for this many sync intervals (0 disables this check)
+	MaxMissedSyncs int
+}
+
+// HealthCheck returns nil if healthy, or an error explaining why not
+type HealthCheck func(ctx context.Context) error
+
+type namedHealthCheck struct {
+	name  string
+	check HealthCheck
+}
+
+// CheckResult is the result of a single check, as served in the /healthz and /readyz JSON bodies
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthReport is the JSON body served by /healthz and /readyz
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+const (
+	healthStatusOk   = "ok"
+	healthStatusFail = "fail"
+)
+
+// AddReadinessCheck adds a check to /readyz, in addition to the quorum sync check. It must be called before Start.
+func (a *AvsSync) AddReadinessCheck(name string, check HealthCheck) {
+	a.readinessChecks = append(a.readinessChecks, namedHealthCheck{name: name, check: check})
+}
+
+func (a *AvsSync) livenessChecks() []namedHealthCheck {
+	return []namedHealthCheck{{name: "sync_loop", check: a.checkSyncLoop}}
+}
+
+func (a *AvsSync) allReadinessChecks() []namedHealthCheck {
+	return append([]namedHealthCheck{{name: "quorum_syncs", check: a.checkQuorumSyncs}}, a.readinessChecks...)
+}
+
+// checkSyncLoop fails if a sync has been running for longer than the stuck threshold,
+// or if the loop didn't start the next scheduled sync within the stuck threshold
+func (a *AvsSync) checkSyncLoop(ctx context.Context) error {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	if a.healthConfig.StuckThreshold <= 0 {
+		return nil
+	}
+	now := time.Now()
+	if a.syncing {
+		if syncDuration := now.Sub(a.syncStartTime); syncDuration > a.healthConfig.StuckThreshold {
+			return fmt.Errorf("sync running for %v, which is more than %v", syncDuration.Round(time.Second), a.healthConfig.StuckThreshold)
+		}
+		return nil
+	}
+	if !a.nextSyncTime.IsZero() {
+		if late := now.Sub(a.nextSyncTime); late > a.healthConfig.StuckThreshold {
+			return fmt.Errorf("sync scheduled at %v didn't start after %v", a.nextSyncTime, late.Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// checkQuorumSyncs fails if a quorum wasn't synced successfully (or skipped because its stakes didn't drift enough)
+// during the last MaxMissedSyncs scheduled syncs. Quorums that were never synced are measured from the start of the loop.
+func (a *AvsSync) checkQuorumSyncs(ctx context.Context) error {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	if a.healthConfig.MaxMissedSyncs <= 0 || a.dryRun || !a.isLeader() {
+		// followers don't sync
+		return nil
+	}
+	if a.activeSchedule == nil {
+		// the loop didn't start yet
+		return nil
+	}
+	now := time.Now()
+	var stale []int
+	for _, quorum := range a.quorums {
+		lastSuccess := a.quorumSyncs[quorum].LastSuccessTime
+		if lastSuccess.IsZero() {
+			lastSuccess = a.loopStartTime
+		}
+		// we give the last missed sync StuckThreshold to complete
+		deadline := nthSyncAfter(a.activeSchedule, lastSuccess, a.healthConfig.MaxMissedSyncs)
+		if !deadline.IsZero() && now.After(deadline.Add(a.healthConfig.StuckThreshold)) {
+			stale = append(stale, int(quorum))
+		}
+	}
+	if len(stale) > 0 {
+		return fmt.Errorf("quorums %v were not synced successfully during the last %d scheduled syncs", stale, a.healthConfig.MaxMissedSyncs)
+	}
+	return nil
+}
+
+type chainIdBackend interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+// ChainIdCheck checks that the RPC is reachable and serves the expected chain
+func ChainIdCheck(client chainIdBackend, expectedChainId *big.Int) HealthCheck {
+	return func(ctx context.Context) error {
+		chainId, err := client.ChainID(ctx)
+		if err != nil {
+			return fmt.Errorf("rpc unreachable: %w", err)
+		}
+		if chainId.Cmp(expectedChainId) != 0 {
+			return fmt.Errorf("rpc serves chain id %s, expected %s", chainId, expectedChainId)
+		}
+		return nil
+	}
+}
+
+// SenderCheck checks that the signer still produces the expected sender address
+func SenderCheck(senderAddress func(ctx context.Context) (common.Address, error), expectedSender common.Address) HealthCheck {
+	return func(ctx context.Context) error {
+		sender, err := senderAddress(ctx)
+		if err != nil {
+			return fmt.Errorf("signer cannot produce sender address: %w", err)
+		}
+		if sender != expectedSender {
+			return fmt.Errorf("signer produces sender %s, expected %s", sender.Hex(), expectedSender.Hex())
+		}
+		return nil
+	}
+}
+
+// healthHandler runs all checks concurrently, and serves a 503 if any of them fails
+func (a *AvsSync) healthHandler(checks func() []namedHealthCheck) http.HandlerFunc {
+	return healthHandler(a.readerTimeout, checks)
+}
+
+// healthHandler runs all checks concurrently with a timeout, and serves a 503 if any of them fails
+func healthHandler(timeout func() time.Duration, checks func() []namedHealthCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout())
+		defer cancel()
+
+		checksToRun := checks()
+		report := HealthReport{Status: healthStatusOk, Checks: make(map[string]CheckResult, len(checksToRun))}
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for _, check := range checksToRun {
+			wg.Add(1)
+			go func(check namedHealthCheck) {
+				defer wg.Done()
+				result := CheckResult{Status: healthStatusOk}
+				if err := check.check(ctx); err != nil {
+					result = CheckResult{Status: healthStatusFail, Error: err.Error()}
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				report.Checks[check.name] = result
+				if result.Status != healthStatusOk {
+					report.Status = healthStatusFail
+				}
+			}(check)
+		}
+		wg.Wait()
+
+		statusCode := http.StatusOK
+		if report.Status != healthStatusOk {
+			statusCode = http.StatusServiceUnavailable
+		}
+		writeJSON(w, statusCode, report)
+	}
+}
+
+// nthSyncAfter returns the time of the nth sync scheduled strictly after t, or the zero time if there are less than n
+func nthSyncAfter(schedule Schedule, t time.Time, n int) time.Time {
+	for i := 0; i < n; i++ {
+		t = schedule.Next(t.Add(time.Nanosecond))
+		if t.IsZero() {
+			return t
+		}
+	}
+	return t
+}
@@ -0,0 +1,74 @@
+package avssync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNthSyncAfter(t *testing.T) {
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	schedule := NewIntervalSchedule(first, time.Hour)
+	require.Equal(t, first, nthSyncAfter(schedule, first.Add(-time.Minute), 1))
+	require.Equal(t, first.Add(2*time.Hour), nthSyncAfter(schedule, first, 2))
+	require.True(t, nthSyncAfter(NewIntervalSchedule(first, 0), first.Add(-time.Minute), 2).IsZero())
+}
+
+func TestCheckSyncLoop(t *testing.T) {
+	avsSync := newTestAvsSync([]byte{0}, nil)
+	avsSync.healthConfig = HealthConfig{StuckThreshold: time.Minute}
+	require.NoError(t, avsSync.checkSyncLoop(context.Background()))
+
+	avsSync.nextSyncTime = time.Now().Add(-2 * time.Minute)
+	require.Error(t, avsSync.checkSyncLoop(context.Background()))
+
+	avsSync.setSyncing(true)
+	require.NoError(t, avsSync.checkSyncLoop(context.Background()))
+	avsSync.syncStartTime = time.Now().Add(-2 * time.Minute)
+	require.Error(t, avsSync.checkSyncLoop(context.Background()))
+}
+
+func TestCheckQuorumSyncs(t *testing.T) {
+	avsSync := newTestAvsSync([]byte{0, 1}, nil)
+	avsSync.healthConfig = HealthConfig{MaxMissedSyncs: 2}
+	now := time.Now()
+	avsSync.activeSchedule = NewIntervalSchedule(now.Add(-3*time.Hour), time.Hour)
+	avsSync.loopStartTime = now.Add(-3 * time.Hour)
+
+	// neither quorum was synced in the last 3 hours
+	err := avsSync.checkQuorumSyncs(context.Background())
+	require.ErrorContains(t, err, "[0 1]")
+
+	avsSync.updateStakeAttemptDone(0, UpdateStakeStatusSucceed)
+	avsSync.updateStakeAttemptDone(1, UpdateStakeStatusSkipped)
+	require.NoError(t, avsSync.checkQuorumSyncs(context.Background()))
+}
+
+func TestHealthHandler(t *testing.T) {
+	avsSync := newTestAvsSync([]byte{0}, nil)
+	checks := func() []namedHealthCheck {
+		return []namedHealthCheck{
+			{name: "ok", check: func(ctx context.Context) error { return nil }},
+			{name: "broken", check: func(ctx context.Context) error { return errors.New("rpc unreachable") }},
+		}
+	}
+	recorder := httptest.NewRecorder()
+	avsSync.healthHandler(checks)(recorder, httptest.NewRequest("GET", "/readyz", nil))
+	require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	var report HealthReport
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&report))
+	require.Equal(t, HealthReport{
+		Status: healthStatusFail,
+		Checks: map[string]CheckResult{
+			"ok":     {Status: healthStatusOk},
+			"broken": {Status: healthStatusFail, Error: "rpc unreachable"},
+		},
+	}, report)
+}
@@ -0,0 +1,184 @@
+package avssync
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	sdklogging "github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	LeaderElectionFile       = "file"
+	LeaderElectionKubernetes = "kubernetes"
+)
+
+// LeaderLock is a lock held by at most one replica of avssync at a time, backing the leader election.
+// Implementing it is all it takes to add a leader election backend.
+type LeaderLock interface {
+	// TryAcquireOrRenew acquires the lock if it is free (or expired), or renews it if it is already held by this replica,
+	// and returns whether this replica holds the lock
+	TryAcquireOrRenew(ctx context.Context) (bool, error)
+	// Release gives up the lock if it is held by this replica, so that another replica can take over right away
+	Release(ctx context.Context) error
+}
+
+type LeaderElectionConfig struct {
+	// how often the lock is acquired or renewed
+	RetryPeriod time.Duration
+	// the leadership is given up when the lock couldn't be renewed for this long. It must be shorter than the
+	// expiry of the lock, so that the leader stops syncing before another replica takes over.
+	RenewDeadline time.Duration
+}
+
+// LeaderElector elects the replica of avssync that runs the syncs, the other replicas stay warm (connected to the rpcs,
+// watching events) and take over when the lock of the leader expires, e.g. because it crashed
+type LeaderElector struct {
+	logger sdklogging.Logger
+	lock   LeaderLock
+	config LeaderElectionConfig
+
+	leader    atomic.Bool
+	lastRenew time.Time // only accessed by tryAcquireOrRenew, which is never called concurrently
+	// called when this replica becomes the leader, set by SetLeaderElector before the election runs
+	onElected func()
+
+	leaderGauge prometheus.Gauge
+}
+
+func NewLeaderElector(logger sdklogging.Logger, lock LeaderLock, config LeaderElectionConfig, prometheusRegistry prometheus.Registerer) *LeaderElector {
+	return &LeaderElector{
+		logger: logger,
+		lock:   lock,
+		config: config,
+		leaderGauge: promauto.With(prometheusRegistry).NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "leader",
+			Help:      "Whether this replica is the leader running the syncs (1) or a follower (0)",
+		}),
+	}
+}
+
+// NewLeaderElectorFromConfig creates the leader elector of the backend selected by the config,
+// or returns nil if leader election is disabled
+func NewLeaderElectorFromConfig(logger sdklogging.Logger, config Config, prometheusRegistry prometheus.Registerer) (*LeaderElector, error) {
+	if config.LeaderElection == "" {
+		return nil, nil
+	}
+	identity := config.LeaderElectionIdentity
+	if identity == "" {
+		// the pod name in kubernetes
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("cannot get hostname to use as leader election identity: %w", err)
+		}
+		identity = hostname
+	}
+	electionConfig := LeaderElectionConfig{
+		RetryPeriod:   config.LeaderElectionLeaseDuration / 5,
+		RenewDeadline: config.LeaderElectionLeaseDuration * 2 / 3,
+	}
+
+	var lock LeaderLock
+	switch config.LeaderElection {
+	case LeaderElectionFile:
+		lock = NewFileLeaderLock(config.LeaderElectionLockFile)
+	case LeaderElectionKubernetes:
+		leaseConfig, err := InClusterKubernetesLeaseConfig(config.LeaderElectionLeaseNamespace, config.LeaderElectionLeaseName)
+		if err != nil {
+			return nil, err
+		}
+		leaseConfig.Identity = identity
+		leaseConfig.LeaseDuration = config.LeaderElectionLeaseDuration
+		lock = NewKubernetesLeaseLock(leaseConfig)
+	default:
+		return nil, fmt.Errorf("unknown leader election backend %q", config.LeaderElection)
+	}
+	logger.Info("Leader election enabled", "backend", config.LeaderElection, "identity", identity)
+	return NewLeaderElector(logger, lock, electionConfig, prometheusRegistry), nil
+}
+
+// IsLeader returns whether this replica currently holds the leadership
+func (e *LeaderElector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns for the leadership, and keeps renewing it once acquired, until ctx is done.
+// The leadership is then released, so that another replica takes over without waiting for the lock to expire.
+func (e *LeaderElector) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.config.RetryPeriod)
+	defer ticker.Stop()
+	for {
+		e.tryAcquireOrRenew(ctx)
+		select {
+		case <-ctx.Done():
+			if e.leader.Load() {
+				e.setLeader(false)
+				releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), e.config.RetryPeriod)
+				if err := e.lock.Release(releaseCtx); err != nil {
+					e.logger.Warn("Error releasing leadership, another replica takes over once it expires", "err", err)
+				}
+				cancel()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *LeaderElector) tryAcquireOrRenew(ctx context.Context) {
+	attemptCtx, cancel := context.WithTimeout(ctx, e.config.RetryPeriod)
+	defer cancel()
+	acquired, err := e.lock.TryAcquireOrRenew(attemptCtx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		e.logger.Warn("Error acquiring or renewing leadership", "err", err)
+		// keep the leadership until the renew deadline, a single failed renewal doesn't mean another replica took over
+		if e.leader.Load() && time.Since(e.lastRenew) > e.config.RenewDeadline {
+			e.logger.Error("Could not renew leadership before the renew deadline, stepping down", "renewDeadline", e.config.RenewDeadline)
+			e.setLeader(false)
+		}
+		return
+	}
+	if acquired {
+		e.lastRenew = time.Now()
+	}
+	e.setLeader(acquired)
+}
+
+func (e *LeaderElector) setLeader(leader bool) {
+	if e.leader.Swap(leader) == leader {
+		return
+	}
+	if leader {
+		e.logger.Info("Became the leader, running syncs")
+		e.leaderGauge.Set(1)
+		if e.onElected != nil {
+			e.onElected()
+		}
+	} else {
+		e.logger.Info("Lost the leadership, following")
+		e.leaderGauge.Set(0)
+	}
+}
+
+// SetLeaderElector makes AvsSync only run syncs while elector says this replica is the leader.
+// The election is run by Start, SetLeaderElector must be called before it.
+func (a *AvsSync) SetLeaderElector(elector *LeaderElector) {
+	a.leaderElector = elector
+	if a.eventWatcher != nil {
+		// pick up the events seen while following
+		elector.onElected = a.eventWatcher.retrigger
+	}
+}
+
+// isLeader returns whether this replica runs the syncs, which is always the case without leader election
+func (a *AvsSync) isLeader() bool {
+	return a.leaderElector == nil || a.leaderElector.IsLeader()
+}
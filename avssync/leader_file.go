@@ -0,0 +1,59 @@
+//go:build unix
+
+package avssync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// FileLeaderLock is a LeaderLock backed by an advisory lock (flock) on a local file, for replicas running on the same
+// host (or sharing a filesystem supporting flock). The lock is released by the kernel when the process dies,
+// so there is no expiry to wait for.
+type FileLeaderLock struct {
+	path string
+
+	mu   sync.Mutex
+	file *os.File // nil while the lock isn't held
+}
+
+func NewFileLeaderLock(path string) *FileLeaderLock {
+	return &FileLeaderLock{path: path}
+}
+
+func (l *FileLeaderLock) TryAcquireOrRenew(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		return true, nil
+	}
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return false, fmt.Errorf("cannot open leader election lock file: %w", err)
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		_ = file.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return false, nil
+		}
+		return false, fmt.Errorf("cannot lock leader election lock file: %w", err)
+	}
+	l.file = file
+	return true, nil
+}
+
+func (l *FileLeaderLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	// closing the file releases the lock
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
@@ -0,0 +1,23 @@
+//go:build !unix
+
+package avssync
+
+import (
+	"context"
+	"errors"
+)
+
+// FileLeaderLock is only supported on unix systems, see leader_file.go
+type FileLeaderLock struct{}
+
+func NewFileLeaderLock(path string) *FileLeaderLock {
+	return &FileLeaderLock{}
+}
+
+func (l *FileLeaderLock) TryAcquireOrRenew(ctx context.Context) (bool, error) {
+	return false, errors.New("file leader election is not supported on this platform")
+}
+
+func (l *FileLeaderLock) Release(ctx context.Context) error {
+	return nil
+}
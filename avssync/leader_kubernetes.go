@@ -0,0 +1,249 @@
+package avssync
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// format of the metav1.MicroTime fields of leases
+	kubernetesMicroTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+type KubernetesLeaseConfig struct {
+	// e.g. https://10.96.0.1:443
+	APIServerURL string
+	Namespace    string
+	// name of the coordination.k8s.io/v1 Lease, created if it doesn't exist
+	Name string
+	// holder identity of this replica, which must be unique among replicas (e.g. the pod name)
+	Identity string
+	// how long the lease is valid after its last renewal, after which another replica can take it over
+	LeaseDuration time.Duration
+	// file from which the bearer token is read before every request, since service account tokens are rotated
+	TokenFile  string
+	HTTPClient *http.Client
+}
+
+// InClusterKubernetesLeaseConfig returns the config of a lease in the cluster avssync runs in, authenticated with the
+// service account of the pod (which must be allowed to get, create and update leases).
+// An empty namespace means the namespace of the pod.
+func InClusterKubernetesLeaseConfig(namespace, name string) (KubernetesLeaseConfig, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return KubernetesLeaseConfig{}, errors.New("kubernetes leader election requires running in a kubernetes pod (KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set)")
+	}
+	if namespace == "" {
+		data, err := os.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return KubernetesLeaseConfig{}, fmt.Errorf("cannot read namespace of the pod: %w", err)
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+	caCert, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return KubernetesLeaseConfig{}, fmt.Errorf("cannot read kubernetes api server ca certificate: %w", err)
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caCert) {
+		return KubernetesLeaseConfig{}, errors.New("invalid kubernetes api server ca certificate")
+	}
+	return KubernetesLeaseConfig{
+		APIServerURL: "https://" + net.JoinHostPort(host, port),
+		Namespace:    namespace,
+		Name:         name,
+		TokenFile:    serviceAccountDir + "/token",
+		HTTPClient: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: caPool}},
+		},
+	}, nil
+}
+
+// kubernetesLease is the subset of a coordination.k8s.io/v1 Lease used for leader election
+type kubernetesLease struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		Name            string `json:"name"`
+		Namespace       string `json:"namespace"`
+		ResourceVersion string `json:"resourceVersion,omitempty"`
+	} `json:"metadata"`
+	Spec kubernetesLeaseSpec `json:"spec"`
+}
+
+type kubernetesLeaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int32  `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int32  `json:"leaseTransitions,omitempty"`
+}
+
+// KubernetesLeaseLock is a LeaderLock backed by a kubernetes Lease, as used by kubernetes controllers.
+// Updates rely on the resourceVersion of the lease, so that two replicas can never both acquire it.
+type KubernetesLeaseLock struct {
+	config KubernetesLeaseConfig
+	now    func() time.Time
+
+	mu sync.Mutex
+	// the expiry of the lease is measured with the local clock from when its current spec was first observed,
+	// rather than from its renewTime, so that clock skew between replicas doesn't matter
+	observedSpec kubernetesLeaseSpec
+	observedTime time.Time
+}
+
+func NewKubernetesLeaseLock(config KubernetesLeaseConfig) *KubernetesLeaseLock {
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	return &KubernetesLeaseLock{config: config, now: time.Now}
+}
+
+func (l *KubernetesLeaseLock) TryAcquireOrRenew(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	lease, err := l.getLease(ctx)
+	if err != nil {
+		return false, err
+	}
+	spec := kubernetesLeaseSpec{
+		HolderIdentity:       l.config.Identity,
+		LeaseDurationSeconds: int32(l.config.LeaseDuration.Seconds()),
+		AcquireTime:          now.UTC().Format(kubernetesMicroTimeFormat),
+		RenewTime:            now.UTC().Format(kubernetesMicroTimeFormat),
+	}
+	if lease == nil {
+		lease = &kubernetesLease{APIVersion: "coordination.k8s.io/v1", Kind: "Lease"}
+		lease.Metadata.Name = l.config.Name
+		lease.Metadata.Namespace = l.config.Namespace
+		lease.Spec = spec
+		return l.writeLease(ctx, http.MethodPost, l.leasesURL(), lease, now)
+	}
+
+	if lease.Spec != l.observedSpec {
+		l.observedSpec = lease.Spec
+		l.observedTime = now
+	}
+	heldByUs := lease.Spec.HolderIdentity == l.config.Identity
+	if !heldByUs && lease.Spec.HolderIdentity != "" {
+		leaseDuration := time.Duration(lease.Spec.LeaseDurationSeconds) * time.Second
+		if now.Before(l.observedTime.Add(leaseDuration)) {
+			return false, nil
+		}
+	}
+	if heldByUs {
+		spec.AcquireTime = lease.Spec.AcquireTime
+		spec.LeaseTransitions = lease.Spec.LeaseTransitions
+	} else {
+		spec.LeaseTransitions = lease.Spec.LeaseTransitions + 1
+	}
+	lease.Spec = spec
+	return l.writeLease(ctx, http.MethodPut, l.leaseURL(), lease, now)
+}
+
+func (l *KubernetesLeaseLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lease, err := l.getLease(ctx)
+	if err != nil {
+		return err
+	}
+	if lease == nil || lease.Spec.HolderIdentity != l.config.Identity {
+		return nil
+	}
+	// an empty holder lets the other replicas acquire the lease without waiting for it to expire
+	lease.Spec.HolderIdentity = ""
+	lease.Spec.RenewTime = l.now().UTC().Format(kubernetesMicroTimeFormat)
+	_, err = l.writeLease(ctx, http.MethodPut, l.leaseURL(), lease, l.now())
+	return err
+}
+
+func (l *KubernetesLeaseLock) leasesURL() string {
+	return fmt.Sprintf("%s/apis/coordination.k8s.io/v1/namespaces/%s/leases", strings.TrimSuffix(l.config.APIServerURL, "/"), l.config.Namespace)
+}
+
+func (l *KubernetesLeaseLock) leaseURL() string {
+	return l.leasesURL() + "/" + l.config.Name
+}
+
+// getLease returns nil if the lease doesn't exist
+func (l *KubernetesLeaseLock) getLease(ctx context.Context) (*kubernetesLease, error) {
+	resp, err := l.do(ctx, http.MethodGet, l.leaseURL(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, kubernetesAPIError("get lease", resp)
+	}
+	var lease kubernetesLease
+	if err := json.NewDecoder(resp.Body).Decode(&lease); err != nil {
+		return nil, fmt.Errorf("cannot decode lease: %w", err)
+	}
+	return &lease, nil
+}
+
+// writeLease creates or updates the lease, and returns whether it was written, i.e. whether we hold it.
+// A conflict means another replica wrote the lease in the meantime.
+func (l *KubernetesLeaseLock) writeLease(ctx context.Context, method, url string, lease *kubernetesLease, now time.Time) (bool, error) {
+	body, err := json.Marshal(lease)
+	if err != nil {
+		return false, err
+	}
+	resp, err := l.do(ctx, method, url, body)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		l.observedSpec = lease.Spec
+		l.observedTime = now
+		return lease.Spec.HolderIdentity == l.config.Identity, nil
+	case http.StatusConflict:
+		return false, nil
+	default:
+		return false, kubernetesAPIError("write lease", resp)
+	}
+}
+
+func (l *KubernetesLeaseLock) do(ctx context.Context, method, url string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if l.config.TokenFile != "" {
+		token, err := os.ReadFile(l.config.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read kubernetes service account token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	return l.config.HTTPClient.Do(req)
+}
+
+func kubernetesAPIError(action string, resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("cannot %s: kubernetes api server returned %s: %s", action, resp.Status, strings.TrimSpace(string(message)))
+}
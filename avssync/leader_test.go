@@ -0,0 +1,231 @@
+package avssync
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileLeaderLock(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "leader.lock")
+	a, b := NewFileLeaderLock(path), NewFileLeaderLock(path)
+
+	acquired, err := a.TryAcquireOrRenew(ctx)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	acquired, err = b.TryAcquireOrRenew(ctx)
+	require.NoError(t, err)
+	require.False(t, acquired)
+	acquired, err = a.TryAcquireOrRenew(ctx)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	require.NoError(t, a.Release(ctx))
+	acquired, err = b.TryAcquireOrRenew(ctx)
+	require.NoError(t, err)
+	require.True(t, acquired)
+}
+
+// fakeKubernetesAPIServer serves the leases of a namespace, enforcing their resourceVersion like the api server
+type fakeKubernetesAPIServer struct {
+	mu              sync.Mutex
+	leases          map[string]kubernetesLease
+	resourceVersion int
+}
+
+func (s *fakeKubernetesAPIServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	const prefix = "/apis/coordination.k8s.io/v1/namespaces/default/leases"
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var lease kubernetesLease
+	if r.Method != http.MethodGet {
+		if err := json.NewDecoder(r.Body).Decode(&lease); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == prefix:
+		if _, ok := s.leases[lease.Metadata.Name]; ok {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodGet && filepath.Dir(r.URL.Path) == prefix:
+		stored, ok := s.leases[filepath.Base(r.URL.Path)]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(stored)
+		return
+	case r.Method == http.MethodPut && filepath.Dir(r.URL.Path) == prefix:
+		stored, ok := s.leases[filepath.Base(r.URL.Path)]
+		if !ok || stored.Metadata.ResourceVersion != lease.Metadata.ResourceVersion {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.resourceVersion++
+	lease.Metadata.ResourceVersion = strconv.Itoa(s.resourceVersion)
+	s.leases[lease.Metadata.Name] = lease
+	_ = json.NewEncoder(w).Encode(lease)
+}
+
+func (s *fakeKubernetesAPIServer) lease(name string) kubernetesLease {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leases[name]
+}
+
+func TestKubernetesLeaseLock(t *testing.T) {
+	ctx := context.Background()
+	apiServer := &fakeKubernetesAPIServer{leases: make(map[string]kubernetesLease)}
+	server := httptest.NewServer(apiServer)
+	defer server.Close()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("token\n"), 0600))
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newLock := func(identity string) *KubernetesLeaseLock {
+		lock := NewKubernetesLeaseLock(KubernetesLeaseConfig{
+			APIServerURL:  server.URL,
+			Namespace:     "default",
+			Name:          "avs-sync",
+			Identity:      identity,
+			LeaseDuration: 15 * time.Second,
+			TokenFile:     tokenFile,
+		})
+		lock.now = func() time.Time { return now }
+		return lock
+	}
+	a, b := newLock("a"), newLock("b")
+
+	// a creates the lease, b observes it
+	acquired, err := a.TryAcquireOrRenew(ctx)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	acquired, err = b.TryAcquireOrRenew(ctx)
+	require.NoError(t, err)
+	require.False(t, acquired)
+
+	// a keeps renewing the lease, so it never expires for b
+	for i := 0; i < 3; i++ {
+		now = now.Add(10 * time.Second)
+		acquired, err = a.TryAcquireOrRenew(ctx)
+		require.NoError(t, err)
+		require.True(t, acquired)
+		acquired, err = b.TryAcquireOrRenew(ctx)
+		require.NoError(t, err)
+		require.False(t, acquired)
+	}
+
+	// a stops renewing (e.g. it crashed), b takes over once the lease expires
+	now = now.Add(10 * time.Second)
+	acquired, err = b.TryAcquireOrRenew(ctx)
+	require.NoError(t, err)
+	require.False(t, acquired)
+	now = now.Add(10 * time.Second)
+	acquired, err = b.TryAcquireOrRenew(ctx)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	require.Equal(t, "b", apiServer.lease("avs-sync").Spec.HolderIdentity)
+	acquired, err = a.TryAcquireOrRenew(ctx)
+	require.NoError(t, err)
+	require.False(t, acquired)
+
+	// releasing lets a take over right away
+	require.NoError(t, b.Release(ctx))
+	require.Empty(t, apiServer.lease("avs-sync").Spec.HolderIdentity)
+	acquired, err = a.TryAcquireOrRenew(ctx)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	require.Equal(t, int32(2), apiServer.lease("avs-sync").Spec.LeaseTransitions)
+
+	// a lock that isn't allowed to access the lease fails
+	unauthorized := newLock("c")
+	unauthorized.config.TokenFile = ""
+	_, err = unauthorized.TryAcquireOrRenew(ctx)
+	require.ErrorContains(t, err, "401")
+}
+
+type fakeLeaderLock struct {
+	mu       sync.Mutex
+	acquired bool
+	err      error
+}
+
+func (l *fakeLeaderLock) set(acquired bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.acquired, l.err = acquired, err
+}
+
+func (l *fakeLeaderLock) TryAcquireOrRenew(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.acquired, l.err
+}
+
+func (l *fakeLeaderLock) Release(ctx context.Context) error {
+	l.set(false, nil)
+	return nil
+}
+
+func TestLeaderElector(t *testing.T) {
+	logger := logging.NewTextSLogger(os.Stderr, &logging.SLoggerOptions{})
+	lock := &fakeLeaderLock{}
+	elector := NewLeaderElector(logger, lock, LeaderElectionConfig{RetryPeriod: 10 * time.Millisecond, RenewDeadline: 200 * time.Millisecond}, prometheus.NewRegistry())
+	avsSync := newTestAvsSync([]byte{0}, nil)
+	avsSync.SetLeaderElector(elector)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		elector.Run(ctx)
+		close(done)
+	}()
+
+	// followers don't accept sync requests
+	require.False(t, avsSync.Status().Leader)
+	require.ErrorIs(t, avsSync.RequestSync(nil, nil), ErrNotLeader)
+
+	lock.set(true, nil)
+	require.Eventually(t, elector.IsLeader, time.Second, 5*time.Millisecond)
+	require.True(t, avsSync.Status().Leader)
+	require.NoError(t, avsSync.RequestSync(nil, nil))
+
+	// a failed renewal doesn't lose the leadership until the renew deadline
+	lock.set(false, context.DeadlineExceeded)
+	time.Sleep(50 * time.Millisecond)
+	require.True(t, elector.IsLeader())
+	require.Eventually(t, func() bool { return !elector.IsLeader() }, time.Second, 5*time.Millisecond)
+
+	// the leadership is released on shutdown
+	lock.set(true, nil)
+	require.Eventually(t, elector.IsLeader, time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+	require.False(t, elector.IsLeader())
+	acquired, _ := lock.TryAcquireOrRenew(context.Background())
+	require.False(t, acquired)
+}
@@ -1,10 +1,13 @@
 package avssync
 
 import (
+	"errors"
+	"math/big"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -13,37 +16,159 @@ const metricsNamespace = "avssync"
 type UpdateStakeStatus string
 
 const (
-	UpdateStakeStatusError   UpdateStakeStatus = "error"
+	UpdateStakeStatusError UpdateStakeStatus = "error"
+	// the update failed with an error that retrying can't fix (see ClassifyError), e.g. the signer ran out of funds
+	UpdateStakeStatusFatal   UpdateStakeStatus = "fatal"
 	UpdateStakeStatusSucceed UpdateStakeStatus = "succeed"
+	// the stakes of the quorum didn't drift enough from the ones recorded in the StakeRegistry to be worth updating
+	UpdateStakeStatusSkipped UpdateStakeStatus = "skipped"
+	// the gas price stayed above max-fee-per-gas for the whole defer window, so the update wasn't sent
+	UpdateStakeStatusDeferred UpdateStakeStatus = "deferred_due_to_gas"
+	// the balance of the sender didn't cover the estimated cost of the sync, so the update wasn't sent
+	UpdateStakeStatusInsufficientFunds UpdateStakeStatus = "insufficient_funds"
+	// the remote signer failed to sign the update (see ErrRemoteSigner)
+	UpdateStakeStatusSignerError UpdateStakeStatus = "signer_error"
 )
 
 type Metrics struct {
 	updateStakeAttempts *prometheus.CounterVec
-	txRevertedTotal     prometheus.Counter
+	txRevertedTotal     *prometheus.CounterVec
+	chunkUpdateAttempts *prometheus.CounterVec
 	operatorsUpdated    *prometheus.GaugeVec
+	nextSyncTimestamp   prometheus.Gauge
+
+	gasSpent               *prometheus.CounterVec
+	gasSpentInBudgetPeriod prometheus.Gauge
+	gasBudgetExceeded      prometheus.Gauge
+
+	senderBalance             prometheus.Gauge
+	senderLowBalanceThreshold prometheus.Gauge
+
+	registryStake         *prometheus.GaugeVec
+	currentStake          *prometheus.GaugeVec
+	stakeDriftRatio       *prometheus.GaugeVec
+	maxOperatorDriftRatio *prometheus.GaugeVec
+	driftedOperators      *prometheus.GaugeVec
+
+	operatorSetOperators          *prometheus.GaugeVec
+	operatorSetAllocatedStake     *prometheus.GaugeVec
+	operatorSetPendingAllocations *prometheus.GaugeVec
 
 	registry *prometheus.Registry
 }
 
-func NewMetrics(reg *prometheus.Registry) *Metrics {
+// NewMetrics registers the metrics of the AvsSync of avs (the avs-name of its config) on reg.
+// Every metric has an avs label, so that the AvsSyncs of several AVSs can share reg.
+func NewMetrics(reg *prometheus.Registry, avs string) *Metrics {
+	avsLabel := prometheus.Labels{"avs": avs}
 	metrics := &Metrics{
-		updateStakeAttempts: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		updateStakeAttempts: registerOrReuse(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: metricsNamespace,
 			Name:      "update_stake_attempt",
-			Help:      "Result from an update stake attempt. Either succeed or error (either tx was mined but reverted, or failed to get processed by chain).",
-		}, []string{"status", "quorum"}),
+			Help:      "Result from an update stake attempt. Either succeed, skipped, error (either tx was mined but reverted, or failed to get processed by chain) fatal (not retried, e.g. insufficient funds) deferred_due_to_gas (gas price above max-fee-per-gas) or insufficient_funds (sender balance below the estimated cost of the sync).",
+		}, []string{"avs", "status", "quorum"})).MustCurryWith(avsLabel),
 
-		txRevertedTotal: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		txRevertedTotal: registerOrReuse(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: metricsNamespace,
 			Name:      "tx_reverted_total",
-			Help:      "The total number of transactions that made it onchain but reverted (most likely because out of gas)",
-		}),
+			Help:      "The total number of transactions that made it onchain but reverted, by revert reason (out_of_gas, custom error name, revert_string or unknown, the full reason is logged)",
+		}, []string{"avs", "reason"})).MustCurryWith(avsLabel),
 
-		operatorsUpdated: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+		chunkUpdateAttempts: registerOrReuse(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "chunk_update_attempt",
+			Help:      "Result from updating the stakes of a chunk of a quorum's operators, when the entire operator set doesn't fit in a single transaction",
+		}, []string{"avs", "status", "quorum"})).MustCurryWith(avsLabel),
+
+		operatorsUpdated: registerOrReuse(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: metricsNamespace,
 			Name:      "operators_updated",
 			Help:      "The total number of operators updated (during the last quorum sync)",
-		}, []string{"quorum"}),
+		}, []string{"avs", "quorum"})).MustCurryWith(avsLabel),
+
+		nextSyncTimestamp: registerOrReuse(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "next_sync_timestamp_seconds",
+			Help:      "Unix timestamp at which the next sync is scheduled to run",
+		}, []string{"avs"})).WithLabelValues(avs),
+
+		gasSpent: registerOrReuse(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "gas_spent_eth_total",
+			Help:      "Cumulative cost (gas used times effective gas price) of the stake update transactions, by quorum (empty for operator subset updates of all quorums) and call type (entire_operator_set or operator_subset)",
+		}, []string{"avs", "quorum", "call_type"})).MustCurryWith(avsLabel),
+
+		gasSpentInBudgetPeriod: registerOrReuse(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "gas_spent_in_budget_period_eth",
+			Help:      "Cost of the stake update transactions over the last gas budget period",
+		}, []string{"avs"})).WithLabelValues(avs),
+
+		gasBudgetExceeded: registerOrReuse(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "gas_budget_exceeded",
+			Help:      "1 if the gas budget of the period is spent, in which case no stake update is sent, 0 otherwise",
+		}, []string{"avs"})).WithLabelValues(avs),
+
+		senderBalance: registerOrReuse(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "sender_balance_wei",
+			Help:      "Balance of the address sending the stake update transactions (as of the last sync)",
+		}, []string{"avs"})).WithLabelValues(avs),
+
+		senderLowBalanceThreshold: registerOrReuse(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "sender_low_balance_threshold_wei",
+			Help:      "Balance under which the sender should be topped up (low-balance-threshold-eth), to alert on along with sender_balance_wei",
+		}, []string{"avs"})).WithLabelValues(avs),
+
+		registryStake: registerOrReuse(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "registry_stake",
+			Help:      "Total stake of the quorum as currently recorded in the StakeRegistry (as of the last stake drift check)",
+		}, []string{"avs", "quorum"})).MustCurryWith(avsLabel),
+
+		currentStake: registerOrReuse(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "current_stake",
+			Help:      "Total stake the quorum would have if all its operators were updated (as of the last stake drift check)",
+		}, []string{"avs", "quorum"})).MustCurryWith(avsLabel),
+
+		stakeDriftRatio: registerOrReuse(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "stake_drift_ratio",
+			Help:      "Relative difference between the current and registry total stakes of the quorum (0.1 means 10%)",
+		}, []string{"avs", "quorum"})).MustCurryWith(avsLabel),
+
+		maxOperatorDriftRatio: registerOrReuse(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "max_operator_stake_drift_ratio",
+			Help:      "Largest relative difference between the current and registry stakes of a single operator in the quorum",
+		}, []string{"avs", "quorum"})).MustCurryWith(avsLabel),
+
+		driftedOperators: registerOrReuse(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "drifted_operators",
+			Help:      "Number of operators in the quorum whose registry stake differs from their current stake",
+		}, []string{"avs", "quorum"})).MustCurryWith(avsLabel),
+
+		operatorSetOperators: registerOrReuse(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "operator_set_operators",
+			Help:      "Number of operators in the operator set of the AVS, registered in the AllocationManager (only with use-operator-sets)",
+		}, []string{"avs", "operator_set"})).MustCurryWith(avsLabel),
+
+		operatorSetAllocatedStake: registerOrReuse(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "operator_set_allocated_stake",
+			Help:      "Total stake (in shares of the strategy) allocated to the operator set by its operators (only with use-operator-sets)",
+		}, []string{"avs", "operator_set", "strategy"})).MustCurryWith(avsLabel),
+
+		operatorSetPendingAllocations: registerOrReuse(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "operator_set_pending_allocations",
+			Help:      "Number of allocations to the operator set that haven't taken effect yet, by direction (allocation, or deallocation whose stake is still slashable) (only with use-operator-sets)",
+		}, []string{"avs", "operator_set", "direction"})).MustCurryWith(avsLabel),
 
 		registry: reg,
 	}
@@ -51,22 +176,97 @@ func NewMetrics(reg *prometheus.Registry) *Metrics {
 	return metrics
 }
 
+// registerOrReuse registers collector on reg, or returns the identical collector already registered by another
+// AvsSync sharing reg
+func registerOrReuse[T prometheus.Collector](reg prometheus.Registerer, collector T) T {
+	err := reg.Register(collector)
+	if err == nil {
+		return collector
+	}
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if errors.As(err, &alreadyRegistered) {
+		if existing, ok := alreadyRegistered.ExistingCollector.(T); ok {
+			return existing
+		}
+	}
+	panic(err)
+}
+
 func (g *Metrics) UpdateStakeAttemptInc(status UpdateStakeStatus, quorum string) {
 	g.updateStakeAttempts.WithLabelValues(string(status), quorum).Inc()
 }
 
-func (g *Metrics) TxRevertedTotalInc() {
-	g.txRevertedTotal.Inc()
+func (g *Metrics) TxRevertedTotalInc(reason string) {
+	g.txRevertedTotal.WithLabelValues(reason).Inc()
+}
+
+func (g *Metrics) ChunkUpdateAttemptInc(status UpdateStakeStatus, quorum string) {
+	g.chunkUpdateAttempts.WithLabelValues(string(status), quorum).Inc()
 }
 
 func (g *Metrics) OperatorsUpdatedSet(quorum string, operators int) {
 	g.operatorsUpdated.WithLabelValues(quorum).Set(float64(operators))
 }
 
-func (g *Metrics) Start(metricsAddr string) {
-	http.Handle("/metrics", promhttp.HandlerFor(g.registry, promhttp.HandlerOpts{}))
-	// not sure if we need to handle this error, since if metric server errors, then we will get alerts from grafana
-	go func() {
-		_ = http.ListenAndServe(metricsAddr, nil)
-	}()
+func (g *Metrics) NextSyncTimestampSet(nextSyncTime time.Time) {
+	g.nextSyncTimestamp.Set(float64(nextSyncTime.Unix()))
+}
+
+func (g *Metrics) GasSpentAdd(quorum string, callType string, eth float64) {
+	g.gasSpent.WithLabelValues(quorum, callType).Add(eth)
+}
+
+func (g *Metrics) GasBudgetSet(spentEth float64, exceeded bool) {
+	g.gasSpentInBudgetPeriod.Set(spentEth)
+	if exceeded {
+		g.gasBudgetExceeded.Set(1)
+	} else {
+		g.gasBudgetExceeded.Set(0)
+	}
+}
+
+func (g *Metrics) SenderBalanceSet(balance *big.Int) {
+	wei, _ := new(big.Float).SetInt(balance).Float64()
+	g.senderBalance.Set(wei)
+}
+
+func (g *Metrics) SenderLowBalanceThresholdSet(threshold *big.Int) {
+	wei := 0.0
+	if threshold != nil {
+		wei, _ = new(big.Float).SetInt(threshold).Float64()
+	}
+	g.senderLowBalanceThreshold.Set(wei)
+}
+
+func (g *Metrics) StakeDriftSet(quorum string, drift *QuorumStakeDrift) {
+	registryStake, _ := new(big.Float).SetInt(drift.RegistryStake).Float64()
+	currentStake, _ := new(big.Float).SetInt(drift.CurrentStake).Float64()
+	g.registryStake.WithLabelValues(quorum).Set(registryStake)
+	g.currentStake.WithLabelValues(quorum).Set(currentStake)
+	g.stakeDriftRatio.WithLabelValues(quorum).Set(driftRatio(drift.RegistryStake, drift.CurrentStake))
+	g.maxOperatorDriftRatio.WithLabelValues(quorum).Set(drift.MaxOperatorDriftRatio())
+	g.driftedOperators.WithLabelValues(quorum).Set(float64(drift.DriftedOperators()))
+}
+
+func (g *Metrics) OperatorSetSet(operatorSet OperatorSetStatus) {
+	id := strconv.Itoa(int(operatorSet.Id))
+	g.operatorSetOperators.WithLabelValues(id).Set(float64(operatorSet.Operators))
+	for strategy, stake := range operatorSet.AllocatedStake {
+		shares, _ := new(big.Float).SetInt(stake).Float64()
+		g.operatorSetAllocatedStake.WithLabelValues(id, strategy.Hex()).Set(shares)
+	}
+	allocations, deallocations := 0, 0
+	for _, pending := range operatorSet.PendingAllocations {
+		if pending.Deallocation() {
+			deallocations++
+		} else {
+			allocations++
+		}
+	}
+	g.operatorSetPendingAllocations.WithLabelValues(id, "allocation").Set(float64(allocations))
+	g.operatorSetPendingAllocations.WithLabelValues(id, "deallocation").Set(float64(deallocations))
+}
+
+func (g *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(g.registry, promhttp.HandlerOpts{})
 }
@@ -0,0 +1,238 @@
+package avssync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	sdklogging "github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/Layr-Labs/eigensdk-go/types"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// SenderLock serializes the stake update transactions of the AvsSyncs sharing a sender. The txmgr gives a transaction
+// the pending nonce of the sender, so transactions sent at the same time by several AvsSyncs would get the same nonce.
+type SenderLock struct {
+	held chan struct{}
+}
+
+func NewSenderLock() *SenderLock {
+	return &SenderLock{held: make(chan struct{}, 1)}
+}
+
+// Writer wraps writer so that its transactions are sent, and their receipts waited for, while holding the lock
+func (l *SenderLock) Writer(writer AvsWriter) AvsWriter {
+	return &lockedAvsWriter{writer: writer, lock: l}
+}
+
+func (l *SenderLock) acquire(ctx context.Context) error {
+	select {
+	case l.held <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for the transaction of another target sharing the sender: %w", ctx.Err())
+	}
+}
+
+func (l *SenderLock) release() {
+	<-l.held
+}
+
+type lockedAvsWriter struct {
+	writer AvsWriter
+	lock   *SenderLock
+}
+
+func (w *lockedAvsWriter) UpdateStakesOfEntireOperatorSetForQuorums(ctx context.Context, operatorsPerQuorum [][]common.Address, quorumNumbers types.QuorumNums, waitForReceipt bool) (*gethtypes.Receipt, error) {
+	if err := w.lock.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer w.lock.release()
+	return w.writer.UpdateStakesOfEntireOperatorSetForQuorums(ctx, operatorsPerQuorum, quorumNumbers, waitForReceipt)
+}
+
+func (w *lockedAvsWriter) UpdateStakesOfOperatorSubsetForAllQuorums(ctx context.Context, operators []common.Address, waitForReceipt bool) (*gethtypes.Receipt, error) {
+	if err := w.lock.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer w.lock.release()
+	return w.writer.UpdateStakesOfOperatorSubsetForAllQuorums(ctx, operators, waitForReceipt)
+}
+
+// MultiAvsSync runs the AvsSyncs of several AVSs (the targets of the config) in one process. Every AvsSync runs its own
+// sync loop, so that a target failing or waiting on its RPC doesn't hold up the others, and they share a metrics server
+// and admin API.
+type MultiAvsSync struct {
+	logger       sdklogging.Logger
+	avsSyncs     []*AvsSync
+	adminServers map[string]*AdminServer
+	// targets whose AvsSync couldn't be created, which fail /readyz
+	failedTargets map[string]error
+	registry      *prometheus.Registry
+	metricsAddr   string
+}
+
+// NewMultiAvsSync creates a MultiAvsSync running avsSyncs, which are told apart by their avs-name. The metrics of all of
+// them must be registered on registry, which is served on metricsAddr (empty means no metrics server) along with
+// /healthz and /readyz, which run the checks of every AvsSync.
+func NewMultiAvsSync(logger sdklogging.Logger, avsSyncs []*AvsSync, registry *prometheus.Registry, metricsAddr string) *MultiAvsSync {
+	return &MultiAvsSync{
+		logger:        logger,
+		avsSyncs:      avsSyncs,
+		adminServers:  map[string]*AdminServer{},
+		failedTargets: map[string]error{},
+		registry:      registry,
+		metricsAddr:   metricsAddr,
+	}
+}
+
+// AddAdminServer serves the admin API of the target name under /targets/<name>/ of AdminHandler. It must be called before Start.
+func (m *MultiAvsSync) AddAdminServer(name string, server *AdminServer) {
+	m.adminServers[name] = server
+}
+
+// AddFailedTarget reports that the AvsSync of the target name couldn't be created, e.g. because its contracts couldn't be
+// read, in the startup check of /readyz. The other targets run without it. It must be called before Start.
+func (m *MultiAvsSync) AddFailedTarget(name string, err error) {
+	m.failedTargets[name] = err
+}
+
+// AdminHandler serves the admin API of every target added by AddAdminServer, e.g. POST /targets/<name>/sync,
+// and the names of the targets on GET /targets
+func (m *MultiAvsSync) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	var names []string
+	for name, server := range m.adminServers {
+		prefix := "/targets/" + name
+		mux.Handle(prefix+"/", http.StripPrefix(prefix, server.Handler()))
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	mux.HandleFunc("GET /targets", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, names)
+	})
+	return mux
+}
+
+// StartAdminServer serves AdminHandler on addr until ctx is done
+func (m *MultiAvsSync) StartAdminServer(ctx context.Context, addr string) error {
+	m.logger.Info("Starting admin server", "addr", addr, "targets", len(m.adminServers))
+	return listenAndServe(ctx, addr, m.AdminHandler())
+}
+
+// Start runs the sync loop of every AvsSync until ctx is done or none of them has any more syncs scheduled.
+// It returns the errors of the AvsSyncs whose last sync failed, or whose loop panicked, which doesn't stop the others.
+func (m *MultiAvsSync) Start(ctx context.Context) error {
+	if m.metricsAddr != "" {
+		go func() {
+			m.logger.Info("Starting metrics server", "addr", m.metricsAddr)
+			if err := listenAndServe(ctx, m.metricsAddr, m.MetricsHandler()); err != nil {
+				m.logger.Error("Metrics server failed, /metrics, /healthz and /readyz are unavailable", "err", err, "addr", m.metricsAddr)
+			}
+		}()
+	} else {
+		m.logger.Info("Prometheus server address not set, not starting metrics server")
+	}
+
+	errs := make([]error, len(m.avsSyncs))
+	var wg sync.WaitGroup
+	for i, avsSync := range m.avsSyncs {
+		wg.Add(1)
+		go func(i int, avsSync *AvsSync) {
+			defer wg.Done()
+			errs[i] = m.run(ctx, avsSync)
+		}(i, avsSync)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// run runs the sync loop of avsSync, turning a panic into an error so that the other targets keep running
+func (m *MultiAvsSync) run(ctx context.Context, avsSync *AvsSync) (err error) {
+	name := avsSync.Config().AvsName
+	defer func() {
+		if r := recover(); r != nil {
+			m.logger.Error("Sync loop of target panicked, the other targets keep running", "avs", name, "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("avs %s: sync loop panicked: %v", name, r)
+		}
+	}()
+	if err := avsSync.Start(ctx); err != nil {
+		return fmt.Errorf("avs %s: %w", name, err)
+	}
+	return nil
+}
+
+// Close closes every AvsSync. It must be called after Start returns.
+func (m *MultiAvsSync) Close() error {
+	var errs []error
+	for _, avsSync := range m.avsSyncs {
+		if err := avsSync.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("avs %s: %w", avsSync.Config().AvsName, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MetricsHandler serves /metrics, as well as the /healthz and /readyz probes, whose checks are named <avs>/<check>
+func (m *MultiAvsSync) MetricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	mux.Handle("/healthz", healthHandler(m.readerTimeout, m.checks((*AvsSync).livenessChecks)))
+	mux.Handle("/readyz", healthHandler(m.readerTimeout, func() []namedHealthCheck {
+		return append(m.checks((*AvsSync).allReadinessChecks)(), m.startupChecks()...)
+	}))
+	return mux
+}
+
+func (m *MultiAvsSync) checks(checksOf func(*AvsSync) []namedHealthCheck) func() []namedHealthCheck {
+	return func() []namedHealthCheck {
+		var checks []namedHealthCheck
+		for _, avsSync := range m.avsSyncs {
+			name := avsSync.Config().AvsName
+			for _, check := range checksOf(avsSync) {
+				checks = append(checks, namedHealthCheck{name: name + "/" + check.name, check: check.check})
+			}
+		}
+		return checks
+	}
+}
+
+func (m *MultiAvsSync) startupChecks() []namedHealthCheck {
+	var checks []namedHealthCheck
+	for name, err := range m.failedTargets {
+		checks = append(checks, namedHealthCheck{name: name + "/startup", check: func(ctx context.Context) error {
+			return fmt.Errorf("not running: %w", err)
+		}})
+	}
+	return checks
+}
+
+// readerTimeout is the longest reader timeout of the targets
+func (m *MultiAvsSync) readerTimeout() time.Duration {
+	var timeout time.Duration
+	for _, avsSync := range m.avsSyncs {
+		timeout = max(timeout, avsSync.readerTimeout())
+	}
+	return timeout
+}
+
+// listenAndServe serves handler on addr until ctx is done
+func listenAndServe(ctx context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{Addr: addr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
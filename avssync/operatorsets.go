@@ -0,0 +1,207 @@
+package avssync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	allocationmanager "github.com/Layr-Labs/eigensdk-go/contracts/bindings/AllocationManager"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// the RegistryCoordinator supports at most 192 quorums, operator sets with larger ids can't be quorums
+const maxQuorumCount = 192
+
+// OperatorSetReader reads the operator sets of an AVS, and the allocations of their operators, from the AllocationManager
+// of slashing enabled deployments.
+// It is implemented by the eigensdk AllocationManager binding, and by the in-memory fake of the avssynctest package.
+type OperatorSetReader interface {
+	GetOperatorSetCount(opts *bind.CallOpts, avs common.Address) (*big.Int, error)
+	IsOperatorSet(opts *bind.CallOpts, operatorSet allocationmanager.OperatorSet) (bool, error)
+	GetMembers(opts *bind.CallOpts, operatorSet allocationmanager.OperatorSet) ([]common.Address, error)
+	GetStrategiesInOperatorSet(opts *bind.CallOpts, operatorSet allocationmanager.OperatorSet) ([]common.Address, error)
+	// GetAllocations returns the allocation of each of the operators to the operator set, for strategy
+	GetAllocations(opts *bind.CallOpts, operators []common.Address, operatorSet allocationmanager.OperatorSet, strategy common.Address) ([]allocationmanager.IAllocationManagerTypesAllocation, error)
+	// GetAllocatedStake returns the stake (in shares) allocated by each of the operators to the operator set, for each of the strategies
+	GetAllocatedStake(opts *bind.CallOpts, operatorSet allocationmanager.OperatorSet, operators []common.Address, strategies []common.Address) ([][]*big.Int, error)
+}
+
+var _ OperatorSetReader = (*allocationmanager.ContractAllocationManagerCaller)(nil)
+
+// PendingAllocation is a change of the magnitude an operator allocates to an operator set for a strategy, which takes
+// effect at EffectBlock. A negative PendingDiff is a deallocation, whose magnitude stays slashable until then.
+type PendingAllocation struct {
+	Operator         common.Address `json:"operator"`
+	Strategy         common.Address `json:"strategy"`
+	CurrentMagnitude uint64         `json:"currentMagnitude"`
+	PendingDiff      *big.Int       `json:"pendingDiff"`
+	EffectBlock      uint32         `json:"effectBlock"`
+}
+
+func (p PendingAllocation) Deallocation() bool {
+	return p.PendingDiff.Sign() < 0
+}
+
+// OperatorSetStatus is an operator set of the AVS, as of the last sync
+type OperatorSetStatus struct {
+	Id         uint32           `json:"id"`
+	Operators  int              `json:"operators"`
+	Strategies []common.Address `json:"strategies"`
+	// total stake (in shares) allocated to the operator set by its operators, by strategy
+	AllocatedStake     map[common.Address]*big.Int `json:"allocatedStake"`
+	PendingAllocations []PendingAllocation         `json:"pendingAllocations,omitempty"`
+}
+
+// SetOperatorSetReader makes AvsSync sync the operator sets of avs (see Config.UseOperatorSets) instead of the quorums of
+// the config: the operator sets are enumerated at every sync, and their ids are the quorums whose stakes are updated.
+// It must be called before Start.
+func (a *AvsSync) SetOperatorSetReader(reader OperatorSetReader, avs common.Address) {
+	a.operatorSetReader = reader
+	a.avsAddr = avs
+}
+
+// updateOperatorSets replaces the quorum set by the operator sets of the AVS, and reports their allocated stake and the
+// operators whose allocations are pending or deallocating. The previous quorum set is kept if they can't be enumerated,
+// or if none is found: an AVS without operator sets is more likely a misconfiguration (e.g. the wrong service manager)
+// than a reason to stop syncing. Without a previous quorum set, the sync fails instead of silently updating nothing.
+func (a *AvsSync) updateOperatorSets(ctx context.Context) {
+	a.logger.Info("Fetching operator sets of the AVS", "avs", a.avsAddr.Hex())
+	ids, err := a.fetchOperatorSetIds(ctx)
+	if err == nil && len(ids) == 0 {
+		err = errors.New("no operator set of the AVS can be a quorum")
+	}
+	if err != nil {
+		a.logger.Error("Error fetching operator sets, keeping the previous quorum set", "err", err, "avs", a.avsAddr.Hex(), "quorums", convertQuorumsBytesToInts(a.quorums))
+		if len(a.quorums) == 0 && len(a.operators) == 0 {
+			a.markSyncFailed()
+		}
+		return
+	}
+
+	var quorums []byte
+	var operatorSets []OperatorSetStatus
+	for _, id := range ids {
+		quorums = append(quorums, byte(id))
+		timeoutCtx, cancel := context.WithTimeout(ctx, a.readerTimeoutDuration)
+		operatorSet, err := a.fetchOperatorSet(timeoutCtx, id)
+		cancel()
+		if err != nil {
+			// the stakes of the operator set are still updated, only the report is missing
+			a.logger.Warn("Error fetching allocations of operator set", "operatorSet", id, "err", err)
+			continue
+		}
+		for _, pending := range operatorSet.PendingAllocations {
+			msg := "Operator has a pending allocation"
+			if pending.Deallocation() {
+				msg = "Operator is deallocating"
+			}
+			a.logger.Info(msg, "operatorSet", id, "operator", pending.Operator.Hex(), "strategy", pending.Strategy.Hex(),
+				"currentMagnitude", pending.CurrentMagnitude, "pendingDiff", pending.PendingDiff, "effectBlock", pending.EffectBlock)
+		}
+		a.Metrics.OperatorSetSet(operatorSet)
+		operatorSets = append(operatorSets, operatorSet)
+	}
+	a.setQuorums(quorums)
+	a.statusMu.Lock()
+	a.operatorSets = operatorSets
+	a.statusMu.Unlock()
+}
+
+// fetchOperatorSetIds returns the ids of the operator sets of the AVS that can be quorums. Every read has its own
+// timeout, since there can be up to one per possible quorum.
+func (a *AvsSync) fetchOperatorSetIds(ctx context.Context) ([]uint32, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, a.readerTimeoutDuration)
+	count, err := a.operatorSetReader.GetOperatorSetCount(&bind.CallOpts{Context: timeoutCtx}, a.avsAddr)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch operator set count: %w", err)
+	}
+	// the AllocationManager doesn't list the ids of the operator sets, but the RegistryCoordinator creates the operator
+	// set of a quorum with the quorum number as id, so we look for the quorum numbers
+	var ids []uint32
+	for id := uint32(0); id < maxQuorumCount && uint64(len(ids)) < count.Uint64(); id++ {
+		timeoutCtx, cancel := context.WithTimeout(ctx, a.readerTimeoutDuration)
+		exists, err := a.operatorSetReader.IsOperatorSet(&bind.CallOpts{Context: timeoutCtx}, allocationmanager.OperatorSet{Avs: a.avsAddr, Id: id})
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("cannot check operator set %d: %w", id, err)
+		}
+		if exists {
+			ids = append(ids, id)
+		}
+	}
+	if uint64(len(ids)) < count.Uint64() {
+		a.logger.Warn("Some operator sets of the AVS have ids that aren't quorum numbers, they are not synced", "operatorSets", count, "synced", len(ids))
+	}
+	return ids, nil
+}
+
+// fetchOperatorSet reads the members of the operator set id, and their allocations
+func (a *AvsSync) fetchOperatorSet(ctx context.Context, id uint32) (OperatorSetStatus, error) {
+	opts := &bind.CallOpts{Context: ctx}
+	operatorSet := allocationmanager.OperatorSet{Avs: a.avsAddr, Id: id}
+	members, err := a.operatorSetReader.GetMembers(opts, operatorSet)
+	if err != nil {
+		return OperatorSetStatus{}, fmt.Errorf("cannot fetch members: %w", err)
+	}
+	strategies, err := a.operatorSetReader.GetStrategiesInOperatorSet(opts, operatorSet)
+	if err != nil {
+		return OperatorSetStatus{}, fmt.Errorf("cannot fetch strategies: %w", err)
+	}
+	status := OperatorSetStatus{
+		Id:             id,
+		Operators:      len(members),
+		Strategies:     strategies,
+		AllocatedStake: make(map[common.Address]*big.Int, len(strategies)),
+	}
+	for _, strategy := range strategies {
+		status.AllocatedStake[strategy] = new(big.Int)
+	}
+	if len(members) == 0 || len(strategies) == 0 {
+		return status, nil
+	}
+
+	allocatedStake, err := a.operatorSetReader.GetAllocatedStake(opts, operatorSet, members, strategies)
+	if err != nil {
+		return OperatorSetStatus{}, fmt.Errorf("cannot fetch allocated stake: %w", err)
+	}
+	for i := range members {
+		for j, strategy := range strategies {
+			status.AllocatedStake[strategy].Add(status.AllocatedStake[strategy], allocatedStake[i][j])
+		}
+	}
+	for _, strategy := range strategies {
+		allocations, err := a.operatorSetReader.GetAllocations(opts, members, operatorSet, strategy)
+		if err != nil {
+			return OperatorSetStatus{}, fmt.Errorf("cannot fetch allocations of strategy %s: %w", strategy.Hex(), err)
+		}
+		for i, allocation := range allocations {
+			// the AllocationManager applies the pending diff once its effect block is reached
+			if allocation.PendingDiff == nil || allocation.PendingDiff.Sign() == 0 {
+				continue
+			}
+			status.PendingAllocations = append(status.PendingAllocations, PendingAllocation{
+				Operator:         members[i],
+				Strategy:         strategy,
+				CurrentMagnitude: allocation.CurrentMagnitude,
+				PendingDiff:      allocation.PendingDiff,
+				EffectBlock:      allocation.EffectBlock,
+			})
+		}
+	}
+	return status, nil
+}
+
+// operatorSet returns the operator set of quorum as of the last sync, nil if it isn't known
+func (a *AvsSync) operatorSet(quorum byte) *OperatorSetStatus {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	for i := range a.operatorSets {
+		if a.operatorSets[i].Id == uint32(quorum) {
+			return &a.operatorSets[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,313 @@
+package avssync
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"slices"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/Layr-Labs/eigensdk-go/types"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// GasPlan is the simulated outcome of a stake update transaction
+type GasPlan struct {
+	GasEstimate   uint64
+	EstimatedCost *big.Int // in wei, nil if the gas price couldn't be fetched
+	FitsInBlock   bool
+	Chunks        int   // number of transactions the update would be split in, when it doesn't fit in a block
+	Err           error // the simulation failed, e.g. because the update would revert
+}
+
+// QuorumPlan is what a sync would do to the stakes of a quorum
+type QuorumPlan struct {
+	Quorum  byte
+	Drift   *QuorumStakeDrift // registry and new stake of every operator that would be updated
+	Skipped bool              // the stake drift is below the configured thresholds, so the quorum would not be updated
+	Gas     *GasPlan          // nil when updating an operator subset, in which case a single transaction updates every quorum
+	Err     error             // the stakes of the quorum couldn't be fetched
+	// the operator set of the quorum, nil unless syncing operator sets (or if it couldn't be fetched)
+	OperatorSet *OperatorSetStatus
+}
+
+// KickedOperators returns the operators that would be deregistered from the quorum for falling under its minimum stake
+func (p *QuorumPlan) KickedOperators() []common.Address {
+	var kicked []common.Address
+	if p.Drift == nil {
+		return kicked
+	}
+	for _, operator := range p.Drift.Operators {
+		if operator.BelowMinimumStake {
+			kicked = append(kicked, operator.Operator)
+		}
+	}
+	return kicked
+}
+
+// SyncPlan is what a sync would do, computed with eth_call/eth_estimateGas without sending any transaction
+type SyncPlan struct {
+	Sender   common.Address
+	GasPrice *big.Int // nil if it couldn't be fetched
+	Quorums  []*QuorumPlan
+	Gas      *GasPlan // only set when updating an operator subset
+}
+
+// Plan computes what the next sync would do, without sending any transaction.
+// onlyQuorums restricts the entire operator set update to these quorums, nil means every quorum.
+// operators plans updating only these operators instead of the configured ones (or the entire operator set), nil means the configured ones.
+func (a *AvsSync) Plan(ctx context.Context, onlyQuorums []byte, operators []common.Address) (*SyncPlan, error) {
+	if a.gasEstimator == nil {
+		return nil, fmt.Errorf("cannot plan a sync without a gas estimator")
+	}
+	plan := &SyncPlan{Sender: a.gasEstimator.sender}
+	timeoutCtx, cancel := context.WithTimeout(ctx, a.readerTimeoutDuration)
+	gasPrice, err := a.gasEstimator.GasPrice(timeoutCtx)
+	cancel()
+	if err != nil {
+		a.logger.Warn("Error fetching gas price, not estimating costs", "err", err)
+	} else {
+		plan.GasPrice = gasPrice
+	}
+
+	if len(operators) == 0 {
+		operators = a.operators
+	}
+	if len(operators) > 0 {
+		return a.planOperatorSubset(ctx, plan, operators)
+	}
+
+	a.maybeUpdateQuorumSet(ctx)
+	for _, quorum := range a.quorums {
+		if onlyQuorums != nil && !slices.Contains(onlyQuorums, quorum) {
+			continue
+		}
+		quorumPlan := a.planQuorum(ctx, quorum, plan.GasPrice)
+		quorumPlan.OperatorSet = a.operatorSet(quorum)
+		plan.Quorums = append(plan.Quorums, quorumPlan)
+	}
+	return plan, nil
+}
+
+// planQuorum fetches the operator set of the quorum the same way the update does, and simulates its entire operator set update
+func (a *AvsSync) planQuorum(ctx context.Context, quorum byte, gasPrice *big.Int) *QuorumPlan {
+	quorumPlan := &QuorumPlan{Quorum: quorum}
+	drift, err := a.getQuorumStakeDrift(ctx, quorum)
+	if err != nil {
+		quorumPlan.Err = err
+		return quorumPlan
+	}
+	quorumPlan.Drift = drift
+	if a.stakeDriftThresholds != nil {
+		quorumPlan.Skipped = !a.stakeDriftThresholds.ExceededBy(drift)
+	}
+
+	operators := make([]common.Address, 0, len(drift.Operators))
+	for _, operator := range drift.Operators {
+		operators = append(operators, operator.Operator)
+	}
+	sort.Slice(operators, func(i, j int) bool {
+		return operators[i].Big().Cmp(operators[j].Big()) < 0
+	})
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, a.readerTimeoutDuration)
+	defer cancel()
+	gasPlan := &GasPlan{}
+	quorumPlan.Gas = gasPlan
+	gasPlan.GasEstimate, gasPlan.Err = a.gasEstimator.SimulateUpdateOperatorsForQuorum(timeoutCtx, operators, quorum)
+	if gasPlan.Err != nil {
+		if a.chunkingEnabled() && isGasLimitError(gasPlan.Err) {
+			gasPlan.Err = nil
+			gasPlan.Chunks = len(chunkOperators(operators, a.maxOperatorsPerTx))
+		}
+		return quorumPlan
+	}
+	gasPlan.FitsInBlock, _, err = a.gasEstimator.FitsInBlock(timeoutCtx, gasPlan.GasEstimate)
+	if err != nil {
+		a.logger.Warn("Error checking block gas limit", "quorum", int(quorum), "err", err)
+	}
+	if !gasPlan.FitsInBlock && a.chunkingEnabled() {
+		gasPlan.Chunks = len(chunkOperators(operators, a.maxOperatorsPerTx))
+	}
+	if gasPrice != nil {
+		gasPlan.EstimatedCost = new(big.Int).Mul(new(big.Int).SetUint64(gasPlan.GasEstimate), gasPrice)
+	}
+	return quorumPlan
+}
+
+// planOperatorSubset computes the stakes of the configured operators in every quorum they are registered in,
+// and simulates the single transaction that updates all of them
+func (a *AvsSync) planOperatorSubset(ctx context.Context, plan *SyncPlan, operators []common.Address) (*SyncPlan, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, a.readerTimeoutDuration)
+	defer cancel()
+	opts := &bind.CallOpts{Context: timeoutCtx}
+
+	drifts := make(map[byte]*QuorumStakeDrift)
+	minimumStakes := make(map[byte]*big.Int)
+	for _, operator := range operators {
+		operatorId, err := a.AvsReader.GetOperatorId(opts, operator)
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch operator id of %s: %w", operator.Hex(), err)
+		}
+		stakes, err := a.AvsReader.GetOperatorStakeInQuorumsOfOperatorAtCurrentBlock(opts, types.OperatorId(operatorId))
+		if err != nil {
+			return nil, fmt.Errorf("cannot fetch stakes of operator %s: %w", operator.Hex(), err)
+		}
+		for quorumNum, registryStake := range stakes {
+			quorum := byte(quorumNum)
+			if _, ok := drifts[quorum]; !ok {
+				minimumStake, err := a.AvsReader.GetMinimumStakeForQuorum(opts, quorum)
+				if err != nil {
+					return nil, fmt.Errorf("cannot fetch minimum stake for quorum %d: %w", quorum, err)
+				}
+				minimumStakes[quorum] = minimumStake
+				drifts[quorum] = &QuorumStakeDrift{Quorum: quorum, RegistryStake: big.NewInt(0), CurrentStake: big.NewInt(0)}
+			}
+			weight, err := a.AvsReader.WeightOfOperatorForQuorum(opts, quorum, operator)
+			if err != nil {
+				return nil, fmt.Errorf("cannot compute weight of operator %s in quorum %d: %w", operator.Hex(), quorum, err)
+			}
+			operatorDrift := OperatorStakeDrift{Operator: operator, RegistryStake: registryStake, CurrentStake: weight}
+			if weight.Cmp(minimumStakes[quorum]) < 0 {
+				operatorDrift.BelowMinimumStake = true
+				operatorDrift.CurrentStake = big.NewInt(0)
+			}
+			drift := drifts[quorum]
+			drift.RegistryStake.Add(drift.RegistryStake, operatorDrift.RegistryStake)
+			drift.CurrentStake.Add(drift.CurrentStake, operatorDrift.CurrentStake)
+			drift.Operators = append(drift.Operators, operatorDrift)
+		}
+	}
+	for _, drift := range drifts {
+		plan.Quorums = append(plan.Quorums, &QuorumPlan{Quorum: drift.Quorum, Drift: drift})
+	}
+	sort.Slice(plan.Quorums, func(i, j int) bool {
+		return plan.Quorums[i].Quorum < plan.Quorums[j].Quorum
+	})
+
+	plan.Gas = &GasPlan{}
+	plan.Gas.GasEstimate, plan.Gas.Err = a.gasEstimator.SimulateUpdateOperators(timeoutCtx, operators)
+	if plan.Gas.Err == nil {
+		plan.Gas.FitsInBlock, _, _ = a.gasEstimator.FitsInBlock(timeoutCtx, plan.Gas.GasEstimate)
+		if plan.GasPrice != nil {
+			plan.Gas.EstimatedCost = new(big.Int).Mul(new(big.Int).SetUint64(plan.Gas.GasEstimate), plan.GasPrice)
+		}
+	}
+	return plan, nil
+}
+
+// EstimatedCost returns the estimated cost of the transactions the sync would send, in wei, or nil if the gas price
+// couldn't be fetched. It is a lower bound when the cost of some update is unknown (e.g. it would be chunked, or
+// its simulation failed).
+func (p *SyncPlan) EstimatedCost() (cost *big.Int, complete bool) {
+	if p.GasPrice == nil {
+		return nil, false
+	}
+	cost, complete = new(big.Int), true
+	add := func(gasPlan *GasPlan) {
+		if gasPlan.EstimatedCost == nil {
+			complete = false
+			return
+		}
+		cost.Add(cost, gasPlan.EstimatedCost)
+	}
+	for _, quorumPlan := range p.Quorums {
+		switch {
+		case quorumPlan.Skipped:
+		case quorumPlan.Err != nil:
+			complete = false
+		case quorumPlan.Gas != nil:
+			add(quorumPlan.Gas)
+		}
+	}
+	if p.Gas != nil {
+		add(p.Gas)
+	}
+	return cost, complete
+}
+
+// Write prints the plan in a human readable format
+func (p *SyncPlan) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Sync plan (simulated from %s, gas price %s wei)\n", p.Sender.Hex(), formatBigInt(p.GasPrice))
+	for _, quorumPlan := range p.Quorums {
+		fmt.Fprintf(tw, "\nQuorum %d\n", quorumPlan.Quorum)
+		if quorumPlan.OperatorSet != nil {
+			writeOperatorSet(tw, quorumPlan.OperatorSet)
+		}
+		if quorumPlan.Err != nil {
+			fmt.Fprintf(tw, "  error: %v\n", quorumPlan.Err)
+			continue
+		}
+		drift := quorumPlan.Drift
+		if quorumPlan.Skipped {
+			fmt.Fprintf(tw, "  stake drift below thresholds, the quorum would not be updated\n")
+		}
+		fmt.Fprintf(tw, "  OPERATOR\tREGISTRY STAKE\tNEW STAKE\tDELTA\tKICKED\n")
+		for _, operator := range drift.Operators {
+			fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%t\n", operator.Operator.Hex(), operator.RegistryStake, operator.CurrentStake, operator.Delta(), operator.BelowMinimumStake)
+		}
+		fmt.Fprintf(tw, "  TOTAL\t%s\t%s\t%s\t%d\n", drift.RegistryStake, drift.CurrentStake, drift.Delta(), len(quorumPlan.KickedOperators()))
+		for _, operator := range quorumPlan.KickedOperators() {
+			fmt.Fprintf(tw, "  would be kicked for falling under the quorum minimum stake: %s\n", operator.Hex())
+		}
+		if quorumPlan.Gas != nil {
+			writeGasPlan(tw, quorumPlan.Gas)
+		}
+	}
+	if p.Gas != nil {
+		fmt.Fprintf(tw, "\nOperator subset update (all quorums in a single transaction)\n")
+		writeGasPlan(tw, p.Gas)
+	}
+	return tw.Flush()
+}
+
+func writeOperatorSet(w io.Writer, operatorSet *OperatorSetStatus) {
+	for _, strategy := range operatorSet.Strategies {
+		fmt.Fprintf(w, "  operator set allocated stake of strategy %s: %s\n", strategy.Hex(), operatorSet.AllocatedStake[strategy])
+	}
+	for _, pending := range operatorSet.PendingAllocations {
+		direction := "allocating"
+		if pending.Deallocation() {
+			direction = "deallocating"
+		}
+		fmt.Fprintf(w, "  %s is %s %s magnitude of strategy %s (currently %d) at block %d\n",
+			pending.Operator.Hex(), direction, new(big.Int).Abs(pending.PendingDiff), pending.Strategy.Hex(), pending.CurrentMagnitude, pending.EffectBlock)
+	}
+}
+
+func writeGasPlan(w io.Writer, gasPlan *GasPlan) {
+	switch {
+	case gasPlan.Err != nil:
+		fmt.Fprintf(w, "  simulation failed: %v\n", gasPlan.Err)
+	case gasPlan.Chunks > 0:
+		fmt.Fprintf(w, "  doesn't fit in a block, would be updated in %d chunks\n", gasPlan.Chunks)
+	default:
+		fmt.Fprintf(w, "  estimated gas: %d (fits in block: %t), estimated cost: %s wei\n", gasPlan.GasEstimate, gasPlan.FitsInBlock, formatBigInt(gasPlan.EstimatedCost))
+	}
+}
+
+func formatBigInt(n *big.Int) string {
+	if n == nil {
+		return "unknown"
+	}
+	return n.String()
+}
+
+// dryRunSync prints what a sync would do instead of sending any transaction
+func (a *AvsSync) dryRunSync(ctx context.Context, onlyQuorums []byte, operators []common.Address) {
+	a.logger.Info("Dry run, simulating stake update without sending any transaction")
+	plan, err := a.Plan(ctx, onlyQuorums, operators)
+	if err != nil {
+		a.markSyncFailed()
+		a.logger.Error("Error planning stake update", "err", err)
+		return
+	}
+	if err := plan.Write(os.Stdout); err != nil {
+		a.logger.Error("Error printing stake update plan", "err", err)
+	}
+}
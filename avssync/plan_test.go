@@ -0,0 +1,64 @@
+package avssync
+
+import (
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncPlanWrite(t *testing.T) {
+	drift := newQuorumStakeDrift([2]int64{100, 150}, [2]int64{100, 0})
+	drift.Operators[1].BelowMinimumStake = true
+	plan := &SyncPlan{
+		GasPrice: big.NewInt(10),
+		Quorums: []*QuorumPlan{
+			{
+				Quorum: 0,
+				Drift:  drift,
+				Gas:    &GasPlan{GasEstimate: 21000, EstimatedCost: big.NewInt(210000), FitsInBlock: true},
+			},
+			{
+				Quorum: 1,
+				Err:    errors.New("cannot fetch operator stakes"),
+			},
+		},
+	}
+	require.Equal(t, []common.Address{drift.Operators[1].Operator}, plan.Quorums[0].KickedOperators())
+	require.Empty(t, plan.Quorums[1].KickedOperators())
+
+	var out strings.Builder
+	require.NoError(t, plan.Write(&out))
+	require.Contains(t, out.String(), "gas price 10 wei")
+	require.Contains(t, out.String(), "would be kicked for falling under the quorum minimum stake: "+drift.Operators[1].Operator.Hex())
+	require.Contains(t, out.String(), "estimated gas: 21000 (fits in block: true), estimated cost: 210000 wei")
+	require.Contains(t, out.String(), "error: cannot fetch operator stakes")
+}
+
+func TestSyncPlanEstimatedCost(t *testing.T) {
+	plan := &SyncPlan{
+		GasPrice: big.NewInt(10),
+		Quorums: []*QuorumPlan{
+			{Quorum: 0, Gas: &GasPlan{EstimatedCost: big.NewInt(100)}},
+			// below the stake drift thresholds, so not updated
+			{Quorum: 1, Skipped: true, Gas: &GasPlan{EstimatedCost: big.NewInt(1000)}},
+			{Quorum: 2, Gas: &GasPlan{EstimatedCost: big.NewInt(200)}},
+		},
+	}
+	cost, complete := plan.EstimatedCost()
+	require.Equal(t, big.NewInt(300), cost)
+	require.True(t, complete)
+
+	// the cost of chunked updates isn't estimated
+	plan.Quorums = append(plan.Quorums, &QuorumPlan{Quorum: 3, Gas: &GasPlan{Chunks: 2}})
+	cost, complete = plan.EstimatedCost()
+	require.Equal(t, big.NewInt(300), cost)
+	require.False(t, complete)
+
+	plan.GasPrice = nil
+	cost, _ = plan.EstimatedCost()
+	require.Nil(t, cost)
+}
@@ -0,0 +1,45 @@
+package avssync
+
+import (
+	"context"
+
+	"github.com/Layr-Labs/eigensdk-go/chainio/clients/avsregistry"
+	opstateretriever "github.com/Layr-Labs/eigensdk-go/contracts/bindings/OperatorStateRetriever"
+	"github.com/Layr-Labs/eigensdk-go/types"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// AvsReader reads the quorums, operators and stakes of the AVS registry contracts.
+// It is implemented by the eigensdk avsregistry.ChainReader, and by the in-memory fake of the avssynctest package.
+type AvsReader interface {
+	GetQuorumCount(opts *bind.CallOpts) (uint8, error)
+	// GetOperatorAddrsInQuorumsAtCurrentBlock returns the operators registered in each of the quorums
+	GetOperatorAddrsInQuorumsAtCurrentBlock(opts *bind.CallOpts, quorumNumbers types.QuorumNums) ([][]common.Address, error)
+	// GetOperatorsStakeInQuorumsAtCurrentBlock returns the operators registered in each of the quorums, with their stake
+	// recorded in the StakeRegistry (i.e. as of their last update)
+	GetOperatorsStakeInQuorumsAtCurrentBlock(opts *bind.CallOpts, quorumNumbers types.QuorumNums) ([][]opstateretriever.OperatorStateRetrieverOperator, error)
+	GetMinimumStakeForQuorum(opts *bind.CallOpts, quorumNumber uint8) (types.StakeAmount, error)
+	// WeightOfOperatorForQuorum returns the current stake of the operator in the quorum, which an update would record
+	WeightOfOperatorForQuorum(opts *bind.CallOpts, quorumNumber uint8, operatorAddr common.Address) (types.StakeAmount, error)
+	// GetOperatorId returns the zero id if the operator never registered
+	GetOperatorId(opts *bind.CallOpts, operatorAddress common.Address) ([32]byte, error)
+	// GetOperatorStakeInQuorumsOfOperatorAtCurrentBlock returns the recorded stake of the operator in the quorums it is registered in
+	GetOperatorStakeInQuorumsOfOperatorAtCurrentBlock(opts *bind.CallOpts, operatorId types.OperatorId) (map[types.QuorumNum]types.StakeAmount, error)
+}
+
+// AvsWriter sends the stake update transactions to the RegistryCoordinator.
+// It is implemented by the eigensdk avsregistry.ChainWriter, and by the in-memory fake of the avssynctest package.
+type AvsWriter interface {
+	// UpdateStakesOfEntireOperatorSetForQuorums updates the stakes of every operator of the quorums, and reverts if
+	// operatorsPerQuorum (sorted by address) isn't exactly the operator set of each quorum
+	UpdateStakesOfEntireOperatorSetForQuorums(ctx context.Context, operatorsPerQuorum [][]common.Address, quorumNumbers types.QuorumNums, waitForReceipt bool) (*gethtypes.Receipt, error)
+	// UpdateStakesOfOperatorSubsetForAllQuorums updates the stakes of the operators in all the quorums they are registered in
+	UpdateStakesOfOperatorSubsetForAllQuorums(ctx context.Context, operators []common.Address, waitForReceipt bool) (*gethtypes.Receipt, error)
+}
+
+var (
+	_ AvsReader = (*avsregistry.ChainReader)(nil)
+	_ AvsWriter = (*avsregistry.ChainWriter)(nil)
+)
@@ -0,0 +1,313 @@
+package avssync
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrConfigNotReloadable = errors.New("config changes can't be applied without a restart")
+
+// hotReloadableConfigKeys are the keys of the config that Reload can change in a running AvsSync.
+// Changing any other key (rpc urls, signer, contracts, schedule, servers...) requires a restart.
+var hotReloadableConfigKeys = map[string]bool{
+	"operators":                  true,
+	"quorums":                    true,
+	"fetch-quorums-dynamically":  true,
+	"retry-sync-n-times":         true,
+	"retry-base-backoff":         true,
+	"retry-max-backoff":          true,
+	"retry-jitter":               true,
+	"retry-deadline":             true,
+	"only-update-on-stake-drift": true,
+	"stake-drift-threshold-abs":  true,
+	"stake-drift-threshold-pct":  true,
+	"max-operators-per-tx":       true,
+	"gas-budget-eth":             true,
+	"gas-budget-period":          true,
+	"low-balance-threshold-eth":  true,
+	"reader-timeout-duration":    true,
+	"writer-timeout-duration":    true,
+	"shutdown-grace-period":      true,
+	"health-stuck-threshold":     true,
+	"readiness-max-missed-syncs": true,
+}
+
+// ConfigChange is the change of a config key, with secrets redacted
+type ConfigChange struct {
+	Key string
+	Old string
+	New string
+}
+
+func (c ConfigChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Key, c.Old, c.New)
+}
+
+// configChanges returns the keys whose values differ between oldConfig and newConfig
+func configChanges(oldConfig, newConfig Config) []ConfigChange {
+	var changes []ConfigChange
+	var diff func(oldValue, newValue, oldRedacted, newRedacted reflect.Value)
+	diff = func(oldValue, newValue, oldRedacted, newRedacted reflect.Value) {
+		for i := 0; i < oldValue.NumField(); i++ {
+			if oldValue.Type().Field(i).Anonymous {
+				// the keys of the embedded SignerConfig are keys of the config
+				diff(oldValue.Field(i), newValue.Field(i), oldRedacted.Field(i), newRedacted.Field(i))
+				continue
+			}
+			if reflect.DeepEqual(oldValue.Field(i).Interface(), newValue.Field(i).Interface()) {
+				continue
+			}
+			changes = append(changes, ConfigChange{
+				Key: oldValue.Type().Field(i).Tag.Get("yaml"),
+				Old: fmt.Sprint(oldRedacted.Field(i).Interface()),
+				New: fmt.Sprint(newRedacted.Field(i).Interface()),
+			})
+		}
+	}
+	diff(reflect.ValueOf(oldConfig), reflect.ValueOf(newConfig), reflect.ValueOf(oldConfig.Redacted()), reflect.ValueOf(newConfig.Redacted()))
+	return changes
+}
+
+// Reload replaces the config of the running AvsSync, which must have been validated (see Config.Validate).
+// The whole reload is rejected with ErrConfigNotReloadable if a key that isn't hot reloadable changed.
+// Otherwise the new config is applied by the sync loop, before the next sync starts (a running sync completes
+// with the previous config). The schedule is left untouched.
+func (a *AvsSync) Reload(config Config) error {
+	if _, err := config.stakeDriftThresholds(); err != nil {
+		return err
+	}
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	// diff against the reload that's still waiting to be applied, if any
+	latestConfig := a.config
+	if a.pendingConfig != nil {
+		latestConfig = *a.pendingConfig
+	}
+	changes := configChanges(latestConfig, config)
+	if len(changes) == 0 {
+		a.logger.Info("Config reloaded, nothing changed")
+		return nil
+	}
+	var rejectedKeys []string
+	for _, change := range changes {
+		if !hotReloadableConfigKeys[change.Key] {
+			rejectedKeys = append(rejectedKeys, change.Key)
+		}
+	}
+	if len(rejectedKeys) > 0 {
+		return fmt.Errorf("%w: %s", ErrConfigNotReloadable, strings.Join(rejectedKeys, ", "))
+	}
+
+	a.pendingConfig = &config
+	select {
+	case a.configReloads <- struct{}{}:
+	default:
+		// the loop was already notified, and will pick the latest pending config
+	}
+	a.logger.Info("Config reload accepted, applying it before the next sync", "changes", changes)
+	return nil
+}
+
+// applyPendingConfig applies the config passed to Reload, if any. It must only be called by the sync loop, in between syncs.
+func (a *AvsSync) applyPendingConfig() {
+	a.statusMu.Lock()
+	config := a.pendingConfig
+	if config == nil {
+		a.statusMu.Unlock()
+		return
+	}
+	a.pendingConfig = nil
+	changes := configChanges(a.config, *config)
+	// validated by Reload
+	stakeDriftThresholds, _ := config.stakeDriftThresholds()
+	var quorums []byte
+	for _, quorum := range config.Quorums {
+		quorums = append(quorums, byte(quorum))
+	}
+
+	a.config = *config
+	a.operators = config.Operators
+	a.quorums = quorums
+	a.fetchQuorumsDynamically = config.FetchQuorumsDynamically
+	a.RetrySyncNTimes = config.RetrySyncNTimes
+	a.retryPolicy = config.retryPolicy()
+	a.stakeDriftThresholds = stakeDriftThresholds
+	a.maxOperatorsPerTx = config.MaxOperatorsPerTx
+	a.gasBudget = config.gasBudget()
+	a.lowBalanceThreshold = ethToWei(config.LowBalanceThresholdEth)
+	a.Metrics.SenderLowBalanceThresholdSet(a.lowBalanceThreshold)
+	a.readerTimeoutDuration = config.ReaderTimeout
+	a.writerTimeoutDuration = config.WriterTimeout
+	a.shutdownGracePeriod = config.ShutdownGracePeriod
+	a.healthConfig = HealthConfig{
+		StuckThreshold: config.HealthStuckThreshold,
+		MaxMissedSyncs: config.ReadinessMaxMissedSyncs,
+	}
+	a.statusMu.Unlock()
+
+	if a.eventWatcher != nil {
+		a.eventWatcher.SetOperators(config.Operators)
+	}
+	a.logger.Info("Config reload applied", "changes", changes)
+}
+
+// ConfigWatcher reloads the config of an AvsSync when asked to (e.g. on SIGHUP), or when the content of the config file changes
+// Reloads are rejected if the secrets of the signer changed, see checkSignerSecrets.
+type ConfigWatcher struct {
+	avsSync      *AvsSync
+	path         string
+	load         func() (Config, error)
+	pollInterval time.Duration
+	lastHash     [sha256.Size]byte
+	// hashes of the signer secrets read by Start, nil if they couldn't be read
+	signerSecrets map[string][sha256.Size]byte
+}
+
+// NewConfigWatcher creates a watcher of the config file at path. load must return the validated config
+// (from the file, overridden by flags and env vars). A pollInterval of 0 disables reloads on file changes.
+func NewConfigWatcher(avsSync *AvsSync, path string, load func() (Config, error), pollInterval time.Duration) *ConfigWatcher {
+	w := &ConfigWatcher{avsSync: avsSync, path: path, load: load, pollInterval: pollInterval}
+	if data, err := os.ReadFile(path); err == nil {
+		w.lastHash = sha256.Sum256(data)
+	}
+	return w
+}
+
+// Start reloads the config on every reloadRequests until ctx is done, and on file changes if polling is enabled
+func (w *ConfigWatcher) Start(ctx context.Context, reloadRequests <-chan os.Signal) {
+	signerSecrets, err := readSignerSecrets(ctx, w.avsSync.Config())
+	if err != nil {
+		w.avsSync.logger.Warn("Cannot read the secrets of the signer, changes of them won't be detected by reloads", "err", err)
+	}
+	w.signerSecrets = signerSecrets
+	var poll <-chan time.Time
+	if w.pollInterval > 0 {
+		ticker := time.NewTicker(w.pollInterval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-reloadRequests:
+			w.avsSync.logger.Info("Reloading config", "reason", sig.String(), "path", w.path)
+			w.reload(ctx)
+		case <-poll:
+			// the content is compared instead of the modification time, so that atomic replacements
+			// of the file (e.g. kubernetes ConfigMap updates) are detected too
+			data, err := os.ReadFile(w.path)
+			if err != nil {
+				w.avsSync.logger.Warn("Cannot read config file to check for changes", "path", w.path, "err", err)
+				continue
+			}
+			if sha256.Sum256(data) == w.lastHash {
+				continue
+			}
+			w.avsSync.logger.Info("Reloading config", "reason", "config file changed", "path", w.path)
+			w.reload(ctx)
+		}
+	}
+}
+
+func (w *ConfigWatcher) reload(ctx context.Context) {
+	// the hash is updated even if the reload fails, so that a rejected change is logged once rather than at every poll
+	if data, err := os.ReadFile(w.path); err == nil {
+		w.lastHash = sha256.Sum256(data)
+	}
+	config, err := w.load()
+	if err != nil {
+		w.avsSync.logger.Error("Config reload rejected, keeping the current config", "err", err)
+		return
+	}
+	if err := w.checkSignerSecrets(ctx, config); err != nil {
+		w.avsSync.logger.Error("Config reload rejected, keeping the current config", "err", err)
+		return
+	}
+	if err := w.avsSync.Reload(config); err != nil {
+		w.avsSync.logger.Error("Config reload rejected, keeping the current config", "err", err)
+	}
+}
+
+// checkSignerSecrets re-reads the secrets of the signer of config, and returns ErrConfigNotReloadable if they changed:
+// the signer only reads them at startup, so using them requires a restart. The check is skipped (with a warning) if
+// the secrets can't be read.
+func (w *ConfigWatcher) checkSignerSecrets(ctx context.Context, config Config) error {
+	if w.signerSecrets == nil {
+		return nil
+	}
+	signerSecrets, err := readSignerSecrets(ctx, config)
+	if err != nil {
+		w.avsSync.logger.Warn("Cannot re-read the secrets of the signer, not checking whether they changed", "err", err)
+		return nil
+	}
+	var changedKeys []string
+	for key, hash := range signerSecrets {
+		if previousHash, ok := w.signerSecrets[key]; ok && previousHash != hash {
+			changedKeys = append(changedKeys, key)
+		}
+	}
+	if len(changedKeys) > 0 {
+		sort.Strings(changedKeys)
+		return fmt.Errorf("%w: the secrets of %s changed", ErrConfigNotReloadable, strings.Join(changedKeys, ", "))
+	}
+	return nil
+}
+
+// readSignerSecrets reads the secrets the signer of config reads at startup from the secret manager and from files,
+// and returns their hashes by config key (the secrets themselves aren't kept)
+func readSignerSecrets(ctx context.Context, config Config) (map[string][sha256.Size]byte, error) {
+	secretRefs := map[string]string{}
+	secretFiles := map[string]string{}
+	if config.UseFireblocks {
+		secretRefs["secret-manager-fireblocks-api-key-name"] = config.SecretManagerFireblocksAPIKeyName
+		secretRefs["secret-manager-fireblocks-api-secret-name"] = config.SecretManagerFireblocksAPISecretName
+		if config.SecretManagerFireblocksAPISecretName == "" {
+			secretFiles["fireblocks-api-secret-path"] = config.FireblocksAPISecretPath
+		}
+	} else {
+		secretRefs["secret-manager-ecdsa-private-key-name"] = config.SecretManagerEcdsaPrivateKeyName
+		secretFiles["ecdsa-private-key-file"] = config.EcdsaPrivateKeyFile
+		secretFiles["ecdsa-keystore-path"] = config.EcdsaKeystorePath
+		secretFiles["ecdsa-keystore-password-file"] = config.EcdsaKeystorePasswordFile
+	}
+
+	hashes := map[string][sha256.Size]byte{}
+	for key, path := range secretFiles {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %w", key, err)
+		}
+		hashes[key] = sha256.Sum256(data)
+	}
+	var secretReader *SecretReader
+	for key, ref := range secretRefs {
+		if ref == "" {
+			continue
+		}
+		if secretReader == nil {
+			var err error
+			if secretReader, err = NewSecretReaderFromConfig(config); err != nil {
+				return nil, fmt.Errorf("cannot create secret reader: %w", err)
+			}
+		}
+		timeoutCtx, cancel := context.WithTimeout(ctx, config.ReaderTimeout)
+		secret, err := secretReader.ReadSecret(timeoutCtx, ref)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %w", key, err)
+		}
+		hashes[key] = sha256.Sum256([]byte(secret))
+	}
+	return hashes, nil
+}
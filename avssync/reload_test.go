@@ -0,0 +1,120 @@
+package avssync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func pendingConfig(avsSync *AvsSync) *Config {
+	avsSync.statusMu.Lock()
+	defer avsSync.statusMu.Unlock()
+	return avsSync.pendingConfig
+}
+
+func TestReload(t *testing.T) {
+	avsSync := newTestAvsSync([]byte{0}, nil)
+	require.NoError(t, avsSync.Reload(avsSync.Config()))
+	require.Nil(t, pendingConfig(avsSync))
+
+	cfg := avsSync.Config()
+	cfg.EthHttpUrls = []string{"http://other"}
+	cfg.RetrySyncNTimes = 5
+	err := avsSync.Reload(cfg)
+	require.ErrorIs(t, err, ErrConfigNotReloadable)
+	require.ErrorContains(t, err, "eth-http-url")
+	require.Nil(t, pendingConfig(avsSync))
+
+	cfg = avsSync.Config()
+	cfg.Operators = []common.Address{common.HexToAddress("0x1")}
+	cfg.Quorums = []int{0, 1}
+	cfg.RetrySyncNTimes = 5
+	cfg.WriterTimeout = time.Minute
+	require.NoError(t, avsSync.Reload(cfg))
+	// nothing changes until the loop applies the reload
+	require.Equal(t, 1, avsSync.RetrySyncNTimes)
+
+	avsSync.applyPendingConfig()
+	require.Nil(t, pendingConfig(avsSync))
+	require.Equal(t, cfg, avsSync.Config())
+	require.Equal(t, []common.Address{common.HexToAddress("0x1")}, avsSync.operators)
+	require.Equal(t, []byte{0, 1}, avsSync.quorums)
+	require.Equal(t, 5, avsSync.RetrySyncNTimes)
+	require.Equal(t, time.Minute, avsSync.writerTimeoutDuration)
+}
+
+func TestConfigChanges(t *testing.T) {
+	oldConfig := validTestConfig()
+	newConfig := oldConfig
+	newConfig.EcdsaPrivateKey = "4567"
+	newConfig.Quorums = []int{1}
+	require.Equal(t, []ConfigChange{
+		{Key: "quorums", Old: "[]", New: "[1]"},
+		{Key: "ecdsa-private-key", Old: redacted, New: redacted},
+	}, configChanges(oldConfig, newConfig))
+}
+
+func TestConfigWatcher(t *testing.T) {
+	avsSync := newTestAvsSync([]byte{0}, nil)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("retry-sync-n-times: 1\n"), 0644))
+	load := func() (Config, error) {
+		cfg := avsSync.Config()
+		return cfg, ReadConfigFile(path, &cfg)
+	}
+	reloadRequests := make(chan os.Signal, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go NewConfigWatcher(avsSync, path, load, 10*time.Millisecond).Start(ctx, reloadRequests)
+
+	require.NoError(t, os.WriteFile(path, []byte("retry-sync-n-times: 2\n"), 0644))
+	require.Eventually(t, func() bool {
+		cfg := pendingConfig(avsSync)
+		return cfg != nil && cfg.RetrySyncNTimes == 2
+	}, time.Second, 10*time.Millisecond)
+
+	// a rejected change is kept out
+	require.NoError(t, os.WriteFile(path, []byte("retry-sync-n-times: 3\nmetrics-addr: :9091\n"), 0644))
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, 2, pendingConfig(avsSync).RetrySyncNTimes)
+
+	require.NoError(t, os.WriteFile(path, []byte("retry-sync-n-times: 4\n"), 0644))
+	// don't wait for the next poll
+	reloadRequests <- syscall.SIGHUP
+	require.Eventually(t, func() bool {
+		return pendingConfig(avsSync).RetrySyncNTimes == 4
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestConfigWatcherSignerSecrets(t *testing.T) {
+	avsSync := newTestAvsSync([]byte{0}, nil)
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("0x1234"), 0600))
+	avsSync.config.EcdsaPrivateKeyFile = keyFile
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("retry-sync-n-times: 2\n"), 0644))
+	load := func() (Config, error) {
+		cfg := avsSync.Config()
+		return cfg, ReadConfigFile(path, &cfg)
+	}
+	w := NewConfigWatcher(avsSync, path, load, 0)
+	var err error
+	w.signerSecrets, err = readSignerSecrets(context.Background(), avsSync.Config())
+	require.NoError(t, err)
+
+	// the signer only reads the key at startup
+	require.NoError(t, os.WriteFile(keyFile, []byte("0x5678"), 0600))
+	w.reload(context.Background())
+	require.Nil(t, pendingConfig(avsSync))
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("0x1234"), 0600))
+	w.reload(context.Background())
+	require.Equal(t, 2, pendingConfig(avsSync).RetrySyncNTimes)
+}
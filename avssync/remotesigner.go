@@ -0,0 +1,211 @@
+package avssync
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync/atomic"
+
+	"github.com/Layr-Labs/eigensdk-go/signerv2"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrRemoteSigner wraps the errors of the remote signer (unreachable, rejecting the transaction or returning an invalid one).
+// Stake updates failing with it get the UpdateStakeStatusSignerError status, since they need the attention of whoever
+// runs the signer rather than an rpc or contract fix.
+var ErrRemoteSigner = errors.New("remote signer error")
+
+// RemoteSignerConfig is how to reach a remote signer implementing the eth_signTransaction JSON-RPC method,
+// like Web3Signer or Clef
+type RemoteSignerConfig struct {
+	URL string
+	// address of the key the signer signs with, which sends the transactions
+	Address common.Address
+	// client certificate and key authenticating us to the signer (mutual TLS), optional
+	TLSCertFile string
+	TLSKeyFile  string
+	// CA certificates verifying the certificate of the signer instead of the system ones, optional
+	TLSCAFile string
+}
+
+func (c Config) RemoteSignerConfig() RemoteSignerConfig {
+	return RemoteSignerConfig{
+		URL:         c.RemoteSignerURL,
+		Address:     c.RemoteSignerAddr,
+		TLSCertFile: c.RemoteSignerTLSCertFile,
+		TLSKeyFile:  c.RemoteSignerTLSKeyFile,
+		TLSCAFile:   c.RemoteSignerTLSCAFile,
+	}
+}
+
+// RemoteSigner signs transactions with a remote signer, so that the private key never is in our memory
+type RemoteSigner struct {
+	config RemoteSignerConfig
+	client *http.Client
+	signer gethtypes.Signer
+	nextID atomic.Uint64
+}
+
+func NewRemoteSigner(config RemoteSignerConfig, chainID *big.Int) (*RemoteSigner, error) {
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &RemoteSigner{
+		config: config,
+		client: &http.Client{Transport: transport},
+		signer: gethtypes.LatestSignerForChainID(chainID),
+	}, nil
+}
+
+func (c RemoteSignerConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.TLSCertFile != "" || c.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load remote signer client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if c.TLSCAFile != "" {
+		caPEM, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read remote signer CA certificates: %w", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("%s doesn't contain any PEM encoded certificate", c.TLSCAFile)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// Address returns the address sending the transactions
+func (s *RemoteSigner) Address() common.Address {
+	return s.config.Address
+}
+
+// SignerFn returns the signer of the wallet sending the transactions, see walletsdk.NewPrivateKeyWallet
+func (s *RemoteSigner) SignerFn() signerv2.SignerFn {
+	return func(ctx context.Context, address common.Address) (bind.SignerFn, error) {
+		return func(from common.Address, tx *gethtypes.Transaction) (*gethtypes.Transaction, error) {
+			return s.SignTransaction(ctx, from, tx)
+		}, nil
+	}
+}
+
+type remoteSignerRequest struct {
+	JsonRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  []any  `json:"params"`
+	ID      uint64 `json:"id"`
+}
+
+type remoteSignerResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// SignTransaction has the remote signer sign tx with the key of from, and checks that it signed tx as is with that key.
+// All errors wrap ErrRemoteSigner.
+func (s *RemoteSigner) SignTransaction(ctx context.Context, from common.Address, tx *gethtypes.Transaction) (*gethtypes.Transaction, error) {
+	signedTx, err := s.signTransaction(ctx, from, tx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRemoteSigner, err)
+	}
+	return signedTx, nil
+}
+
+func (s *RemoteSigner) signTransaction(ctx context.Context, from common.Address, tx *gethtypes.Transaction) (*gethtypes.Transaction, error) {
+	if from != s.config.Address {
+		return nil, fmt.Errorf("cannot sign for %s, the signer key is %s", from.Hex(), s.config.Address.Hex())
+	}
+	txArgs := map[string]any{
+		"from":    from,
+		"gas":     hexutil.Uint64(tx.Gas()),
+		"value":   (*hexutil.Big)(tx.Value()),
+		"nonce":   hexutil.Uint64(tx.Nonce()),
+		"data":    hexutil.Bytes(tx.Data()),
+		"chainId": (*hexutil.Big)(s.signer.ChainID()),
+	}
+	if tx.To() != nil {
+		txArgs["to"] = tx.To()
+	}
+	if tx.Type() == gethtypes.DynamicFeeTxType {
+		txArgs["maxFeePerGas"] = (*hexutil.Big)(tx.GasFeeCap())
+		txArgs["maxPriorityFeePerGas"] = (*hexutil.Big)(tx.GasTipCap())
+	} else {
+		txArgs["gasPrice"] = (*hexutil.Big)(tx.GasPrice())
+	}
+	body, err := json.Marshal(remoteSignerRequest{JsonRPC: "2.0", Method: "eth_signTransaction", Params: []any{txArgs}, ID: s.nextID.Add(1)})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	var rpcResp remoteSignerResponse
+	if err := json.Unmarshal(respBody, &rpcResp); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("http status %s", resp.Status)
+		}
+		return nil, fmt.Errorf("cannot decode response: %w", err)
+	}
+	if rpcResp.Error != nil {
+		return nil, fmt.Errorf("%s (code %d)", rpcResp.Error.Message, rpcResp.Error.Code)
+	}
+
+	// Web3Signer returns the raw signed transaction, Clef an object with the raw transaction and its json
+	var raw hexutil.Bytes
+	if err := json.Unmarshal(rpcResp.Result, &raw); err != nil {
+		var result struct {
+			Raw hexutil.Bytes `json:"raw"`
+		}
+		if err := json.Unmarshal(rpcResp.Result, &result); err != nil || result.Raw == nil {
+			return nil, fmt.Errorf("unexpected result %s", rpcResp.Result)
+		}
+		raw = result.Raw
+	}
+	signedTx := new(gethtypes.Transaction)
+	if err := signedTx.UnmarshalBinary(raw); err != nil {
+		return nil, fmt.Errorf("cannot decode signed transaction: %w", err)
+	}
+	if s.signer.Hash(signedTx) != s.signer.Hash(tx) {
+		return nil, errors.New("signed transaction differs from the one to sign")
+	}
+	sender, err := gethtypes.Sender(s.signer, signedTx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	if sender != from {
+		return nil, fmt.Errorf("transaction signed by %s instead of %s", sender.Hex(), from.Hex())
+	}
+	return signedTx, nil
+}
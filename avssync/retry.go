@@ -0,0 +1,132 @@
+package avssync
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// errStaleOperatorSet is returned when an entire operator set update reverts, which is almost always because
+// the operator set of the quorum changed in between us fetching it and the transaction being mined
+// (the contract makes sure the entire operator set is updated and reverts if not)
+var errStaleOperatorSet = errors.New("entire operator set update reverted, most likely because the operator set changed")
+
+// RetryPolicy is how failed stake updates are retried
+type RetryPolicy struct {
+	// total number of attempts, including the first one
+	MaxAttempts int
+	// wait before the second attempt, doubled after every failed attempt up to MaxBackoff
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// fraction of the backoff randomly added or removed, e.g. 0.2 waits between 80% and 120% of the backoff
+	Jitter float64
+	// no attempt is started after this long since the first one, 0 means no deadline
+	Deadline time.Duration
+}
+
+// Backoff returns how long to wait after the failedAttempts-th failed attempt, before the next one
+func (p RetryPolicy) Backoff(failedAttempts int) time.Duration {
+	backoff := p.BaseBackoff
+	for i := 1; i < failedAttempts && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		backoff += time.Duration(float64(backoff) * p.Jitter * (2*rand.Float64() - 1))
+	}
+	return backoff
+}
+
+type ErrorClass string
+
+const (
+	// transient errors (rpc and network errors, stale operator set reverts, nonce too low...), which a later attempt can fix
+	ErrorClassRetryable ErrorClass = "retryable"
+	// errors that retrying can't fix (insufficient funds, unauthorized signer, invalid quorum, paused registry, gas budget spent...), which need an operator
+	ErrorClassFatal ErrorClass = "fatal"
+)
+
+// fatalErrorMessages are (lowercase) substrings of the errors that are not worth retrying, as returned by the rpcs,
+// the signers (e.g. fireblocks) and the RegistryCoordinator revert reasons
+var fatalErrorMessages = []string{
+	"insufficient funds",
+	"unauthorized",
+	"not authorized",
+	"forbidden",
+	"permission denied",
+	"caller is not",
+	"quorum does not exist",
+	"quorumdoesnotexist",
+	"invalid quorum",
+	"currentlypaused",
+}
+
+// ClassifyError returns whether a failed stake update is worth retrying.
+// Unknown errors are retryable, since giving up on a quorum is worse than a few wasted attempts.
+func ClassifyError(err error) ErrorClass {
+	if errors.Is(err, errStaleOperatorSet) {
+		return ErrorClassRetryable
+	}
+	if errors.Is(err, errGasBudgetExceeded) {
+		return ErrorClassFatal
+	}
+	msg := strings.ToLower(err.Error())
+	for _, fatalMsg := range fatalErrorMessages {
+		if strings.Contains(msg, fatalMsg) {
+			return ErrorClassFatal
+		}
+	}
+	return ErrorClassRetryable
+}
+
+// retry runs attempt until it succeeds, fails with a fatal error, or the retry policy gives up, and returns the error
+// of the last attempt. No attempt is started once ctx is done. logArgs identify the update in the logs.
+func (a *AvsSync) retry(ctx context.Context, attempt func(ctx context.Context) error, logArgs ...any) error {
+	policy := a.retryPolicy
+	var deadline time.Time
+	if policy.Deadline > 0 {
+		deadline = time.Now().Add(policy.Deadline)
+	}
+	for try := 1; ; try++ {
+		err := attempt(ctx)
+		if err == nil {
+			return nil
+		}
+		args := append([]any{"err", err, "try", try, "maxAttempts", policy.MaxAttempts}, logArgs...)
+		if ClassifyError(err) == ErrorClassFatal {
+			a.logger.Error("Fatal error, not retrying", args...)
+			return err
+		}
+		if try >= policy.MaxAttempts {
+			return err
+		}
+		backoff := policy.Backoff(try)
+		if !deadline.IsZero() && time.Now().Add(backoff).After(deadline) {
+			a.logger.Warn("Retry deadline reached, not retrying", append(args, "retryDeadline", policy.Deadline)...)
+			return err
+		}
+		a.logger.Warn("Attempt failed, retrying", append(args, "backoff", backoff)...)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// failedUpdateStatus returns the status of an update that failed with err
+func failedUpdateStatus(err error) UpdateStakeStatus {
+	if errors.Is(err, ErrRemoteSigner) {
+		return UpdateStakeStatusSignerError
+	}
+	if ClassifyError(err) == ErrorClassFatal {
+		return UpdateStakeStatusFatal
+	}
+	return UpdateStakeStatusError
+}
@@ -0,0 +1,88 @@
+package avssync
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	require.Equal(t, time.Second, policy.Backoff(1))
+	require.Equal(t, 2*time.Second, policy.Backoff(2))
+	require.Equal(t, 4*time.Second, policy.Backoff(3))
+	require.Equal(t, 5*time.Second, policy.Backoff(4))
+	require.Equal(t, 5*time.Second, policy.Backoff(100))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		backoff := policy.Backoff(2)
+		require.GreaterOrEqual(t, backoff, time.Second)
+		require.LessOrEqual(t, backoff, 3*time.Second)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected ErrorClass
+	}{
+		{errors.New("Post \"http://localhost:8545\": dial tcp: connection refused"), ErrorClassRetryable},
+		{context.DeadlineExceeded, ErrorClassRetryable},
+		{errors.New("nonce too low"), ErrorClassRetryable},
+		{errStaleOperatorSet, ErrorClassRetryable},
+		{errors.New("execution reverted: RegistryCoordinator.updateOperatorsForQuorum: number of updated operators does not match quorum total"), ErrorClassRetryable},
+		{errors.New("insufficient funds for gas * price + value"), ErrorClassFatal},
+		{errors.New("fireblocks returned 401 Unauthorized"), ErrorClassFatal},
+		{errors.New("execution reverted: RegistryCoordinator.updateOperatorsForQuorum: quorum does not exist"), ErrorClassFatal},
+		{revertedUpdateError(&gethtypes.Receipt{}, "CurrentlyPaused"), ErrorClassFatal},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, ClassifyError(tt.err), tt.err.Error())
+	}
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	avsSync := newTestAvsSync([]byte{0}, nil)
+	avsSync.retryPolicy = RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+	attempts := 0
+	failTimes := func(n int, err error) func(context.Context) error {
+		attempts = 0
+		return func(context.Context) error {
+			attempts++
+			if attempts <= n {
+				return err
+			}
+			return nil
+		}
+	}
+
+	require.NoError(t, avsSync.retry(ctx, failTimes(2, errStaleOperatorSet)))
+	require.Equal(t, 3, attempts)
+
+	require.ErrorIs(t, avsSync.retry(ctx, failTimes(3, errStaleOperatorSet)), errStaleOperatorSet)
+	require.Equal(t, 3, attempts)
+
+	// fatal errors fail fast
+	insufficientFunds := errors.New("insufficient funds for gas * price + value")
+	require.ErrorIs(t, avsSync.retry(ctx, failTimes(1, insufficientFunds)), insufficientFunds)
+	require.Equal(t, 1, attempts)
+	require.Equal(t, UpdateStakeStatusFatal, failedUpdateStatus(insufficientFunds))
+
+	// no attempt is started past the deadline
+	avsSync.retryPolicy = RetryPolicy{MaxAttempts: 10, BaseBackoff: 20 * time.Millisecond, MaxBackoff: 20 * time.Millisecond, Deadline: 50 * time.Millisecond}
+	require.Error(t, avsSync.retry(ctx, failTimes(10, errStaleOperatorSet)))
+	require.Equal(t, 3, attempts)
+
+	// nor once ctx is done
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	require.Error(t, avsSync.retry(cancelledCtx, failTimes(10, errStaleOperatorSet)))
+	require.Equal(t, 1, attempts)
+}
@@ -0,0 +1,150 @@
+package avssync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	regcoord "github.com/Layr-Labs/eigensdk-go/contracts/bindings/RegistryCoordinator"
+	stakeregistry "github.com/Layr-Labs/eigensdk-go/contracts/bindings/StakeRegistry"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// the transaction used up its entire gas limit
+	RevertReasonOutOfGas = "out of gas"
+	// the transaction couldn't be replayed, or its replay didn't revert (e.g. the state changed since)
+	RevertReasonUnknown = "unknown"
+)
+
+// labels of the avssync_tx_reverted_total metric other than the names of custom errors. Revert strings are free text,
+// so they are only logged: as labels, they would make the cardinality of the metric unbounded.
+const (
+	RevertLabelOutOfGas     = "out_of_gas"
+	RevertLabelRevertString = "revert_string"
+	RevertLabelUnknown      = "unknown"
+)
+
+// prefix of the reason of reverts with a custom error that isn't in the ABIs, followed by its selector
+const unknownCustomErrorPrefix = "unknown custom error "
+
+// revertErrors returns the custom errors of the contracts called by stake updates, by selector
+func revertErrors() (map[[4]byte]abi.Error, error) {
+	errorsBySelector := make(map[[4]byte]abi.Error)
+	for _, metaData := range []*bind.MetaData{regcoord.ContractRegistryCoordinatorMetaData, stakeregistry.ContractStakeRegistryMetaData} {
+		contractAbi, err := metaData.GetAbi()
+		if err != nil {
+			return nil, err
+		}
+		for _, contractError := range contractAbi.Errors {
+			errorsBySelector[[4]byte(contractError.ID[:4])] = contractError
+		}
+	}
+	return errorsBySelector, nil
+}
+
+// RevertReason returns why a stake update transaction reverted.
+// Running out of gas is detected by comparing the gas used by the transaction to its gas limit. Other reverts are
+// replayed with eth_call at the block the transaction was mined in (so that the state which made it revert, e.g. an
+// operator registered earlier in the block, is included), and their revert data is decoded against the custom errors
+// of the RegistryCoordinator and StakeRegistry, Error(string) and Panic(uint256).
+func (g *GasEstimator) RevertReason(ctx context.Context, receipt *gethtypes.Receipt) (string, error) {
+	tx, _, err := g.client.TransactionByHash(ctx, receipt.TxHash)
+	if err != nil {
+		return "", fmt.Errorf("cannot fetch transaction %s: %w", receipt.TxHash.Hex(), err)
+	}
+	if receipt.GasUsed >= tx.Gas() {
+		return RevertReasonOutOfGas, nil
+	}
+	_, err = g.client.CallContract(ctx, ethereum.CallMsg{
+		From:  g.sender,
+		To:    tx.To(),
+		Gas:   tx.Gas(),
+		Value: tx.Value(),
+		Data:  tx.Data(),
+	}, receipt.BlockNumber)
+	if err == nil {
+		return RevertReasonUnknown, nil
+	}
+	return g.decodeRevert(err)
+}
+
+// decodeRevert returns the reason of the revert returned by eth_call, or err if it isn't a revert (e.g. an rpc error)
+func (g *GasEstimator) decodeRevert(err error) (string, error) {
+	var dataErr rpc.DataError
+	if errors.As(err, &dataErr) {
+		if data, ok := dataErr.ErrorData().(string); ok {
+			if revertData, decodeErr := hexutil.Decode(data); decodeErr == nil && len(revertData) >= 4 {
+				if reason, unpackErr := abi.UnpackRevert(revertData); unpackErr == nil {
+					return reason, nil
+				}
+				if contractError, ok := g.revertErrors[[4]byte(revertData[:4])]; ok {
+					return contractError.Name, nil
+				}
+				return unknownCustomErrorPrefix + hexutil.Encode(revertData[:4]), nil
+			}
+		}
+	}
+	// some nodes don't return the revert data, only the reason in the message
+	msg := err.Error()
+	if i := strings.Index(msg, "execution reverted"); i >= 0 {
+		reason := strings.TrimPrefix(strings.TrimPrefix(msg[i:], "execution reverted"), ": ")
+		if reason == "" {
+			return RevertReasonUnknown, nil
+		}
+		return reason, nil
+	}
+	return "", fmt.Errorf("cannot replay reverted transaction: %w", err)
+}
+
+// revertReason returns why a stake update transaction reverted, or RevertReasonUnknown if it can't be determined
+func (a *AvsSync) revertReason(ctx context.Context, receipt *gethtypes.Receipt) string {
+	if a.gasEstimator == nil {
+		return RevertReasonUnknown
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, a.readerTimeoutDuration)
+	defer cancel()
+	reason, err := a.gasEstimator.RevertReason(timeoutCtx, receipt)
+	if err != nil {
+		a.logger.Warn("Error determining why transaction reverted", "txHash", receipt.TxHash.Hex(), "err", err)
+		return RevertReasonUnknown
+	}
+	return reason
+}
+
+// revertLabel returns the label of the avssync_tx_reverted_total metric for reason: the name of the custom error if it
+// is one of the RegistryCoordinator or StakeRegistry, otherwise RevertLabelOutOfGas, RevertLabelRevertString or
+// RevertLabelUnknown (which includes unknown custom errors)
+func (a *AvsSync) revertLabel(reason string) string {
+	switch {
+	case reason == RevertReasonOutOfGas:
+		return RevertLabelOutOfGas
+	case reason == RevertReasonUnknown || strings.HasPrefix(reason, unknownCustomErrorPrefix):
+		return RevertLabelUnknown
+	case a.gasEstimator != nil && a.gasEstimator.isCustomError(reason):
+		return reason
+	default:
+		return RevertLabelRevertString
+	}
+}
+
+// isCustomError returns whether name is the name of a custom error of the contracts called by stake updates
+func (g *GasEstimator) isCustomError(name string) bool {
+	for _, contractError := range g.revertErrors {
+		if contractError.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// revertedUpdateError returns the error of a stake update that reverted for reason
+func revertedUpdateError(receipt *gethtypes.Receipt, reason string) error {
+	return fmt.Errorf("%w (%s): %s", errTxReverted, receipt.TxHash.Hex(), reason)
+}
@@ -0,0 +1,75 @@
+package avssync
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+// testDataError is a revert returned by eth_call, with its revert data
+type testDataError struct {
+	data string
+}
+
+func (e testDataError) Error() string          { return "execution reverted" }
+func (e testDataError) ErrorCode() int         { return 3 }
+func (e testDataError) ErrorData() interface{} { return e.data }
+
+func TestDecodeRevert(t *testing.T) {
+	gasEstimator, err := NewGasEstimator(nil, common.Address{}, common.Address{})
+	require.NoError(t, err)
+
+	stringType, err := abi.NewType("string", "", nil)
+	require.NoError(t, err)
+	reasonData, err := abi.Arguments{{Type: stringType}}.Pack("Pausable: index is paused")
+	require.NoError(t, err)
+	errorStringData := append(hexutil.MustDecode("0x08c379a0"), reasonData...)
+	quorumOperatorCountMismatch := gasEstimator.registryCoordinatorAbi.Errors["QuorumOperatorCountMismatch"].ID
+
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{testDataError{hexutil.Encode(errorStringData)}, "Pausable: index is paused"},
+		{testDataError{hexutil.Encode(quorumOperatorCountMismatch[:4])}, "QuorumOperatorCountMismatch"},
+		{testDataError{"0x12345678"}, "unknown custom error 0x12345678"},
+		// the data error can be wrapped, e.g. by the FailoverClient
+		{fmt.Errorf("eth_call: %w", testDataError{hexutil.Encode(quorumOperatorCountMismatch[:4])}), "QuorumOperatorCountMismatch"},
+		// nodes that only return the reason in the message
+		{errors.New("execution reverted: RegistryCoordinator.updateOperatorsForQuorum: operator not registered"), "RegistryCoordinator.updateOperatorsForQuorum: operator not registered"},
+		{errors.New("execution reverted"), RevertReasonUnknown},
+	}
+	for _, tt := range tests {
+		reason, err := gasEstimator.decodeRevert(tt.err)
+		require.NoError(t, err, tt.err.Error())
+		require.Equal(t, tt.expected, reason, tt.err.Error())
+	}
+
+	_, err = gasEstimator.decodeRevert(errors.New("connection refused"))
+	require.ErrorContains(t, err, "cannot replay reverted transaction")
+}
+
+func TestRevertLabel(t *testing.T) {
+	gasEstimator, err := NewGasEstimator(nil, common.Address{}, common.Address{})
+	require.NoError(t, err)
+	avsSync := &AvsSync{gasEstimator: gasEstimator}
+
+	tests := []struct {
+		reason   string
+		expected string
+	}{
+		{RevertReasonOutOfGas, RevertLabelOutOfGas},
+		{RevertReasonUnknown, RevertLabelUnknown},
+		{"QuorumOperatorCountMismatch", "QuorumOperatorCountMismatch"},
+		{"unknown custom error 0x12345678", RevertLabelUnknown},
+		{"Pausable: index is paused", RevertLabelRevertString},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.expected, avsSync.revertLabel(tt.reason), tt.reason)
+	}
+}
@@ -0,0 +1,415 @@
+package avssync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Layr-Labs/eigensdk-go/chainio/clients/eth"
+	sdklogging "github.com/Layr-Labs/eigensdk-go/logging"
+	rpccalls "github.com/Layr-Labs/eigensdk-go/metrics/collectors/rpc_calls"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// json-rpc error code used by most providers when a request is rate limited
+const rpcLimitExceededErrorCode = -32005
+
+// FailoverConfig configures how a FailoverClient switches between its endpoints
+type FailoverConfig struct {
+	// an endpoint that failed is only used again after this long, unless every other endpoint failed too
+	Cooldown time.Duration
+	// timeout of a call to a single endpoint, after which the next endpoint is tried (0 means only the caller's context applies)
+	AttemptTimeout time.Duration
+}
+
+// EndpointStatus is the health of an endpoint of a FailoverClient
+type EndpointStatus struct {
+	Endpoint            string    `json:"endpoint"`
+	Healthy             bool      `json:"healthy"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastError           string    `json:"lastError,omitempty"`
+	LastErrorTime       time.Time `json:"lastErrorTime,omitempty"`
+}
+
+type rpcEndpoint struct {
+	label  string
+	client *eth.InstrumentedClient
+
+	// guarded by FailoverClient.mu
+	consecutiveFailures int
+	unhealthyUntil      time.Time
+	lastError           error
+	lastErrorTime       time.Time
+}
+
+// FailoverClient is an eth client backed by several RPC endpoints, in order of preference.
+// A call goes to the first healthy endpoint, and fails over to the next one when the endpoint is unreachable,
+// times out or is rate limited. Errors returned by the node itself (e.g. a reverted call) are returned as is.
+// An endpoint that failed is considered unhealthy for the cooldown, and is only tried again before then
+// if every healthy endpoint failed as well.
+type FailoverClient struct {
+	logger    sdklogging.Logger
+	role      string
+	config    FailoverConfig
+	endpoints []*rpcEndpoint
+
+	mu sync.Mutex
+
+	endpointErrors  *prometheus.CounterVec
+	endpointHealthy *prometheus.GaugeVec
+}
+
+var _ eth.HttpBackend = (*FailoverClient)(nil)
+
+// NewFailoverClient dials the urls, which are tried in the given order. role (e.g. "read" or "write") distinguishes
+// the metrics of clients that share endpoints. The rpccalls metrics of each endpoint are labeled with the endpoint's host.
+func NewFailoverClient(
+	logger sdklogging.Logger,
+	role string,
+	urls []string,
+	config FailoverConfig,
+	avsName string,
+	reg *prometheus.Registry,
+) (*FailoverClient, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no rpc url")
+	}
+	labels := endpointLabels(urls)
+	c := &FailoverClient{
+		logger: logger,
+		role:   role,
+		config: config,
+		endpointErrors: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace:   metricsNamespace,
+			Name:        "rpc_endpoint_errors_total",
+			Help:        "Calls to an rpc endpoint that failed because it was unreachable, timed out or rate limited, and were retried on the next endpoint",
+			ConstLabels: prometheus.Labels{"role": role},
+		}, []string{"endpoint", "method"}),
+		endpointHealthy: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   metricsNamespace,
+			Name:        "rpc_endpoint_healthy",
+			Help:        "1 if the rpc endpoint is healthy, 0 if it recently failed and is only used when no other endpoint is healthy",
+			ConstLabels: prometheus.Labels{"role": role},
+		}, []string{"endpoint"}),
+	}
+	for i, rpcUrl := range urls {
+		// each endpoint gets its own rpccalls collector, so that the existing request total and duration metrics
+		// are split per endpoint
+		collector := rpccalls.NewCollector(avsName, prometheus.WrapRegistererWith(prometheus.Labels{"endpoint": labels[i], "role": role}, reg))
+		client, err := eth.NewInstrumentedClient(rpcUrl, collector)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create eth client for %s rpc endpoint %s: %w", role, labels[i], err)
+		}
+		c.endpoints = append(c.endpoints, &rpcEndpoint{label: labels[i], client: client})
+		c.endpointHealthy.WithLabelValues(labels[i]).Set(1)
+	}
+	return c, nil
+}
+
+// endpointLabels returns the host of each url, which identifies endpoints in logs and metrics
+// without leaking the api keys that providers put in the path or query
+func endpointLabels(urls []string) []string {
+	labels := make([]string, len(urls))
+	seen := make(map[string]bool, len(urls))
+	for i, rpcUrl := range urls {
+		label := fmt.Sprintf("endpoint-%d", i)
+		if parsed, err := url.Parse(rpcUrl); err == nil && parsed.Host != "" {
+			label = parsed.Host
+		}
+		if seen[label] {
+			label = fmt.Sprintf("%s-%d", label, i)
+		}
+		seen[label] = true
+		labels[i] = label
+	}
+	return labels
+}
+
+// CheckChainId queries the chain id of every endpoint, and fails if they don't all serve the same chain.
+// Endpoints that can't be reached are marked unhealthy, as long as at least one endpoint answers.
+func (c *FailoverClient) CheckChainId(ctx context.Context) (*big.Int, error) {
+	var chainId *big.Int
+	var chainIdEndpoint string
+	for _, endpoint := range c.endpoints {
+		attemptCtx, cancel := c.attemptContext(ctx)
+		endpointChainId, err := endpoint.client.ChainID(attemptCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			c.markFailed(endpoint, "eth_chainId", err)
+			continue
+		}
+		if chainId == nil {
+			chainId, chainIdEndpoint = endpointChainId, endpoint.label
+		} else if chainId.Cmp(endpointChainId) != 0 {
+			return nil, fmt.Errorf("%s rpc endpoint %s serves chain id %s, but %s serves chain id %s",
+				c.role, endpoint.label, endpointChainId, chainIdEndpoint, chainId)
+		}
+	}
+	if chainId == nil {
+		return nil, fmt.Errorf("no %s rpc endpoint is reachable", c.role)
+	}
+	return chainId, nil
+}
+
+// EndpointStatuses returns the health of the endpoints, in order of preference
+func (c *FailoverClient) EndpointStatuses() []EndpointStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	statuses := make([]EndpointStatus, len(c.endpoints))
+	for i, endpoint := range c.endpoints {
+		statuses[i] = EndpointStatus{
+			Endpoint:            endpoint.label,
+			Healthy:             !now.Before(endpoint.unhealthyUntil),
+			ConsecutiveFailures: endpoint.consecutiveFailures,
+			LastErrorTime:       endpoint.lastErrorTime,
+		}
+		if endpoint.lastError != nil {
+			statuses[i].LastError = endpoint.lastError.Error()
+		}
+	}
+	return statuses
+}
+
+// endpointsByPreference returns the healthy endpoints in the configured order,
+// followed by the unhealthy ones, starting with the one whose cooldown ends first
+func (c *FailoverClient) endpointsByPreference() []*rpcEndpoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	var healthy, unhealthy []*rpcEndpoint
+	for _, endpoint := range c.endpoints {
+		if now.Before(endpoint.unhealthyUntil) {
+			unhealthy = append(unhealthy, endpoint)
+		} else {
+			healthy = append(healthy, endpoint)
+		}
+	}
+	sort.SliceStable(unhealthy, func(i, j int) bool {
+		return unhealthy[i].unhealthyUntil.Before(unhealthy[j].unhealthyUntil)
+	})
+	return append(healthy, unhealthy...)
+}
+
+func (c *FailoverClient) markFailed(endpoint *rpcEndpoint, method string, err error) {
+	c.endpointErrors.WithLabelValues(endpoint.label, method).Inc()
+	c.endpointHealthy.WithLabelValues(endpoint.label).Set(0)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	endpoint.consecutiveFailures++
+	endpoint.unhealthyUntil = time.Now().Add(c.config.Cooldown)
+	endpoint.lastError = err
+	endpoint.lastErrorTime = time.Now()
+	c.logger.Warn("Rpc endpoint failed, failing over to the next endpoint",
+		"role", c.role, "endpoint", endpoint.label, "method", method, "consecutiveFailures", endpoint.consecutiveFailures, "err", err)
+}
+
+func (c *FailoverClient) markSucceeded(endpoint *rpcEndpoint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if endpoint.consecutiveFailures == 0 {
+		return
+	}
+	c.logger.Info("Rpc endpoint recovered", "role", c.role, "endpoint", endpoint.label)
+	endpoint.consecutiveFailures = 0
+	endpoint.unhealthyUntil = time.Time{}
+	c.endpointHealthy.WithLabelValues(endpoint.label).Set(1)
+}
+
+func (c *FailoverClient) attemptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.config.AttemptTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.config.AttemptTimeout)
+}
+
+// isEndpointError returns whether err means that the endpoint couldn't serve the call, in which case another endpoint
+// may succeed. Errors returned by the node for the call itself (reverts, unknown transactions, nonce too low...) aren't.
+func isEndpointError(err error) bool {
+	if errors.Is(err, ethereum.NotFound) {
+		return false
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr.ErrorCode() == rpcLimitExceededErrorCode
+	}
+	// transport errors, http errors (e.g. 429 or 503) and timeouts
+	return true
+}
+
+// callWithFailover calls fn on each endpoint by order of preference, until one of them serves the call
+func callWithFailover[T any](ctx context.Context, c *FailoverClient, method string, fn func(ctx context.Context, client *eth.InstrumentedClient) (T, error)) (T, error) {
+	var lastErr error
+	for _, endpoint := range c.endpointsByPreference() {
+		attemptCtx, cancel := c.attemptContext(ctx)
+		result, err := fn(attemptCtx, endpoint.client)
+		cancel()
+		if err == nil || !isEndpointError(err) {
+			c.markSucceeded(endpoint)
+			return result, err
+		}
+		if ctx.Err() != nil {
+			// the caller gave up, which says nothing about the endpoint
+			return result, err
+		}
+		c.markFailed(endpoint, method, err)
+		lastErr = err
+	}
+	var zero T
+	return zero, fmt.Errorf("all %s rpc endpoints failed, last error: %w", c.role, lastErr)
+}
+
+func (c *FailoverClient) ChainID(ctx context.Context) (*big.Int, error) {
+	return callWithFailover(ctx, c, "eth_chainId", func(ctx context.Context, client *eth.InstrumentedClient) (*big.Int, error) {
+		return client.ChainID(ctx)
+	})
+}
+
+func (c *FailoverClient) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return callWithFailover(ctx, c, "eth_getBalance", func(ctx context.Context, client *eth.InstrumentedClient) (*big.Int, error) {
+		return client.BalanceAt(ctx, account, blockNumber)
+	})
+}
+
+func (c *FailoverClient) BlockNumber(ctx context.Context) (uint64, error) {
+	return callWithFailover(ctx, c, "eth_blockNumber", func(ctx context.Context, client *eth.InstrumentedClient) (uint64, error) {
+		return client.BlockNumber(ctx)
+	})
+}
+
+func (c *FailoverClient) BlockByNumber(ctx context.Context, number *big.Int) (*gethtypes.Block, error) {
+	return callWithFailover(ctx, c, "eth_getBlockByNumber", func(ctx context.Context, client *eth.InstrumentedClient) (*gethtypes.Block, error) {
+		return client.BlockByNumber(ctx, number)
+	})
+}
+
+func (c *FailoverClient) HeaderByNumber(ctx context.Context, number *big.Int) (*gethtypes.Header, error) {
+	return callWithFailover(ctx, c, "eth_getBlockByNumber", func(ctx context.Context, client *eth.InstrumentedClient) (*gethtypes.Header, error) {
+		return client.HeaderByNumber(ctx, number)
+	})
+}
+
+func (c *FailoverClient) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return callWithFailover(ctx, c, "eth_getCode", func(ctx context.Context, client *eth.InstrumentedClient) ([]byte, error) {
+		return client.CodeAt(ctx, contract, blockNumber)
+	})
+}
+
+func (c *FailoverClient) CallContract(ctx context.Context, call ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return callWithFailover(ctx, c, "eth_call", func(ctx context.Context, client *eth.InstrumentedClient) ([]byte, error) {
+		return client.CallContract(ctx, call, blockNumber)
+	})
+}
+
+func (c *FailoverClient) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return callWithFailover(ctx, c, "eth_getCode", func(ctx context.Context, client *eth.InstrumentedClient) ([]byte, error) {
+		return client.PendingCodeAt(ctx, account)
+	})
+}
+
+func (c *FailoverClient) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return callWithFailover(ctx, c, "eth_getTransactionCount", func(ctx context.Context, client *eth.InstrumentedClient) (uint64, error) {
+		return client.PendingNonceAt(ctx, account)
+	})
+}
+
+func (c *FailoverClient) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	return callWithFailover(ctx, c, "eth_getTransactionCount", func(ctx context.Context, client *eth.InstrumentedClient) (uint64, error) {
+		return client.NonceAt(ctx, account, blockNumber)
+	})
+}
+
+func (c *FailoverClient) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return callWithFailover(ctx, c, "eth_gasPrice", func(ctx context.Context, client *eth.InstrumentedClient) (*big.Int, error) {
+		return client.SuggestGasPrice(ctx)
+	})
+}
+
+func (c *FailoverClient) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return callWithFailover(ctx, c, "eth_maxPriorityFeePerGas", func(ctx context.Context, client *eth.InstrumentedClient) (*big.Int, error) {
+		return client.SuggestGasTipCap(ctx)
+	})
+}
+
+func (c *FailoverClient) EstimateGas(ctx context.Context, call ethereum.CallMsg) (uint64, error) {
+	return callWithFailover(ctx, c, "eth_estimateGas", func(ctx context.Context, client *eth.InstrumentedClient) (uint64, error) {
+		return client.EstimateGas(ctx, call)
+	})
+}
+
+// SendTransaction sends the signed transaction to the next endpoint if the current one fails. Resending the same
+// signed transaction is safe: if a previous endpoint did broadcast it (e.g. before timing out), the next one rejects it
+// as already known, or with nonce too low once it is mined, which are successful sends (see alreadySent).
+func (c *FailoverClient) SendTransaction(ctx context.Context, tx *gethtypes.Transaction) error {
+	_, err := callWithFailover(ctx, c, "eth_sendRawTransaction", func(ctx context.Context, client *eth.InstrumentedClient) (struct{}, error) {
+		err := client.SendTransaction(ctx, tx)
+		if err != nil && alreadySent(ctx, client, tx, err) {
+			c.logger.Info("Transaction was already sent", "role", c.role, "txHash", tx.Hash().Hex(), "err", err)
+			return struct{}{}, nil
+		}
+		return struct{}{}, err
+	})
+	return err
+}
+
+// alreadySent returns whether err rejects tx because the node already has it: it is in its mempool (already known,
+// known transaction), or it was mined (nonce too low, and the node has the transaction with the hash of tx). Reporting
+// these as failed sends would make the txmgr send the same update again at the next nonce.
+func alreadySent(ctx context.Context, client *eth.InstrumentedClient, tx *gethtypes.Transaction, err error) bool {
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "already known"), strings.Contains(msg, "known transaction"):
+		// the mempool is keyed by transaction hash
+		return true
+	case strings.Contains(msg, "nonce too low"):
+		// the nonce was also used by another transaction of the sender if the node doesn't know this one
+		_, _, err := client.TransactionByHash(ctx, tx.Hash())
+		return err == nil
+	default:
+		return false
+	}
+}
+
+func (c *FailoverClient) TransactionReceipt(ctx context.Context, txHash common.Hash) (*gethtypes.Receipt, error) {
+	return callWithFailover(ctx, c, "eth_getTransactionReceipt", func(ctx context.Context, client *eth.InstrumentedClient) (*gethtypes.Receipt, error) {
+		return client.TransactionReceipt(ctx, txHash)
+	})
+}
+
+func (c *FailoverClient) TransactionByHash(ctx context.Context, hash common.Hash) (*gethtypes.Transaction, bool, error) {
+	type txByHash struct {
+		tx        *gethtypes.Transaction
+		isPending bool
+	}
+	result, err := callWithFailover(ctx, c, "eth_getTransactionByHash", func(ctx context.Context, client *eth.InstrumentedClient) (txByHash, error) {
+		tx, isPending, err := client.TransactionByHash(ctx, hash)
+		return txByHash{tx: tx, isPending: isPending}, err
+	})
+	return result.tx, result.isPending, err
+}
+
+func (c *FailoverClient) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]gethtypes.Log, error) {
+	return callWithFailover(ctx, c, "eth_getLogs", func(ctx context.Context, client *eth.InstrumentedClient) ([]gethtypes.Log, error) {
+		return client.FilterLogs(ctx, query)
+	})
+}
+
+// SubscribeFilterLogs isn't failed over: a subscription is tied to the endpoint that created it.
+// It is only part of the interface required by the bindings, AvsSync polls logs with FilterLogs.
+func (c *FailoverClient) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- gethtypes.Log) (ethereum.Subscription, error) {
+	return c.endpointsByPreference()[0].client.SubscribeFilterLogs(ctx, query, ch)
+}
@@ -0,0 +1,221 @@
+package avssync
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRpcEndpoint serves eth_chainId with chainId, eth_blockNumber with blockNumber, and fails eth_call with a revert.
+// If down is set, every call after the client version lookup fails with a 503.
+// eth_sendRawTransaction hangs until the call times out if sendHangs is set, and otherwise fails with sendError if set.
+// eth_getTransactionByHash returns knownTx if its hash is requested, and null otherwise.
+type fakeRpcEndpoint struct {
+	*httptest.Server
+	chainId     string
+	blockNumber string
+	down        atomic.Bool
+	calls       atomic.Int32
+	sendHangs   bool
+	sendError   string
+	sends       atomic.Int32
+	knownTx     *gethtypes.Transaction
+}
+
+func newFakeRpcEndpoint(t *testing.T, chainId, blockNumber string) *fakeRpcEndpoint {
+	endpoint := &fakeRpcEndpoint{chainId: chainId, blockNumber: blockNumber}
+	endpoint.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Id     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params []any           `json:"params"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.Method != "web3_clientVersion" {
+			endpoint.calls.Add(1)
+			if endpoint.down.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+		resp := map[string]any{"jsonrpc": "2.0", "id": req.Id}
+		switch req.Method {
+		case "web3_clientVersion":
+			resp["result"] = "fake/v1"
+		case "eth_chainId":
+			resp["result"] = endpoint.chainId
+		case "eth_blockNumber":
+			resp["result"] = endpoint.blockNumber
+		case "eth_sendRawTransaction":
+			endpoint.sends.Add(1)
+			if endpoint.sendHangs {
+				// the transaction is broadcast, but the response never makes it back
+				<-r.Context().Done()
+				return
+			}
+			if endpoint.sendError != "" {
+				resp["error"] = map[string]any{"code": -32000, "message": endpoint.sendError}
+			} else {
+				resp["result"] = common.Hash{}
+			}
+		case "eth_getTransactionByHash":
+			if endpoint.knownTx != nil && req.Params[0] == endpoint.knownTx.Hash().Hex() {
+				resp["result"] = endpoint.knownTx
+			} else {
+				resp["result"] = nil
+			}
+		default:
+			resp["error"] = map[string]any{"code": 3, "message": "execution reverted"}
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+	t.Cleanup(endpoint.Close)
+	return endpoint
+}
+
+func newTestFailoverClient(t *testing.T, endpoints ...*fakeRpcEndpoint) *FailoverClient {
+	var urls []string
+	for _, endpoint := range endpoints {
+		urls = append(urls, endpoint.URL)
+	}
+	logger := logging.NewTextSLogger(os.Stderr, &logging.SLoggerOptions{})
+	client, err := NewFailoverClient(logger, "read", urls, FailoverConfig{Cooldown: time.Minute, AttemptTimeout: time.Second}, "test", prometheus.NewRegistry())
+	require.NoError(t, err)
+	return client
+}
+
+func TestFailoverClientFailsOver(t *testing.T) {
+	primary := newFakeRpcEndpoint(t, "0x1", "0x10")
+	secondary := newFakeRpcEndpoint(t, "0x1", "0x20")
+	client := newTestFailoverClient(t, primary, secondary)
+
+	blockNumber, err := client.BlockNumber(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(0x10), blockNumber)
+
+	primary.down.Store(true)
+	blockNumber, err = client.BlockNumber(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(0x20), blockNumber)
+	require.False(t, client.EndpointStatuses()[0].Healthy)
+	require.Equal(t, 1, client.EndpointStatuses()[0].ConsecutiveFailures)
+
+	// the primary is skipped during its cooldown, even once it is back up
+	primary.down.Store(false)
+	primaryCalls := primary.calls.Load()
+	_, err = client.BlockNumber(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, primaryCalls, primary.calls.Load())
+
+	// an unhealthy endpoint is still tried when the healthy ones fail
+	secondary.down.Store(true)
+	blockNumber, err = client.BlockNumber(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(0x10), blockNumber)
+	require.True(t, client.EndpointStatuses()[0].Healthy)
+
+	primary.down.Store(true)
+	_, err = client.BlockNumber(context.Background())
+	require.ErrorContains(t, err, "all read rpc endpoints failed")
+}
+
+func TestFailoverClientDoesntFailOverOnNodeErrors(t *testing.T) {
+	primary := newFakeRpcEndpoint(t, "0x1", "0x10")
+	secondary := newFakeRpcEndpoint(t, "0x1", "0x20")
+	client := newTestFailoverClient(t, primary, secondary)
+
+	_, err := client.CallContract(context.Background(), ethereum.CallMsg{}, nil)
+	require.ErrorContains(t, err, "execution reverted")
+	require.Zero(t, secondary.calls.Load())
+	require.True(t, client.EndpointStatuses()[0].Healthy)
+}
+
+func TestFailoverClientSendTransaction(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	tx, err := gethtypes.SignNewTx(key, gethtypes.LatestSignerForChainID(big.NewInt(1)), &gethtypes.LegacyTx{Nonce: 1, Gas: 21000, GasPrice: big.NewInt(1)})
+	require.NoError(t, err)
+	otherTx, err := gethtypes.SignNewTx(key, gethtypes.LatestSignerForChainID(big.NewInt(1)), &gethtypes.LegacyTx{Nonce: 1, Gas: 21000, GasPrice: big.NewInt(2)})
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		sendError   string
+		knownTx     *gethtypes.Transaction
+		errContains string
+	}{
+		"in the mempool of the secondary": {sendError: "already known"},
+		"in the mempool of an older node": {sendError: "known transaction: " + tx.Hash().Hex()[2:]},
+		"already mined":                   {sendError: "nonce too low: next nonce 2, tx nonce 1", knownTx: tx},
+		"nonce used by another transaction": {
+			sendError:   "nonce too low: next nonce 2, tx nonce 1",
+			errContains: "nonce too low",
+		},
+		"mined transaction isn't this one": {
+			sendError:   "nonce too low: next nonce 2, tx nonce 1",
+			knownTx:     otherTx,
+			errContains: "nonce too low",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			// every case waits for the attempt timeout of the primary
+			t.Parallel()
+			// the primary broadcasts the transaction, but times out before responding
+			primary := newFakeRpcEndpoint(t, "0x1", "0x10")
+			primary.sendHangs = true
+			secondary := newFakeRpcEndpoint(t, "0x1", "0x10")
+			secondary.sendError = tc.sendError
+			secondary.knownTx = tc.knownTx
+			client := newTestFailoverClient(t, primary, secondary)
+
+			err := client.SendTransaction(context.Background(), tx)
+			require.Equal(t, int32(1), primary.sends.Load())
+			require.Equal(t, int32(1), secondary.sends.Load())
+			require.False(t, client.EndpointStatuses()[0].Healthy)
+			if tc.errContains == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tc.errContains)
+			}
+		})
+	}
+}
+
+func TestFailoverClientCheckChainId(t *testing.T) {
+	up := newFakeRpcEndpoint(t, "0x1", "0x10")
+	down := newFakeRpcEndpoint(t, "0x1", "0x10")
+	down.down.Store(true)
+	client := newTestFailoverClient(t, down, up)
+	chainId, err := client.CheckChainId(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, int64(1), chainId.Int64())
+	require.False(t, client.EndpointStatuses()[0].Healthy)
+
+	otherChain := newFakeRpcEndpoint(t, "0x5", "0x10")
+	_, err = newTestFailoverClient(t, up, otherChain).CheckChainId(context.Background())
+	require.ErrorContains(t, err, "serves chain id 5")
+
+	_, err = newTestFailoverClient(t, down).CheckChainId(context.Background())
+	require.ErrorContains(t, err, "no read rpc endpoint is reachable")
+}
+
+func TestEndpointLabels(t *testing.T) {
+	require.Equal(t,
+		[]string{"eth-mainnet.example.com", "eth-mainnet.example.com-1", "endpoint-2"},
+		endpointLabels([]string{"https://eth-mainnet.example.com/v2/apikey", "https://eth-mainnet.example.com/v2/otherkey", "::"}),
+	)
+}
@@ -0,0 +1,95 @@
+package avssync
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule decides when the next stake sync should happen.
+// Next returns the time of the next sync after t, or the zero time if there are no more syncs to run.
+// Note that this is the same interface as cron.Schedule, so parsed cron expressions can be used directly.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// accepts both standard 5-field expressions (minute hour dom month dow) and 6-field expressions
+// with a leading seconds field, as well as descriptors such as @daily or @every 1h
+var cronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// ParseCronSchedule parses one or more cron expressions into a single Schedule that fires whenever any of them fires.
+// Expressions are evaluated in loc, unless they explicitly specify their own time zone via a CRON_TZ= or TZ= prefix
+// (e.g. "CRON_TZ=America/New_York 0 9 * * 1-5").
+func ParseCronSchedule(exprs []string, loc *time.Location) (Schedule, error) {
+	if len(exprs) == 0 {
+		return nil, fmt.Errorf("no cron expression provided")
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	var schedules multiSchedule
+	for _, expr := range exprs {
+		expr = strings.TrimSpace(expr)
+		if !strings.HasPrefix(expr, "CRON_TZ=") && !strings.HasPrefix(expr, "TZ=") {
+			expr = "CRON_TZ=" + loc.String() + " " + expr
+		}
+		schedule, err := cronParser.Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+		schedules = append(schedules, schedule)
+	}
+	if len(schedules) == 1 {
+		return schedules[0], nil
+	}
+	return schedules, nil
+}
+
+// multiSchedule fires whenever any of its underlying schedules fires
+type multiSchedule []cron.Schedule
+
+func (m multiSchedule) Next(t time.Time) time.Time {
+	var next time.Time
+	for _, schedule := range m {
+		n := schedule.Next(t)
+		if n.IsZero() {
+			continue
+		}
+		if next.IsZero() || n.Before(next) {
+			next = n
+		}
+	}
+	return next
+}
+
+// IntervalSchedule fires at firstSyncTime and then every interval after it.
+// Fire times are anchored to firstSyncTime, so that a long running sync doesn't push back every subsequent sync.
+// An interval of 0 means only a single sync at firstSyncTime.
+type IntervalSchedule struct {
+	firstSyncTime time.Time
+	interval      time.Duration
+}
+
+func NewIntervalSchedule(firstSyncTime time.Time, interval time.Duration) *IntervalSchedule {
+	return &IntervalSchedule{
+		firstSyncTime: firstSyncTime,
+		interval:      interval,
+	}
+}
+
+// Next returns firstSyncTime if t is not after it (so that the very first sync isn't skipped when it's due right now),
+// and otherwise the first fire time strictly after t.
+func (s *IntervalSchedule) Next(t time.Time) time.Time {
+	if !t.After(s.firstSyncTime) {
+		return s.firstSyncTime
+	}
+	if s.interval <= 0 {
+		return time.Time{}
+	}
+	elapsedIntervals := t.Sub(s.firstSyncTime) / s.interval
+	return s.firstSyncTime.Add((elapsedIntervals + 1) * s.interval)
+}
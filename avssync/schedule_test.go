@@ -0,0 +1,61 @@
+package avssync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	// 02:00 UTC on weekdays and 14:00 UTC on the 1st of the month
+	schedule, err := ParseCronSchedule([]string{"0 2 * * 1-5", "0 0 14 1 * *"}, time.UTC)
+	require.NoError(t, err)
+
+	// Friday 2024-02-02 03:00 UTC -> next weekday at 02:00 is Monday 2024-02-05
+	now := time.Date(2024, 2, 2, 3, 0, 0, 0, time.UTC)
+	require.Equal(t, time.Date(2024, 2, 5, 2, 0, 0, 0, time.UTC), schedule.Next(now))
+
+	// Thursday 2024-02-29 03:00 UTC -> Friday the 1st of March is a weekday, so its 02:00 run comes first,
+	// followed by the 14:00 run of the 1st of the month
+	now = time.Date(2024, 2, 29, 3, 0, 0, 0, time.UTC)
+	require.Equal(t, time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC), schedule.Next(now))
+	now = time.Date(2024, 3, 1, 2, 0, 0, 0, time.UTC)
+	require.Equal(t, time.Date(2024, 3, 1, 14, 0, 0, 0, time.UTC), schedule.Next(now))
+}
+
+func TestParseCronScheduleTimezone(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	schedule, err := ParseCronSchedule([]string{"0 2 * * *"}, paris)
+	require.NoError(t, err)
+	require.True(t, time.Date(2024, 1, 10, 1, 0, 0, 0, time.UTC).Equal(schedule.Next(now)))
+
+	// an explicit CRON_TZ takes precedence over the default location
+	schedule, err = ParseCronSchedule([]string{"CRON_TZ=UTC 0 2 * * *"}, paris)
+	require.NoError(t, err)
+	require.True(t, time.Date(2024, 1, 10, 2, 0, 0, 0, time.UTC).Equal(schedule.Next(now)))
+}
+
+func TestParseCronScheduleInvalid(t *testing.T) {
+	_, err := ParseCronSchedule([]string{"not a cron expression"}, time.UTC)
+	require.Error(t, err)
+	_, err = ParseCronSchedule(nil, time.UTC)
+	require.Error(t, err)
+}
+
+func TestIntervalSchedule(t *testing.T) {
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	schedule := NewIntervalSchedule(first, time.Hour)
+
+	require.Equal(t, first, schedule.Next(first.Add(-time.Minute)))
+	require.Equal(t, first, schedule.Next(first))
+	// a sync that took 90 minutes doesn't shift the following syncs
+	require.Equal(t, first.Add(2*time.Hour), schedule.Next(first.Add(90*time.Minute)))
+
+	once := NewIntervalSchedule(first, 0)
+	require.Equal(t, first, once.Next(first))
+	require.True(t, once.Next(first.Add(time.Second)).IsZero())
+}
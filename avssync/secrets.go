@@ -0,0 +1,255 @@
+package avssync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// Schemes of the secret references, which select the provider reading the secret
+const (
+	// env://NAME reads the environment variable NAME
+	SecretSchemeEnv = "env"
+	// file:///path/to/secret reads a file which must only be accessible by its owner
+	SecretSchemeFile = "file"
+	// awssm://name?region=us-east-1 reads the AWS Secrets Manager secret name, in secret-manager-region by default.
+	// A reference without scheme is the name of an AWS Secrets Manager secret.
+	SecretSchemeAWSSecretsManager = "awssm"
+	// vault://mount/path#field reads field of the secret at path of the KV secrets engine mounted at mount.
+	// ?kv-version=1 reads from a version 1 engine, version 2 is the default.
+	SecretSchemeVault = "vault"
+)
+
+// SecretProvider reads secrets from one kind of secret store
+type SecretProvider interface {
+	// ReadSecret returns the secret referenced by ref. A fragment names the field to return of a secret
+	// holding a JSON object.
+	ReadSecret(ctx context.Context, ref *url.URL) (string, error)
+}
+
+// SecretReader reads secrets referenced by URIs like file:///run/secrets/key or vault://kv/avs-sync#key,
+// with the provider of their scheme
+type SecretReader struct {
+	providers map[string]SecretProvider
+}
+
+// NewSecretReader creates a SecretReader reading the secrets with the providers by scheme
+func NewSecretReader(providers map[string]SecretProvider) *SecretReader {
+	return &SecretReader{providers: providers}
+}
+
+// NewSecretReaderFromConfig creates a SecretReader reading secrets from the environment, files, the AWS Secrets Manager
+// of secret-manager-region and, when vault-addr is set, Vault
+func NewSecretReaderFromConfig(cfg Config) (*SecretReader, error) {
+	providers := map[string]SecretProvider{
+		SecretSchemeEnv:               EnvSecretProvider{},
+		SecretSchemeFile:              FileSecretProvider{},
+		SecretSchemeAWSSecretsManager: &AWSSecretsManagerProvider{Region: cfg.SecretManagerRegion},
+	}
+	if cfg.VaultAddr != "" {
+		vaultProvider, err := NewVaultKVSecretProvider(cfg.VaultConfig())
+		if err != nil {
+			return nil, err
+		}
+		providers[SecretSchemeVault] = vaultProvider
+	}
+	return NewSecretReader(providers), nil
+}
+
+// ReadSecret returns the secret referenced by ref
+func (r *SecretReader) ReadSecret(ctx context.Context, ref string) (string, error) {
+	refURL, err := ParseSecretRef(ref)
+	if err != nil {
+		return "", err
+	}
+	provider, ok := r.providers[refURL.Scheme]
+	if !ok {
+		return "", fmt.Errorf("no secret provider for %s:// references", refURL.Scheme)
+	}
+	secret, err := provider.ReadSecret(ctx, refURL)
+	if err != nil {
+		return "", fmt.Errorf("cannot read secret %s: %w", ref, err)
+	}
+	return secret, nil
+}
+
+// ParseSecretRef parses a secret reference. A reference without scheme is the name of an AWS Secrets Manager secret,
+// for compatibility with the secret-manager-*-name flags which used to only take those.
+func ParseSecretRef(ref string) (*url.URL, error) {
+	if ref == "" {
+		return nil, errors.New("empty secret reference")
+	}
+	if !strings.Contains(ref, "://") {
+		return &url.URL{Scheme: SecretSchemeAWSSecretsManager, Path: ref}, nil
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret reference: %w", err)
+	}
+	switch refURL.Scheme {
+	case SecretSchemeEnv, SecretSchemeFile, SecretSchemeAWSSecretsManager, SecretSchemeVault:
+	default:
+		return nil, fmt.Errorf("secret reference %s must start with %s://, %s://, %s:// or %s://", ref,
+			SecretSchemeEnv, SecretSchemeFile, SecretSchemeAWSSecretsManager, SecretSchemeVault)
+	}
+	if secretRefName(refURL) == "" {
+		return nil, fmt.Errorf("secret reference %s doesn't name a secret", ref)
+	}
+	return refURL, nil
+}
+
+// secretRefName returns what ref names after its scheme, without query and fragment
+func secretRefName(ref *url.URL) string {
+	return ref.Host + ref.Path
+}
+
+// jsonSecretField returns field of secret, which must hold a JSON object, or secret itself if field is empty
+func jsonSecretField(secret string, field string) (string, error) {
+	if field == "" {
+		return secret, nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(secret), &fields); err != nil {
+		// not wrapped, so that the secret doesn't end up in the logs
+		return "", fmt.Errorf("secret doesn't hold a JSON object, cannot read its field %s", field)
+	}
+	return secretField(fields, field)
+}
+
+func secretField(fields map[string]any, field string) (string, error) {
+	value, ok := fields[field]
+	if !ok {
+		return "", fmt.Errorf("secret has no field %s", field)
+	}
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("field %s of the secret isn't a string", field)
+	}
+	return str, nil
+}
+
+// EnvSecretProvider reads secrets from environment variables: env://NAME
+type EnvSecretProvider struct{}
+
+func (EnvSecretProvider) ReadSecret(_ context.Context, ref *url.URL) (string, error) {
+	name := secretRefName(ref)
+	secret, ok := os.LookupEnv(name)
+	if !ok || secret == "" {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return jsonSecretField(secret, ref.Fragment)
+}
+
+// FileSecretProvider reads secrets from files, which must only be accessible by their owner: file:///run/secrets/key
+type FileSecretProvider struct{}
+
+func (FileSecretProvider) ReadSecret(_ context.Context, ref *url.URL) (string, error) {
+	if ref.Host != "" {
+		return "", fmt.Errorf("file references must have an absolute path (file:///%s%s)", ref.Host, ref.Path)
+	}
+	secret, err := ReadSecretFile(ref.Path)
+	if err != nil {
+		return "", err
+	}
+	return jsonSecretField(secret, ref.Fragment)
+}
+
+// AWSSecretsManagerProvider reads secrets from AWS Secrets Manager: awssm://name?region=us-east-1
+type AWSSecretsManagerProvider struct {
+	// Region of the secrets whose reference doesn't set one
+	Region string
+	// Endpoint replaces the AWS endpoint (e.g. for LocalStack), optional
+	Endpoint string
+}
+
+func (p *AWSSecretsManagerProvider) ReadSecret(ctx context.Context, ref *url.URL) (string, error) {
+	region := ref.Query().Get("region")
+	if region == "" {
+		region = p.Region
+	}
+	if region == "" {
+		return "", errors.New("the region of the AWS secret manager is not set")
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
+	if err != nil {
+		return "", fmt.Errorf("cannot load AWS config: %w", err)
+	}
+	client := secretsmanager.NewFromConfig(awsCfg, func(o *secretsmanager.Options) {
+		if p.Endpoint != "" {
+			o.BaseEndpoint = aws.String(p.Endpoint)
+		}
+	})
+	result, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(secretRefName(ref))})
+	if err != nil {
+		return "", err
+	}
+	if result.SecretString == nil {
+		return "", errors.New("secret is binary, only string secrets are supported")
+	}
+	return jsonSecretField(*result.SecretString, ref.Fragment)
+}
+
+// VaultKVSecretProvider reads secrets from the KV secrets engine of Vault: vault://kv/avs-sync#key
+type VaultKVSecretProvider struct {
+	client *vaultClient
+}
+
+func NewVaultKVSecretProvider(config VaultConfig) (*VaultKVSecretProvider, error) {
+	client, err := newVaultClient(config)
+	if err != nil {
+		return nil, err
+	}
+	return &VaultKVSecretProvider{client: client}, nil
+}
+
+func (p *VaultKVSecretProvider) ReadSecret(ctx context.Context, ref *url.URL) (string, error) {
+	mount, path := ref.Host, strings.Trim(ref.Path, "/")
+	if mount == "" || path == "" {
+		return "", errors.New("vault references must be vault://<mount>/<path>#<field>")
+	}
+	var fields map[string]any
+	switch kvVersion := ref.Query().Get("kv-version"); kvVersion {
+	case "", "2":
+		var data struct {
+			Data map[string]any `json:"data"`
+		}
+		if err := p.client.request(ctx, "GET", mount+"/data/"+path, nil, &data); err != nil {
+			return "", err
+		}
+		fields = data.Data
+	case "1":
+		if err := p.client.request(ctx, "GET", mount+"/"+path, nil, &fields); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("invalid kv-version %s, must be 1 or 2", kvVersion)
+	}
+	if len(fields) == 0 {
+		return "", errors.New("secret has no fields, it may have been deleted")
+	}
+	field := ref.Fragment
+	if field == "" {
+		// the field can be left out of the reference of secrets with a single field
+		if len(fields) != 1 {
+			names := make([]string, 0, len(fields))
+			for name := range fields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return "", fmt.Errorf("secret has fields %s, name the one to read after # in the reference", strings.Join(names, ", "))
+		}
+		for name := range fields {
+			field = name
+		}
+	}
+	return secretField(fields, field)
+}
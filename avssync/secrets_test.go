@@ -0,0 +1,180 @@
+package avssync
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSecretRef(t *testing.T) {
+	ref, err := ParseSecretRef("prod/avs-sync-key")
+	require.NoError(t, err)
+	require.Equal(t, SecretSchemeAWSSecretsManager, ref.Scheme)
+	require.Equal(t, "prod/avs-sync-key", secretRefName(ref))
+
+	ref, err = ParseSecretRef("vault://kv/avs-sync#key")
+	require.NoError(t, err)
+	require.Equal(t, SecretSchemeVault, ref.Scheme)
+	require.Equal(t, "kv/avs-sync", secretRefName(ref))
+	require.Equal(t, "key", ref.Fragment)
+
+	ref, err = ParseSecretRef("env://AVS_SYNC_KEY")
+	require.NoError(t, err)
+	require.Equal(t, "AVS_SYNC_KEY", secretRefName(ref))
+
+	_, err = ParseSecretRef("gcpsm://key")
+	require.ErrorContains(t, err, "must start with env://, file://, awssm:// or vault://")
+	_, err = ParseSecretRef("file://")
+	require.ErrorContains(t, err, "doesn't name a secret")
+	_, err = ParseSecretRef("")
+	require.Error(t, err)
+}
+
+func TestSecretReaderEnvAndFile(t *testing.T) {
+	ctx := context.Background()
+	reader := NewSecretReader(map[string]SecretProvider{
+		SecretSchemeEnv:  EnvSecretProvider{},
+		SecretSchemeFile: FileSecretProvider{},
+	})
+
+	t.Setenv("AVS_SYNC_TEST_SECRET", "s3cret")
+	t.Setenv("AVS_SYNC_TEST_JSON_SECRET", `{"key":"k3y"}`)
+	secret, err := reader.ReadSecret(ctx, "env://AVS_SYNC_TEST_SECRET")
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", secret)
+	secret, err = reader.ReadSecret(ctx, "env://AVS_SYNC_TEST_JSON_SECRET#key")
+	require.NoError(t, err)
+	require.Equal(t, "k3y", secret)
+	_, err = reader.ReadSecret(ctx, "env://AVS_SYNC_TEST_SECRET#key")
+	require.ErrorContains(t, err, "doesn't hold a JSON object")
+	require.NotContains(t, err.Error(), "s3cret")
+	_, err = reader.ReadSecret(ctx, "env://AVS_SYNC_TEST_MISSING")
+	require.ErrorContains(t, err, "environment variable AVS_SYNC_TEST_MISSING is not set")
+
+	path := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(path, []byte("s3cret\n"), 0o600))
+	secret, err = reader.ReadSecret(ctx, "file://"+path)
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", secret)
+	require.NoError(t, os.Chmod(path, 0o644))
+	_, err = reader.ReadSecret(ctx, "file://"+path)
+	require.ErrorContains(t, err, "accessible by group or others")
+
+	// no provider for the AWS secret manager
+	_, err = reader.ReadSecret(ctx, "key-name")
+	require.ErrorContains(t, err, "no secret provider for awssm:// references")
+}
+
+func TestAWSSecretsManagerProvider(t *testing.T) {
+	// fake of the GetSecretValue action of the AWS Secrets Manager API
+	secrets := map[string]string{
+		"avs-sync/key":        "0xabc",
+		"avs-sync/fireblocks": `{"api-key":"fb-key"}`,
+	}
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		// the region is part of the credential scope of the signature
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		var input struct {
+			SecretId string
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&input))
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		secret, ok := secrets[input.SecretId]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"__type":  "ResourceNotFoundException",
+				"message": "Secrets Manager can't find the specified secret.",
+			})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"Name": input.SecretId, "SecretString": secret})
+	}))
+	defer server.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	ctx := context.Background()
+	reader := NewSecretReader(map[string]SecretProvider{
+		SecretSchemeAWSSecretsManager: &AWSSecretsManagerProvider{Region: "us-east-1", Endpoint: server.URL},
+	})
+	secret, err := reader.ReadSecret(ctx, "avs-sync/key")
+	require.NoError(t, err)
+	require.Equal(t, "0xabc", secret)
+	secret, err = reader.ReadSecret(ctx, "awssm://avs-sync/fireblocks?region=eu-west-1#api-key")
+	require.NoError(t, err)
+	require.Equal(t, "fb-key", secret)
+	require.Contains(t, authorizations[0], "/us-east-1/secretsmanager/")
+	require.Contains(t, authorizations[1], "/eu-west-1/secretsmanager/")
+
+	_, err = reader.ReadSecret(ctx, "avs-sync/missing")
+	require.ErrorContains(t, err, "ResourceNotFoundException")
+
+	noRegionReader := NewSecretReader(map[string]SecretProvider{
+		SecretSchemeAWSSecretsManager: &AWSSecretsManagerProvider{Endpoint: server.URL},
+	})
+	_, err = noRegionReader.ReadSecret(ctx, "avs-sync/key")
+	require.ErrorContains(t, err, "region of the AWS secret manager is not set")
+}
+
+func TestVaultKVSecretProvider(t *testing.T) {
+	// fake of the KV secrets engine, version 2 mounted at kv and version 1 mounted at secret
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		require.Equal(t, "ns1", r.Header.Get("X-Vault-Namespace"))
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			_, _ = w.Write([]byte(`{"data":{"ttl":0,"renewable":false}}`))
+		case "/v1/kv/data/avs-sync":
+			_, _ = w.Write([]byte(`{"data":{"data":{"key":"0xabc","api-key":"fb-key"},"metadata":{"version":3}}}`))
+		case "/v1/secret/avs-sync":
+			_, _ = w.Write([]byte(`{"data":{"key":"0xdef"}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	newReader := func(token string) *SecretReader {
+		provider, err := NewVaultKVSecretProvider(VaultConfig{Address: server.URL, Token: token, Namespace: "ns1"})
+		require.NoError(t, err)
+		return NewSecretReader(map[string]SecretProvider{SecretSchemeVault: provider})
+	}
+	reader := newReader("root")
+
+	secret, err := reader.ReadSecret(ctx, "vault://kv/avs-sync#key")
+	require.NoError(t, err)
+	require.Equal(t, "0xabc", secret)
+	secret, err = reader.ReadSecret(ctx, "vault://secret/avs-sync?kv-version=1")
+	require.NoError(t, err)
+	require.Equal(t, "0xdef", secret)
+
+	_, err = reader.ReadSecret(ctx, "vault://kv/avs-sync")
+	require.ErrorContains(t, err, "secret has fields api-key, key, name the one to read after #")
+	_, err = reader.ReadSecret(ctx, "vault://kv/avs-sync#missing")
+	require.ErrorContains(t, err, "secret has no field missing")
+	_, err = reader.ReadSecret(ctx, "vault://kv/missing#key")
+	require.ErrorContains(t, err, "404")
+	_, err = newReader("wrong").ReadSecret(ctx, "vault://kv/avs-sync#key")
+	require.ErrorContains(t, err, "permission denied")
+
+	_, err = (&VaultKVSecretProvider{}).ReadSecret(ctx, &url.URL{Scheme: SecretSchemeVault, Host: "kv"})
+	require.ErrorContains(t, err, "vault references must be vault://<mount>/<path>#<field>")
+}
@@ -0,0 +1,96 @@
+package avssync
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ReadSecretFile returns the content of a file holding a secret (a private key or a password), without surrounding
+// whitespace. The file must not be accessible by group or others, like ssh private keys.
+func ReadSecretFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	// windows doesn't have unix permissions
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return "", fmt.Errorf("%s is accessible by group or others (mode %04o), restrict it to its owner (chmod 600)", path, info.Mode().Perm())
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("%s is empty", path)
+	}
+	return secret, nil
+}
+
+// ReadEcdsaPrivateKeyFile reads a hex encoded (optionally 0x prefixed) ecdsa private key from the file at path,
+// which must only be accessible by its owner
+func ReadEcdsaPrivateKeyFile(path string) (*ecdsa.PrivateKey, error) {
+	keyHex, err := ReadSecretFile(path)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := ParseEcdsaPrivateKey(keyHex)
+	if err != nil {
+		return nil, fmt.Errorf("%s doesn't contain a valid hex encoded ecdsa private key", path)
+	}
+	return privateKey, nil
+}
+
+// ParseEcdsaPrivateKey parses a hex encoded (optionally 0x prefixed) ecdsa private key, ignoring surrounding whitespace
+func ParseEcdsaPrivateKey(keyHex string) (*ecdsa.PrivateKey, error) {
+	privateKey, err := crypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(keyHex), "0x"))
+	if err != nil {
+		// not wrapped, so that the key doesn't end up in the logs
+		return nil, errors.New("invalid hex encoded ecdsa private key")
+	}
+	return privateKey, nil
+}
+
+// ReadEcdsaKeystore decrypts the go-ethereum encrypted json keystore at path with the password in passwordFile, which
+// must only be accessible by its owner. Decrypting it once at startup surfaces a wrong password right away, and spares
+// decrypting it (which is slow by design) for every transaction.
+func ReadEcdsaKeystore(path string, passwordFile string) (*ecdsa.PrivateKey, error) {
+	password, err := ReadSecretFile(passwordFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read keystore password: %w", err)
+	}
+	keyJson, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := keystore.DecryptKey(keyJson, password)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt keystore %s: %w", path, err)
+	}
+	return key.PrivateKey, nil
+}
+
+// ecdsaKeySources returns the names of the flags of the configured local ecdsa private key sources
+func (c Config) ecdsaKeySources() []string {
+	var sources []string
+	if c.EcdsaPrivateKey != "" {
+		sources = append(sources, "ecdsa-private-key")
+	}
+	if c.EcdsaPrivateKeyFile != "" {
+		sources = append(sources, "ecdsa-private-key-file")
+	}
+	if c.EcdsaKeystorePath != "" {
+		sources = append(sources, "ecdsa-keystore-path")
+	}
+	if c.SecretManagerEcdsaPrivateKeyName != "" {
+		sources = append(sources, "secret-manager-ecdsa-private-key-name")
+	}
+	return sources
+}
@@ -0,0 +1,68 @@
+package avssync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSecretFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret")
+	require.NoError(t, os.WriteFile(path, []byte("s3cret\n"), 0o600))
+	secret, err := ReadSecretFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "s3cret", secret)
+
+	require.NoError(t, os.Chmod(path, 0o644))
+	_, err = ReadSecretFile(path)
+	require.ErrorContains(t, err, "accessible by group or others (mode 0644)")
+
+	empty := filepath.Join(dir, "empty")
+	require.NoError(t, os.WriteFile(empty, []byte("\n"), 0o600))
+	_, err = ReadSecretFile(empty)
+	require.ErrorContains(t, err, "is empty")
+
+	_, err = ReadSecretFile(filepath.Join(dir, "missing"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestReadEcdsaPrivateKeyFile(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "key")
+	require.NoError(t, os.WriteFile(path, []byte(hexutil.Encode(crypto.FromECDSA(privateKey))+"\n"), 0o600))
+	readKey, err := ReadEcdsaPrivateKeyFile(path)
+	require.NoError(t, err)
+	require.Equal(t, privateKey.D, readKey.D)
+
+	require.NoError(t, os.WriteFile(path, []byte("not a key"), 0o600))
+	_, err = ReadEcdsaPrivateKeyFile(path)
+	require.EqualError(t, err, path+" doesn't contain a valid hex encoded ecdsa private key")
+}
+
+func TestReadEcdsaKeystore(t *testing.T) {
+	dir := t.TempDir()
+	account, err := keystore.StoreKey(dir, "password", keystore.LightScryptN, keystore.LightScryptP)
+	require.NoError(t, err)
+	keystorePath, passwordFile := account.URL.Path, filepath.Join(dir, "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("password\n"), 0o600))
+
+	privateKey, err := ReadEcdsaKeystore(keystorePath, passwordFile)
+	require.NoError(t, err)
+	require.Equal(t, account.Address, crypto.PubkeyToAddress(privateKey.PublicKey))
+
+	require.NoError(t, os.WriteFile(passwordFile, []byte("wrong"), 0o600))
+	_, err = ReadEcdsaKeystore(keystorePath, passwordFile)
+	require.ErrorIs(t, err, keystore.ErrDecrypt)
+
+	require.NoError(t, os.Chmod(passwordFile, 0o640))
+	_, err = ReadEcdsaKeystore(keystorePath, passwordFile)
+	require.ErrorContains(t, err, "accessible by group or others")
+}
@@ -0,0 +1,120 @@
+package avssync
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	// updateOperatorsForQuorum, which updates the entire operator set of a quorum
+	CallTypeEntireOperatorSet = "entire_operator_set"
+	// updateOperators, which updates a subset of the operators (or a chunk of a quorum's operators) in all their quorums
+	CallTypeOperatorSubset = "operator_subset"
+)
+
+// errGasBudgetExceeded is returned instead of sending a stake update once the gas budget of the period is spent
+var errGasBudgetExceeded = errors.New("gas budget exceeded")
+
+// GasSpend is the cost of a stake update transaction that made it onchain (reverted transactions cost gas too)
+type GasSpend struct {
+	TxHash common.Hash `json:"txHash"`
+	Time   time.Time   `json:"time"`
+	// empty for operator subset updates, which update all the quorums of the operators
+	Quorum   string   `json:"quorum"`
+	CallType string   `json:"callType"`
+	Wei      *big.Int `json:"wei"`
+}
+
+// GasSpendKey identifies the cumulative cost of the stake updates of a quorum by call type
+type GasSpendKey struct {
+	Quorum   string
+	CallType string
+}
+
+// GasBudget caps the cost of the stake updates over a rolling period
+type GasBudget struct {
+	MaxWei *big.Int // nil means no budget
+	Period time.Duration
+}
+
+func weiToEth(wei *big.Int) float64 {
+	eth, _ := new(big.Float).Quo(new(big.Float).SetInt(wei), big.NewFloat(params.Ether)).Float64()
+	return eth
+}
+
+func ethToWei(eth float64) *big.Int {
+	if eth == 0 {
+		return nil
+	}
+	wei, _ := new(big.Float).Mul(big.NewFloat(eth), big.NewFloat(params.Ether)).Int(nil)
+	return wei
+}
+
+func (c Config) gasBudget() GasBudget {
+	return GasBudget{MaxWei: ethToWei(c.GasBudgetEth), Period: c.GasBudgetPeriod}
+}
+
+// recordGasSpend accounts for the cost of a stake update transaction, i.e. its gas used times its effective gas price.
+// quorum is empty for operator subset updates of all quorums.
+func (a *AvsSync) recordGasSpend(receipt *gethtypes.Receipt, quorum string, callType string) {
+	if receipt.EffectiveGasPrice == nil {
+		a.logger.Warn("Receipt has no effective gas price, cannot account for the cost of the transaction", "txHash", receipt.TxHash.Hex())
+		return
+	}
+	spend := GasSpend{
+		TxHash:   receipt.TxHash,
+		Time:     time.Now(),
+		Quorum:   quorum,
+		CallType: callType,
+		Wei:      new(big.Int).Mul(new(big.Int).SetUint64(receipt.GasUsed), receipt.EffectiveGasPrice),
+	}
+	a.logger.Info("Transaction cost", "txHash", spend.TxHash.Hex(), "quorum", quorum, "callType", callType, "costEth", weiToEth(spend.Wei))
+	a.Metrics.GasSpentAdd(quorum, callType, weiToEth(spend.Wei))
+	a.gasSpends = append(a.gasSpends, spend)
+	a.pruneGasSpends(spend.Time)
+	if a.stateStore != nil {
+		if err := a.stateStore.AddGasSpend(spend, spend.Time.Add(-a.gasBudget.Period)); err != nil {
+			a.logger.Error("Error persisting transaction cost", "err", err, "txHash", spend.TxHash.Hex())
+		}
+	}
+	a.gasSpentInBudgetPeriod(spend.Time)
+}
+
+// pruneGasSpends forgets the spends that are out of the budget period
+func (a *AvsSync) pruneGasSpends(now time.Time) {
+	periodStart := now.Add(-a.gasBudget.Period)
+	i := 0
+	for i < len(a.gasSpends) && !a.gasSpends[i].Time.After(periodStart) {
+		i++
+	}
+	a.gasSpends = a.gasSpends[i:]
+}
+
+// gasSpentInBudgetPeriod returns the cost of the stake updates of the budget period ending now, and exports it
+// along with whether it exceeds the budget
+func (a *AvsSync) gasSpentInBudgetPeriod(now time.Time) *big.Int {
+	a.pruneGasSpends(now)
+	spent := new(big.Int)
+	for _, spend := range a.gasSpends {
+		spent.Add(spent, spend.Wei)
+	}
+	a.Metrics.GasBudgetSet(weiToEth(spent), a.gasBudget.MaxWei != nil && spent.Cmp(a.gasBudget.MaxWei) >= 0)
+	return spent
+}
+
+// checkGasBudget returns errGasBudgetExceeded if the stake updates of the budget period already cost the whole budget,
+// in which case no transaction must be sent until older spends leave the period
+func (a *AvsSync) checkGasBudget() error {
+	spent := a.gasSpentInBudgetPeriod(time.Now())
+	if a.gasBudget.MaxWei == nil || spent.Cmp(a.gasBudget.MaxWei) < 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: spent %g ETH of the %g ETH budget in the last %s", errGasBudgetExceeded,
+		weiToEth(spent), weiToEth(a.gasBudget.MaxWei), a.gasBudget.Period)
+}
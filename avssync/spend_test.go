@@ -0,0 +1,77 @@
+package avssync
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateStoreGasSpends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	store, err := OpenStateStore(path)
+	require.NoError(t, err)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	spend := func(i int64, quorum string, callType string) GasSpend {
+		return GasSpend{TxHash: common.BigToHash(big.NewInt(i)), Time: start.Add(time.Duration(i) * time.Hour), Quorum: quorum, CallType: callType, Wei: big.NewInt(i * 100)}
+	}
+	require.NoError(t, store.AddGasSpend(spend(1, "0", CallTypeEntireOperatorSet), start))
+	require.NoError(t, store.AddGasSpend(spend(2, "0", CallTypeEntireOperatorSet), start))
+	require.NoError(t, store.AddGasSpend(spend(3, "", CallTypeOperatorSubset), start))
+	// the first two leave the period, but still count in the totals
+	require.NoError(t, store.AddGasSpend(spend(4, "1", CallTypeEntireOperatorSet), start.Add(2*time.Hour+time.Minute)))
+	require.NoError(t, store.Close())
+
+	store, err = OpenStateStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	spends, err := store.GasSpends()
+	require.NoError(t, err)
+	require.Equal(t, []GasSpend{spend(3, "", CallTypeOperatorSubset), spend(4, "1", CallTypeEntireOperatorSet)}, spends)
+	totals, err := store.GasSpendTotals()
+	require.NoError(t, err)
+	require.Equal(t, map[GasSpendKey]*big.Int{
+		{Quorum: "0", CallType: CallTypeEntireOperatorSet}: big.NewInt(300),
+		{Quorum: "", CallType: CallTypeOperatorSubset}:     big.NewInt(300),
+		{Quorum: "1", CallType: CallTypeEntireOperatorSet}: big.NewInt(400),
+	}, totals)
+}
+
+func TestGasBudget(t *testing.T) {
+	avsSync := newTestAvsSync([]byte{0}, nil)
+	avsSync.gasBudget = GasBudget{MaxWei: big.NewInt(params.Ether), Period: time.Hour}
+	receipt := func(gasUsed uint64, gasPriceGwei int64) *gethtypes.Receipt {
+		return &gethtypes.Receipt{GasUsed: gasUsed, EffectiveGasPrice: new(big.Int).Mul(big.NewInt(gasPriceGwei), big.NewInt(params.GWei))}
+	}
+
+	// 0.6 ETH
+	avsSync.recordGasSpend(receipt(20_000_000, 30), "0", CallTypeEntireOperatorSet)
+	require.NoError(t, avsSync.checkGasBudget())
+	require.Equal(t, 0.0, testutil.ToFloat64(avsSync.Metrics.gasBudgetExceeded))
+	// 1.2 ETH
+	avsSync.recordGasSpend(receipt(20_000_000, 30), "", CallTypeOperatorSubset)
+	err := avsSync.checkGasBudget()
+	require.ErrorIs(t, err, errGasBudgetExceeded)
+	require.Equal(t, UpdateStakeStatusFatal, failedUpdateStatus(err))
+	require.Equal(t, 1.0, testutil.ToFloat64(avsSync.Metrics.gasBudgetExceeded))
+	require.InDelta(t, 1.2, testutil.ToFloat64(avsSync.Metrics.gasSpentInBudgetPeriod), 1e-9)
+	require.InDelta(t, 0.6, testutil.ToFloat64(avsSync.Metrics.gasSpent.WithLabelValues("0", CallTypeEntireOperatorSet)), 1e-9)
+
+	// the first spend leaves the period
+	avsSync.gasSpends[0].Time = time.Now().Add(-2 * time.Hour)
+	require.NoError(t, avsSync.checkGasBudget())
+	require.Equal(t, 0.0, testutil.ToFloat64(avsSync.Metrics.gasBudgetExceeded))
+	require.Len(t, avsSync.gasSpends, 1)
+
+	// no budget
+	avsSync.gasBudget = GasBudget{}
+	avsSync.recordGasSpend(receipt(20_000_000, 1000), "0", CallTypeEntireOperatorSet)
+	require.NoError(t, avsSync.checkGasBudget())
+}
@@ -0,0 +1,384 @@
+package avssync
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	walletsdk "github.com/Layr-Labs/eigensdk-go/chainio/clients/wallet"
+	sdklogging "github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	quorumsBucket = []byte("quorums")
+	txsBucket     = []byte("txs")
+	metaBucket    = []byte("meta")
+	// the cost of every transaction of the last budget period, by time
+	gasSpendsBucket = []byte("gasSpends")
+	// the cumulative cost of all the transactions, by call type and quorum
+	gasSpendTotalsBucket = []byte("gasSpendTotals")
+
+	lastEventBlockKey = []byte("lastEventBlock")
+)
+
+// InFlightTx is a transaction that was sent, and whose receipt wasn't fetched yet
+type InFlightTx struct {
+	// id of the transaction in the wallet, which is its hash except for Fireblocks wallets
+	TxID     string      `json:"txId"`
+	Hash     common.Hash `json:"hash"`
+	Nonce    uint64      `json:"nonce"`
+	SentTime time.Time   `json:"sentTime"`
+}
+
+// StateStore persists the state of AvsSync in a file, so that it survives restarts: the outcome of the last syncs
+// of each quorum, the transactions in flight, the last block whose events were synced and what the transactions cost.
+// A file can only be opened by a single process at a time.
+type StateStore struct {
+	db *bolt.DB
+}
+
+// OpenStateStore opens the store at path, creating it if it doesn't exist
+func OpenStateStore(path string) (*StateStore, error) {
+	// the file is locked while open, so a second avssync using the same file fails instead of waiting forever
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open state file %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{quorumsBucket, txsBucket, metaBucket, gasSpendsBucket, gasSpendTotalsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("cannot initialize state file %s: %w", path, err)
+	}
+	return &StateStore{db: db}, nil
+}
+
+func (s *StateStore) Close() error {
+	return s.db.Close()
+}
+
+// QuorumSyncs returns the last persisted sync status of every quorum
+func (s *StateStore) QuorumSyncs() (map[byte]QuorumSyncStatus, error) {
+	quorumSyncs := make(map[byte]QuorumSyncStatus)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(quorumsBucket).ForEach(func(k, v []byte) error {
+			quorum, err := strconv.ParseUint(string(k), 10, 8)
+			if err != nil {
+				return fmt.Errorf("invalid quorum %q: %w", k, err)
+			}
+			var quorumSync QuorumSyncStatus
+			if err := json.Unmarshal(v, &quorumSync); err != nil {
+				return fmt.Errorf("invalid sync status of quorum %d: %w", quorum, err)
+			}
+			quorumSyncs[byte(quorum)] = quorumSync
+			return nil
+		})
+	})
+	return quorumSyncs, err
+}
+
+func (s *StateStore) SetQuorumSync(quorum byte, quorumSync QuorumSyncStatus) error {
+	value, err := json.Marshal(quorumSync)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(quorumsBucket).Put([]byte(strconv.Itoa(int(quorum))), value)
+	})
+}
+
+// InFlightTxs returns the transactions that were sent and whose receipt wasn't fetched yet
+func (s *StateStore) InFlightTxs() ([]InFlightTx, error) {
+	var txs []InFlightTx
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(txsBucket).ForEach(func(k, v []byte) error {
+			var inFlightTx InFlightTx
+			if err := json.Unmarshal(v, &inFlightTx); err != nil {
+				return fmt.Errorf("invalid in-flight transaction %s: %w", k, err)
+			}
+			txs = append(txs, inFlightTx)
+			return nil
+		})
+	})
+	return txs, err
+}
+
+func (s *StateStore) AddInFlightTx(inFlightTx InFlightTx) error {
+	value, err := json.Marshal(inFlightTx)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(txsBucket).Put([]byte(inFlightTx.TxID), value)
+	})
+}
+
+func (s *StateStore) RemoveInFlightTx(txID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(txsBucket).Delete([]byte(txID))
+	})
+}
+
+// LastEventBlock returns the last block whose events were synced, found is false if none was persisted
+func (s *StateStore) LastEventBlock() (block uint64, found bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(metaBucket).Get(lastEventBlockKey)
+		if value == nil {
+			return nil
+		}
+		if len(value) != 8 {
+			return fmt.Errorf("invalid last event block %x", value)
+		}
+		block, found = binary.BigEndian.Uint64(value), true
+		return nil
+	})
+	return block, found, err
+}
+
+func (s *StateStore) SetLastEventBlock(block uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(lastEventBlockKey, binary.BigEndian.AppendUint64(nil, block))
+	})
+}
+
+// GasSpends returns the costs of the transactions persisted by AddGasSpend, oldest first
+func (s *StateStore) GasSpends() ([]GasSpend, error) {
+	var spends []GasSpend
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(gasSpendsBucket).ForEach(func(k, v []byte) error {
+			var spend GasSpend
+			if err := json.Unmarshal(v, &spend); err != nil {
+				return fmt.Errorf("invalid transaction cost %x: %w", k, err)
+			}
+			spends = append(spends, spend)
+			return nil
+		})
+	})
+	return spends, err
+}
+
+// GasSpendTotals returns the cumulative cost of all the transactions persisted by AddGasSpend
+func (s *StateStore) GasSpendTotals() (map[GasSpendKey]*big.Int, error) {
+	totals := make(map[GasSpendKey]*big.Int)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(gasSpendTotalsBucket).ForEach(func(k, v []byte) error {
+			callType, quorum, found := strings.Cut(string(k), "/")
+			total, ok := new(big.Int).SetString(string(v), 10)
+			if !found || !ok {
+				return fmt.Errorf("invalid total transaction cost %q: %q", k, v)
+			}
+			totals[GasSpendKey{Quorum: quorum, CallType: callType}] = total
+			return nil
+		})
+	})
+	return totals, err
+}
+
+// AddGasSpend records the cost of a transaction, adds it to the cumulative cost of its call type and quorum,
+// and forgets the costs of the transactions that happened before pruneBefore
+func (s *StateStore) AddGasSpend(spend GasSpend, pruneBefore time.Time) error {
+	value, err := json.Marshal(spend)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		spends := tx.Bucket(gasSpendsBucket)
+		// keys start with the time, so that they are iterated in order
+		key := append(binary.BigEndian.AppendUint64(nil, uint64(spend.Time.UnixNano())), spend.TxHash.Bytes()...)
+		if err := spends.Put(key, value); err != nil {
+			return err
+		}
+		// deleting while iterating with a cursor skips keys
+		var pruned [][]byte
+		cursor := spends.Cursor()
+		for k, _ := cursor.First(); k != nil && int64(binary.BigEndian.Uint64(k[:8])) < pruneBefore.UnixNano(); k, _ = cursor.Next() {
+			pruned = append(pruned, k)
+		}
+		for _, k := range pruned {
+			if err := spends.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		totals := tx.Bucket(gasSpendTotalsBucket)
+		totalKey := []byte(spend.CallType + "/" + spend.Quorum)
+		total := new(big.Int)
+		if value := totals.Get(totalKey); value != nil {
+			if _, ok := total.SetString(string(value), 10); !ok {
+				return fmt.Errorf("invalid total transaction cost %q: %q", totalKey, value)
+			}
+		}
+		return totals.Put(totalKey, []byte(total.Add(total, spend.Wei).String()))
+	})
+}
+
+// stateRecordingWallet records the transactions sent by a wallet as in flight until their receipt is fetched
+type stateRecordingWallet struct {
+	walletsdk.Wallet
+	store  *StateStore
+	logger sdklogging.Logger
+}
+
+// NewStateRecordingWallet wraps wallet so that the transactions it sends are recorded in store until their receipt
+// is fetched, which lets ReconcileInFlightTxs check what happened to them after a crash or restart
+func NewStateRecordingWallet(wallet walletsdk.Wallet, store *StateStore, logger sdklogging.Logger) walletsdk.Wallet {
+	return &stateRecordingWallet{Wallet: wallet, store: store, logger: logger}
+}
+
+func (w *stateRecordingWallet) SendTransaction(ctx context.Context, tx *gethtypes.Transaction) (walletsdk.TxID, error) {
+	txID, err := w.Wallet.SendTransaction(ctx, tx)
+	if err != nil {
+		return txID, err
+	}
+	// failing to record the transaction must not fail the sync, it was sent already
+	inFlightTx := InFlightTx{TxID: txID, Hash: tx.Hash(), Nonce: tx.Nonce(), SentTime: time.Now()}
+	if err := w.store.AddInFlightTx(inFlightTx); err != nil {
+		w.logger.Error("Error recording in-flight transaction", "err", err, "txID", txID)
+	}
+	return txID, nil
+}
+
+func (w *stateRecordingWallet) GetTransactionReceipt(ctx context.Context, txID walletsdk.TxID) (*gethtypes.Receipt, error) {
+	receipt, err := w.Wallet.GetTransactionReceipt(ctx, txID)
+	if err == nil || errors.Is(err, walletsdk.ErrTransactionFailed) {
+		if err := w.store.RemoveInFlightTx(txID); err != nil {
+			w.logger.Error("Error removing in-flight transaction", "err", err, "txID", txID)
+		}
+	}
+	return receipt, err
+}
+
+type nonceBackend interface {
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+}
+
+// ReconcileInFlightTxs checks what happened to the transactions that were in flight when avssync last stopped,
+// and forgets about the ones that landed, failed or whose nonce was used by another transaction.
+// The transactions that are still pending are kept, and returned.
+func ReconcileInFlightTxs(ctx context.Context, logger sdklogging.Logger, store *StateStore, wallet walletsdk.Wallet, client nonceBackend) ([]InFlightTx, error) {
+	txs, err := store.InFlightTxs()
+	if err != nil {
+		return nil, err
+	}
+	if len(txs) == 0 {
+		return nil, nil
+	}
+	sender, err := wallet.SenderAddress(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get sender address: %w", err)
+	}
+	confirmedNonce, err := client.NonceAt(ctx, sender, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get nonce of %s: %w", sender.Hex(), err)
+	}
+
+	var pending []InFlightTx
+	for _, tx := range txs {
+		receipt, err := wallet.GetTransactionReceipt(ctx, tx.TxID)
+		switch {
+		case err == nil && receipt.Status == gethtypes.ReceiptStatusSuccessful:
+			logger.Info("Transaction in flight before restart landed", "txID", tx.TxID, "txHash", receipt.TxHash.Hex(), "block", receipt.BlockNumber)
+		case err == nil:
+			logger.Warn("Transaction in flight before restart reverted", "txID", tx.TxID, "txHash", receipt.TxHash.Hex(), "block", receipt.BlockNumber)
+		case errors.Is(err, walletsdk.ErrTransactionFailed):
+			logger.Warn("Transaction in flight before restart failed", "txID", tx.TxID, "err", err)
+		case errors.Is(err, ethereum.NotFound) && confirmedNonce > tx.Nonce:
+			// another transaction with the same nonce landed, e.g. a gas price bump of this one
+			logger.Warn("Transaction in flight before restart was replaced or dropped", "txID", tx.TxID, "nonce", tx.Nonce)
+		default:
+			logger.Warn("Transaction in flight before restart is still pending", "txID", tx.TxID, "nonce", tx.Nonce, "sentTime", tx.SentTime, "err", err)
+			pending = append(pending, tx)
+			continue
+		}
+		// the wrapped wallet may have removed it already
+		if err := store.RemoveInFlightTx(tx.TxID); err != nil {
+			return nil, err
+		}
+	}
+	return pending, nil
+}
+
+// SetStateStore makes AvsSync persist the outcome of quorum syncs in store, and restores the ones persisted
+// by a previous run. It must be called before Start.
+func (a *AvsSync) SetStateStore(store *StateStore) error {
+	quorumSyncs, err := store.QuorumSyncs()
+	if err != nil {
+		return fmt.Errorf("cannot read quorum sync statuses from state file: %w", err)
+	}
+	gasSpends, err := store.GasSpends()
+	if err != nil {
+		return fmt.Errorf("cannot read transaction costs from state file: %w", err)
+	}
+	gasSpendTotals, err := store.GasSpendTotals()
+	if err != nil {
+		return fmt.Errorf("cannot read total transaction costs from state file: %w", err)
+	}
+	a.gasSpends = gasSpends
+	for key, total := range gasSpendTotals {
+		a.Metrics.GasSpentAdd(key.Quorum, key.CallType, weiToEth(total))
+	}
+	a.gasSpentInBudgetPeriod(time.Now())
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	a.stateStore = store
+	for quorum, quorumSync := range quorumSyncs {
+		a.quorumSyncs[quorum] = quorumSync
+	}
+	return nil
+}
+
+// persistQuorumSync writes the sync status of quorum to the state store, if any
+func (a *AvsSync) persistQuorumSync(quorum byte, quorumSync QuorumSyncStatus) {
+	if a.stateStore == nil {
+		return
+	}
+	if err := a.stateStore.SetQuorumSync(quorum, quorumSync); err != nil {
+		a.logger.Error("Error persisting quorum sync status", "err", err, "quorum", int(quorum))
+	}
+}
+
+// syncOverdue returns whether a scheduled sync was missed since the quorums were last synced successfully
+// (e.g. because avssync was down at the time), in which case the first sync should run right away rather than
+// on schedule. It is never overdue without persisted state.
+func (a *AvsSync) syncOverdue(now time.Time) (bool, time.Time) {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	if a.stateStore == nil || len(a.quorumSyncs) == 0 {
+		return false, time.Time{}
+	}
+	// the least recently synced quorum, among the configured quorums if any (they're fetched later otherwise)
+	var lastSuccess time.Time
+	for quorum, quorumSync := range a.quorumSyncs {
+		if len(a.quorums) > 0 && !slices.Contains(a.quorums, quorum) {
+			continue
+		}
+		if lastSuccess.IsZero() || quorumSync.LastSuccessTime.Before(lastSuccess) {
+			lastSuccess = quorumSync.LastSuccessTime
+		}
+	}
+	if lastSuccess.IsZero() {
+		return true, lastSuccess
+	}
+	if a.schedule != nil {
+		next := a.schedule.Next(lastSuccess)
+		return !next.IsZero() && next.Before(now), lastSuccess
+	}
+	return a.syncInterval > 0 && now.Sub(lastSuccess) >= a.syncInterval, lastSuccess
+}
@@ -0,0 +1,189 @@
+package avssync
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	walletsdk "github.com/Layr-Labs/eigensdk-go/chainio/clients/wallet"
+	"github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.db")
+	store, err := OpenStateStore(path)
+	require.NoError(t, err)
+
+	// the file is locked by the first store
+	_, err = OpenStateStore(path)
+	require.Error(t, err)
+
+	syncTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	quorumSync := QuorumSyncStatus{
+		LastSyncTime:     syncTime,
+		LastSyncStatus:   UpdateStakeStatusSucceed,
+		LastSuccessTime:  syncTime,
+		LastTxHash:       common.HexToHash("0xabc"),
+		LastSuccessBlock: 42,
+	}
+	require.NoError(t, store.SetQuorumSync(1, quorumSync))
+	tx := InFlightTx{TxID: "0x1", Hash: common.HexToHash("0x1"), Nonce: 3, SentTime: syncTime}
+	require.NoError(t, store.AddInFlightTx(tx))
+	require.NoError(t, store.AddInFlightTx(InFlightTx{TxID: "0x2", Hash: common.HexToHash("0x2"), Nonce: 4, SentTime: syncTime}))
+	require.NoError(t, store.RemoveInFlightTx("0x2"))
+	_, found, err := store.LastEventBlock()
+	require.NoError(t, err)
+	require.False(t, found)
+	require.NoError(t, store.SetLastEventBlock(100))
+	require.NoError(t, store.Close())
+
+	// everything survives reopening the file
+	store, err = OpenStateStore(path)
+	require.NoError(t, err)
+	defer store.Close()
+	quorumSyncs, err := store.QuorumSyncs()
+	require.NoError(t, err)
+	require.Equal(t, map[byte]QuorumSyncStatus{1: quorumSync}, quorumSyncs)
+	txs, err := store.InFlightTxs()
+	require.NoError(t, err)
+	require.Equal(t, []InFlightTx{tx}, txs)
+	block, found, err := store.LastEventBlock()
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, uint64(100), block)
+}
+
+type fakeWallet struct {
+	receipts map[string]*gethtypes.Receipt
+	errs     map[string]error
+}
+
+func (w *fakeWallet) SendTransaction(ctx context.Context, tx *gethtypes.Transaction) (walletsdk.TxID, error) {
+	return tx.Hash().Hex(), nil
+}
+
+func (w *fakeWallet) GetTransactionReceipt(ctx context.Context, txID walletsdk.TxID) (*gethtypes.Receipt, error) {
+	if receipt, ok := w.receipts[txID]; ok {
+		return receipt, nil
+	}
+	if err, ok := w.errs[txID]; ok {
+		return nil, err
+	}
+	return nil, ethereum.NotFound
+}
+
+func (w *fakeWallet) SenderAddress(ctx context.Context) (common.Address, error) {
+	return common.HexToAddress("0x1"), nil
+}
+
+type fakeNonceBackend uint64
+
+func (n fakeNonceBackend) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	return uint64(n), nil
+}
+
+func TestReconcileInFlightTxs(t *testing.T) {
+	logger := logging.NewTextSLogger(os.Stderr, &logging.SLoggerOptions{})
+	store, err := OpenStateStore(filepath.Join(t.TempDir(), "state.db"))
+	require.NoError(t, err)
+	defer store.Close()
+	wallet := &fakeWallet{receipts: make(map[string]*gethtypes.Receipt), errs: make(map[string]error)}
+	recordingWallet := NewStateRecordingWallet(wallet, store, logger)
+
+	ctx := context.Background()
+	send := func(nonce uint64) string {
+		txID, err := recordingWallet.SendTransaction(ctx, gethtypes.NewTx(&gethtypes.DynamicFeeTx{Nonce: nonce}))
+		require.NoError(t, err)
+		return txID
+	}
+	// the transaction with nonce 4 gets replaced
+	landed, reverted, failed, _, pending := send(1), send(2), send(3), send(4), send(5)
+	txs, err := store.InFlightTxs()
+	require.NoError(t, err)
+	require.Len(t, txs, 5)
+
+	wallet.receipts[landed] = &gethtypes.Receipt{Status: gethtypes.ReceiptStatusSuccessful, BlockNumber: big.NewInt(10)}
+	wallet.receipts[reverted] = &gethtypes.Receipt{Status: gethtypes.ReceiptStatusFailed, BlockNumber: big.NewInt(10)}
+	wallet.errs[failed] = walletsdk.ErrTransactionFailed
+	// nonce 4 was used, but not nonce 5
+	stillPending, err := ReconcileInFlightTxs(ctx, logger, store, wallet, fakeNonceBackend(5))
+	require.NoError(t, err)
+	require.Len(t, stillPending, 1)
+	require.Equal(t, pending, stillPending[0].TxID)
+	require.Equal(t, uint64(5), stillPending[0].Nonce)
+	txs, err = store.InFlightTxs()
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+
+	// fetching the receipt through the recording wallet removes the transaction
+	wallet.receipts[pending] = &gethtypes.Receipt{Status: gethtypes.ReceiptStatusSuccessful}
+	_, err = recordingWallet.GetTransactionReceipt(ctx, pending)
+	require.NoError(t, err)
+	txs, err = store.InFlightTxs()
+	require.NoError(t, err)
+	require.Empty(t, txs)
+}
+
+func TestSyncOverdue(t *testing.T) {
+	now := time.Date(2024, 1, 2, 1, 0, 0, 0, time.UTC)
+	store, err := OpenStateStore(filepath.Join(t.TempDir(), "state.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	// hourly syncs
+	avsSync := newTestAvsSync([]byte{0, 1}, nil)
+	overdue, _ := avsSync.syncOverdue(now)
+	require.False(t, overdue, "never overdue without a state store")
+	require.NoError(t, avsSync.SetStateStore(store))
+	overdue, _ = avsSync.syncOverdue(now)
+	require.False(t, overdue, "never overdue on the first run")
+
+	avsSync.quorumSyncs[0] = QuorumSyncStatus{LastSuccessTime: now.Add(-30 * time.Minute)}
+	avsSync.quorumSyncs[1] = QuorumSyncStatus{LastSuccessTime: now.Add(-20 * time.Minute)}
+	overdue, _ = avsSync.syncOverdue(now)
+	require.False(t, overdue)
+	avsSync.quorumSyncs[1] = QuorumSyncStatus{LastSuccessTime: now.Add(-90 * time.Minute)}
+	overdue, lastSuccess := avsSync.syncOverdue(now)
+	require.True(t, overdue)
+	require.Equal(t, now.Add(-90*time.Minute), lastSuccess)
+	// quorums that are no longer configured don't count
+	avsSync.quorums = []byte{0}
+	overdue, _ = avsSync.syncOverdue(now)
+	require.False(t, overdue)
+
+	// daily syncs at 00:00
+	avsSync.schedule, err = ParseCronSchedule([]string{"0 0 * * *"}, time.UTC)
+	require.NoError(t, err)
+	avsSync.quorumSyncs[0] = QuorumSyncStatus{LastSuccessTime: time.Date(2024, 1, 2, 0, 0, 5, 0, time.UTC)}
+	overdue, _ = avsSync.syncOverdue(now)
+	require.False(t, overdue)
+	avsSync.quorumSyncs[0] = QuorumSyncStatus{LastSuccessTime: time.Date(2024, 1, 1, 0, 0, 5, 0, time.UTC)}
+	overdue, _ = avsSync.syncOverdue(now)
+	require.True(t, overdue, "the sync of 2024-01-02 00:00 was missed")
+}
+
+func TestSetStateStoreRestoresQuorumSyncs(t *testing.T) {
+	store, err := OpenStateStore(filepath.Join(t.TempDir(), "state.db"))
+	require.NoError(t, err)
+	defer store.Close()
+
+	avsSync := newTestAvsSync([]byte{0}, nil)
+	require.NoError(t, avsSync.SetStateStore(store))
+	avsSync.updateStakeTxLanded(0, &gethtypes.Receipt{TxHash: common.HexToHash("0xabc"), BlockNumber: big.NewInt(7)})
+	avsSync.updateStakeAttemptDone(0, UpdateStakeStatusSucceed)
+
+	restarted := newTestAvsSync([]byte{0}, nil)
+	require.NoError(t, restarted.SetStateStore(store))
+	quorumSync := restarted.Status().QuorumSyncs["0"]
+	require.Equal(t, UpdateStakeStatusSucceed, quorumSync.LastSyncStatus)
+	require.Equal(t, common.HexToHash("0xabc"), quorumSync.LastTxHash)
+	require.Equal(t, uint64(7), quorumSync.LastSuccessBlock)
+	require.True(t, quorumSync.LastSuccessTime.Equal(avsSync.Status().QuorumSyncs["0"].LastSuccessTime))
+}
@@ -0,0 +1,211 @@
+package avssync
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+)
+
+var (
+	ErrSyncsPaused          = errors.New("syncs are paused")
+	ErrSyncAlreadyRequested = errors.New("a sync was already requested and hasn't started yet")
+	ErrLastSyncFailed       = errors.New("last sync failed")
+	ErrNotLeader            = errors.New("this replica is not the leader, syncs run on the leader")
+)
+
+// QuorumSyncStatus is the outcome of the last syncs of a quorum
+type QuorumSyncStatus struct {
+	LastSyncTime   time.Time         `json:"lastSyncTime"`
+	LastSyncStatus UpdateStakeStatus `json:"lastSyncStatus"`
+	// last time the stakes of the quorum were known to be up to date, i.e. its update succeeded or was skipped
+	// because the stakes didn't drift enough
+	LastSuccessTime time.Time `json:"lastSuccessTime"`
+	// last transaction that updated the stakes of the quorum, and the block it landed in
+	LastTxHash       common.Hash `json:"lastTxHash,omitempty"`
+	LastSuccessBlock uint64      `json:"lastSuccessBlock,omitempty"`
+}
+
+// Status is a snapshot of the state of the sync loop
+type Status struct {
+	Paused       bool                        `json:"paused"`
+	Leader       bool                        `json:"leader"`
+	Syncing      bool                        `json:"syncing"`
+	DryRun       bool                        `json:"dryRun"`
+	LastSyncTime time.Time                   `json:"lastSyncTime"`
+	NextSyncTime time.Time                   `json:"nextSyncTime"`
+	Quorums      []int                       `json:"quorums"`
+	Operators    []common.Address            `json:"operators,omitempty"`
+	QuorumSyncs  map[string]QuorumSyncStatus `json:"quorumSyncs"`
+	// only set with use-operator-sets
+	OperatorSets []OperatorSetStatus `json:"operatorSets,omitempty"`
+}
+
+type syncRequest struct {
+	quorums   []byte           // nil means every quorum
+	operators []common.Address // if set, only these operators are updated, in all their quorums
+}
+
+// RequestSync asks the sync loop to run a sync as soon as the current one (if any) completes.
+// quorums restricts the entire operator set update to these quorums, and operators restricts the update
+// to these operators (in all their quorums), both can be nil.
+func (a *AvsSync) RequestSync(quorums []byte, operators []common.Address) error {
+	if a.paused.Load() {
+		return ErrSyncsPaused
+	}
+	if !a.isLeader() {
+		return ErrNotLeader
+	}
+	a.statusMu.Lock()
+	configuredOperators := a.operators
+	a.statusMu.Unlock()
+	if len(quorums) > 0 && (len(operators) > 0 || len(configuredOperators) > 0) {
+		return errors.New("quorums can only be selected when updating the entire operator set")
+	}
+	select {
+	case a.syncRequests <- syncRequest{quorums: quorums, operators: operators}:
+		return nil
+	default:
+		return ErrSyncAlreadyRequested
+	}
+}
+
+// Pause stops scheduled, event triggered and requested syncs from running until Resume is called.
+// A sync that is already running completes.
+func (a *AvsSync) Pause() {
+	if !a.paused.Swap(true) {
+		a.logger.Info("Syncs paused")
+	}
+}
+
+func (a *AvsSync) Resume() {
+	if a.paused.Swap(false) {
+		a.logger.Info("Syncs resumed")
+		if a.eventWatcher != nil {
+			// pick up the events seen while paused
+			a.eventWatcher.retrigger()
+		}
+	}
+}
+
+func (a *AvsSync) Paused() bool {
+	return a.paused.Load()
+}
+
+// Status returns a snapshot of the state of the sync loop
+func (a *AvsSync) Status() Status {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	status := Status{
+		Paused:       a.paused.Load(),
+		Leader:       a.isLeader(),
+		Syncing:      a.syncing,
+		DryRun:       a.dryRun,
+		LastSyncTime: a.lastSyncTime,
+		NextSyncTime: a.nextSyncTime,
+		Quorums:      convertQuorumsBytesToInts(a.quorums),
+		Operators:    a.operators,
+		QuorumSyncs:  make(map[string]QuorumSyncStatus, len(a.quorumSyncs)),
+		OperatorSets: a.operatorSets,
+	}
+	for quorum, quorumSync := range a.quorumSyncs {
+		status.QuorumSyncs[strconv.Itoa(int(quorum))] = quorumSync
+	}
+	return status
+}
+
+// updateStakeAttemptDone records the outcome of the update of a quorum, in the metrics, the status and the state store
+func (a *AvsSync) updateStakeAttemptDone(quorum byte, status UpdateStakeStatus) {
+	a.Metrics.UpdateStakeAttemptInc(status, strconv.Itoa(int(quorum)))
+
+	a.statusMu.Lock()
+	quorumSync := a.quorumSyncs[quorum]
+	quorumSync.LastSyncTime = time.Now()
+	quorumSync.LastSyncStatus = status
+	switch status {
+	case UpdateStakeStatusSucceed, UpdateStakeStatusSkipped:
+		quorumSync.LastSuccessTime = quorumSync.LastSyncTime
+	case UpdateStakeStatusDeferred:
+		// deferring is deliberate, the stakes are as stale as they were, which the health checks catch if it lasts
+	default:
+		a.syncFailed = true
+	}
+	a.quorumSyncs[quorum] = quorumSync
+	a.statusMu.Unlock()
+	a.persistQuorumSync(quorum, quorumSync)
+}
+
+// syncNotRun records that the quorums of req weren't updated, with status telling why
+func (a *AvsSync) syncNotRun(req syncRequest, status UpdateStakeStatus) {
+	quorums := req.quorums
+	if quorums == nil {
+		quorums = a.quorums
+	}
+	for _, quorum := range quorums {
+		a.updateStakeAttemptDone(quorum, status)
+	}
+}
+
+// updateStakeTxLanded records the transaction that successfully updated the stakes of quorum (or of some of its operators),
+// it must be followed by updateStakeAttemptDone once the update of the quorum is done
+func (a *AvsSync) updateStakeTxLanded(quorum byte, receipt *gethtypes.Receipt) {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	quorumSync := a.quorumSyncs[quorum]
+	quorumSync.LastTxHash = receipt.TxHash
+	if receipt.BlockNumber != nil {
+		quorumSync.LastSuccessBlock = receipt.BlockNumber.Uint64()
+	}
+	a.quorumSyncs[quorum] = quorumSync
+}
+
+func (a *AvsSync) setSyncing(syncing bool) {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	a.syncing = syncing
+	if syncing {
+		a.syncStartTime = time.Now()
+		a.syncFailed = false
+	} else {
+		a.lastSyncTime = time.Now()
+	}
+}
+
+func (a *AvsSync) setNextSyncTime(nextSyncTime time.Time) {
+	a.Metrics.NextSyncTimestampSet(nextSyncTime)
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	a.nextSyncTime = nextSyncTime
+}
+
+func (a *AvsSync) setQuorums(quorums []byte) {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	a.quorums = quorums
+}
+
+// readerTimeout returns the reader timeout, which can be changed by config reloads
+func (a *AvsSync) readerTimeout() time.Duration {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	return a.readerTimeoutDuration
+}
+
+// markSyncFailed records that the current sync failed, for failures that aren't specific to a quorum
+func (a *AvsSync) markSyncFailed() {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	a.syncFailed = true
+}
+
+// lastSyncError returns ErrLastSyncFailed if the last sync failed
+func (a *AvsSync) lastSyncError() error {
+	a.statusMu.Lock()
+	defer a.statusMu.Unlock()
+	if a.syncFailed {
+		return ErrLastSyncFailed
+	}
+	return nil
+}
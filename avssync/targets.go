@@ -0,0 +1,152 @@
+package avssync
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// target names end up in file names, kubernetes lease names and metric labels
+var targetNameRegexp = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// TargetConfig is an AVS synced by a multi-target AvsSync. Keys left unset default to the ones of the config.
+type TargetConfig struct {
+	// Name identifies the target in logs, metrics (avs label) and the admin API
+	Name                       string         `yaml:"name" toml:"name" json:"name"`
+	RegistryCoordinatorAddr    common.Address `yaml:"registry-coordinator-addr" toml:"registry-coordinator-addr" json:"registry-coordinator-addr"`
+	OperatorStateRetrieverAddr common.Address `yaml:"operator-state-retriever-addr" toml:"operator-state-retriever-addr" json:"operator-state-retriever-addr"`
+	ServiceManagerAddr         common.Address `yaml:"service-manager-addr" toml:"service-manager-addr" json:"service-manager-addr"`
+
+	// setting operators or quorums turns fetch-quorums-dynamically off, unless the target sets it too
+	Operators               []common.Address `yaml:"operators" toml:"operators" json:"operators"`
+	Quorums                 []int            `yaml:"quorums" toml:"quorums" json:"quorums"`
+	FetchQuorumsDynamically *bool            `yaml:"fetch-quorums-dynamically" toml:"fetch-quorums-dynamically" json:"fetch-quorums-dynamically"`
+	UseOperatorSets         *bool            `yaml:"use-operator-sets" toml:"use-operator-sets" json:"use-operator-sets"`
+
+	// setting any of the schedule keys replaces all of them
+	SyncInterval  time.Duration `yaml:"sync-interval" toml:"sync-interval" json:"sync-interval"`
+	FirstSyncTime string        `yaml:"first-sync-time" toml:"first-sync-time" json:"first-sync-time"`
+	Schedule      []string      `yaml:"schedule" toml:"schedule" json:"schedule"`
+
+	// Signer is the name of the signer of signers sending the transactions, empty means the signer of the config
+	Signer string `yaml:"signer" toml:"signer" json:"signer"`
+}
+
+// TargetConfig returns the config of the AvsSync of target: the config, overridden by the keys set by target.
+// The state files and leader election locks get the name of the target as a suffix, so that targets don't share them,
+// and the metrics server is left to the MultiAvsSync.
+func (c Config) TargetConfig(target TargetConfig) Config {
+	c.AvsName = target.Name
+	if target.RegistryCoordinatorAddr != (common.Address{}) {
+		c.RegistryCoordinatorAddr = target.RegistryCoordinatorAddr
+	}
+	if target.OperatorStateRetrieverAddr != (common.Address{}) {
+		c.OperatorStateRetrieverAddr = target.OperatorStateRetrieverAddr
+	}
+	if target.ServiceManagerAddr != (common.Address{}) {
+		c.ServiceManagerAddr = target.ServiceManagerAddr
+	}
+	if len(target.Operators) > 0 || len(target.Quorums) > 0 {
+		c.Operators = target.Operators
+		c.Quorums = target.Quorums
+		c.FetchQuorumsDynamically = false
+	}
+	if target.FetchQuorumsDynamically != nil {
+		c.FetchQuorumsDynamically = *target.FetchQuorumsDynamically
+	}
+	if target.UseOperatorSets != nil {
+		c.UseOperatorSets = *target.UseOperatorSets
+		if c.UseOperatorSets && len(target.Quorums) == 0 {
+			// the operator sets replace the quorums of the config
+			c.Quorums = nil
+		}
+	}
+	if target.SyncInterval != 0 || target.FirstSyncTime != "" || len(target.Schedule) > 0 {
+		c.SyncInterval = target.SyncInterval
+		c.FirstSyncTime = target.FirstSyncTime
+		c.Schedule = target.Schedule
+	}
+	if target.Signer != "" {
+		c.SignerConfig = c.Signers[target.Signer]
+	}
+
+	c.StateFile = targetPath(c.StateFile, target.Name)
+	c.EventStateFile = targetPath(c.EventStateFile, target.Name)
+	c.LeaderElectionLockFile = targetPath(c.LeaderElectionLockFile, target.Name)
+	if c.LeaderElectionLeaseName != "" {
+		c.LeaderElectionLeaseName += "-" + target.Name
+	}
+	c.MetricsAddr = ""
+	c.Targets = nil
+	c.Signers = nil
+	return c
+}
+
+// targetPath inserts the name of the target before the extension of path, e.g. state.db becomes state-eigenda.db
+func targetPath(path string, name string) string {
+	if path == "" {
+		return ""
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "-" + name + ext
+}
+
+// validateTargets checks every target, as well as the signers they use
+func (c Config) validateTargets() []error {
+	var errs []error
+	names := map[string]bool{}
+	registryCoordinators := map[common.Address]string{}
+	usesConfigSigner := false
+	for i, target := range c.Targets {
+		if !targetNameRegexp.MatchString(target.Name) {
+			errs = append(errs, fmt.Errorf("name %q of target %d must be lowercase letters, digits and dashes", target.Name, i))
+			continue
+		}
+		if names[target.Name] {
+			errs = append(errs, fmt.Errorf("target %s is defined more than once", target.Name))
+			continue
+		}
+		names[target.Name] = true
+
+		targetConfig := c.TargetConfig(target)
+		for _, err := range targetConfig.validateTargetKeys() {
+			errs = append(errs, fmt.Errorf("target %s: %w", target.Name, err))
+		}
+		if other, ok := registryCoordinators[targetConfig.RegistryCoordinatorAddr]; ok {
+			errs = append(errs, fmt.Errorf("targets %s and %s have the same registry-coordinator-addr", other, target.Name))
+		} else if targetConfig.RegistryCoordinatorAddr != (common.Address{}) {
+			registryCoordinators[targetConfig.RegistryCoordinatorAddr] = target.Name
+		}
+		if target.Signer == "" {
+			usesConfigSigner = true
+		} else if _, ok := c.Signers[target.Signer]; !ok {
+			errs = append(errs, fmt.Errorf("target %s: unknown signer %s", target.Name, target.Signer))
+		}
+	}
+
+	// dry runs don't need a signer
+	if c.DryRun {
+		return errs
+	}
+	if usesConfigSigner {
+		errs = append(errs, c.validateSigner()...)
+	}
+	signerNames := make([]string, 0, len(c.Signers))
+	for name := range c.Signers {
+		signerNames = append(signerNames, name)
+	}
+	sort.Strings(signerNames)
+	for _, name := range signerNames {
+		signerConfig := c
+		signerConfig.SignerConfig = c.Signers[name]
+		for _, err := range signerConfig.validateSigner() {
+			errs = append(errs, fmt.Errorf("signer %s: %w", name, err))
+		}
+	}
+	return errs
+}
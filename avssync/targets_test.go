@@ -0,0 +1,193 @@
+package avssync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadConfigFileTargets(t *testing.T) {
+	dir := t.TempDir()
+	yamlPath := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`
+ecdsa-private-key-file: /keys/default
+targets:
+  - name: eigenda
+    registry-coordinator-addr: "0x0000000000000000000000000000000000000001"
+    quorums: [0, 1]
+  - name: other-avs
+    registry-coordinator-addr: "0x0000000000000000000000000000000000000002"
+    fetch-quorums-dynamically: true
+    signer: other
+signers:
+  other:
+    vault-addr: https://vault:8200
+    vault-transit-key: other-avs
+`), 0644))
+	tomlPath := filepath.Join(dir, "config.toml")
+	require.NoError(t, os.WriteFile(tomlPath, []byte(`
+ecdsa-private-key-file = "/keys/default"
+
+[[targets]]
+name = "eigenda"
+registry-coordinator-addr = "0x0000000000000000000000000000000000000001"
+quorums = [0, 1]
+
+[[targets]]
+name = "other-avs"
+registry-coordinator-addr = "0x0000000000000000000000000000000000000002"
+fetch-quorums-dynamically = true
+signer = "other"
+
+[signers.other]
+vault-addr = "https://vault:8200"
+vault-transit-key = "other-avs"
+`), 0644))
+
+	fetchQuorumsDynamically := true
+	for _, path := range []string{yamlPath, tomlPath} {
+		var cfg Config
+		require.NoError(t, ReadConfigFile(path, &cfg), path)
+		require.Equal(t, Config{
+			SignerConfig: SignerConfig{EcdsaPrivateKeyFile: "/keys/default"},
+			Targets: []TargetConfig{
+				{Name: "eigenda", RegistryCoordinatorAddr: common.HexToAddress("0x1"), Quorums: []int{0, 1}},
+				{Name: "other-avs", RegistryCoordinatorAddr: common.HexToAddress("0x2"), FetchQuorumsDynamically: &fetchQuorumsDynamically, Signer: "other"},
+			},
+			Signers: map[string]SignerConfig{
+				"other": {VaultAddr: "https://vault:8200", VaultTransitKey: "other-avs"},
+			},
+		}, cfg, path)
+	}
+}
+
+func TestTargetConfig(t *testing.T) {
+	cfg := validTestConfig()
+	cfg.Schedule = []string{"0 0 * * *"}
+	cfg.StateFile = "/data/state.db"
+	cfg.LeaderElectionLeaseName = "avs-sync"
+	cfg.MetricsAddr = ":9090"
+	cfg.Signers = map[string]SignerConfig{"other": {EcdsaPrivateKeyFile: "/keys/other"}}
+	cfg.Targets = []TargetConfig{{Name: "eigenda"}}
+
+	// unset keys default to the ones of the config
+	targetCfg := cfg.TargetConfig(TargetConfig{Name: "eigenda"})
+	require.Equal(t, "eigenda", targetCfg.AvsName)
+	require.Equal(t, cfg.RegistryCoordinatorAddr, targetCfg.RegistryCoordinatorAddr)
+	require.True(t, targetCfg.FetchQuorumsDynamically)
+	require.Equal(t, cfg.Schedule, targetCfg.Schedule)
+	require.Equal(t, cfg.SignerConfig, targetCfg.SignerConfig)
+	require.Equal(t, "/data/state-eigenda.db", targetCfg.StateFile)
+	require.Equal(t, "avs-sync-eigenda", targetCfg.LeaderElectionLeaseName)
+	require.Empty(t, targetCfg.MetricsAddr)
+	require.Nil(t, targetCfg.Targets)
+	require.Nil(t, targetCfg.Signers)
+
+	targetCfg = cfg.TargetConfig(TargetConfig{
+		Name:                    "other",
+		RegistryCoordinatorAddr: common.HexToAddress("0x11"),
+		Quorums:                 []int{2},
+		SyncInterval:            time.Hour,
+		Signer:                  "other",
+	})
+	require.Equal(t, common.HexToAddress("0x11"), targetCfg.RegistryCoordinatorAddr)
+	require.Equal(t, cfg.OperatorStateRetrieverAddr, targetCfg.OperatorStateRetrieverAddr)
+	require.Equal(t, []int{2}, targetCfg.Quorums)
+	// setting quorums turns fetching them off
+	require.False(t, targetCfg.FetchQuorumsDynamically)
+	// setting the sync interval replaces the schedule
+	require.Empty(t, targetCfg.Schedule)
+	require.Equal(t, time.Hour, targetCfg.SyncInterval)
+	require.Equal(t, SignerConfig{EcdsaPrivateKeyFile: "/keys/other"}, targetCfg.SignerConfig)
+	require.Equal(t, cfg.WriterTimeout, targetCfg.WriterTimeout)
+
+	// the operator sets of a target replace the quorums of the config
+	useOperatorSets := true
+	cfg.Quorums = []int{0}
+	targetCfg = cfg.TargetConfig(TargetConfig{Name: "slashing", UseOperatorSets: &useOperatorSets})
+	require.True(t, targetCfg.UseOperatorSets)
+	require.Empty(t, targetCfg.Quorums)
+}
+
+func TestValidateTargets(t *testing.T) {
+	validTargetsConfig := func() Config {
+		cfg := validTestConfig()
+		cfg.Signers = map[string]SignerConfig{"other": {EcdsaPrivateKeyFile: "/keys/other"}}
+		cfg.Targets = []TargetConfig{
+			{Name: "eigenda"},
+			{Name: "other-avs", RegistryCoordinatorAddr: common.HexToAddress("0x11"), Signer: "other"},
+		}
+		return cfg
+	}
+	require.NoError(t, validTargetsConfig().Validate())
+
+	testCases := map[string]struct {
+		modify      func(cfg *Config)
+		errContains string
+	}{
+		"invalid name": {
+			modify:      func(cfg *Config) { cfg.Targets[0].Name = "EigenDA" },
+			errContains: `name "EigenDA" of target 0 must be lowercase letters, digits and dashes`,
+		},
+		"duplicate name": {
+			modify:      func(cfg *Config) { cfg.Targets[1].Name = "eigenda" },
+			errContains: "target eigenda is defined more than once",
+		},
+		"same registry coordinator": {
+			modify:      func(cfg *Config) { cfg.Targets[1].RegistryCoordinatorAddr = cfg.RegistryCoordinatorAddr },
+			errContains: "targets eigenda and other-avs have the same registry-coordinator-addr",
+		},
+		"missing registry coordinator": {
+			modify: func(cfg *Config) {
+				cfg.RegistryCoordinatorAddr = common.Address{}
+			},
+			errContains: "target eigenda: registry-coordinator-addr is required",
+		},
+		"invalid schedule": {
+			modify:      func(cfg *Config) { cfg.Targets[1].Schedule = []string{"not a cron"} },
+			errContains: "target other-avs: ",
+		},
+		"unknown signer": {
+			modify:      func(cfg *Config) { cfg.Targets[1].Signer = "unknown" },
+			errContains: "target other-avs: unknown signer unknown",
+		},
+		"invalid signer": {
+			modify: func(cfg *Config) {
+				cfg.Signers["other"] = SignerConfig{EcdsaPrivateKeyFile: "/keys/other", EcdsaPrivateKey: "0123"}
+			},
+			errContains: "signer other: only one signer can be set",
+		},
+		"signers without targets": {
+			modify:      func(cfg *Config) { cfg.Targets = nil },
+			errContains: "signers can only be used by targets",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cfg := validTargetsConfig()
+			tc.modify(&cfg)
+			require.ErrorContains(t, cfg.Validate(), tc.errContains)
+		})
+	}
+
+	// the signer of the config isn't needed when every target has its own
+	cfg := validTargetsConfig()
+	cfg.Targets[0].Signer = "other"
+	cfg.Targets[0].RegistryCoordinatorAddr = common.HexToAddress("0x12")
+	cfg.SignerConfig = SignerConfig{}
+	require.NoError(t, cfg.Validate())
+}
+
+func TestConfigRedactedSigners(t *testing.T) {
+	cfg := validTestConfig()
+	cfg.Signers = map[string]SignerConfig{"other": {EcdsaPrivateKey: "0456", VaultToken: "hvs.token"}}
+	redactedCfg := cfg.Redacted()
+	require.Equal(t, redacted, redactedCfg.EcdsaPrivateKey)
+	require.Equal(t, SignerConfig{EcdsaPrivateKey: redacted, VaultToken: redacted}, redactedCfg.Signers["other"])
+	// the config itself is left untouched
+	require.Equal(t, "0456", cfg.Signers["other"].EcdsaPrivateKey)
+}
@@ -0,0 +1,351 @@
+package avssync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	sdklogging "github.com/Layr-Labs/eigensdk-go/logging"
+)
+
+// Methods authenticating to Vault
+const (
+	VaultAuthToken      = "token"
+	VaultAuthAppRole    = "approle"
+	VaultAuthKubernetes = "kubernetes"
+)
+
+// DefaultVaultKubernetesTokenFile is the service account token mounted in Kubernetes pods
+const DefaultVaultKubernetesTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// VaultConfig is how to reach and authenticate to a HashiCorp Vault server
+type VaultConfig struct {
+	// Address of the server, e.g. https://vault:8200
+	Address string
+	// Vault Enterprise namespace, optional
+	Namespace string
+	// AuthMethod is VaultAuthToken (the default), VaultAuthAppRole or VaultAuthKubernetes
+	AuthMethod string
+	// AuthMount is the path the auth method is mounted at, the name of the method by default
+	AuthMount string
+	// Token of the token auth method
+	Token string
+	// AppRoleID and AppRoleSecretID of the approle auth method
+	AppRoleID       string
+	AppRoleSecretID string
+	// KubernetesRole of the kubernetes auth method, logged in to with the service account token in KubernetesTokenFile
+	// (DefaultVaultKubernetesTokenFile by default)
+	KubernetesRole      string
+	KubernetesTokenFile string
+}
+
+func (c Config) VaultConfig() VaultConfig {
+	return VaultConfig{
+		Address:             c.VaultAddr,
+		Namespace:           c.VaultNamespace,
+		AuthMethod:          c.VaultAuthMethod,
+		AuthMount:           c.VaultAuthMount,
+		Token:               c.VaultToken,
+		AppRoleID:           c.VaultAppRoleID,
+		AppRoleSecretID:     c.VaultAppRoleSecretID,
+		KubernetesRole:      c.VaultKubernetesRole,
+		KubernetesTokenFile: c.VaultKubernetesTokenFile,
+	}
+}
+
+// validateVault checks the settings of vault-addr's auth method
+func (c Config) validateVault() []error {
+	var errs []error
+	switch c.VaultAuthMethod {
+	case "", VaultAuthToken:
+		if c.VaultToken == "" {
+			errs = append(errs, errors.New("vault-token is required with token vault auth"))
+		}
+	case VaultAuthAppRole:
+		if c.VaultAppRoleID == "" || c.VaultAppRoleSecretID == "" {
+			errs = append(errs, errors.New("vault-approle-role-id and vault-approle-secret-id are required with approle vault auth"))
+		}
+	case VaultAuthKubernetes:
+		if c.VaultKubernetesRole == "" {
+			errs = append(errs, errors.New("vault-kubernetes-role is required with kubernetes vault auth"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("vault-auth-method must be %s, %s or %s", VaultAuthToken, VaultAuthAppRole, VaultAuthKubernetes))
+	}
+	return errs
+}
+
+// vaultClient calls the HTTP API of Vault, logging in with the configured auth method when it needs a token
+type vaultClient struct {
+	config VaultConfig
+	client *http.Client
+
+	mu    sync.Mutex
+	token string
+	// expiry is zero for tokens that don't expire
+	expiry    time.Time
+	renewable bool
+}
+
+func newVaultClient(config VaultConfig) (*vaultClient, error) {
+	if config.Address == "" {
+		return nil, errors.New("vault address is not set")
+	}
+	if config.AuthMethod == "" {
+		config.AuthMethod = VaultAuthToken
+	}
+	if config.AuthMount == "" {
+		config.AuthMount = config.AuthMethod
+	}
+	if config.KubernetesTokenFile == "" {
+		config.KubernetesTokenFile = DefaultVaultKubernetesTokenFile
+	}
+	return &vaultClient{config: config, client: &http.Client{}}, nil
+}
+
+// vaultResponse is the envelope of the responses of Vault
+type vaultResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Auth   *vaultAuth      `json:"auth"`
+	Errors []string        `json:"errors"`
+}
+
+// vaultAuth is the token issued by a login or renewal
+type vaultAuth struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int64  `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
+
+// vaultStatusError is returned for responses with a non 2xx status
+type vaultStatusError struct {
+	method     string
+	path       string
+	statusCode int
+	status     string
+	errors     []string
+}
+
+func (e *vaultStatusError) Error() string {
+	if len(e.errors) > 0 {
+		return fmt.Sprintf("vault %s %s: http status %s: %s", e.method, e.path, e.status, strings.Join(e.errors, ", "))
+	}
+	return fmt.Sprintf("vault %s %s: http status %s", e.method, e.path, e.status)
+}
+
+// request calls the Vault API at path (below /v1/), with body encoded as json if not nil, and decodes the data of
+// the response into out if not nil. A request denied because the token expired is retried once with a new token,
+// for the auth methods that can log in again.
+func (c *vaultClient) request(ctx context.Context, method string, path string, body any, out any) error {
+	token, err := c.currentToken(ctx)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(ctx, method, path, token, body)
+	var statusErr *vaultStatusError
+	if errors.As(err, &statusErr) && statusErr.statusCode == http.StatusForbidden && c.config.AuthMethod != VaultAuthToken {
+		c.forgetToken(token)
+		if token, err = c.currentToken(ctx); err != nil {
+			return err
+		}
+		resp, err = c.do(ctx, method, path, token, body)
+	}
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(resp.Data, out); err != nil {
+		return fmt.Errorf("vault %s %s: cannot decode response data: %w", method, path, err)
+	}
+	return nil
+}
+
+func (c *vaultClient) do(ctx context.Context, method string, path string, token string, body any) (*vaultResponse, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	url := strings.TrimSuffix(c.config.Address, "/") + "/v1/" + strings.TrimPrefix(path, "/")
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if c.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.config.Namespace)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	var vaultResp vaultResponse
+	// errors come with a json body listing them, but proxies may answer otherwise
+	decodeErr := json.Unmarshal(respBody, &vaultResp)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, &vaultStatusError{method: method, path: path, statusCode: resp.StatusCode, status: resp.Status, errors: vaultResp.Errors}
+	}
+	if decodeErr != nil && len(respBody) > 0 {
+		return nil, fmt.Errorf("vault %s %s: cannot decode response: %w", method, path, decodeErr)
+	}
+	return &vaultResp, nil
+}
+
+// currentToken returns the token to call Vault with, logging in first if there is none
+func (c *vaultClient) currentToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == "" {
+		if err := c.login(ctx); err != nil {
+			return "", err
+		}
+	}
+	return c.token, nil
+}
+
+// forgetToken drops token if it still is the current one, so that the next request logs in again
+func (c *vaultClient) forgetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+// login gets a token with the configured auth method. c.mu must be held.
+func (c *vaultClient) login(ctx context.Context) error {
+	var loginBody map[string]any
+	switch c.config.AuthMethod {
+	case VaultAuthToken:
+		// the token is given, look it up to know when it expires
+		resp, err := c.do(ctx, "GET", "auth/token/lookup-self", c.config.Token, nil)
+		if err != nil {
+			return fmt.Errorf("cannot look up vault token: %w", err)
+		}
+		var data struct {
+			TTL       int64 `json:"ttl"`
+			Renewable bool  `json:"renewable"`
+		}
+		if err := json.Unmarshal(resp.Data, &data); err != nil {
+			return fmt.Errorf("cannot decode vault token lookup: %w", err)
+		}
+		c.setToken(vaultAuth{ClientToken: c.config.Token, LeaseDuration: data.TTL, Renewable: data.Renewable})
+		return nil
+	case VaultAuthAppRole:
+		loginBody = map[string]any{"role_id": c.config.AppRoleID, "secret_id": c.config.AppRoleSecretID}
+	case VaultAuthKubernetes:
+		jwt, err := os.ReadFile(c.config.KubernetesTokenFile)
+		if err != nil {
+			return fmt.Errorf("cannot read kubernetes service account token: %w", err)
+		}
+		loginBody = map[string]any{"role": c.config.KubernetesRole, "jwt": strings.TrimSpace(string(jwt))}
+	default:
+		return fmt.Errorf("unknown vault auth method %s", c.config.AuthMethod)
+	}
+	resp, err := c.do(ctx, "POST", "auth/"+c.config.AuthMount+"/login", "", loginBody)
+	if err != nil {
+		return fmt.Errorf("cannot log in to vault with %s auth: %w", c.config.AuthMethod, err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return fmt.Errorf("vault %s login didn't return a token", c.config.AuthMethod)
+	}
+	c.setToken(*resp.Auth)
+	return nil
+}
+
+// setToken records a token issued by a login or renewal. c.mu must be held.
+func (c *vaultClient) setToken(auth vaultAuth) {
+	c.token = auth.ClientToken
+	c.renewable = auth.Renewable
+	c.expiry = time.Time{}
+	if auth.LeaseDuration > 0 {
+		c.expiry = time.Now().Add(time.Duration(auth.LeaseDuration) * time.Second)
+	}
+}
+
+// renewToken extends the lease of the token, or logs in again if it can't be renewed
+func (c *vaultClient) renewToken(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var renewErr error
+	if c.token != "" && c.renewable {
+		resp, err := c.do(ctx, "POST", "auth/token/renew-self", c.token, map[string]any{})
+		if err == nil && resp.Auth != nil {
+			if resp.Auth.ClientToken == "" {
+				resp.Auth.ClientToken = c.token
+			}
+			c.setToken(*resp.Auth)
+			return nil
+		}
+		renewErr = err
+		if renewErr == nil {
+			renewErr = errors.New("vault token renewal didn't return a lease")
+		}
+	}
+	if c.config.AuthMethod == VaultAuthToken {
+		if renewErr != nil {
+			return fmt.Errorf("cannot renew vault token: %w", renewErr)
+		}
+		return fmt.Errorf("vault token is not renewable and expires at %s", c.expiry.Format(time.RFC3339))
+	}
+	// the token reached its max ttl, or was revoked
+	return c.login(ctx)
+}
+
+// renewalDelay returns how long to wait before renewing the token, at two thirds of its remaining lease, and false
+// if it doesn't need to be renewed
+func (c *vaultClient) renewalDelay() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == "" || c.expiry.IsZero() {
+		return 0, false
+	}
+	return max(time.Until(c.expiry)*2/3, minVaultRenewalDelay), true
+}
+
+// minVaultRenewalDelay keeps a token that can't be renewed from being renewed in a loop
+const minVaultRenewalDelay = time.Second
+
+// keepTokenAlive renews the token before it expires until ctx is done
+func (c *vaultClient) keepTokenAlive(ctx context.Context, logger sdklogging.Logger) {
+	for {
+		delay, ok := c.renewalDelay()
+		if !ok {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		if err := c.renewToken(ctx); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Error("Cannot renew vault token", "err", err)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package avssync
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	sdklogging "github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/Layr-Labs/eigensdk-go/signerv2"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// VaultSecp256k1KeyType is the type of the transit keys that can sign Ethereum transactions
+const VaultSecp256k1KeyType = "ecdsa-secp256k1"
+
+var (
+	oidPublicKeyECDSA      = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidNamedCurveSecp256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+// VaultTransitSignerConfig is the Vault transit key signing the transactions
+type VaultTransitSignerConfig struct {
+	Vault VaultConfig
+	// Mount is the path the transit secrets engine is mounted at, transit by default
+	Mount string
+	// Key is the name of the secp256k1 transit key
+	Key string
+}
+
+func (c Config) VaultTransitSignerConfig() VaultTransitSignerConfig {
+	return VaultTransitSignerConfig{
+		Vault: c.VaultConfig(),
+		Mount: c.VaultTransitMount,
+		Key:   c.VaultTransitKey,
+	}
+}
+
+// VaultTransitSigner signs transactions with a secp256k1 key of the Vault transit secrets engine, which never leaves Vault.
+// Vault signs the transaction hash, and the recovery id of the signature is worked out locally.
+type VaultTransitSigner struct {
+	config VaultTransitSignerConfig
+	client *vaultClient
+	logger sdklogging.Logger
+	signer gethtypes.Signer
+
+	// the key version is pinned at startup, so that rotating the key doesn't change the sender in the middle of a run
+	keyVersion int
+	publicKey  []byte
+	address    common.Address
+}
+
+// NewVaultTransitSigner logs in to Vault and reads the public key of the latest version of the transit key
+func NewVaultTransitSigner(ctx context.Context, config VaultTransitSignerConfig, chainID *big.Int, logger sdklogging.Logger) (*VaultTransitSigner, error) {
+	if config.Mount == "" {
+		config.Mount = "transit"
+	}
+	if config.Key == "" {
+		return nil, errors.New("vault transit key is not set")
+	}
+	client, err := newVaultClient(config.Vault)
+	if err != nil {
+		return nil, err
+	}
+	var key struct {
+		Type          string `json:"type"`
+		LatestVersion int    `json:"latest_version"`
+		Keys          map[string]struct {
+			PublicKey string `json:"public_key"`
+		} `json:"keys"`
+	}
+	if err := client.request(ctx, "GET", config.Mount+"/keys/"+config.Key, nil, &key); err != nil {
+		return nil, fmt.Errorf("cannot read transit key %s: %w", config.Key, err)
+	}
+	if key.Type != VaultSecp256k1KeyType {
+		return nil, fmt.Errorf("transit key %s is a %s key, signing transactions requires a %s key", config.Key, key.Type, VaultSecp256k1KeyType)
+	}
+	publicKey, err := parseSecp256k1PublicKeyPEM(key.Keys[strconv.Itoa(key.LatestVersion)].PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key of version %d of transit key %s: %w", key.LatestVersion, config.Key, err)
+	}
+	return &VaultTransitSigner{
+		config:     config,
+		client:     client,
+		logger:     logger,
+		signer:     gethtypes.LatestSignerForChainID(chainID),
+		keyVersion: key.LatestVersion,
+		publicKey:  publicKey,
+		address:    common.BytesToAddress(crypto.Keccak256(publicKey[1:])[12:]),
+	}, nil
+}
+
+// parseSecp256k1PublicKeyPEM returns the uncompressed secp256k1 public key of a PEM encoded SubjectPublicKeyInfo,
+// which crypto/x509 can't parse since it doesn't support the curve
+func parseSecp256k1PublicKeyPEM(publicKeyPEM string) ([]byte, error) {
+	block, _ := pem.Decode([]byte(publicKeyPEM))
+	if block == nil {
+		return nil, errors.New("no PEM encoded public key")
+	}
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(block.Bytes, &spki); err != nil {
+		return nil, err
+	}
+	var curve asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(spki.Algorithm.Parameters.FullBytes, &curve); err != nil {
+		return nil, fmt.Errorf("cannot decode curve: %w", err)
+	}
+	if !spki.Algorithm.Algorithm.Equal(oidPublicKeyECDSA) || !curve.Equal(oidNamedCurveSecp256k1) {
+		return nil, errors.New("not a secp256k1 public key")
+	}
+	publicKey, err := crypto.UnmarshalPubkey(spki.PublicKey.RightAlign())
+	if err != nil {
+		return nil, err
+	}
+	return crypto.FromECDSAPub(publicKey), nil
+}
+
+// Address returns the address of the transit key, which sends the transactions
+func (s *VaultTransitSigner) Address() common.Address {
+	return s.address
+}
+
+// KeyVersion returns the version of the transit key signing the transactions
+func (s *VaultTransitSigner) KeyVersion() int {
+	return s.keyVersion
+}
+
+// KeepTokenAlive renews the Vault token before it expires (logging in again when it can't be renewed anymore),
+// until ctx is done
+func (s *VaultTransitSigner) KeepTokenAlive(ctx context.Context) {
+	s.client.keepTokenAlive(ctx, s.logger)
+}
+
+// SignerFn returns the signer of the wallet sending the transactions, see walletsdk.NewPrivateKeyWallet
+func (s *VaultTransitSigner) SignerFn() signerv2.SignerFn {
+	return func(ctx context.Context, address common.Address) (bind.SignerFn, error) {
+		return func(from common.Address, tx *gethtypes.Transaction) (*gethtypes.Transaction, error) {
+			return s.SignTransaction(ctx, from, tx)
+		}, nil
+	}
+}
+
+// SignTransaction has Vault sign the hash of tx. All errors wrap ErrRemoteSigner.
+func (s *VaultTransitSigner) SignTransaction(ctx context.Context, from common.Address, tx *gethtypes.Transaction) (*gethtypes.Transaction, error) {
+	signedTx, err := s.signTransaction(ctx, from, tx)
+	if err != nil {
+		return nil, fmt.Errorf("%w: vault transit: %w", ErrRemoteSigner, err)
+	}
+	return signedTx, nil
+}
+
+func (s *VaultTransitSigner) signTransaction(ctx context.Context, from common.Address, tx *gethtypes.Transaction) (*gethtypes.Transaction, error) {
+	if from != s.address {
+		return nil, fmt.Errorf("cannot sign for %s, the transit key address is %s", from.Hex(), s.address.Hex())
+	}
+	hash := s.signer.Hash(tx)
+	var data struct {
+		Signature string `json:"signature"`
+	}
+	err := s.client.request(ctx, "POST", s.config.Mount+"/sign/"+s.config.Key, map[string]any{
+		"input":                base64.StdEncoding.EncodeToString(hash[:]),
+		"prehashed":            true,
+		"hash_algorithm":       "sha2-256",
+		"marshaling_algorithm": "asn1",
+		"key_version":          s.keyVersion,
+	}, &data)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := s.ethereumSignature(hash, data.Signature)
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSignature(s.signer, sig)
+}
+
+// ethereumSignature converts the ASN.1 DER signature returned by Vault (vault:v<version>:<base64 der>) into the
+// 65 bytes [R || S || V] signature of Ethereum, with the low S value and the recovery id V recovering the public key
+// of the transit key
+func (s *VaultTransitSigner) ethereumSignature(hash common.Hash, vaultSignature string) ([]byte, error) {
+	parts := strings.SplitN(vaultSignature, ":", 3)
+	if len(parts) != 3 || parts[0] != "vault" {
+		return nil, fmt.Errorf("unexpected signature format %q", vaultSignature)
+	}
+	der, err := base64.StdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode signature: %w", err)
+	}
+	var rs struct {
+		R, S *big.Int
+	}
+	if rest, err := asn1.Unmarshal(der, &rs); err != nil || len(rest) > 0 {
+		return nil, errors.New("signature is not an ASN.1 DER encoded ecdsa signature")
+	}
+	curveOrder := crypto.S256().Params().N
+	if rs.R.Sign() <= 0 || rs.S.Sign() <= 0 || rs.R.Cmp(curveOrder) >= 0 || rs.S.Cmp(curveOrder) >= 0 {
+		return nil, errors.New("signature values out of range")
+	}
+	// Ethereum only accepts the lower of the two valid S values (EIP-2)
+	if rs.S.Cmp(new(big.Int).Rsh(curveOrder, 1)) > 0 {
+		rs.S = new(big.Int).Sub(curveOrder, rs.S)
+	}
+	sig := make([]byte, crypto.SignatureLength)
+	rs.R.FillBytes(sig[:32])
+	rs.S.FillBytes(sig[32:64])
+	for v := byte(0); v < 2; v++ {
+		sig[crypto.RecoveryIDOffset] = v
+		publicKey, err := crypto.Ecrecover(hash[:], sig)
+		if err == nil && string(publicKey) == string(s.publicKey) {
+			return sig, nil
+		}
+	}
+	return nil, errors.New("signature doesn't recover the public key of the transit key")
+}
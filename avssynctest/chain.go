@@ -0,0 +1,647 @@
+// Package avssynctest provides an in-memory fake of the AVS registry contracts, to unit test code built on the
+// avssync package (scheduling, retries, metrics...) without a chain.
+package avssynctest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/Layr-Labs/avs-sync/avssync"
+	"github.com/Layr-Labs/eigensdk-go/chainio/txmgr"
+	opstateretriever "github.com/Layr-Labs/eigensdk-go/contracts/bindings/OperatorStateRetriever"
+	regcoord "github.com/Layr-Labs/eigensdk-go/contracts/bindings/RegistryCoordinator"
+	"github.com/Layr-Labs/eigensdk-go/types"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	DefaultBlockGasLimit = 30_000_000
+	// in wei, the suggested tip is always 1 gwei
+	DefaultBaseFee = 1_000_000_000
+	// in ETH, balance of the accounts whose balance wasn't set
+	DefaultBalance = 100
+	// gas used by a stake update transaction, on top of GasPerOperator for every operator it updates
+	TxBaseGas      = 50_000
+	GasPerOperator = 30_000
+)
+
+// Fault is a failure injected in the next stake update transaction, see Chain.InjectFaults
+type Fault int
+
+const (
+	// the transaction is mined but reverts
+	FaultRevert Fault = iota + 1
+	// the transaction never gets a receipt: the update blocks until its context is done, and isn't applied
+	FaultTimeout
+	// an operator registers in the updated quorums right before the transaction is mined,
+	// so an entire operator set update reverts because its operator set is stale
+	FaultOperatorSetRace
+	// the transaction runs out of gas and reverts
+	FaultOutOfGas
+	// the sender can't pay for the transaction, which is never sent
+	FaultInsufficientFunds
+	// the remote signer fails to sign the transaction, which is never sent
+	FaultSignerError
+)
+
+// RegistryCoordinatorAddr is the address the stake update transactions are sent to
+var RegistryCoordinatorAddr = common.HexToAddress("0xc0")
+
+// Tx is a stake update transaction that was mined
+type Tx struct {
+	Hash        common.Hash
+	BlockNumber uint64
+	// the quorums whose entire operator set was updated, nil for an operator subset update
+	Quorums   []byte
+	Operators []common.Address
+	// calldata of the RegistryCoordinator call
+	Data     []byte
+	GasLimit uint64
+	GasUsed  uint64
+	Reverted bool
+}
+
+type operatorStake struct {
+	// stake recorded in the StakeRegistry, as of the last update of the operator
+	recorded *big.Int
+	// stake computed from the current delegated shares, which the next update records
+	current *big.Int
+}
+
+type quorum struct {
+	minimumStake *big.Int
+	operators    map[common.Address]*operatorStake
+}
+
+// Chain is an in-memory AVS registry, implementing avssync.AvsReader and avssync.AvsWriter, as well as
+// avssync.OperatorSetReader for the quorums made operator sets by AddOperatorSet.
+// It also serves the calls of avssync.GasEstimator (see avssync.NewGasEstimator), with a gas usage of
+// TxBaseGas + GasPerOperator per updated operator.
+// Stake updates are mined instantly, each in its own block. It is safe for concurrent use.
+type Chain struct {
+	mu            sync.Mutex
+	quorums       []*quorum
+	operatorSets  map[byte]*operatorSet // by quorum number, see AddOperatorSet
+	operatorIds   map[common.Address]types.OperatorId
+	blockNumber   uint64
+	blockGasLimit uint64
+	baseFee       *big.Int
+	balances      map[common.Address]*big.Int
+	txs           []Tx
+	faults        []Fault
+	readFailures  int
+	readErr       error
+	// used to generate the operators registered by FaultOperatorSetRace
+	raceOperators int
+
+	registryCoordinatorAbi *abi.ABI
+}
+
+var (
+	_ avssync.AvsReader = (*Chain)(nil)
+	_ avssync.AvsWriter = (*Chain)(nil)
+)
+
+func NewChain() *Chain {
+	registryCoordinatorAbi, err := regcoord.ContractRegistryCoordinatorMetaData.GetAbi()
+	if err != nil {
+		panic(err)
+	}
+	return &Chain{
+		operatorSets:           make(map[byte]*operatorSet),
+		operatorIds:            make(map[common.Address]types.OperatorId),
+		blockNumber:            1,
+		blockGasLimit:          DefaultBlockGasLimit,
+		baseFee:                big.NewInt(DefaultBaseFee),
+		balances:               make(map[common.Address]*big.Int),
+		registryCoordinatorAbi: registryCoordinatorAbi,
+	}
+}
+
+// AddQuorum creates the next quorum, and returns its number
+func (c *Chain) AddQuorum(minimumStake *big.Int) byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.quorums = append(c.quorums, &quorum{
+		minimumStake: new(big.Int).Set(minimumStake),
+		operators:    make(map[common.Address]*operatorStake),
+	})
+	return byte(len(c.quorums) - 1)
+}
+
+// RegisterOperator registers the operator in the quorums, with stake both recorded and current
+func (c *Chain) RegisterOperator(operator common.Address, stake *big.Int, quorums ...byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.registerOperator(operator, stake, quorums...)
+}
+
+func (c *Chain) registerOperator(operator common.Address, stake *big.Int, quorums ...byte) {
+	if _, ok := c.operatorIds[operator]; !ok {
+		c.operatorIds[operator] = types.OperatorId(crypto.Keccak256Hash(operator.Bytes()))
+	}
+	for _, quorumNumber := range quorums {
+		c.quorum(quorumNumber).operators[operator] = &operatorStake{
+			recorded: new(big.Int).Set(stake),
+			current:  new(big.Int).Set(stake),
+		}
+	}
+}
+
+// DeregisterOperator removes the operator from the quorums
+func (c *Chain) DeregisterOperator(operator common.Address, quorums ...byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, quorumNumber := range quorums {
+		delete(c.quorum(quorumNumber).operators, operator)
+	}
+}
+
+// SetStake changes the current stake of an operator in a quorum (e.g. after a delegation), which is only
+// recorded by the next update of the operator
+func (c *Chain) SetStake(operator common.Address, quorumNumber byte, stake *big.Int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	operatorStake, ok := c.quorum(quorumNumber).operators[operator]
+	if !ok {
+		panic(fmt.Sprintf("operator %s is not registered in quorum %d", operator.Hex(), quorumNumber))
+	}
+	operatorStake.current = new(big.Int).Set(stake)
+}
+
+// RecordedStake returns the stake of the operator recorded in the quorum, or nil if the operator isn't registered in it
+func (c *Chain) RecordedStake(operator common.Address, quorumNumber byte) *big.Int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	operatorStake, ok := c.quorum(quorumNumber).operators[operator]
+	if !ok {
+		return nil
+	}
+	return new(big.Int).Set(operatorStake.recorded)
+}
+
+// SetBlockGasLimit changes the gas limit of the blocks, which stake updates must fit in
+func (c *Chain) SetBlockGasLimit(gasLimit uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blockGasLimit = gasLimit
+}
+
+// SetBaseFee changes the base fee of the latest block, which the gas price is derived from
+func (c *Chain) SetBaseFee(baseFee *big.Int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.baseFee = new(big.Int).Set(baseFee)
+}
+
+// SetBalance changes the balance of account, e.g. the sender of the stake updates.
+// Balances are not charged for the transactions.
+func (c *Chain) SetBalance(account common.Address, balance *big.Int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.balances[account] = new(big.Int).Set(balance)
+}
+
+// InjectFaults queues faults, each of which is applied to one of the next stake update transactions, in order
+func (c *Chain) InjectFaults(faults ...Fault) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.faults = append(c.faults, faults...)
+}
+
+// FailReads makes the next n reads of the AvsReader fail with err
+func (c *Chain) FailReads(n int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readFailures, c.readErr = n, err
+}
+
+// Txs returns the stake update transactions mined so far, including the reverted ones
+func (c *Chain) Txs() []Tx {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Tx(nil), c.txs...)
+}
+
+func (c *Chain) quorum(quorumNumber byte) *quorum {
+	if int(quorumNumber) >= len(c.quorums) {
+		panic(fmt.Sprintf("quorum %d doesn't exist", quorumNumber))
+	}
+	return c.quorums[quorumNumber]
+}
+
+func (c *Chain) quorumExists(quorumNumber types.QuorumNum) error {
+	if int(quorumNumber) >= len(c.quorums) {
+		return fmt.Errorf("execution reverted: quorum %d does not exist", quorumNumber)
+	}
+	return nil
+}
+
+// sortedOperators returns the operators of the quorum sorted by address, like the RegistryCoordinator expects them
+func (q *quorum) sortedOperators() []common.Address {
+	operators := make([]common.Address, 0, len(q.operators))
+	for operator := range q.operators {
+		operators = append(operators, operator)
+	}
+	sort.Slice(operators, func(i, j int) bool {
+		return operators[i].Big().Cmp(operators[j].Big()) < 0
+	})
+	return operators
+}
+
+// read is called by every AvsReader method, with the lock held
+func (c *Chain) read(opts *bind.CallOpts) error {
+	if opts != nil && opts.Context != nil && opts.Context.Err() != nil {
+		return opts.Context.Err()
+	}
+	if c.readFailures > 0 {
+		c.readFailures--
+		return c.readErr
+	}
+	return nil
+}
+
+func (c *Chain) GetQuorumCount(opts *bind.CallOpts) (uint8, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.read(opts); err != nil {
+		return 0, err
+	}
+	return uint8(len(c.quorums)), nil
+}
+
+func (c *Chain) GetOperatorAddrsInQuorumsAtCurrentBlock(opts *bind.CallOpts, quorumNumbers types.QuorumNums) ([][]common.Address, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.read(opts); err != nil {
+		return nil, err
+	}
+	var operatorsPerQuorum [][]common.Address
+	for _, quorumNumber := range quorumNumbers {
+		if err := c.quorumExists(quorumNumber); err != nil {
+			return nil, err
+		}
+		operatorsPerQuorum = append(operatorsPerQuorum, c.quorums[quorumNumber].sortedOperators())
+	}
+	return operatorsPerQuorum, nil
+}
+
+func (c *Chain) GetOperatorsStakeInQuorumsAtCurrentBlock(opts *bind.CallOpts, quorumNumbers types.QuorumNums) ([][]opstateretriever.OperatorStateRetrieverOperator, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.read(opts); err != nil {
+		return nil, err
+	}
+	var operatorsPerQuorum [][]opstateretriever.OperatorStateRetrieverOperator
+	for _, quorumNumber := range quorumNumbers {
+		if err := c.quorumExists(quorumNumber); err != nil {
+			return nil, err
+		}
+		q := c.quorums[quorumNumber]
+		var operators []opstateretriever.OperatorStateRetrieverOperator
+		for _, operator := range q.sortedOperators() {
+			operators = append(operators, opstateretriever.OperatorStateRetrieverOperator{
+				Operator:   operator,
+				OperatorId: c.operatorIds[operator],
+				Stake:      new(big.Int).Set(q.operators[operator].recorded),
+			})
+		}
+		operatorsPerQuorum = append(operatorsPerQuorum, operators)
+	}
+	return operatorsPerQuorum, nil
+}
+
+func (c *Chain) GetMinimumStakeForQuorum(opts *bind.CallOpts, quorumNumber uint8) (types.StakeAmount, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.read(opts); err != nil {
+		return nil, err
+	}
+	if err := c.quorumExists(types.QuorumNum(quorumNumber)); err != nil {
+		return nil, err
+	}
+	return new(big.Int).Set(c.quorums[quorumNumber].minimumStake), nil
+}
+
+func (c *Chain) WeightOfOperatorForQuorum(opts *bind.CallOpts, quorumNumber uint8, operatorAddr common.Address) (types.StakeAmount, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.read(opts); err != nil {
+		return nil, err
+	}
+	if err := c.quorumExists(types.QuorumNum(quorumNumber)); err != nil {
+		return nil, err
+	}
+	operatorStake, ok := c.quorums[quorumNumber].operators[operatorAddr]
+	if !ok {
+		return big.NewInt(0), nil
+	}
+	return new(big.Int).Set(operatorStake.current), nil
+}
+
+func (c *Chain) GetOperatorId(opts *bind.CallOpts, operatorAddress common.Address) ([32]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.read(opts); err != nil {
+		return [32]byte{}, err
+	}
+	return c.operatorIds[operatorAddress], nil
+}
+
+func (c *Chain) GetOperatorStakeInQuorumsOfOperatorAtCurrentBlock(opts *bind.CallOpts, operatorId types.OperatorId) (map[types.QuorumNum]types.StakeAmount, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.read(opts); err != nil {
+		return nil, err
+	}
+	stakes := make(map[types.QuorumNum]types.StakeAmount)
+	for operator, id := range c.operatorIds {
+		if id != operatorId {
+			continue
+		}
+		for quorumNumber, q := range c.quorums {
+			if operatorStake, ok := q.operators[operator]; ok {
+				stakes[types.QuorumNum(quorumNumber)] = new(big.Int).Set(operatorStake.recorded)
+			}
+		}
+	}
+	return stakes, nil
+}
+
+func (c *Chain) UpdateStakesOfEntireOperatorSetForQuorums(ctx context.Context, operatorsPerQuorum [][]common.Address, quorumNumbers types.QuorumNums, waitForReceipt bool) (*gethtypes.Receipt, error) {
+	var quorums []byte
+	var operators []common.Address
+	for i, quorumNumber := range quorumNumbers {
+		quorums = append(quorums, byte(quorumNumber))
+		if i < len(operatorsPerQuorum) {
+			operators = append(operators, operatorsPerQuorum[i]...)
+		}
+	}
+	data, err := c.registryCoordinatorAbi.Pack("updateOperatorsForQuorum", operatorsPerQuorum, quorums)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(ctx, data, quorums, operators, func() error {
+		return c.updateOperatorsForQuorums(operatorsPerQuorum, quorums)
+	})
+}
+
+func (c *Chain) UpdateStakesOfOperatorSubsetForAllQuorums(ctx context.Context, operators []common.Address, waitForReceipt bool) (*gethtypes.Receipt, error) {
+	data, err := c.registryCoordinatorAbi.Pack("updateOperators", operators)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(ctx, data, nil, operators, func() error {
+		c.updateOperators(operators)
+		return nil
+	})
+}
+
+// send mines a stake update transaction, whose execution is apply (called with the lock held)
+func (c *Chain) send(ctx context.Context, data []byte, quorums []byte, operators []common.Address, apply func() error) (*gethtypes.Receipt, error) {
+	c.mu.Lock()
+	var fault Fault
+	if len(c.faults) > 0 {
+		fault, c.faults = c.faults[0], c.faults[1:]
+	}
+	if fault == FaultTimeout {
+		c.mu.Unlock()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	defer c.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if fault == FaultInsufficientFunds {
+		return nil, errors.New("insufficient funds for gas * price + value")
+	}
+	if fault == FaultSignerError {
+		return nil, fmt.Errorf("%w: connection refused", avssync.ErrRemoteSigner)
+	}
+
+	// the txmgr estimates the gas of the transaction before sending it, which fails if it doesn't fit in a block
+	gasUsed := gasOf(len(operators))
+	gasLimit := uint64(float64(gasUsed) * txmgr.FallbackGasLimitMultiplier)
+	if gasLimit > c.blockGasLimit {
+		return nil, fmt.Errorf("gas required exceeds allowance (%d)", c.blockGasLimit)
+	}
+
+	c.blockNumber++
+	tx := Tx{
+		Hash:        crypto.Keccak256Hash(big.NewInt(int64(len(c.txs))).Bytes(), []byte("avssynctest")),
+		BlockNumber: c.blockNumber,
+		Quorums:     quorums,
+		Operators:   operators,
+		Data:        data,
+		GasLimit:    gasLimit,
+		GasUsed:     gasUsed,
+	}
+	switch fault {
+	case FaultRevert:
+		tx.Reverted = true
+	case FaultOutOfGas:
+		tx.Reverted = true
+		tx.GasUsed = gasLimit
+	case FaultOperatorSetRace:
+		c.raceOperators++
+		operator := common.BigToAddress(big.NewInt(int64(0xface0000 + c.raceOperators)))
+		for _, quorumNumber := range quorums {
+			c.registerOperator(operator, c.quorum(quorumNumber).minimumStake, quorumNumber)
+		}
+	}
+	if !tx.Reverted {
+		tx.Reverted = apply() != nil
+	}
+	c.txs = append(c.txs, tx)
+
+	status := gethtypes.ReceiptStatusSuccessful
+	if tx.Reverted {
+		status = gethtypes.ReceiptStatusFailed
+	}
+	return &gethtypes.Receipt{
+		Status:      status,
+		TxHash:      tx.Hash,
+		BlockNumber: new(big.Int).SetUint64(tx.BlockNumber),
+		GasUsed:     tx.GasUsed,
+		// the base fee plus the suggested tip
+		EffectiveGasPrice: new(big.Int).Add(c.baseFee, big.NewInt(1e9)),
+	}, nil
+}
+
+// updateOperatorsForQuorums checks that operatorsPerQuorum is the entire operator set of each quorum,
+// sorted by address, like RegistryCoordinator.updateOperatorsForQuorum, and updates their stakes
+func (c *Chain) updateOperatorsForQuorums(operatorsPerQuorum [][]common.Address, quorums []byte) error {
+	if err := c.checkOperatorsForQuorums(operatorsPerQuorum, quorums); err != nil {
+		return err
+	}
+	for _, quorumNumber := range quorums {
+		c.updateOperators(c.quorums[quorumNumber].sortedOperators())
+	}
+	return nil
+}
+
+// updateOperators records the current stake of the operators in all their quorums, and deregisters them
+// from the quorums where it is below the minimum stake, like RegistryCoordinator.updateOperators
+func (c *Chain) updateOperators(operators []common.Address) {
+	for _, operator := range operators {
+		for _, q := range c.quorums {
+			operatorStake, ok := q.operators[operator]
+			if !ok {
+				continue
+			}
+			if operatorStake.current.Cmp(q.minimumStake) < 0 {
+				delete(q.operators, operator)
+				continue
+			}
+			operatorStake.recorded = new(big.Int).Set(operatorStake.current)
+		}
+	}
+}
+
+// revertError is a revert of the RegistryCoordinator with one of its custom errors, as returned by eth_call and eth_estimateGas
+type revertError struct {
+	data []byte
+}
+
+func (c *Chain) revert(name string) error {
+	id := c.registryCoordinatorAbi.Errors[name].ID
+	return &revertError{data: id[:4]}
+}
+
+func (e *revertError) Error() string {
+	return "execution reverted: custom error " + hexutil.Encode(e.data)
+}
+
+func (e *revertError) ErrorCode() int {
+	return 3
+}
+
+func (e *revertError) ErrorData() interface{} {
+	return hexutil.Encode(e.data)
+}
+
+func gasOf(operators int) uint64 {
+	return TxBaseGas + GasPerOperator*uint64(operators)
+}
+
+// simulate runs a call to the RegistryCoordinator (without applying it), and returns the gas it uses
+func (c *Chain) simulate(msg ethereum.CallMsg) (uint64, error) {
+	if len(msg.Data) < 4 {
+		return 0, errors.New("execution reverted: no method")
+	}
+	method, err := c.registryCoordinatorAbi.MethodById(msg.Data[:4])
+	if err != nil {
+		return 0, fmt.Errorf("execution reverted: %w", err)
+	}
+	args, err := method.Inputs.Unpack(msg.Data[4:])
+	if err != nil {
+		return 0, fmt.Errorf("execution reverted: %w", err)
+	}
+	var operators int
+	switch method.Name {
+	case "updateOperatorsForQuorum":
+		operatorsPerQuorum := args[0].([][]common.Address)
+		if err := c.checkOperatorsForQuorums(operatorsPerQuorum, args[1].([]byte)); err != nil {
+			return 0, err
+		}
+		for _, quorumOperators := range operatorsPerQuorum {
+			operators += len(quorumOperators)
+		}
+	case "updateOperators":
+		operators = len(args[0].([]common.Address))
+	default:
+		return 0, fmt.Errorf("execution reverted: unsupported method %s", method.Name)
+	}
+	gas := gasOf(operators)
+	if gas > c.blockGasLimit {
+		return 0, fmt.Errorf("gas required exceeds allowance (%d)", c.blockGasLimit)
+	}
+	return gas, nil
+}
+
+// checkOperatorsForQuorums runs the checks of updateOperatorsForQuorums without updating anything
+func (c *Chain) checkOperatorsForQuorums(operatorsPerQuorum [][]common.Address, quorums []byte) error {
+	if len(operatorsPerQuorum) != len(quorums) {
+		return c.revert("InputLengthMismatch")
+	}
+	for i, quorumNumber := range quorums {
+		if int(quorumNumber) >= len(c.quorums) {
+			return c.revert("QuorumDoesNotExist")
+		}
+		q := c.quorums[quorumNumber]
+		expected := q.sortedOperators()
+		if len(expected) != len(operatorsPerQuorum[i]) {
+			return c.revert("QuorumOperatorCountMismatch")
+		}
+		for j, operator := range operatorsPerQuorum[i] {
+			if _, ok := q.operators[operator]; !ok {
+				return c.revert("NotRegisteredForQuorum")
+			}
+			if operator != expected[j] {
+				return c.revert("NotSorted")
+			}
+		}
+	}
+	return nil
+}
+
+// CallContract simulates a stake update, see avssync.GasEstimator
+func (c *Chain) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.simulate(msg)
+	return nil, err
+}
+
+// EstimateGas estimates the gas of a stake update, see avssync.GasEstimator
+func (c *Chain) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.simulate(msg)
+}
+
+func (c *Chain) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(1e9), nil
+}
+
+func (c *Chain) HeaderByNumber(ctx context.Context, number *big.Int) (*gethtypes.Header, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return &gethtypes.Header{
+		Number:   new(big.Int).SetUint64(c.blockNumber),
+		GasLimit: c.blockGasLimit,
+		BaseFee:  new(big.Int).Set(c.baseFee),
+	}, nil
+}
+
+func (c *Chain) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if balance, ok := c.balances[account]; ok {
+		return new(big.Int).Set(balance), nil
+	}
+	return new(big.Int).Mul(big.NewInt(DefaultBalance), big.NewInt(params.Ether)), nil
+}
+
+func (c *Chain) TransactionByHash(ctx context.Context, hash common.Hash) (*gethtypes.Transaction, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, tx := range c.txs {
+		if tx.Hash == hash {
+			return gethtypes.NewTx(&gethtypes.DynamicFeeTx{To: &RegistryCoordinatorAddr, Gas: tx.GasLimit, Data: tx.Data}), false, nil
+		}
+	}
+	return nil, false, ethereum.NotFound
+}
@@ -0,0 +1,406 @@
+package avssynctest_test
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Layr-Labs/avs-sync/avssync"
+	"github.com/Layr-Labs/avs-sync/avssynctest"
+	"github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	minimumStake = big.NewInt(100)
+	operator1    = common.HexToAddress("0x1")
+	operator2    = common.HexToAddress("0x2")
+	operator3    = common.HexToAddress("0x3")
+)
+
+// newChain returns a chain with one quorum of 3 operators, whose stakes changed since their last update
+func newChain() *avssynctest.Chain {
+	chain := avssynctest.NewChain()
+	quorum := chain.AddQuorum(minimumStake)
+	for _, operator := range []common.Address{operator3, operator1, operator2} {
+		chain.RegisterOperator(operator, big.NewInt(1000), quorum)
+	}
+	chain.SetStake(operator1, quorum, big.NewInt(2000))
+	chain.SetStake(operator2, quorum, big.NewInt(50))
+	return chain
+}
+
+// runSync runs a single sync of quorum 0, and returns the error of Start (i.e. whether the sync failed)
+func runSync(t *testing.T, chain *avssynctest.Chain, config avssync.Config, gasEstimator *avssync.GasEstimator) (*avssync.AvsSync, error) {
+	return runSyncWithRegistry(t, chain, config, gasEstimator, prometheus.NewRegistry())
+}
+
+func runSyncWithRegistry(t *testing.T, chain *avssynctest.Chain, config avssync.Config, gasEstimator *avssync.GasEstimator, reg *prometheus.Registry) (*avssync.AvsSync, error) {
+	avsSync := newAvsSync(t, chain, config, gasEstimator, reg)
+	return avsSync, avsSync.Start(context.Background())
+}
+
+// newAvsSync returns an AvsSync running a single sync of quorum 0 when started
+func newAvsSync(t *testing.T, chain *avssynctest.Chain, config avssync.Config, gasEstimator *avssync.GasEstimator, reg *prometheus.Registry) *avssync.AvsSync {
+	config.Quorums = []int{0}
+	config.RetrySyncNTimes = max(config.RetrySyncNTimes, 1)
+	config.ReaderTimeout = time.Second
+	if config.WriterTimeout == 0 {
+		config.WriterTimeout = time.Second
+	}
+	config.ShutdownGracePeriod = time.Second
+	logger := logging.NewTextSLogger(os.Stderr, &logging.SLoggerOptions{})
+	avsSync, err := avssync.NewAvsSync(logger, config, chain, chain, nil, gasEstimator, reg)
+	require.NoError(t, err)
+	return avsSync
+}
+
+func requireStakesUpdated(t *testing.T, chain *avssynctest.Chain) {
+	require.Equal(t, big.NewInt(2000), chain.RecordedStake(operator1, 0))
+	// below the minimum stake, so it was kicked
+	require.Nil(t, chain.RecordedStake(operator2, 0))
+	require.Equal(t, big.NewInt(1000), chain.RecordedStake(operator3, 0))
+}
+
+func TestSync(t *testing.T) {
+	chain := newChain()
+	avsSync, err := runSync(t, chain, avssync.Config{}, nil)
+	require.NoError(t, err)
+	requireStakesUpdated(t, chain)
+
+	txs := chain.Txs()
+	require.Len(t, txs, 1)
+	require.Equal(t, []byte{0}, txs[0].Quorums)
+	require.Equal(t, []common.Address{operator1, operator2, operator3}, txs[0].Operators)
+	quorumSync := avsSync.Status().QuorumSyncs["0"]
+	require.Equal(t, avssync.UpdateStakeStatusSucceed, quorumSync.LastSyncStatus)
+	require.Equal(t, txs[0].Hash, quorumSync.LastTxHash)
+	require.Equal(t, txs[0].BlockNumber, quorumSync.LastSuccessBlock)
+}
+
+func TestSyncRetries(t *testing.T) {
+	tests := []struct {
+		name   string
+		faults []avssynctest.Fault
+		// wrong entire operator set updates revert, the race registers a 4th operator
+		expectedOperators int
+	}{
+		{name: "revert", faults: []avssynctest.Fault{avssynctest.FaultRevert}, expectedOperators: 3},
+		{name: "operator set race", faults: []avssynctest.Fault{avssynctest.FaultOperatorSetRace}, expectedOperators: 4},
+		{name: "timeout", faults: []avssynctest.Fault{avssynctest.FaultTimeout}, expectedOperators: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := newChain()
+			chain.InjectFaults(tt.faults...)
+			_, err := runSync(t, chain, avssync.Config{RetrySyncNTimes: 2, WriterTimeout: 100 * time.Millisecond}, nil)
+			require.NoError(t, err)
+			requireStakesUpdated(t, chain)
+
+			txs := chain.Txs()
+			require.False(t, txs[len(txs)-1].Reverted)
+			require.Len(t, txs[len(txs)-1].Operators, tt.expectedOperators)
+		})
+	}
+
+	t.Run("gives up", func(t *testing.T) {
+		chain := newChain()
+		chain.InjectFaults(avssynctest.FaultRevert, avssynctest.FaultRevert)
+		avsSync, err := runSync(t, chain, avssync.Config{RetrySyncNTimes: 2}, nil)
+		require.ErrorIs(t, err, avssync.ErrLastSyncFailed)
+		require.Equal(t, big.NewInt(1000), chain.RecordedStake(operator1, 0))
+		require.Len(t, chain.Txs(), 2)
+		require.Equal(t, avssync.UpdateStakeStatusError, avsSync.Status().QuorumSyncs["0"].LastSyncStatus)
+	})
+}
+
+func TestSyncOperatorSubsetRetries(t *testing.T) {
+	chain := newChain()
+	chain.InjectFaults(avssynctest.FaultRevert, avssynctest.FaultTimeout)
+	_, err := runSync(t, chain, avssync.Config{Operators: []common.Address{operator1}, RetrySyncNTimes: 3, WriterTimeout: 100 * time.Millisecond}, nil)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(2000), chain.RecordedStake(operator1, 0))
+	// the stake of the other operators wasn't updated
+	require.Equal(t, big.NewInt(1000), chain.RecordedStake(operator2, 0))
+
+	txs := chain.Txs()
+	require.Len(t, txs, 2)
+	require.True(t, txs[0].Reverted)
+	require.False(t, txs[1].Reverted)
+}
+
+func TestSyncFatalErrorFailsFast(t *testing.T) {
+	for _, config := range []avssync.Config{{}, {Operators: []common.Address{operator1}}} {
+		chain := newChain()
+		chain.InjectFaults(avssynctest.FaultInsufficientFunds)
+		config.RetrySyncNTimes = 3
+		avsSync, err := runSync(t, chain, config, nil)
+		require.ErrorIs(t, err, avssync.ErrLastSyncFailed)
+		require.Empty(t, chain.Txs())
+		require.Equal(t, avssync.UpdateStakeStatusFatal, avsSync.Status().QuorumSyncs["0"].LastSyncStatus)
+	}
+}
+
+func TestSyncRemoteSignerError(t *testing.T) {
+	chain := newChain()
+	chain.InjectFaults(avssynctest.FaultSignerError, avssynctest.FaultSignerError)
+	avsSync, err := runSync(t, chain, avssync.Config{RetrySyncNTimes: 2}, nil)
+	require.ErrorIs(t, err, avssync.ErrLastSyncFailed)
+	require.Empty(t, chain.Txs())
+	require.Equal(t, avssync.UpdateStakeStatusSignerError, avsSync.Status().QuorumSyncs["0"].LastSyncStatus)
+}
+
+func newGasEstimator(t *testing.T, chain *avssynctest.Chain) *avssync.GasEstimator {
+	gasEstimator, err := avssync.NewGasEstimator(chain, avssynctest.RegistryCoordinatorAddr, common.HexToAddress("0x5e"))
+	require.NoError(t, err)
+	return gasEstimator
+}
+
+func TestSyncRevertReasons(t *testing.T) {
+	tests := []struct {
+		fault avssynctest.Fault
+		label string
+	}{
+		{fault: avssynctest.FaultOperatorSetRace, label: "QuorumOperatorCountMismatch"},
+		{fault: avssynctest.FaultOutOfGas, label: avssync.RevertLabelOutOfGas},
+		// the replay of the transaction doesn't revert
+		{fault: avssynctest.FaultRevert, label: avssync.RevertLabelUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			chain := newChain()
+			chain.InjectFaults(tt.fault)
+			reg := prometheus.NewRegistry()
+			_, err := runSyncWithRegistry(t, chain, avssync.Config{RetrySyncNTimes: 2}, newGasEstimator(t, chain), reg)
+			require.NoError(t, err)
+			require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+# HELP avssync_tx_reverted_total The total number of transactions that made it onchain but reverted, by revert reason (out_of_gas, custom error name, revert_string or unknown, the full reason is logged)
+# TYPE avssync_tx_reverted_total counter
+avssync_tx_reverted_total{avs="",reason=%q} 1
+`, tt.label)), "avssync_tx_reverted_total"))
+		})
+	}
+}
+
+func TestSyncInChunks(t *testing.T) {
+	t.Run("doesn't fit in a block", func(t *testing.T) {
+		chain := newChain()
+		// fits 2 operators per transaction, but not 3
+		chain.SetBlockGasLimit(avssynctest.TxBaseGas + 3*avssynctest.GasPerOperator)
+		_, err := runSync(t, chain, avssync.Config{MaxOperatorsPerTx: 2}, newGasEstimator(t, chain))
+		require.NoError(t, err)
+		requireStakesUpdated(t, chain)
+
+		txs := chain.Txs()
+		require.Len(t, txs, 2)
+		require.Nil(t, txs[0].Quorums)
+		require.Equal(t, []common.Address{operator1, operator2}, txs[0].Operators)
+		require.Equal(t, []common.Address{operator3}, txs[1].Operators)
+	})
+
+	t.Run("out of gas", func(t *testing.T) {
+		chain := newChain()
+		chain.InjectFaults(avssynctest.FaultOutOfGas)
+		_, err := runSync(t, chain, avssync.Config{MaxOperatorsPerTx: 2}, newGasEstimator(t, chain))
+		require.NoError(t, err)
+		requireStakesUpdated(t, chain)
+
+		txs := chain.Txs()
+		require.Len(t, txs, 3)
+		require.True(t, txs[0].Reverted)
+		require.Equal(t, txs[0].GasLimit, txs[0].GasUsed)
+		require.Len(t, txs[1].Operators, 2)
+		require.Len(t, txs[2].Operators, 1)
+	})
+}
+
+func TestSyncSkipsBelowStakeDrift(t *testing.T) {
+	chain := avssynctest.NewChain()
+	chain.AddQuorum(minimumStake)
+	chain.RegisterOperator(operator1, big.NewInt(1000), 0)
+	chain.SetStake(operator1, 0, big.NewInt(1001))
+
+	config := avssync.Config{OnlyUpdateOnStakeDrift: true, StakeDriftThresholdAbs: "10", StakeDriftThresholdPct: 1}
+	avsSync, err := runSync(t, chain, config, nil)
+	require.NoError(t, err)
+	require.Empty(t, chain.Txs())
+	require.Equal(t, avssync.UpdateStakeStatusSkipped, avsSync.Status().QuorumSyncs["0"].LastSyncStatus)
+
+	chain.SetStake(operator1, 0, big.NewInt(2000))
+	_, err = runSync(t, chain, config, nil)
+	require.NoError(t, err)
+	require.Len(t, chain.Txs(), 1)
+	require.Equal(t, big.NewInt(2000), chain.RecordedStake(operator1, 0))
+}
+
+func TestSyncDefersDuringGasSpikes(t *testing.T) {
+	// the gas price is the base fee plus the 1 gwei tip
+	config := avssync.Config{
+		MaxFeePerGasGwei:        10,
+		GasPriceDeferWindow:     100 * time.Millisecond,
+		GasPriceRecheckInterval: 10 * time.Millisecond,
+		GasPriceDeferAction:     avssync.GasPriceDeferActionSkip,
+	}
+	spike := big.NewInt(50e9)
+
+	t.Run("skipped", func(t *testing.T) {
+		chain := newChain()
+		chain.SetBaseFee(spike)
+		avsSync, err := runSync(t, chain, config, newGasEstimator(t, chain))
+		require.NoError(t, err)
+		require.Empty(t, chain.Txs())
+		quorumSync := avsSync.Status().QuorumSyncs["0"]
+		require.Equal(t, avssync.UpdateStakeStatusDeferred, quorumSync.LastSyncStatus)
+		require.True(t, quorumSync.LastSuccessTime.IsZero())
+	})
+
+	t.Run("sent at cap", func(t *testing.T) {
+		chain := newChain()
+		chain.SetBaseFee(spike)
+		config := config
+		config.GasPriceDeferAction = avssync.GasPriceDeferActionSendAtCap
+		avsSync, err := runSync(t, chain, config, newGasEstimator(t, chain))
+		require.NoError(t, err)
+		requireStakesUpdated(t, chain)
+		require.Equal(t, avssync.UpdateStakeStatusSucceed, avsSync.Status().QuorumSyncs["0"].LastSyncStatus)
+	})
+
+	t.Run("gas price comes down within the window", func(t *testing.T) {
+		chain := newChain()
+		chain.SetBaseFee(spike)
+		config := config
+		config.GasPriceDeferWindow = 10 * time.Second
+		time.AfterFunc(30*time.Millisecond, func() { chain.SetBaseFee(big.NewInt(avssynctest.DefaultBaseFee)) })
+		start := time.Now()
+		_, err := runSync(t, chain, config, newGasEstimator(t, chain))
+		require.NoError(t, err)
+		require.Less(t, time.Since(start), 5*time.Second)
+		requireStakesUpdated(t, chain)
+	})
+}
+
+func TestSyncGasBudget(t *testing.T) {
+	// each sync updates 3 operators at 2 gwei
+	syncCost := 2e9 * float64(avssynctest.TxBaseGas+3*avssynctest.GasPerOperator) / 1e18
+	config := avssync.Config{GasBudgetEth: syncCost / 2, GasBudgetPeriod: time.Hour}
+	stateFile := filepath.Join(t.TempDir(), "state.db")
+	runSyncWithState := func(chain *avssynctest.Chain, reg *prometheus.Registry) (*avssync.AvsSync, error) {
+		avsSync := newAvsSync(t, chain, config, nil, reg)
+		store, err := avssync.OpenStateStore(stateFile)
+		require.NoError(t, err)
+		require.NoError(t, avsSync.SetStateStore(store))
+		defer avsSync.Close()
+		return avsSync, avsSync.Start(context.Background())
+	}
+
+	// the budget isn't spent yet
+	chain := newChain()
+	reg := prometheus.NewRegistry()
+	_, err := runSyncWithState(chain, reg)
+	require.NoError(t, err)
+	requireStakesUpdated(t, chain)
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+# HELP avssync_gas_budget_exceeded 1 if the gas budget of the period is spent, in which case no stake update is sent, 0 otherwise
+# TYPE avssync_gas_budget_exceeded gauge
+avssync_gas_budget_exceeded{avs=""} 1
+# HELP avssync_gas_spent_eth_total Cumulative cost (gas used times effective gas price) of the stake update transactions, by quorum (empty for operator subset updates of all quorums) and call type (entire_operator_set or operator_subset)
+# TYPE avssync_gas_spent_eth_total counter
+avssync_gas_spent_eth_total{avs="",call_type="entire_operator_set",quorum="0"} %g
+`, syncCost)), "avssync_gas_budget_exceeded", "avssync_gas_spent_eth_total"))
+
+	// it is after a restart, so nothing is sent
+	chain = newChain()
+	reg = prometheus.NewRegistry()
+	avsSync, err := runSyncWithState(chain, reg)
+	require.Error(t, err)
+	require.Empty(t, chain.Txs())
+	require.Equal(t, avssync.UpdateStakeStatusFatal, avsSync.Status().QuorumSyncs["0"].LastSyncStatus)
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+# HELP avssync_gas_spent_eth_total Cumulative cost (gas used times effective gas price) of the stake update transactions, by quorum (empty for operator subset updates of all quorums) and call type (entire_operator_set or operator_subset)
+# TYPE avssync_gas_spent_eth_total counter
+avssync_gas_spent_eth_total{avs="",call_type="entire_operator_set",quorum="0"} %g
+`, syncCost)), "avssync_gas_spent_eth_total"))
+}
+
+func TestSyncSenderBalance(t *testing.T) {
+	// each sync updates 3 operators at 2 gwei
+	syncCost := big.NewInt(2e9 * (avssynctest.TxBaseGas + 3*avssynctest.GasPerOperator))
+	config := avssync.Config{LowBalanceThresholdEth: 1}
+
+	t.Run("insufficient funds", func(t *testing.T) {
+		chain := newChain()
+		balance := new(big.Int).Sub(syncCost, big.NewInt(1))
+		chain.SetBalance(common.HexToAddress("0x5e"), balance)
+		reg := prometheus.NewRegistry()
+		avsSync, err := runSyncWithRegistry(t, chain, config, newGasEstimator(t, chain), reg)
+		require.Error(t, err)
+		require.Empty(t, chain.Txs())
+		require.Equal(t, avssync.UpdateStakeStatusInsufficientFunds, avsSync.Status().QuorumSyncs["0"].LastSyncStatus)
+		require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(fmt.Sprintf(`
+# HELP avssync_sender_balance_wei Balance of the address sending the stake update transactions (as of the last sync)
+# TYPE avssync_sender_balance_wei gauge
+avssync_sender_balance_wei{avs=""} %s
+# HELP avssync_sender_low_balance_threshold_wei Balance under which the sender should be topped up (low-balance-threshold-eth), to alert on along with sender_balance_wei
+# TYPE avssync_sender_low_balance_threshold_wei gauge
+avssync_sender_low_balance_threshold_wei{avs=""} 1e+18
+`, balance)), "avssync_sender_balance_wei", "avssync_sender_low_balance_threshold_wei"))
+	})
+
+	t.Run("enough funds", func(t *testing.T) {
+		chain := newChain()
+		chain.SetBalance(common.HexToAddress("0x5e"), syncCost)
+		avsSync, err := runSync(t, chain, config, newGasEstimator(t, chain))
+		require.NoError(t, err)
+		requireStakesUpdated(t, chain)
+		require.Equal(t, avssync.UpdateStakeStatusSucceed, avsSync.Status().QuorumSyncs["0"].LastSyncStatus)
+	})
+
+	t.Run("quorums fetched dynamically", func(t *testing.T) {
+		chain := newChain()
+		// only known after refetching the quorum set, enough for quorum 0 but not for both
+		quorum := chain.AddQuorum(minimumStake)
+		chain.RegisterOperator(common.HexToAddress("0x4"), big.NewInt(1000), quorum)
+		chain.SetBalance(common.HexToAddress("0x5e"), syncCost)
+		avsSync, err := runSync(t, chain, avssync.Config{FetchQuorumsDynamically: true}, newGasEstimator(t, chain))
+		require.Error(t, err)
+		require.Empty(t, chain.Txs())
+		require.Equal(t, avssync.UpdateStakeStatusInsufficientFunds, avsSync.Status().QuorumSyncs["1"].LastSyncStatus)
+	})
+
+	t.Run("operator subset", func(t *testing.T) {
+		chain := newChain()
+		// one wei short of the update of the single operator
+		chain.SetBalance(common.HexToAddress("0x5e"), big.NewInt(2e9*(avssynctest.TxBaseGas+avssynctest.GasPerOperator)-1))
+		_, err := runSync(t, chain, avssync.Config{Operators: []common.Address{operator1}}, newGasEstimator(t, chain))
+		require.Error(t, err)
+		require.Empty(t, chain.Txs())
+	})
+}
+
+func TestChainReads(t *testing.T) {
+	chain := newChain()
+	chain.DeregisterOperator(operator2, 0)
+
+	chain.FailReads(1, context.DeadlineExceeded)
+	_, err := chain.GetQuorumCount(nil)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	count, err := chain.GetQuorumCount(nil)
+	require.NoError(t, err)
+	require.Equal(t, uint8(1), count)
+
+	// operator ids are kept after deregistration, like in the BLSApkRegistry
+	id, err := chain.GetOperatorId(nil, operator2)
+	require.NoError(t, err)
+	require.NotEqual(t, [32]byte{}, id)
+	stakes, err := chain.GetOperatorStakeInQuorumsOfOperatorAtCurrentBlock(nil, id)
+	require.NoError(t, err)
+	require.Empty(t, stakes)
+}
@@ -0,0 +1,201 @@
+package avssynctest_test
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Layr-Labs/avs-sync/avssync"
+	"github.com/Layr-Labs/avs-sync/avssynctest"
+	"github.com/Layr-Labs/eigensdk-go/chainio/clients/eth"
+	delegationmanager "github.com/Layr-Labs/eigensdk-go/contracts/bindings/DelegationManager"
+	"github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+var delegationManagerAddr = common.HexToAddress("0xd0")
+
+// logBackend serves the logs polled by the event watcher, the other calls of eth.HttpBackend panic
+type logBackend struct {
+	eth.HttpBackend
+
+	mu   sync.Mutex
+	head uint64
+	logs []gethtypes.Log
+}
+
+func (b *logBackend) BlockNumber(ctx context.Context) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.head, nil
+}
+
+func (b *logBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]gethtypes.Log, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var logs []gethtypes.Log
+	for _, log := range b.logs {
+		if log.BlockNumber >= query.FromBlock.Uint64() && log.BlockNumber <= query.ToBlock.Uint64() {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+// sharesIncreased mines a block with an OperatorSharesIncreased event of the operator, and returns its number
+func (b *logBackend) sharesIncreased(t *testing.T, operator common.Address) uint64 {
+	parsed, err := delegationmanager.ContractDelegationManagerMetaData.GetAbi()
+	require.NoError(t, err)
+	event := parsed.Events["OperatorSharesIncreased"]
+	data, err := event.Inputs.NonIndexed().Pack(operator, common.Address{}, big.NewInt(1))
+	require.NoError(t, err)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.head++
+	b.logs = append(b.logs, gethtypes.Log{
+		Address:     delegationManagerAddr,
+		Topics:      []common.Hash{event.ID, common.BytesToHash(operator.Bytes())},
+		Data:        data,
+		BlockNumber: b.head,
+	})
+	return b.head
+}
+
+func readEventStateFile(t *testing.T, stateFile string) uint64 {
+	data, err := os.ReadFile(stateFile)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	require.NoError(t, err)
+	block, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	require.NoError(t, err)
+	return block
+}
+
+// newEventDrivenSync returns an AvsSync of quorum 0 syncing on the events of the returned log backend, whose last
+// synced event block is persisted in the returned file. The scheduled sync runs when started, the next one is only due
+// in an hour so the others are triggered by events.
+func newEventDrivenSync(t *testing.T, chain *avssynctest.Chain) (*avssync.AvsSync, *logBackend, string) {
+	stateFile := filepath.Join(t.TempDir(), "events")
+	logger := logging.NewTextSLogger(os.Stderr, &logging.SLoggerOptions{})
+	logs := &logBackend{}
+	eventWatcher, err := avssync.NewEventWatcher(logger, logs, chain, avssync.EventWatcherConfig{
+		DelegationManagerAddr: delegationManagerAddr,
+		PollInterval:          10 * time.Millisecond,
+		StartBlock:            1,
+		StateFilePath:         stateFile,
+	}, prometheus.NewRegistry())
+	require.NoError(t, err)
+	avsSync, err := avssync.NewAvsSync(logger, avssync.Config{
+		Quorums:                 []int{0},
+		SyncInterval:            time.Hour,
+		RetrySyncNTimes:         1,
+		ReaderTimeout:           time.Second,
+		WriterTimeout:           time.Second,
+		ShutdownGracePeriod:     time.Second,
+		MaxFeePerGasGwei:        10,
+		GasPriceDeferWindow:     50 * time.Millisecond,
+		GasPriceRecheckInterval: 10 * time.Millisecond,
+		GasPriceDeferAction:     avssync.GasPriceDeferActionSkip,
+	}, chain, chain, eventWatcher, newGasEstimator(t, chain), prometheus.NewRegistry())
+	require.NoError(t, err)
+	return avsSync, logs, stateFile
+}
+
+// startAvsSync runs avsSync until the end of the test
+func startAvsSync(t *testing.T, avsSync *avssync.AvsSync) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- avsSync.Start(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+func TestSyncOnEvents(t *testing.T) {
+	tests := []struct {
+		name   string
+		faults []avssynctest.Fault
+		// the gas price spikes when the event is emitted, and comes down a bit later
+		gasSpike    bool
+		expectedTxs int
+	}{
+		{name: "success", expectedTxs: 2},
+		// the reverted update is mined too
+		{name: "retriggered after failed update", faults: []avssynctest.Fault{avssynctest.FaultRevert}, expectedTxs: 3},
+		{name: "retriggered after insufficient funds", faults: []avssynctest.Fault{avssynctest.FaultInsufficientFunds}, expectedTxs: 2},
+		{name: "retriggered after deferral", gasSpike: true, expectedTxs: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := newChain()
+			avsSync, logs, stateFile := newEventDrivenSync(t, chain)
+			startAvsSync(t, avsSync)
+			require.Eventually(t, func() bool { return len(chain.Txs()) == 1 }, 5*time.Second, 10*time.Millisecond)
+			requireStakesUpdated(t, chain)
+
+			chain.InjectFaults(tt.faults...)
+			if tt.gasSpike {
+				chain.SetBaseFee(big.NewInt(50e9))
+				time.AfterFunc(300*time.Millisecond, func() { chain.SetBaseFee(big.NewInt(avssynctest.DefaultBaseFee)) })
+			}
+			chain.SetStake(operator1, 0, big.NewInt(3000))
+			eventBlock := logs.sharesIncreased(t, operator1)
+
+			// a sync that didn't update the quorum doesn't mark the event as synced, and is retried until one does
+			require.Eventually(t, func() bool { return readEventStateFile(t, stateFile) >= eventBlock }, 5*time.Second, 10*time.Millisecond)
+			require.Equal(t, big.NewInt(3000), chain.RecordedStake(operator1, 0))
+			require.Len(t, chain.Txs(), tt.expectedTxs)
+			require.Equal(t, avssync.UpdateStakeStatusSucceed, avsSync.Status().QuorumSyncs["0"].LastSyncStatus)
+		})
+	}
+}
+
+// leaderLock is held by this replica once acquired is set
+type leaderLock struct {
+	acquired atomic.Bool
+}
+
+func (l *leaderLock) TryAcquireOrRenew(ctx context.Context) (bool, error) {
+	return l.acquired.Load(), nil
+}
+
+func (l *leaderLock) Release(ctx context.Context) error {
+	return nil
+}
+
+func TestSyncOnEventsAsFollower(t *testing.T) {
+	chain := newChain()
+	avsSync, logs, stateFile := newEventDrivenSync(t, chain)
+	lock := &leaderLock{}
+	logger := logging.NewTextSLogger(os.Stderr, &logging.SLoggerOptions{})
+	avsSync.SetLeaderElector(avssync.NewLeaderElector(logger, lock, avssync.LeaderElectionConfig{
+		RetryPeriod:   10 * time.Millisecond,
+		RenewDeadline: time.Second,
+	}, prometheus.NewRegistry()))
+	startAvsSync(t, avsSync)
+
+	// the event is left to the leader, neither synced nor marked as synced
+	eventBlock := logs.sharesIncreased(t, operator1)
+	time.Sleep(200 * time.Millisecond)
+	require.Empty(t, chain.Txs())
+	require.Less(t, readEventStateFile(t, stateFile), eventBlock)
+
+	// and synced once this replica becomes the leader
+	lock.acquired.Store(true)
+	require.Eventually(t, func() bool { return readEventStateFile(t, stateFile) >= eventBlock }, 5*time.Second, 10*time.Millisecond)
+	requireStakesUpdated(t, chain)
+	require.Len(t, chain.Txs(), 1)
+}
@@ -0,0 +1,154 @@
+package avssynctest_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Layr-Labs/avs-sync/avssync"
+	"github.com/Layr-Labs/avs-sync/avssynctest"
+	"github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/Layr-Labs/eigensdk-go/types"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+// inFlightCounter records how many transactions of the writers sharing it are in flight at the same time
+type inFlightCounter struct {
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+type countingWriter struct {
+	avssync.AvsWriter
+	counter *inFlightCounter
+}
+
+func (w *countingWriter) UpdateStakesOfEntireOperatorSetForQuorums(ctx context.Context, operatorsPerQuorum [][]common.Address, quorumNumbers types.QuorumNums, waitForReceipt bool) (*gethtypes.Receipt, error) {
+	w.counter.mu.Lock()
+	w.counter.inFlight++
+	w.counter.maxInFlight = max(w.counter.maxInFlight, w.counter.inFlight)
+	w.counter.mu.Unlock()
+	defer func() {
+		w.counter.mu.Lock()
+		w.counter.inFlight--
+		w.counter.mu.Unlock()
+	}()
+	// waiting for the receipt
+	time.Sleep(50 * time.Millisecond)
+	return w.AvsWriter.UpdateStakesOfEntireOperatorSetForQuorums(ctx, operatorsPerQuorum, quorumNumbers, waitForReceipt)
+}
+
+// newTarget returns the AvsSync of the target avs, running a single sync of quorum 0 of chain when started
+func newTarget(t *testing.T, avs string, chain *avssynctest.Chain, writer avssync.AvsWriter, reg *prometheus.Registry) *avssync.AvsSync {
+	config := avssync.Config{
+		AvsName:             avs,
+		Quorums:             []int{0},
+		RetrySyncNTimes:     1,
+		ReaderTimeout:       time.Second,
+		WriterTimeout:       time.Second,
+		ShutdownGracePeriod: time.Second,
+	}
+	logger := logging.NewTextSLogger(os.Stderr, &logging.SLoggerOptions{}).With("avs", avs)
+	avsSync, err := avssync.NewAvsSync(logger, config, chain, writer, nil, nil, reg)
+	require.NoError(t, err)
+	return avsSync
+}
+
+func TestMultiAvsSync(t *testing.T) {
+	logger := logging.NewTextSLogger(os.Stderr, &logging.SLoggerOptions{})
+	reg := prometheus.NewRegistry()
+	counter := &inFlightCounter{}
+	senderLock := avssync.NewSenderLock()
+
+	chains := map[string]*avssynctest.Chain{}
+	var avsSyncs []*avssync.AvsSync
+	for _, avs := range []string{"a", "b", "failing"} {
+		chains[avs] = newChain()
+		writer := senderLock.Writer(&countingWriter{AvsWriter: chains[avs], counter: counter})
+		avsSyncs = append(avsSyncs, newTarget(t, avs, chains[avs], writer, reg))
+	}
+	chains["failing"].FailReads(10, errors.New("rpc down"))
+
+	multiAvsSync := avssync.NewMultiAvsSync(logger, avsSyncs, reg, "")
+	multiAvsSync.AddFailedTarget("broken", errors.New("cannot read contracts"))
+	err := multiAvsSync.Start(context.Background())
+	require.ErrorContains(t, err, "avs failing: ")
+	require.NotContains(t, err.Error(), "avs a: ")
+	require.NotContains(t, err.Error(), "avs b: ")
+
+	// the failing target didn't stop the others
+	requireStakesUpdated(t, chains["a"])
+	requireStakesUpdated(t, chains["b"])
+	// the targets share a sender, so their transactions were sent one at a time
+	require.Equal(t, 1, counter.maxInFlight)
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP avssync_update_stake_attempt Result from an update stake attempt. Either succeed, skipped, error (either tx was mined but reverted, or failed to get processed by chain) fatal (not retried, e.g. insufficient funds) deferred_due_to_gas (gas price above max-fee-per-gas) or insufficient_funds (sender balance below the estimated cost of the sync).
+# TYPE avssync_update_stake_attempt counter
+avssync_update_stake_attempt{avs="a",quorum="0",status="succeed"} 1
+avssync_update_stake_attempt{avs="b",quorum="0",status="succeed"} 1
+avssync_update_stake_attempt{avs="failing",quorum="0",status="error"} 1
+`), "avssync_update_stake_attempt"))
+
+	t.Run("health checks", func(t *testing.T) {
+		server := httptest.NewServer(multiAvsSync.MetricsHandler())
+		defer server.Close()
+		resp, err := http.Get(server.URL + "/readyz")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		var report avssync.HealthReport
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+		require.Equal(t, "ok", report.Checks["a/quorum_syncs"].Status)
+		require.Equal(t, "fail", report.Checks["broken/startup"].Status)
+		require.Contains(t, report.Checks["broken/startup"].Error, "cannot read contracts")
+	})
+}
+
+func TestMultiAvsSyncAdmin(t *testing.T) {
+	logger := logging.NewTextSLogger(os.Stderr, &logging.SLoggerOptions{})
+	reg := prometheus.NewRegistry()
+	multiAvsSync := avssync.NewMultiAvsSync(logger, nil, reg, "")
+	for _, avs := range []string{"a", "b"} {
+		chain := newChain()
+		avsSync := newTarget(t, avs, chain, chain, reg)
+		multiAvsSync.AddAdminServer(avs, avssync.NewAdminServer(logger, avsSync, chain, common.HexToAddress("0x5e"), "token"))
+	}
+	server := httptest.NewServer(multiAvsSync.AdminHandler())
+	defer server.Close()
+
+	request := func(method string, path string) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer token")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	resp := request("GET", "/targets")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var names []string
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&names))
+	require.Equal(t, []string{"a", "b"}, names)
+
+	require.Equal(t, http.StatusOK, request("POST", "/targets/a/pause").StatusCode)
+	var status avssync.AdminStatus
+	require.NoError(t, json.NewDecoder(request("GET", "/targets/a/status").Body).Decode(&status))
+	require.True(t, status.Paused)
+	require.NoError(t, json.NewDecoder(request("GET", "/targets/b/status").Body).Decode(&status))
+	require.False(t, status.Paused)
+	require.Equal(t, http.StatusNotFound, request("GET", "/targets/c/status").StatusCode)
+}
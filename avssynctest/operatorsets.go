@@ -0,0 +1,155 @@
+package avssynctest
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/Layr-Labs/avs-sync/avssync"
+	allocationmanager "github.com/Layr-Labs/eigensdk-go/contracts/bindings/AllocationManager"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// ServiceManagerAddr is the AVS the operator sets added by AddOperatorSet belong to
+var ServiceManagerAddr = common.HexToAddress("0x5a")
+
+var _ avssync.OperatorSetReader = (*Chain)(nil)
+
+// Allocation is the allocation of an operator to an operator set, for a strategy
+type Allocation struct {
+	// stake (in shares) currently allocated
+	Stake     *big.Int
+	Magnitude uint64
+	// change of the magnitude taking effect at EffectBlock, negative for a deallocation, nil if there is none
+	PendingDiff *big.Int
+	EffectBlock uint32
+}
+
+type operatorSet struct {
+	strategies []common.Address
+	// by operator, then strategy
+	allocations map[common.Address]map[common.Address]Allocation
+}
+
+// AddOperatorSet makes the quorum an operator set of ServiceManagerAddr, weighing strategies, like the RegistryCoordinator
+// of slashing enabled deployments does. Its members are the operators registered in the quorum.
+func (c *Chain) AddOperatorSet(quorumNumber byte, strategies ...common.Address) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.quorum(quorumNumber)
+	c.operatorSets[quorumNumber] = &operatorSet{
+		strategies:  strategies,
+		allocations: make(map[common.Address]map[common.Address]Allocation),
+	}
+}
+
+// SetAllocation sets the allocation of the operator to the operator set of the quorum, for strategy
+func (c *Chain) SetAllocation(operator common.Address, quorumNumber byte, strategy common.Address, allocation Allocation) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	set, ok := c.operatorSets[quorumNumber]
+	if !ok {
+		panic(fmt.Sprintf("quorum %d is not an operator set", quorumNumber))
+	}
+	if set.allocations[operator] == nil {
+		set.allocations[operator] = make(map[common.Address]Allocation)
+	}
+	set.allocations[operator][strategy] = allocation
+}
+
+// operatorSet returns the operator set, nil if it doesn't exist
+func (c *Chain) operatorSet(operatorSet allocationmanager.OperatorSet) *operatorSet {
+	if operatorSet.Avs != ServiceManagerAddr || operatorSet.Id > 255 {
+		return nil
+	}
+	return c.operatorSets[byte(operatorSet.Id)]
+}
+
+func (c *Chain) GetOperatorSetCount(opts *bind.CallOpts, avs common.Address) (*big.Int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.read(opts); err != nil {
+		return nil, err
+	}
+	if avs != ServiceManagerAddr {
+		return new(big.Int), nil
+	}
+	return big.NewInt(int64(len(c.operatorSets))), nil
+}
+
+func (c *Chain) IsOperatorSet(opts *bind.CallOpts, operatorSet allocationmanager.OperatorSet) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.read(opts); err != nil {
+		return false, err
+	}
+	return c.operatorSet(operatorSet) != nil, nil
+}
+
+func (c *Chain) GetMembers(opts *bind.CallOpts, operatorSet allocationmanager.OperatorSet) ([]common.Address, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.read(opts); err != nil {
+		return nil, err
+	}
+	if c.operatorSet(operatorSet) == nil {
+		return nil, nil
+	}
+	return c.quorums[operatorSet.Id].sortedOperators(), nil
+}
+
+func (c *Chain) GetStrategiesInOperatorSet(opts *bind.CallOpts, operatorSet allocationmanager.OperatorSet) ([]common.Address, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.read(opts); err != nil {
+		return nil, err
+	}
+	set := c.operatorSet(operatorSet)
+	if set == nil {
+		return nil, nil
+	}
+	return append([]common.Address(nil), set.strategies...), nil
+}
+
+func (c *Chain) GetAllocations(opts *bind.CallOpts, operators []common.Address, operatorSet allocationmanager.OperatorSet, strategy common.Address) ([]allocationmanager.IAllocationManagerTypesAllocation, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.read(opts); err != nil {
+		return nil, err
+	}
+	set := c.operatorSet(operatorSet)
+	allocations := make([]allocationmanager.IAllocationManagerTypesAllocation, len(operators))
+	for i, operator := range operators {
+		allocations[i].PendingDiff = new(big.Int)
+		if set == nil {
+			continue
+		}
+		allocation := set.allocations[operator][strategy]
+		allocations[i].CurrentMagnitude = allocation.Magnitude
+		allocations[i].EffectBlock = allocation.EffectBlock
+		if allocation.PendingDiff != nil {
+			allocations[i].PendingDiff.Set(allocation.PendingDiff)
+		}
+	}
+	return allocations, nil
+}
+
+func (c *Chain) GetAllocatedStake(opts *bind.CallOpts, operatorSet allocationmanager.OperatorSet, operators []common.Address, strategies []common.Address) ([][]*big.Int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.read(opts); err != nil {
+		return nil, err
+	}
+	set := c.operatorSet(operatorSet)
+	stakes := make([][]*big.Int, len(operators))
+	for i, operator := range operators {
+		stakes[i] = make([]*big.Int, len(strategies))
+		for j, strategy := range strategies {
+			stakes[i][j] = new(big.Int)
+			if set != nil && set.allocations[operator][strategy].Stake != nil {
+				stakes[i][j].Set(set.allocations[operator][strategy].Stake)
+			}
+		}
+	}
+	return stakes, nil
+}
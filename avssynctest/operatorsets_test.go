@@ -0,0 +1,123 @@
+package avssynctest_test
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Layr-Labs/avs-sync/avssync"
+	"github.com/Layr-Labs/avs-sync/avssynctest"
+	"github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncOperatorSets(t *testing.T) {
+	strategyA := common.HexToAddress("0xa")
+	strategyB := common.HexToAddress("0xb")
+	chain := newChain()
+	// quorum 1 isn't an operator set, e.g. a quorum created before the slashing upgrade
+	legacyOperator := common.HexToAddress("0x4")
+	legacyQuorum := chain.AddQuorum(minimumStake)
+	chain.RegisterOperator(legacyOperator, big.NewInt(1000), legacyQuorum)
+	chain.SetStake(legacyOperator, legacyQuorum, big.NewInt(3000))
+	slashableQuorum := chain.AddQuorum(minimumStake)
+	chain.RegisterOperator(operator1, big.NewInt(1000), slashableQuorum)
+	chain.RegisterOperator(operator3, big.NewInt(1000), slashableQuorum)
+	chain.SetStake(operator3, slashableQuorum, big.NewInt(500))
+
+	chain.AddOperatorSet(0, strategyA)
+	chain.AddOperatorSet(slashableQuorum, strategyA, strategyB)
+	chain.SetAllocation(operator1, 0, strategyA, avssynctest.Allocation{Stake: big.NewInt(2000), Magnitude: 500, PendingDiff: big.NewInt(100), EffectBlock: 100})
+	chain.SetAllocation(operator3, 0, strategyA, avssynctest.Allocation{Stake: big.NewInt(1000), Magnitude: 500})
+	chain.SetAllocation(operator1, slashableQuorum, strategyB, avssynctest.Allocation{Stake: big.NewInt(1000), Magnitude: 300})
+	chain.SetAllocation(operator3, slashableQuorum, strategyB, avssynctest.Allocation{Stake: big.NewInt(500), Magnitude: 300, PendingDiff: big.NewInt(-200), EffectBlock: 120})
+
+	reg := prometheus.NewRegistry()
+	config := avssync.Config{
+		UseOperatorSets:     true,
+		RetrySyncNTimes:     1,
+		ReaderTimeout:       time.Second,
+		WriterTimeout:       time.Second,
+		ShutdownGracePeriod: time.Second,
+	}
+	logger := logging.NewTextSLogger(os.Stderr, &logging.SLoggerOptions{})
+	avsSync, err := avssync.NewAvsSync(logger, config, chain, chain, nil, newGasEstimator(t, chain), reg)
+	require.NoError(t, err)
+	avsSync.SetOperatorSetReader(chain, avssynctest.ServiceManagerAddr)
+	require.NoError(t, avsSync.Start(context.Background()))
+
+	// only the operator sets are synced
+	requireStakesUpdated(t, chain)
+	require.Equal(t, big.NewInt(500), chain.RecordedStake(operator3, slashableQuorum))
+	require.Equal(t, big.NewInt(1000), chain.RecordedStake(legacyOperator, legacyQuorum))
+	txs := chain.Txs()
+	require.Len(t, txs, 2)
+	require.Equal(t, []byte{0}, txs[0].Quorums)
+	require.Equal(t, []byte{slashableQuorum}, txs[1].Quorums)
+
+	status := avsSync.Status()
+	require.Equal(t, []int{0, int(slashableQuorum)}, status.Quorums)
+	require.Len(t, status.OperatorSets, 2)
+	require.Equal(t, avssync.OperatorSetStatus{
+		Id:         uint32(slashableQuorum),
+		Operators:  2,
+		Strategies: []common.Address{strategyA, strategyB},
+		AllocatedStake: map[common.Address]*big.Int{
+			strategyA: big.NewInt(0),
+			strategyB: big.NewInt(1500),
+		},
+		PendingAllocations: []avssync.PendingAllocation{
+			{Operator: operator3, Strategy: strategyB, CurrentMagnitude: 300, PendingDiff: big.NewInt(-200), EffectBlock: 120},
+		},
+	}, status.OperatorSets[1])
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP avssync_operator_set_pending_allocations Number of allocations to the operator set that haven't taken effect yet, by direction (allocation, or deallocation whose stake is still slashable) (only with use-operator-sets)
+# TYPE avssync_operator_set_pending_allocations gauge
+avssync_operator_set_pending_allocations{avs="",direction="allocation",operator_set="0"} 1
+avssync_operator_set_pending_allocations{avs="",direction="allocation",operator_set="2"} 0
+avssync_operator_set_pending_allocations{avs="",direction="deallocation",operator_set="0"} 0
+avssync_operator_set_pending_allocations{avs="",direction="deallocation",operator_set="2"} 1
+# HELP avssync_operator_set_allocated_stake Total stake (in shares of the strategy) allocated to the operator set by its operators (only with use-operator-sets)
+# TYPE avssync_operator_set_allocated_stake gauge
+avssync_operator_set_allocated_stake{avs="",operator_set="0",strategy="0x000000000000000000000000000000000000000A"} 3000
+avssync_operator_set_allocated_stake{avs="",operator_set="2",strategy="0x000000000000000000000000000000000000000A"} 0
+avssync_operator_set_allocated_stake{avs="",operator_set="2",strategy="0x000000000000000000000000000000000000000b"} 1500
+`), "avssync_operator_set_pending_allocations", "avssync_operator_set_allocated_stake"))
+
+	t.Run("plan", func(t *testing.T) {
+		plan, err := avsSync.Plan(context.Background(), nil, nil)
+		require.NoError(t, err)
+		var out strings.Builder
+		require.NoError(t, plan.Write(&out))
+		require.Contains(t, out.String(), "operator set allocated stake of strategy 0x000000000000000000000000000000000000000b: 1500")
+		require.Contains(t, out.String(), "0x0000000000000000000000000000000000000003 is deallocating 200 magnitude of strategy 0x000000000000000000000000000000000000000b (currently 300) at block 120")
+	})
+}
+
+func TestSyncOperatorSetsNotFound(t *testing.T) {
+	// e.g. the service manager address is wrong
+	chain := newChain()
+	config := avssync.Config{
+		UseOperatorSets:     true,
+		RetrySyncNTimes:     1,
+		ReaderTimeout:       time.Second,
+		WriterTimeout:       time.Second,
+		ShutdownGracePeriod: time.Second,
+	}
+	logger := logging.NewTextSLogger(os.Stderr, &logging.SLoggerOptions{})
+	avsSync, err := avssync.NewAvsSync(logger, config, chain, chain, nil, newGasEstimator(t, chain), prometheus.NewRegistry())
+	require.NoError(t, err)
+	avsSync.SetOperatorSetReader(chain, avssynctest.ServiceManagerAddr)
+
+	// there is no previous quorum set to keep, so the sync fails rather than updating no quorum
+	require.ErrorIs(t, avsSync.Start(context.Background()), avssync.ErrLastSyncFailed)
+	require.Empty(t, chain.Txs())
+	require.Empty(t, avsSync.Status().Quorums)
+}
@@ -0,0 +1,128 @@
+package avssynctest
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// RemoteSigner is a stand-in for a remote signer like Web3Signer, serving eth_signTransaction over TLS
+// with a local private key
+type RemoteSigner struct {
+	*httptest.Server
+	Address common.Address
+
+	privateKey *ecdsa.PrivateKey
+	signer     gethtypes.Signer
+
+	mu       sync.Mutex
+	errorMsg string
+	signed   int
+}
+
+// NewRemoteSigner starts a remote signer signing with privateKey for chainID. If clientCAs is not nil,
+// clients must authenticate with a certificate signed by one of them (mutual TLS).
+func NewRemoteSigner(privateKey *ecdsa.PrivateKey, chainID *big.Int, clientCAs *x509.CertPool) *RemoteSigner {
+	s := &RemoteSigner{
+		Address:    crypto.PubkeyToAddress(privateKey.PublicKey),
+		privateKey: privateKey,
+		signer:     gethtypes.LatestSignerForChainID(chainID),
+	}
+	s.Server = httptest.NewUnstartedServer(http.HandlerFunc(s.serveHTTP))
+	if clientCAs != nil {
+		s.Server.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.RequireAndVerifyClientCert}
+	}
+	s.Server.StartTLS()
+	return s
+}
+
+// FailSigning makes the signer reject the next transactions with a JSON-RPC error, until called with ""
+func (s *RemoteSigner) FailSigning(msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errorMsg = msg
+}
+
+// Signed returns the number of transactions signed
+func (s *RemoteSigner) Signed() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.signed
+}
+
+type signTransactionArgs struct {
+	From                 common.Address  `json:"from"`
+	To                   *common.Address `json:"to"`
+	Gas                  hexutil.Uint64  `json:"gas"`
+	Value                *hexutil.Big    `json:"value"`
+	Nonce                hexutil.Uint64  `json:"nonce"`
+	Data                 hexutil.Bytes   `json:"data"`
+	ChainID              *hexutil.Big    `json:"chainId"`
+	MaxFeePerGas         *hexutil.Big    `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big    `json:"maxPriorityFeePerGas"`
+}
+
+func (s *RemoteSigner) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     json.RawMessage       `json:"id"`
+		Method string                `json:"method"`
+		Params []signTransactionArgs `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+	rpcError := func(code int, msg string) {
+		resp["error"] = map[string]any{"code": code, "message": msg}
+	}
+
+	s.mu.Lock()
+	switch {
+	case req.Method != "eth_signTransaction":
+		rpcError(-32601, "method not found")
+	case len(req.Params) != 1:
+		rpcError(-32602, "invalid params")
+	case s.errorMsg != "":
+		rpcError(-32000, s.errorMsg)
+	case req.Params[0].From != s.Address:
+		rpcError(-32000, "no key for "+req.Params[0].From.Hex())
+	default:
+		args := req.Params[0]
+		tx, err := gethtypes.SignNewTx(s.privateKey, s.signer, &gethtypes.DynamicFeeTx{
+			ChainID:   (*big.Int)(args.ChainID),
+			Nonce:     uint64(args.Nonce),
+			GasTipCap: (*big.Int)(args.MaxPriorityFeePerGas),
+			GasFeeCap: (*big.Int)(args.MaxFeePerGas),
+			Gas:       uint64(args.Gas),
+			To:        args.To,
+			Value:     (*big.Int)(args.Value),
+			Data:      args.Data,
+		})
+		if err != nil {
+			rpcError(-32000, err.Error())
+			break
+		}
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			rpcError(-32000, err.Error())
+			break
+		}
+		s.signed++
+		resp["result"] = hexutil.Bytes(raw)
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,117 @@
+package avssynctest_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Layr-Labs/avs-sync/avssync"
+	"github.com/Layr-Labs/avs-sync/avssynctest"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+// writeClientCert writes a self-signed client certificate and its key to dir, and returns their paths
+// and the pool to verify the certificate with
+func writeClientCert(t *testing.T, dir string) (string, string, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "avs-sync"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600))
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+func TestRemoteSigner(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, clientCAs := writeClientCert(t, dir)
+	privateKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	chainID := big.NewInt(31337)
+	remoteSigner := avssynctest.NewRemoteSigner(privateKey, chainID, clientCAs)
+	defer remoteSigner.Close()
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: remoteSigner.Certificate().Raw}), 0o600))
+
+	config := avssync.RemoteSignerConfig{
+		URL:         remoteSigner.URL,
+		Address:     remoteSigner.Address,
+		TLSCertFile: certFile,
+		TLSKeyFile:  keyFile,
+		TLSCAFile:   caFile,
+	}
+	signer, err := avssync.NewRemoteSigner(config, chainID)
+	require.NoError(t, err)
+	tx := gethtypes.NewTx(&gethtypes.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     7,
+		GasTipCap: big.NewInt(1e9),
+		GasFeeCap: big.NewInt(3e9),
+		Gas:       100_000,
+		To:        &avssynctest.RegistryCoordinatorAddr,
+		Value:     new(big.Int),
+		Data:      []byte{1, 2, 3},
+	})
+	signFn, err := signer.SignerFn()(context.Background(), remoteSigner.Address)
+	require.NoError(t, err)
+	signedTx, err := signFn(remoteSigner.Address, tx)
+	require.NoError(t, err)
+	sender, err := gethtypes.Sender(gethtypes.LatestSignerForChainID(chainID), signedTx)
+	require.NoError(t, err)
+	require.Equal(t, remoteSigner.Address, sender)
+	require.Equal(t, tx.Nonce(), signedTx.Nonce())
+	require.Equal(t, tx.Data(), signedTx.Data())
+
+	t.Run("signer error", func(t *testing.T) {
+		remoteSigner.FailSigning("key is locked")
+		defer remoteSigner.FailSigning("")
+		_, err := signer.SignTransaction(context.Background(), remoteSigner.Address, tx)
+		require.ErrorIs(t, err, avssync.ErrRemoteSigner)
+		require.ErrorContains(t, err, "key is locked")
+	})
+
+	t.Run("other sender", func(t *testing.T) {
+		_, err := signer.SignTransaction(context.Background(), common.HexToAddress("0x5e"), tx)
+		require.ErrorIs(t, err, avssync.ErrRemoteSigner)
+	})
+
+	t.Run("no client certificate", func(t *testing.T) {
+		noCertConfig := config
+		noCertConfig.TLSCertFile, noCertConfig.TLSKeyFile = "", ""
+		noCertSigner, err := avssync.NewRemoteSigner(noCertConfig, chainID)
+		require.NoError(t, err)
+		_, err = noCertSigner.SignTransaction(context.Background(), remoteSigner.Address, tx)
+		require.ErrorIs(t, err, avssync.ErrRemoteSigner)
+	})
+	require.Equal(t, 1, remoteSigner.Signed())
+}
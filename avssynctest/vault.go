@@ -0,0 +1,284 @@
+package avssynctest
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Vault is a stand-in for the HTTP API of a HashiCorp Vault server, with the token, approle and kubernetes auth
+// methods and a transit secrets engine mounted at transit holding secp256k1 keys
+type Vault struct {
+	*httptest.Server
+	// RootToken never expires
+	RootToken string
+
+	mu           sync.Mutex
+	tokenTTL     time.Duration
+	tokens       map[string]time.Time
+	appRoles     map[string]string
+	k8sRoles     map[string]string
+	transitKeys  map[string][]*ecdsa.PrivateKey
+	logins       int
+	renewals     int
+	signed       int
+	highS        bool
+	errorMessage string
+}
+
+// NewVault starts a Vault
+func NewVault() *Vault {
+	v := &Vault{
+		RootToken:   "root",
+		tokenTTL:    time.Hour,
+		tokens:      map[string]time.Time{},
+		appRoles:    map[string]string{},
+		k8sRoles:    map[string]string{},
+		transitKeys: map[string][]*ecdsa.PrivateKey{},
+	}
+	v.Server = httptest.NewServer(http.HandlerFunc(v.serveHTTP))
+	return v
+}
+
+// AddAppRole lets the approle auth method log in with roleID and secretID
+func (v *Vault) AddAppRole(roleID string, secretID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.appRoles[roleID] = secretID
+}
+
+// AddKubernetesRole lets the kubernetes auth method log in to role with the service account token jwt
+func (v *Vault) AddKubernetesRole(role string, jwt string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.k8sRoles[role] = jwt
+}
+
+// AddTransitKeyVersion adds privateKey as the latest version of the secp256k1 transit key name
+func (v *Vault) AddTransitKeyVersion(name string, privateKey *ecdsa.PrivateKey) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.transitKeys[name] = append(v.transitKeys[name], privateKey)
+}
+
+// TransitKeyAddress returns the address of the latest version of the transit key name
+func (v *Vault) TransitKeyAddress(name string) common.Address {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	versions := v.transitKeys[name]
+	return crypto.PubkeyToAddress(versions[len(versions)-1].PublicKey)
+}
+
+// SetTokenTTL sets the ttl of the tokens issued by the next logins and renewals, an hour by default
+func (v *Vault) SetTokenTTL(ttl time.Duration) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.tokenTTL = ttl
+}
+
+// RevokeTokens revokes every token but the root token
+func (v *Vault) RevokeTokens() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.tokens = map[string]time.Time{}
+}
+
+// ReturnHighS makes the transit engine return signatures with the high S value, which Ethereum rejects
+func (v *Vault) ReturnHighS(highS bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.highS = highS
+}
+
+// FailSigning makes the transit engine fail signing with an internal error, until called with ""
+func (v *Vault) FailSigning(msg string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.errorMessage = msg
+}
+
+// Logins returns the number of logins with the approle and kubernetes auth methods
+func (v *Vault) Logins() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.logins
+}
+
+// Renewals returns the number of token renewals
+func (v *Vault) Renewals() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.renewals
+}
+
+// Signed returns the number of signatures made by the transit engine
+func (v *Vault) Signed() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.signed
+}
+
+func (v *Vault) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	reply := func(status int, resp map[string]any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+	fail := func(status int, msg string) {
+		reply(status, map[string]any{"errors": []string{msg}})
+	}
+	var body map[string]any
+	if r.Method == http.MethodPost {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			fail(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+
+	// login endpoints don't need a token
+	switch path {
+	case "auth/approle/login":
+		roleID, _ := body["role_id"].(string)
+		secretID, _ := body["secret_id"].(string)
+		if expected, ok := v.appRoles[roleID]; !ok || expected != secretID {
+			fail(http.StatusBadRequest, "invalid role or secret ID")
+			return
+		}
+		v.logins++
+		reply(http.StatusOK, map[string]any{"auth": v.issueToken()})
+		return
+	case "auth/kubernetes/login":
+		role, _ := body["role"].(string)
+		jwt, _ := body["jwt"].(string)
+		if expected, ok := v.k8sRoles[role]; !ok || expected != jwt {
+			fail(http.StatusForbidden, "permission denied")
+			return
+		}
+		v.logins++
+		reply(http.StatusOK, map[string]any{"auth": v.issueToken()})
+		return
+	}
+
+	token := r.Header.Get("X-Vault-Token")
+	expiry, ok := v.tokens[token]
+	if token != v.RootToken && (!ok || time.Now().After(expiry)) {
+		fail(http.StatusForbidden, "permission denied")
+		return
+	}
+	switch {
+	case path == "auth/token/lookup-self":
+		ttl, renewable := 0, false
+		if token != v.RootToken {
+			ttl, renewable = int(time.Until(expiry).Seconds()), true
+		}
+		reply(http.StatusOK, map[string]any{"data": map[string]any{"ttl": ttl, "renewable": renewable}})
+	case path == "auth/token/renew-self":
+		if token == v.RootToken {
+			fail(http.StatusBadRequest, "lease is not renewable")
+			return
+		}
+		v.renewals++
+		v.tokens[token] = time.Now().Add(v.tokenTTL)
+		reply(http.StatusOK, map[string]any{"auth": map[string]any{
+			"client_token": token, "lease_duration": int(v.tokenTTL.Seconds()), "renewable": true,
+		}})
+	case strings.HasPrefix(path, "transit/keys/") && r.Method == http.MethodGet:
+		versions, ok := v.transitKeys[strings.TrimPrefix(path, "transit/keys/")]
+		if !ok {
+			fail(http.StatusNotFound, "")
+			return
+		}
+		keys := map[string]any{}
+		for i, privateKey := range versions {
+			keys[fmt.Sprint(i+1)] = map[string]any{"public_key": secp256k1PublicKeyPEM(&privateKey.PublicKey)}
+		}
+		reply(http.StatusOK, map[string]any{"data": map[string]any{
+			"type": "ecdsa-secp256k1", "latest_version": len(versions), "keys": keys,
+		}})
+	case strings.HasPrefix(path, "transit/sign/") && r.Method == http.MethodPost:
+		versions, ok := v.transitKeys[strings.TrimPrefix(path, "transit/sign/")]
+		if !ok {
+			fail(http.StatusBadRequest, "signing key not found")
+			return
+		}
+		if v.errorMessage != "" {
+			fail(http.StatusInternalServerError, v.errorMessage)
+			return
+		}
+		version := len(versions)
+		if keyVersion, ok := body["key_version"].(float64); ok && keyVersion > 0 {
+			version = int(keyVersion)
+		}
+		input, _ := body["input"].(string)
+		hash, err := base64.StdEncoding.DecodeString(input)
+		if err != nil || len(hash) != 32 || body["prehashed"] != true || version > len(versions) {
+			fail(http.StatusBadRequest, "invalid input")
+			return
+		}
+		sig, err := crypto.Sign(hash, versions[version-1])
+		if err != nil {
+			fail(http.StatusInternalServerError, err.Error())
+			return
+		}
+		sigR, sigS := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64])
+		if v.highS {
+			sigS.Sub(crypto.S256().Params().N, sigS)
+		}
+		der, err := asn1.Marshal(struct{ R, S *big.Int }{sigR, sigS})
+		if err != nil {
+			fail(http.StatusInternalServerError, err.Error())
+			return
+		}
+		v.signed++
+		reply(http.StatusOK, map[string]any{"data": map[string]any{
+			"signature": fmt.Sprintf("vault:v%d:%s", version, base64.StdEncoding.EncodeToString(der)),
+		}})
+	default:
+		fail(http.StatusNotFound, "")
+	}
+}
+
+// issueToken returns the auth of a new token. v.mu must be held.
+func (v *Vault) issueToken() map[string]any {
+	tokenBytes := make([]byte, 16)
+	_, _ = rand.Read(tokenBytes)
+	token := "hvs." + hex.EncodeToString(tokenBytes)
+	v.tokens[token] = time.Now().Add(v.tokenTTL)
+	return map[string]any{"client_token": token, "lease_duration": int(v.tokenTTL.Seconds()), "renewable": true}
+}
+
+// secp256k1PublicKeyPEM encodes publicKey as a PEM SubjectPublicKeyInfo, like the transit engine
+func secp256k1PublicKeyPEM(publicKey *ecdsa.PublicKey) string {
+	curve, _ := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 10})
+	publicKeyBytes := crypto.FromECDSAPub(publicKey)
+	der, _ := asn1.Marshal(struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}{
+		Algorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1},
+			Parameters: asn1.RawValue{FullBytes: curve},
+		},
+		PublicKey: asn1.BitString{Bytes: publicKeyBytes, BitLength: 8 * len(publicKeyBytes)},
+	})
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
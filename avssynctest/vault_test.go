@@ -0,0 +1,200 @@
+package avssynctest_test
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Layr-Labs/avs-sync/avssync"
+	"github.com/Layr-Labs/avs-sync/avssynctest"
+	"github.com/Layr-Labs/eigensdk-go/logging"
+	"github.com/ethereum/go-ethereum/common"
+	gethtypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTx(chainID *big.Int, nonce uint64) *gethtypes.Transaction {
+	return gethtypes.NewTx(&gethtypes.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1e9),
+		GasFeeCap: big.NewInt(3e9),
+		Gas:       100_000,
+		To:        &avssynctest.RegistryCoordinatorAddr,
+		Value:     new(big.Int),
+		Data:      []byte{1, 2, 3},
+	})
+}
+
+func TestVaultTransitSigner(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTextSLogger(os.Stderr, &logging.SLoggerOptions{})
+	chainID := big.NewInt(31337)
+	vault := avssynctest.NewVault()
+	defer vault.Close()
+	privateKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	vault.AddTransitKeyVersion("avs-sync", privateKey)
+	vault.AddAppRole("role-id", "secret-id")
+
+	config := avssync.VaultTransitSignerConfig{
+		Vault: avssync.VaultConfig{
+			Address:         vault.URL,
+			AuthMethod:      avssync.VaultAuthAppRole,
+			AppRoleID:       "role-id",
+			AppRoleSecretID: "secret-id",
+		},
+		Key: "avs-sync",
+	}
+	signer, err := avssync.NewVaultTransitSigner(ctx, config, chainID, logger)
+	require.NoError(t, err)
+	address := crypto.PubkeyToAddress(privateKey.PublicKey)
+	require.Equal(t, address, signer.Address())
+	require.Equal(t, 1, signer.KeyVersion())
+
+	checkSigned := func(signedTx *gethtypes.Transaction, tx *gethtypes.Transaction) {
+		sender, err := gethtypes.Sender(gethtypes.LatestSignerForChainID(chainID), signedTx)
+		require.NoError(t, err)
+		require.Equal(t, address, sender)
+		require.Equal(t, tx.Nonce(), signedTx.Nonce())
+	}
+	// 20 signatures make it all but certain that both recovery ids come up
+	for nonce := uint64(0); nonce < 20; nonce++ {
+		tx := newTestTx(chainID, nonce)
+		signFn, err := signer.SignerFn()(ctx, address)
+		require.NoError(t, err)
+		signedTx, err := signFn(address, tx)
+		require.NoError(t, err)
+		checkSigned(signedTx, tx)
+	}
+
+	t.Run("high S signatures are normalized", func(t *testing.T) {
+		vault.ReturnHighS(true)
+		defer vault.ReturnHighS(false)
+		tx := newTestTx(chainID, 100)
+		signedTx, err := signer.SignTransaction(ctx, address, tx)
+		require.NoError(t, err)
+		checkSigned(signedTx, tx)
+	})
+
+	t.Run("rotated key", func(t *testing.T) {
+		// the signer keeps signing with the version it started with
+		newPrivateKey, err := crypto.GenerateKey()
+		require.NoError(t, err)
+		vault.AddTransitKeyVersion("avs-sync", newPrivateKey)
+		tx := newTestTx(chainID, 101)
+		signedTx, err := signer.SignTransaction(ctx, address, tx)
+		require.NoError(t, err)
+		checkSigned(signedTx, tx)
+
+		rotatedSigner, err := avssync.NewVaultTransitSigner(ctx, config, chainID, logger)
+		require.NoError(t, err)
+		require.Equal(t, 2, rotatedSigner.KeyVersion())
+		require.Equal(t, vault.TransitKeyAddress("avs-sync"), rotatedSigner.Address())
+	})
+
+	t.Run("revoked token", func(t *testing.T) {
+		logins := vault.Logins()
+		vault.RevokeTokens()
+		_, err := signer.SignTransaction(ctx, address, newTestTx(chainID, 102))
+		require.NoError(t, err)
+		require.Equal(t, logins+1, vault.Logins())
+	})
+
+	t.Run("signer error", func(t *testing.T) {
+		vault.FailSigning("transit engine sealed")
+		defer vault.FailSigning("")
+		_, err := signer.SignTransaction(ctx, address, newTestTx(chainID, 103))
+		require.ErrorIs(t, err, avssync.ErrRemoteSigner)
+		require.ErrorContains(t, err, "transit engine sealed")
+	})
+
+	t.Run("other sender", func(t *testing.T) {
+		_, err := signer.SignTransaction(ctx, common.HexToAddress("0x5e"), newTestTx(chainID, 104))
+		require.ErrorIs(t, err, avssync.ErrRemoteSigner)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		unknownKeyConfig := config
+		unknownKeyConfig.Key = "unknown"
+		_, err := avssync.NewVaultTransitSigner(ctx, unknownKeyConfig, chainID, logger)
+		require.ErrorContains(t, err, "cannot read transit key unknown")
+	})
+}
+
+func TestVaultAuth(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewTextSLogger(os.Stderr, &logging.SLoggerOptions{})
+	chainID := big.NewInt(31337)
+	vault := avssynctest.NewVault()
+	defer vault.Close()
+	privateKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	vault.AddTransitKeyVersion("avs-sync", privateKey)
+
+	t.Run("token", func(t *testing.T) {
+		config := avssync.VaultTransitSignerConfig{Vault: avssync.VaultConfig{Address: vault.URL, Token: vault.RootToken}, Key: "avs-sync"}
+		signer, err := avssync.NewVaultTransitSigner(ctx, config, chainID, logger)
+		require.NoError(t, err)
+		_, err = signer.SignTransaction(ctx, signer.Address(), newTestTx(chainID, 0))
+		require.NoError(t, err)
+
+		config.Vault.Token = "wrong"
+		_, err = avssync.NewVaultTransitSigner(ctx, config, chainID, logger)
+		require.ErrorContains(t, err, "permission denied")
+	})
+
+	t.Run("kubernetes", func(t *testing.T) {
+		tokenFile := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(tokenFile, []byte("service-account-jwt\n"), 0o600))
+		vault.AddKubernetesRole("avs-sync", "service-account-jwt")
+		config := avssync.VaultTransitSignerConfig{
+			Vault: avssync.VaultConfig{
+				Address:             vault.URL,
+				AuthMethod:          avssync.VaultAuthKubernetes,
+				KubernetesRole:      "avs-sync",
+				KubernetesTokenFile: tokenFile,
+			},
+			Key: "avs-sync",
+		}
+		signer, err := avssync.NewVaultTransitSigner(ctx, config, chainID, logger)
+		require.NoError(t, err)
+		_, err = signer.SignTransaction(ctx, signer.Address(), newTestTx(chainID, 0))
+		require.NoError(t, err)
+
+		config.Vault.KubernetesRole = "other"
+		_, err = avssync.NewVaultTransitSigner(ctx, config, chainID, logger)
+		require.ErrorContains(t, err, "cannot log in to vault with kubernetes auth")
+	})
+
+	t.Run("renewal", func(t *testing.T) {
+		vault.SetTokenTTL(3 * time.Second)
+		defer vault.SetTokenTTL(time.Hour)
+		vault.AddAppRole("role-id", "secret-id")
+		config := avssync.VaultTransitSignerConfig{
+			Vault: avssync.VaultConfig{
+				Address:         vault.URL,
+				AuthMethod:      avssync.VaultAuthAppRole,
+				AppRoleID:       "role-id",
+				AppRoleSecretID: "secret-id",
+			},
+			Key: "avs-sync",
+		}
+		signer, err := avssync.NewVaultTransitSigner(ctx, config, chainID, logger)
+		require.NoError(t, err)
+		logins := vault.Logins()
+		renewCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go signer.KeepTokenAlive(renewCtx)
+
+		// the token is renewed at two thirds of its ttl, so it is still valid after its initial ttl
+		require.Eventually(t, func() bool { return vault.Renewals() >= 2 }, 10*time.Second, 100*time.Millisecond)
+		_, err = signer.SignTransaction(ctx, signer.Address(), newTestTx(chainID, 0))
+		require.NoError(t, err)
+		require.Equal(t, logins, vault.Logins())
+	})
+}
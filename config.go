@@ -0,0 +1,138 @@
+package main
+
+import (
+	"github.com/Layr-Labs/avs-sync/avssync"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/urfave/cli"
+)
+
+// loadConfig builds the config from the flag defaults, overridden by the config file if one is given,
+// overridden by the flags and env vars that are set
+func loadConfig(cliCtx *cli.Context) (avssync.Config, error) {
+	var cfg avssync.Config
+	applyFlags(cliCtx, &cfg, func(string) bool { return true })
+	if configFile := cliCtx.String(ConfigFileFlag.Name); configFile != "" {
+		if err := avssync.ReadConfigFile(configFile, &cfg); err != nil {
+			return avssync.Config{}, err
+		}
+		// IsSet is also true for flags set through their env var
+		applyFlags(cliCtx, &cfg, cliCtx.IsSet)
+	}
+	return cfg, nil
+}
+
+// applyFlags copies the value of the flags for which shouldApply returns true into cfg
+func applyFlags(cliCtx *cli.Context, cfg *avssync.Config, shouldApply func(name string) bool) {
+	apply := func(flag cli.Flag, set func(name string)) {
+		if shouldApply(flag.GetName()) {
+			set(flag.GetName())
+		}
+	}
+	addresses := func(name string) []common.Address {
+		var addrs []common.Address
+		for _, addr := range cliCtx.StringSlice(name) {
+			addrs = append(addrs, common.HexToAddress(addr))
+		}
+		return addrs
+	}
+
+	apply(RegistryCoordinatorAddrFlag, func(name string) { cfg.RegistryCoordinatorAddr = common.HexToAddress(cliCtx.String(name)) })
+	apply(OperatorStateRetrieverAddrFlag, func(name string) { cfg.OperatorStateRetrieverAddr = common.HexToAddress(cliCtx.String(name)) })
+	apply(ServiceManagerAddrFlag, func(name string) { cfg.ServiceManagerAddr = common.HexToAddress(cliCtx.String(name)) })
+	apply(DontUseAllocationManagerFlag, func(name string) { cfg.DontUseAllocationManager = cliCtx.Bool(name) })
+	apply(UseOperatorSetsFlag, func(name string) { cfg.UseOperatorSets = cliCtx.Bool(name) })
+	apply(AvsNameFlag, func(name string) { cfg.AvsName = cliCtx.String(name) })
+
+	apply(EthHttpUrlFlag, func(name string) { cfg.EthHttpUrls = cliCtx.StringSlice(name) })
+	apply(EthWriteHttpUrlFlag, func(name string) { cfg.EthWriteHttpUrls = cliCtx.StringSlice(name) })
+	apply(ChainIdFlag, func(name string) { cfg.ChainId = cliCtx.Uint64(name) })
+	apply(RpcEndpointCooldownFlag, func(name string) { cfg.RpcEndpointCooldown = cliCtx.Duration(name) })
+	apply(RpcAttemptTimeoutFlag, func(name string) { cfg.RpcAttemptTimeout = cliCtx.Duration(name) })
+
+	apply(SyncIntervalFlag, func(name string) { cfg.SyncInterval = cliCtx.Duration(name) })
+	apply(FirstSyncTimeFlag, func(name string) { cfg.FirstSyncTime = cliCtx.String(name) })
+	apply(ScheduleFlag, func(name string) { cfg.Schedule = cliCtx.StringSlice(name) })
+	apply(ScheduleTimezoneFlag, func(name string) { cfg.ScheduleTimezone = cliCtx.String(name) })
+	apply(OperatorListFlag, func(name string) { cfg.Operators = addresses(name) })
+	apply(QuorumListFlag, func(name string) { cfg.Quorums = cliCtx.IntSlice(name) })
+	apply(FetchQuorumDynamicallyFlag, func(name string) { cfg.FetchQuorumsDynamically = cliCtx.BoolT(name) })
+	apply(retrySyncNTimes, func(name string) { cfg.RetrySyncNTimes = cliCtx.Int(name) })
+	apply(RetryBaseBackoffFlag, func(name string) { cfg.RetryBaseBackoff = cliCtx.Duration(name) })
+	apply(RetryMaxBackoffFlag, func(name string) { cfg.RetryMaxBackoff = cliCtx.Duration(name) })
+	apply(RetryJitterFlag, func(name string) { cfg.RetryJitter = cliCtx.Float64(name) })
+	apply(RetryDeadlineFlag, func(name string) { cfg.RetryDeadline = cliCtx.Duration(name) })
+
+	apply(OnlyUpdateOnStakeDriftFlag, func(name string) { cfg.OnlyUpdateOnStakeDrift = cliCtx.Bool(name) })
+	apply(StakeDriftThresholdAbsFlag, func(name string) { cfg.StakeDriftThresholdAbs = cliCtx.String(name) })
+	apply(StakeDriftThresholdPctFlag, func(name string) { cfg.StakeDriftThresholdPct = cliCtx.Float64(name) })
+
+	apply(EventDrivenSyncFlag, func(name string) { cfg.EventDrivenSync = cliCtx.Bool(name) })
+	apply(EventPollIntervalFlag, func(name string) { cfg.EventPollInterval = cliCtx.Duration(name) })
+	apply(EventDebounceFlag, func(name string) { cfg.EventDebounce = cliCtx.Duration(name) })
+	apply(EventConfirmationsFlag, func(name string) { cfg.EventConfirmations = cliCtx.Uint64(name) })
+	apply(EventMaxBlockRangeFlag, func(name string) { cfg.EventMaxBlockRange = cliCtx.Uint64(name) })
+	apply(EventStartBlockFlag, func(name string) { cfg.EventStartBlock = cliCtx.Uint64(name) })
+	apply(EventStateFileFlag, func(name string) { cfg.EventStateFile = cliCtx.String(name) })
+
+	apply(MaxOperatorsPerTxFlag, func(name string) { cfg.MaxOperatorsPerTx = cliCtx.Int(name) })
+	apply(DryRunFlag, func(name string) { cfg.DryRun = cliCtx.Bool(name) })
+	apply(DryRunSenderAddrFlag, func(name string) { cfg.DryRunSenderAddr = common.HexToAddress(cliCtx.String(name)) })
+
+	apply(MaxFeePerGasGweiFlag, func(name string) { cfg.MaxFeePerGasGwei = cliCtx.Float64(name) })
+	apply(MaxPriorityFeePerGasGweiFlag, func(name string) { cfg.MaxPriorityFeePerGasGwei = cliCtx.Float64(name) })
+	apply(GasPriceDeferWindowFlag, func(name string) { cfg.GasPriceDeferWindow = cliCtx.Duration(name) })
+	apply(GasPriceRecheckIntervalFlag, func(name string) { cfg.GasPriceRecheckInterval = cliCtx.Duration(name) })
+	apply(GasPriceDeferActionFlag, func(name string) { cfg.GasPriceDeferAction = cliCtx.String(name) })
+	apply(GasBudgetEthFlag, func(name string) { cfg.GasBudgetEth = cliCtx.Float64(name) })
+	apply(GasBudgetPeriodFlag, func(name string) { cfg.GasBudgetPeriod = cliCtx.Duration(name) })
+	apply(LowBalanceThresholdEthFlag, func(name string) { cfg.LowBalanceThresholdEth = cliCtx.Float64(name) })
+
+	apply(StateFileFlag, func(name string) { cfg.StateFile = cliCtx.String(name) })
+
+	apply(LeaderElectionFlag, func(name string) { cfg.LeaderElection = cliCtx.String(name) })
+	apply(LeaderElectionLockFileFlag, func(name string) { cfg.LeaderElectionLockFile = cliCtx.String(name) })
+	apply(LeaderElectionLeaseNameFlag, func(name string) { cfg.LeaderElectionLeaseName = cliCtx.String(name) })
+	apply(LeaderElectionLeaseNamespaceFlag, func(name string) { cfg.LeaderElectionLeaseNamespace = cliCtx.String(name) })
+	apply(LeaderElectionIdentityFlag, func(name string) { cfg.LeaderElectionIdentity = cliCtx.String(name) })
+	apply(LeaderElectionLeaseDurationFlag, func(name string) { cfg.LeaderElectionLeaseDuration = cliCtx.Duration(name) })
+
+	apply(MetricsAddrFlag, func(name string) { cfg.MetricsAddr = cliCtx.String(name) })
+	apply(AdminAddrFlag, func(name string) { cfg.AdminAddr = cliCtx.String(name) })
+	apply(AdminAuthTokenFlag, func(name string) { cfg.AdminAuthToken = cliCtx.String(name) })
+	apply(HealthStuckThresholdFlag, func(name string) { cfg.HealthStuckThreshold = cliCtx.Duration(name) })
+	apply(ReadinessMaxMissedSyncsFlag, func(name string) { cfg.ReadinessMaxMissedSyncs = cliCtx.Int(name) })
+
+	apply(ReaderTimeoutDurationFlag, func(name string) { cfg.ReaderTimeout = cliCtx.Duration(name) })
+	apply(WriterTimeoutDurationFlag, func(name string) { cfg.WriterTimeout = cliCtx.Duration(name) })
+	apply(ShutdownGracePeriodFlag, func(name string) { cfg.ShutdownGracePeriod = cliCtx.Duration(name) })
+
+	apply(UseFireblocksFlag, func(name string) { cfg.UseFireblocks = cliCtx.BoolT(name) })
+	apply(SecretManagerRegionFlag, func(name string) { cfg.SecretManagerRegion = cliCtx.String(name) })
+	apply(SecretManagerEcdsaPrivateKeyNameFlag, func(name string) { cfg.SecretManagerEcdsaPrivateKeyName = cliCtx.String(name) })
+	apply(EcdsaPrivateKeyFlag, func(name string) { cfg.EcdsaPrivateKey = cliCtx.String(name) })
+	apply(EcdsaPrivateKeyFileFlag, func(name string) { cfg.EcdsaPrivateKeyFile = cliCtx.String(name) })
+	apply(EcdsaKeystorePathFlag, func(name string) { cfg.EcdsaKeystorePath = cliCtx.String(name) })
+	apply(EcdsaKeystorePasswordFileFlag, func(name string) { cfg.EcdsaKeystorePasswordFile = cliCtx.String(name) })
+	apply(RemoteSignerURLFlag, func(name string) { cfg.RemoteSignerURL = cliCtx.String(name) })
+	apply(RemoteSignerAddrFlag, func(name string) { cfg.RemoteSignerAddr = common.HexToAddress(cliCtx.String(name)) })
+	apply(RemoteSignerTLSCertFileFlag, func(name string) { cfg.RemoteSignerTLSCertFile = cliCtx.String(name) })
+	apply(RemoteSignerTLSKeyFileFlag, func(name string) { cfg.RemoteSignerTLSKeyFile = cliCtx.String(name) })
+	apply(RemoteSignerTLSCAFileFlag, func(name string) { cfg.RemoteSignerTLSCAFile = cliCtx.String(name) })
+	apply(SecretManagerFireblocksAPIKeyNameFlag, func(name string) { cfg.SecretManagerFireblocksAPIKeyName = cliCtx.String(name) })
+	apply(FireblocksAPIKeyFlag, func(name string) { cfg.FireblocksAPIKey = cliCtx.String(name) })
+	apply(SecretManagerFireblocksAPISecretNameFlag, func(name string) { cfg.SecretManagerFireblocksAPISecretName = cliCtx.String(name) })
+	apply(FireblocksAPISecretPathFlag, func(name string) { cfg.FireblocksAPISecretPath = cliCtx.String(name) })
+	apply(FireblocksBaseURLFlag, func(name string) { cfg.FireblocksBaseURL = cliCtx.String(name) })
+	apply(FireblocksVaultAccountNameFlag, func(name string) { cfg.FireblocksVaultAccountName = cliCtx.String(name) })
+	apply(VaultAddrFlag, func(name string) { cfg.VaultAddr = cliCtx.String(name) })
+	apply(VaultNamespaceFlag, func(name string) { cfg.VaultNamespace = cliCtx.String(name) })
+	apply(VaultAuthMethodFlag, func(name string) { cfg.VaultAuthMethod = cliCtx.String(name) })
+	apply(VaultAuthMountFlag, func(name string) { cfg.VaultAuthMount = cliCtx.String(name) })
+	apply(VaultTokenFlag, func(name string) { cfg.VaultToken = cliCtx.String(name) })
+	apply(VaultAppRoleIDFlag, func(name string) { cfg.VaultAppRoleID = cliCtx.String(name) })
+	apply(VaultAppRoleSecretIDFlag, func(name string) { cfg.VaultAppRoleSecretID = cliCtx.String(name) })
+	apply(VaultKubernetesRoleFlag, func(name string) { cfg.VaultKubernetesRole = cliCtx.String(name) })
+	apply(VaultKubernetesTokenFileFlag, func(name string) { cfg.VaultKubernetesTokenFile = cliCtx.String(name) })
+	apply(VaultTransitMountFlag, func(name string) { cfg.VaultTransitMount = cliCtx.String(name) })
+	apply(VaultTransitKeyFlag, func(name string) { cfg.VaultTransitKey = cliCtx.String(name) })
+}
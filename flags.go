@@ -9,24 +9,21 @@ import (
 var envVarPrefix = "AVS_SYNC_"
 
 var (
-	/* Required Flags */
+	/* Required Flags, which can also be set in the config file */
 	RegistryCoordinatorAddrFlag = cli.StringFlag{
-		Name:     "registry-coordinator-addr",
-		Required: true,
-		Usage:    "AVS Registry coordinator address",
-		EnvVar:   envVarPrefix + "REGISTRY_COORDINATOR_ADDR",
+		Name:   "registry-coordinator-addr",
+		Usage:  "AVS Registry coordinator address",
+		EnvVar: envVarPrefix + "REGISTRY_COORDINATOR_ADDR",
 	}
 	OperatorStateRetrieverAddrFlag = cli.StringFlag{
-		Name:     "operator-state-retriever-addr",
-		Required: true,
-		Usage:    "AVS Operator state retriever address",
-		EnvVar:   envVarPrefix + "OPERATOR_STATE_RETRIEVER_ADDR",
+		Name:   "operator-state-retriever-addr",
+		Usage:  "AVS Operator state retriever address",
+		EnvVar: envVarPrefix + "OPERATOR_STATE_RETRIEVER_ADDR",
 	}
 	ServiceManagerAddrFlag = cli.StringFlag{
-		Name:     "service-manager-addr",
-		Required: true,
-		Usage:    "AVS Service Manager address",
-		EnvVar:   envVarPrefix + "SERVICE_MANAGER_ADDR",
+		Name:   "service-manager-addr",
+		Usage:  "AVS Service Manager address",
+		EnvVar: envVarPrefix + "SERVICE_MANAGER_ADDR",
 	}
 	DontUseAllocationManagerFlag = cli.BoolFlag{
 		Name: "dont-use-allocation-manager",
@@ -34,20 +31,63 @@ var (
 			"This flag should be set to true when using EigenLayer deployments that are pre-slashing upgrade and false for slashing enabled deployments",
 		EnvVar: envVarPrefix + "DONT_USE_ALLOCATION_MANAGER",
 	}
-	EthHttpUrlFlag = cli.StringFlag{
-		Name:     "eth-http-url",
-		Required: true,
-		Usage:    "Ethereum http url",
-		EnvVar:   envVarPrefix + "ETH_HTTP_URL",
+	UseOperatorSetsFlag = cli.BoolFlag{
+		Name: "use-operator-sets",
+		Usage: "Sync the operator sets of the AVS (service-manager-addr) registered in the AllocationManager, for slashing enabled middleware, instead of quorums. " +
+			"The operator sets are enumerated at every sync, and their ids are the quorums whose stakes are updated. Their allocated stake, and the operators with pending allocations or deallocations, are reported in the metrics and GET /status",
+		EnvVar: envVarPrefix + "USE_OPERATOR_SETS",
 	}
-	SyncIntervalFlag = cli.DurationFlag{
-		Name:     "sync-interval",
-		Required: true,
-		Usage:    "Interval at which to sync with the chain (e.g. 24h). If set to 0, will only sync once and then exit.",
-		Value:    24 * time.Hour,
-		EnvVar:   envVarPrefix + "SYNC_INTERVAL",
+	AvsNameFlag = cli.StringFlag{
+		Name:   "avs-name",
+		Usage:  "Name of the AVS, which is the value of the avs label of the avssync_* metrics. The targets of the config file are named by their name key instead.",
+		EnvVar: envVarPrefix + "AVS_NAME",
+	}
+	EthHttpUrlFlag = cli.StringSliceFlag{
+		Name:   "eth-http-url",
+		Usage:  "Ethereum http urls (repeat the flag, or comma separate them in the env var), in order of preference. Calls fail over to the next url when one is unreachable, times out or is rate limited",
+		EnvVar: envVarPrefix + "ETH_HTTP_URL",
 	}
 	/* Optional Flags */
+	ConfigFileFlag = cli.StringFlag{
+		Name:   "config",
+		Usage:  "Path to a YAML (.yaml, .yml) or TOML (.toml) config file whose keys are the names of the other flags (except config-watch-interval and the log.* flags). Flags and env vars that are set override the values of the file.",
+		EnvVar: envVarPrefix + "CONFIG",
+	}
+	ConfigWatchIntervalFlag = cli.DurationFlag{
+		Name: "config-watch-interval",
+		Usage: "Interval at which the config file is checked for changes, which are then reloaded like on SIGHUP. " +
+			"Only operators, quorums, retries, timeouts, stake drift, chunking and health settings can be reloaded, other changes are rejected. 0 disables the check.",
+		Value:  10 * time.Second,
+		EnvVar: envVarPrefix + "CONFIG_WATCH_INTERVAL",
+	}
+	ChainIdFlag = cli.Uint64Flag{
+		Name:   "chain-id",
+		Usage:  "Expected chain id of eth-http-url and eth-write-http-url. AvsSync refuses to start, and /readyz fails, if an rpc serves another chain. Defaults to the chain id served at startup.",
+		EnvVar: envVarPrefix + "CHAIN_ID",
+	}
+	EthWriteHttpUrlFlag = cli.StringSliceFlag{
+		Name:   "eth-write-http-url",
+		Usage:  "Ethereum http urls used to submit the stake update transactions (e.g. a private mempool relay), in order of preference. They serve every call of the transaction manager and signer, so they must support the standard eth methods. Defaults to eth-http-url",
+		EnvVar: envVarPrefix + "ETH_WRITE_HTTP_URL",
+	}
+	RpcEndpointCooldownFlag = cli.DurationFlag{
+		Name:   "rpc-endpoint-cooldown",
+		Usage:  "How long an rpc url that failed is skipped in favor of the next ones, unless all of them fail",
+		Value:  30 * time.Second,
+		EnvVar: envVarPrefix + "RPC_ENDPOINT_COOLDOWN",
+	}
+	RpcAttemptTimeoutFlag = cli.DurationFlag{
+		Name:   "rpc-attempt-timeout",
+		Usage:  "Timeout of a call to a single rpc url, after which the call fails over to the next url. 0 means calls only time out after reader-timeout/writer-timeout",
+		Value:  10 * time.Second,
+		EnvVar: envVarPrefix + "RPC_ATTEMPT_TIMEOUT",
+	}
+	SyncIntervalFlag = cli.DurationFlag{
+		Name:   "sync-interval",
+		Usage:  "Interval at which to sync with the chain (e.g. 24h). If set to 0, will only sync once and then exit. Ignored if schedule is set.",
+		Value:  24 * time.Hour,
+		EnvVar: envVarPrefix + "SYNC_INTERVAL",
+	}
 	MetricsAddrFlag = cli.StringFlag{
 		Name:   "metrics-addr",
 		Usage:  "Prometheus server address (ip:port)",
@@ -57,9 +97,22 @@ var (
 	FirstSyncTimeFlag = cli.StringFlag{
 		Name:     "first-sync-time",
 		Required: false,
-		Usage:    "Set the HH:MI:SS time at which to run the first sync update (in UTC)",
+		Usage:    "Set the HH:MI:SS time at which to run the first sync update (in UTC). Ignored if schedule is set.",
 		EnvVar:   envVarPrefix + "FIRST_SYNC_TIME",
 	}
+	ScheduleFlag = cli.StringSliceFlag{
+		Name: "schedule",
+		Usage: "Cron expression(s) at which to run syncs, either 5 fields (minute hour dom month dow) or 6 fields with leading seconds. " +
+			"Can be repeated (comma separated for the env var, so use the flag for expressions containing commas), in which case a sync runs whenever any of them fires. " +
+			"Expressions can set their own time zone with a CRON_TZ= prefix. If set, sync-interval and first-sync-time are ignored.",
+		EnvVar: envVarPrefix + "SCHEDULE",
+	}
+	ScheduleTimezoneFlag = cli.StringFlag{
+		Name:   "schedule-timezone",
+		Usage:  "IANA time zone (e.g. UTC, Europe/Paris) in which schedule cron expressions are evaluated",
+		Value:  "UTC",
+		EnvVar: envVarPrefix + "SCHEDULE_TIMEZONE",
+	}
 	OperatorListFlag = cli.StringSliceFlag{
 		Name:   "operators",
 		Usage:  "List of operators to update stakes for",
@@ -75,6 +128,192 @@ var (
 		Usage:  "If set to true (default), will fetch the list of quorums registered in the contract and update all of them",
 		EnvVar: envVarPrefix + "FETCH_QUORUMS_DYNAMICALLY",
 	}
+	OnlyUpdateOnStakeDriftFlag = cli.BoolFlag{
+		Name: "only-update-on-stake-drift",
+		Usage: "Only send the update transaction of a quorum if the stakes recorded in the StakeRegistry drifted from the operators' current stakes " +
+			"by more than stake-drift-threshold-abs or stake-drift-threshold-pct (either in aggregate or for any single operator). " +
+			"If neither threshold is set, a quorum is updated as soon as any stake changed. Only applies when operators is empty.",
+		EnvVar: envVarPrefix + "ONLY_UPDATE_ON_STAKE_DRIFT",
+	}
+	StakeDriftThresholdAbsFlag = cli.StringFlag{
+		Name:   "stake-drift-threshold-abs",
+		Usage:  "Absolute stake drift (in stake weight units, as a decimal integer) above which a quorum is updated. 0 disables this threshold.",
+		Value:  "0",
+		EnvVar: envVarPrefix + "STAKE_DRIFT_THRESHOLD_ABS",
+	}
+	StakeDriftThresholdPctFlag = cli.Float64Flag{
+		Name:   "stake-drift-threshold-pct",
+		Usage:  "Relative stake drift (in percent of the stake recorded in the StakeRegistry) above which a quorum is updated. 0 disables this threshold.",
+		EnvVar: envVarPrefix + "STAKE_DRIFT_THRESHOLD_PCT",
+	}
+	EventDrivenSyncFlag = cli.BoolFlag{
+		Name: "event-driven-sync",
+		Usage: "In addition to the scheduled syncs, poll for DelegationManager share changes, RegistryCoordinator (de)registrations " +
+			"and StakeRegistry strategy changes, and sync the affected quorums shortly after they happen",
+		EnvVar: envVarPrefix + "EVENT_DRIVEN_SYNC",
+	}
+	EventPollIntervalFlag = cli.DurationFlag{
+		Name:   "event-poll-interval",
+		Usage:  "Interval at which to poll for new events when event-driven-sync is set",
+		Value:  12 * time.Second,
+		EnvVar: envVarPrefix + "EVENT_POLL_INTERVAL",
+	}
+	EventDebounceFlag = cli.DurationFlag{
+		Name:   "event-debounce",
+		Usage:  "How long to accumulate events after the first one is seen before syncing the affected quorums",
+		Value:  time.Minute,
+		EnvVar: envVarPrefix + "EVENT_DEBOUNCE",
+	}
+	EventConfirmationsFlag = cli.Uint64Flag{
+		Name:   "event-confirmations",
+		Usage:  "Number of blocks to wait for before processing the events of a block",
+		Value:  2,
+		EnvVar: envVarPrefix + "EVENT_CONFIRMATIONS",
+	}
+	EventMaxBlockRangeFlag = cli.Uint64Flag{
+		Name:   "event-max-block-range",
+		Usage:  "Maximum number of blocks to query in a single eth_getLogs call",
+		Value:  10_000,
+		EnvVar: envVarPrefix + "EVENT_MAX_BLOCK_RANGE",
+	}
+	EventStartBlockFlag = cli.Uint64Flag{
+		Name:   "event-start-block",
+		Usage:  "Block from which to start processing events if none was persisted in event-state-file. Defaults to the current block.",
+		EnvVar: envVarPrefix + "EVENT_START_BLOCK",
+	}
+	EventStateFileFlag = cli.StringFlag{
+		Name:   "event-state-file",
+		Usage:  "File in which to persist the last block whose events were synced, so that restarts don't miss events. Ignored if state-file is set.",
+		EnvVar: envVarPrefix + "EVENT_STATE_FILE",
+	}
+	StateFileFlag = cli.StringFlag{
+		Name: "state-file",
+		Usage: "File in which to persist the outcome of the last syncs of each quorum, the transactions in flight and the last block " +
+			"whose events were synced, so that a restart checks what happened to pending transactions and syncs right away if a sync was missed",
+		EnvVar: envVarPrefix + "STATE_FILE",
+	}
+	LeaderElectionFlag = cli.StringFlag{
+		Name: "leader-election",
+		Usage: "Elect a leader among the replicas of avssync, which is the only one running syncs while the others stay warm and take over " +
+			"when it goes away: file (flock on leader-election-lock-file, for replicas on the same host) or kubernetes (a Lease in the cluster). Disabled if not set.",
+		EnvVar: envVarPrefix + "LEADER_ELECTION",
+	}
+	LeaderElectionLockFileFlag = cli.StringFlag{
+		Name:   "leader-election-lock-file",
+		Usage:  "File locked by the leader, with file leader election",
+		EnvVar: envVarPrefix + "LEADER_ELECTION_LOCK_FILE",
+	}
+	LeaderElectionLeaseNameFlag = cli.StringFlag{
+		Name:   "leader-election-lease-name",
+		Usage:  "Name of the Lease held by the leader, with kubernetes leader election. The service account of the pods must be allowed to get, create and update it.",
+		Value:  "avs-sync",
+		EnvVar: envVarPrefix + "LEADER_ELECTION_LEASE_NAME",
+	}
+	LeaderElectionLeaseNamespaceFlag = cli.StringFlag{
+		Name:   "leader-election-lease-namespace",
+		Usage:  "Namespace of the Lease held by the leader, with kubernetes leader election. Defaults to the namespace of the pod.",
+		EnvVar: envVarPrefix + "LEADER_ELECTION_LEASE_NAMESPACE",
+	}
+	LeaderElectionIdentityFlag = cli.StringFlag{
+		Name:   "leader-election-identity",
+		Usage:  "Identity of this replica in the leader election, which must be unique among replicas. Defaults to the hostname (the pod name in kubernetes).",
+		EnvVar: envVarPrefix + "LEADER_ELECTION_IDENTITY",
+	}
+	LeaderElectionLeaseDurationFlag = cli.DurationFlag{
+		Name:   "leader-election-lease-duration",
+		Usage:  "How long after its last renewal the leadership can be taken over by another replica, with kubernetes leader election",
+		Value:  15 * time.Second,
+		EnvVar: envVarPrefix + "LEADER_ELECTION_LEASE_DURATION",
+	}
+	MaxOperatorsPerTxFlag = cli.IntFlag{
+		Name: "max-operators-per-tx",
+		Usage: "If updating the entire operator set of a quorum doesn't fit in a block (or runs out of gas), update its operators " +
+			"in chunks of at most this many operators per transaction instead. 0 disables chunking.",
+		Value:  100,
+		EnvVar: envVarPrefix + "MAX_OPERATORS_PER_TX",
+	}
+	DryRunFlag = cli.BoolFlag{
+		Name: "dry-run",
+		Usage: "Instead of sending the stake update transactions, simulate them with eth_call/eth_estimateGas and print " +
+			"the stake changes, kicked operators and estimated gas cost of every sync. No signer is needed.",
+		EnvVar: envVarPrefix + "DRY_RUN",
+	}
+	DryRunSenderAddrFlag = cli.StringFlag{
+		Name:   "dry-run-sender-addr",
+		Usage:  "Address from which transactions are simulated in dry-run mode and by the plan command (defaults to the zero address)",
+		EnvVar: envVarPrefix + "DRY_RUN_SENDER_ADDR",
+	}
+	MaxFeePerGasGweiFlag = cli.Float64Flag{
+		Name: "max-fee-per-gas-gwei",
+		Usage: "Cap on the max fee per gas of the stake update transactions, in gwei. Syncs are deferred while the gas price " +
+			"(base fee + tip) is above it, see gas-price-defer-window. 0 means no cap.",
+		EnvVar: envVarPrefix + "MAX_FEE_PER_GAS_GWEI",
+	}
+	MaxPriorityFeePerGasGweiFlag = cli.Float64Flag{
+		Name:   "max-priority-fee-per-gas-gwei",
+		Usage:  "Cap on the max priority fee per gas (tip) of the stake update transactions, in gwei. 0 means no cap.",
+		EnvVar: envVarPrefix + "MAX_PRIORITY_FEE_PER_GAS_GWEI",
+	}
+	GasPriceDeferWindowFlag = cli.DurationFlag{
+		Name: "gas-price-defer-window",
+		Usage: "While the gas price is above max-fee-per-gas-gwei, wait for at most this long for it to come down before syncing, " +
+			"then apply gas-price-defer-action. 0 means syncs are never deferred (but still sent at the cap).",
+		EnvVar: envVarPrefix + "GAS_PRICE_DEFER_WINDOW",
+	}
+	GasPriceRecheckIntervalFlag = cli.DurationFlag{
+		Name:   "gas-price-recheck-interval",
+		Usage:  "How often the gas price is checked again while a sync is deferred",
+		Value:  time.Minute,
+		EnvVar: envVarPrefix + "GAS_PRICE_RECHECK_INTERVAL",
+	}
+	GasPriceDeferActionFlag = cli.StringFlag{
+		Name: "gas-price-defer-action",
+		Usage: "What to do when the gas price is still too high at the end of the defer window: skip the sync (recorded with the " +
+			"deferred_due_to_gas status) or send-at-cap",
+		Value:  "skip",
+		EnvVar: envVarPrefix + "GAS_PRICE_DEFER_ACTION",
+	}
+	GasBudgetEthFlag = cli.Float64Flag{
+		Name: "gas-budget-eth",
+		Usage: "Maximum cost, in ETH, of the stake update transactions over gas-budget-period. Once spent, no stake update is sent " +
+			"until older transactions leave the period. 0 means no budget.",
+		EnvVar: envVarPrefix + "GAS_BUDGET_ETH",
+	}
+	GasBudgetPeriodFlag = cli.DurationFlag{
+		Name:   "gas-budget-period",
+		Usage:  "Rolling period over which gas-budget-eth applies",
+		Value:  30 * 24 * time.Hour,
+		EnvVar: envVarPrefix + "GAS_BUDGET_PERIOD",
+	}
+	LowBalanceThresholdEthFlag = cli.Float64Flag{
+		Name: "low-balance-threshold-eth",
+		Usage: "Balance, in ETH, under which the sender should be topped up. It is logged at every sync while the balance is lower, " +
+			"and exported as avssync_sender_low_balance_threshold_wei to alert on. 0 means no threshold.",
+		EnvVar: envVarPrefix + "LOW_BALANCE_THRESHOLD_ETH",
+	}
+	AdminAddrFlag = cli.StringFlag{
+		Name: "admin-addr",
+		Usage: "Address (ip:port) of the admin HTTP API used to trigger (POST /sync), pause (POST /pause, POST /resume) and inspect " +
+			"(GET /status, GET /config) syncs at runtime. The admin API is disabled if not set.",
+		EnvVar: envVarPrefix + "ADMIN_ADDR",
+	}
+	AdminAuthTokenFlag = cli.StringFlag{
+		Name:   "admin-auth-token",
+		Usage:  "Bearer token required by the admin HTTP API. If not set, the admin API is unauthenticated, so admin-addr should not be publicly reachable.",
+		EnvVar: envVarPrefix + "ADMIN_AUTH_TOKEN",
+	}
+	HealthStuckThresholdFlag = cli.DurationFlag{
+		Name:   "health-stuck-threshold",
+		Usage:  "/healthz fails if a sync runs for longer than this, or if a scheduled sync didn't start this long after its scheduled time. 0 disables this check.",
+		Value:  time.Hour,
+		EnvVar: envVarPrefix + "HEALTH_STUCK_THRESHOLD",
+	}
+	ReadinessMaxMissedSyncsFlag = cli.IntFlag{
+		Name:   "readiness-max-missed-syncs",
+		Usage:  "/readyz fails if a quorum wasn't synced successfully during this many scheduled syncs. 0 disables this check.",
+		Value:  2,
+		EnvVar: envVarPrefix + "READINESS_MAX_MISSED_SYNCS",
+	}
 	ReaderTimeoutDurationFlag = cli.DurationFlag{
 		Name:   "reader-timeout-duration",
 		Usage:  "Timeout duration for rpc calls to read from chain in `SECONDS`",
@@ -87,12 +326,41 @@ var (
 		Value:  90 * time.Second,
 		EnvVar: envVarPrefix + "WRITER_TIMEOUT_DURATION",
 	}
+	ShutdownGracePeriodFlag = cli.DurationFlag{
+		Name:   "shutdown-grace-period",
+		Usage:  "On SIGINT/SIGTERM, how long to keep waiting for the receipt of an in-flight transaction before exiting",
+		Value:  30 * time.Second,
+		EnvVar: envVarPrefix + "SHUTDOWN_GRACE_PERIOD",
+	}
 	retrySyncNTimes = cli.IntFlag{
 		Name:   "retry-sync-n-times",
 		Usage:  "Number of times to retry syncing before giving up",
 		Value:  3,
 		EnvVar: envVarPrefix + "RETRY_SYNC_N_TIMES",
 	}
+	RetryBaseBackoffFlag = cli.DurationFlag{
+		Name:   "retry-base-backoff",
+		Usage:  "How long to wait before retrying a failed stake update, doubled after every failed attempt",
+		Value:  2 * time.Second,
+		EnvVar: envVarPrefix + "RETRY_BASE_BACKOFF",
+	}
+	RetryMaxBackoffFlag = cli.DurationFlag{
+		Name:   "retry-max-backoff",
+		Usage:  "Maximum wait in between two attempts of a stake update",
+		Value:  time.Minute,
+		EnvVar: envVarPrefix + "RETRY_MAX_BACKOFF",
+	}
+	RetryJitterFlag = cli.Float64Flag{
+		Name:   "retry-jitter",
+		Usage:  "Fraction of the backoff randomly added or removed (0.2 waits between 80% and 120% of it)",
+		Value:  0.2,
+		EnvVar: envVarPrefix + "RETRY_JITTER",
+	}
+	RetryDeadlineFlag = cli.DurationFlag{
+		Name:   "retry-deadline",
+		Usage:  "No attempt of a stake update is started after this long since the first one (0 means no deadline)",
+		EnvVar: envVarPrefix + "RETRY_DEADLINE",
+	}
 	UseFireblocksFlag = cli.BoolTFlag{
 		Name:     "use-fireblocks",
 		Usage:    "Use Fireblocks to sign transactions. Ignores ecdsa-private-key. Fireblocks credentials must be provided.",
@@ -101,12 +369,12 @@ var (
 	}
 	SecretManagerRegionFlag = cli.StringFlag{
 		Name:   "secret-manager-region",
-		Usage:  "Region of the secret manager",
+		Usage:  "Region of the AWS secret manager secrets whose reference doesn't set one",
 		EnvVar: envVarPrefix + "SECRET_MANAGER_REGION",
 	}
 	SecretManagerEcdsaPrivateKeyNameFlag = cli.StringFlag{
 		Name:   "secret-manager-ecdsa-private-key-name",
-		Usage:  "Name of the secret in the secret manager that contains the Ethereum ecdsa private key. If not set, ecdsa-private-key must be specified.",
+		Usage:  "Secret containing the hex encoded Ethereum ecdsa private key: the name of an AWS secret manager secret, or a reference like file:///run/secrets/key, env://NAME, awssm://name?region=us-east-1 or vault://kv/avs-sync#key",
 		EnvVar: envVarPrefix + "SECRET_MANAGER_ECDSA_PRIVATE_KEY_NAME",
 	}
 	EcdsaPrivateKeyFlag = cli.StringFlag{
@@ -114,10 +382,51 @@ var (
 		Usage:  "Ethereum ecdsa private key. If not set, Fireblocks credentials must be set.",
 		EnvVar: envVarPrefix + "ECDSA_PRIVATE_KEY",
 	}
+	EcdsaPrivateKeyFileFlag = cli.StringFlag{
+		Name:   "ecdsa-private-key-file",
+		Usage:  "File containing the hex encoded Ethereum ecdsa private key, which must only be readable by its owner (chmod 600)",
+		EnvVar: envVarPrefix + "ECDSA_PRIVATE_KEY_FILE",
+	}
+	EcdsaKeystorePathFlag = cli.StringFlag{
+		Name:   "ecdsa-keystore-path",
+		Usage:  "go-ethereum encrypted json keystore file containing the Ethereum ecdsa private key, decrypted with ecdsa-keystore-password-file",
+		EnvVar: envVarPrefix + "ECDSA_KEYSTORE_PATH",
+	}
+	EcdsaKeystorePasswordFileFlag = cli.StringFlag{
+		Name:   "ecdsa-keystore-password-file",
+		Usage:  "File containing the password of ecdsa-keystore-path, which must only be readable by its owner (chmod 600)",
+		EnvVar: envVarPrefix + "ECDSA_KEYSTORE_PASSWORD_FILE",
+	}
+	// Remote signer flags
+	RemoteSignerURLFlag = cli.StringFlag{
+		Name:   "remote-signer-url",
+		Usage:  "URL of a remote signer implementing the eth_signTransaction JSON-RPC method (e.g. Web3Signer or Clef), which signs the transactions instead of a local private key",
+		EnvVar: envVarPrefix + "REMOTE_SIGNER_URL",
+	}
+	RemoteSignerAddrFlag = cli.StringFlag{
+		Name:   "remote-signer-addr",
+		Usage:  "Address of the key the remote signer signs the transactions with",
+		EnvVar: envVarPrefix + "REMOTE_SIGNER_ADDR",
+	}
+	RemoteSignerTLSCertFileFlag = cli.StringFlag{
+		Name:   "remote-signer-tls-cert-file",
+		Usage:  "PEM encoded client certificate authenticating avs-sync to the remote signer (mutual TLS), along with remote-signer-tls-key-file",
+		EnvVar: envVarPrefix + "REMOTE_SIGNER_TLS_CERT_FILE",
+	}
+	RemoteSignerTLSKeyFileFlag = cli.StringFlag{
+		Name:   "remote-signer-tls-key-file",
+		Usage:  "PEM encoded private key of remote-signer-tls-cert-file",
+		EnvVar: envVarPrefix + "REMOTE_SIGNER_TLS_KEY_FILE",
+	}
+	RemoteSignerTLSCAFileFlag = cli.StringFlag{
+		Name:   "remote-signer-tls-ca-file",
+		Usage:  "PEM encoded CA certificates verifying the certificate of the remote signer (defaults to the system ones)",
+		EnvVar: envVarPrefix + "REMOTE_SIGNER_TLS_CA_FILE",
+	}
 	// Fireblocks flags
 	SecretManagerFireblocksAPIKeyNameFlag = cli.StringFlag{
 		Name:   "secret-manager-fireblocks-api-key-name",
-		Usage:  "Name of the secret in the secret manager that contains the Fireblocks API Key.",
+		Usage:  "Secret containing the Fireblocks API Key, see secret-manager-ecdsa-private-key-name. Takes precedence over fireblocks-api-key.",
 		EnvVar: envVarPrefix + "SECRET_MANAGER_FIREBLOCKS_API_KEY_NAME",
 	}
 	FireblocksAPIKeyFlag = cli.StringFlag{
@@ -127,7 +436,7 @@ var (
 	}
 	SecretManagerFireblocksAPISecretNameFlag = cli.StringFlag{
 		Name:   "secret-manager-fireblocks-api-secret-name",
-		Usage:  "Name of the secret in the secret manager that contains the Fireblocks API Secret.",
+		Usage:  "Secret containing the Fireblocks API Secret, see secret-manager-ecdsa-private-key-name. Takes precedence over fireblocks-api-secret-path.",
 		EnvVar: envVarPrefix + "SECRET_MANAGER_FIREBLOCKS_API_SECRET_NAME",
 	}
 	FireblocksAPISecretPathFlag = cli.StringFlag{
@@ -146,6 +455,65 @@ var (
 		Usage:  "Fireblocks Vault Account Name. Ignored if ecdsa-private-key is set.",
 		EnvVar: envVarPrefix + "FIREBLOCKS_VAULT_ACCOUNT_NAME",
 	}
+	// HashiCorp Vault flags
+	VaultAddrFlag = cli.StringFlag{
+		Name:   "vault-addr",
+		Usage:  "Address of the HashiCorp Vault server holding the secrets referenced by vault:// references, e.g. https://vault:8200",
+		EnvVar: envVarPrefix + "VAULT_ADDR,VAULT_ADDR",
+	}
+	VaultNamespaceFlag = cli.StringFlag{
+		Name:   "vault-namespace",
+		Usage:  "Vault Enterprise namespace of the secrets and transit key, optional",
+		EnvVar: envVarPrefix + "VAULT_NAMESPACE,VAULT_NAMESPACE",
+	}
+	VaultAuthMethodFlag = cli.StringFlag{
+		Name:   "vault-auth-method",
+		Usage:  "How to authenticate to vault-addr: token (vault-token), approle (vault-approle-role-id and vault-approle-secret-id) or kubernetes (vault-kubernetes-role)",
+		EnvVar: envVarPrefix + "VAULT_AUTH_METHOD",
+		Value:  "token",
+	}
+	VaultAuthMountFlag = cli.StringFlag{
+		Name:   "vault-auth-mount",
+		Usage:  "Path the vault auth method is mounted at, the name of the method by default",
+		EnvVar: envVarPrefix + "VAULT_AUTH_MOUNT",
+	}
+	VaultTokenFlag = cli.StringFlag{
+		Name:   "vault-token",
+		Usage:  "Token authenticating to vault-addr with the token auth method",
+		EnvVar: envVarPrefix + "VAULT_TOKEN,VAULT_TOKEN",
+	}
+	VaultAppRoleIDFlag = cli.StringFlag{
+		Name:   "vault-approle-role-id",
+		Usage:  "Role ID of the approle vault auth method",
+		EnvVar: envVarPrefix + "VAULT_APPROLE_ROLE_ID",
+	}
+	VaultAppRoleSecretIDFlag = cli.StringFlag{
+		Name:   "vault-approle-secret-id",
+		Usage:  "Secret ID of the approle vault auth method",
+		EnvVar: envVarPrefix + "VAULT_APPROLE_SECRET_ID",
+	}
+	VaultKubernetesRoleFlag = cli.StringFlag{
+		Name:   "vault-kubernetes-role",
+		Usage:  "Role of the kubernetes vault auth method",
+		EnvVar: envVarPrefix + "VAULT_KUBERNETES_ROLE",
+	}
+	VaultKubernetesTokenFileFlag = cli.StringFlag{
+		Name:   "vault-kubernetes-token-file",
+		Usage:  "Service account token logging in with the kubernetes vault auth method",
+		EnvVar: envVarPrefix + "VAULT_KUBERNETES_TOKEN_FILE",
+		Value:  "/var/run/secrets/kubernetes.io/serviceaccount/token",
+	}
+	VaultTransitMountFlag = cli.StringFlag{
+		Name:   "vault-transit-mount",
+		Usage:  "Path the vault transit secrets engine holding vault-transit-key is mounted at",
+		EnvVar: envVarPrefix + "VAULT_TRANSIT_MOUNT",
+		Value:  "transit",
+	}
+	VaultTransitKeyFlag = cli.StringFlag{
+		Name:   "vault-transit-key",
+		Usage:  "Name of the ecdsa-secp256k1 key of the vault transit secrets engine signing the transactions, which never leaves vault",
+		EnvVar: envVarPrefix + "VAULT_TRANSIT_KEY",
+	}
 )
 
 var RequiredFlags = []cli.Flag{
@@ -154,28 +522,94 @@ var RequiredFlags = []cli.Flag{
 	ServiceManagerAddrFlag,
 	DontUseAllocationManagerFlag,
 	EthHttpUrlFlag,
-	SyncIntervalFlag,
 }
 
 var OptionalFlags = []cli.Flag{
+	ConfigFileFlag,
+	ConfigWatchIntervalFlag,
+	AvsNameFlag,
+	UseOperatorSetsFlag,
+	ChainIdFlag,
+	EthWriteHttpUrlFlag,
+	RpcEndpointCooldownFlag,
+	RpcAttemptTimeoutFlag,
+	SyncIntervalFlag,
 	MetricsAddrFlag,
 	FirstSyncTimeFlag,
+	ScheduleFlag,
+	ScheduleTimezoneFlag,
 	OperatorListFlag,
 	QuorumListFlag,
 	FetchQuorumDynamicallyFlag,
+	OnlyUpdateOnStakeDriftFlag,
+	StakeDriftThresholdAbsFlag,
+	StakeDriftThresholdPctFlag,
+	EventDrivenSyncFlag,
+	EventPollIntervalFlag,
+	EventDebounceFlag,
+	EventConfirmationsFlag,
+	EventMaxBlockRangeFlag,
+	EventStartBlockFlag,
+	EventStateFileFlag,
+	StateFileFlag,
+	LeaderElectionFlag,
+	LeaderElectionLockFileFlag,
+	LeaderElectionLeaseNameFlag,
+	LeaderElectionLeaseNamespaceFlag,
+	LeaderElectionIdentityFlag,
+	LeaderElectionLeaseDurationFlag,
+	MaxOperatorsPerTxFlag,
+	DryRunFlag,
+	DryRunSenderAddrFlag,
+	MaxFeePerGasGweiFlag,
+	MaxPriorityFeePerGasGweiFlag,
+	GasPriceDeferWindowFlag,
+	GasPriceRecheckIntervalFlag,
+	GasPriceDeferActionFlag,
+	GasBudgetEthFlag,
+	GasBudgetPeriodFlag,
+	LowBalanceThresholdEthFlag,
+	AdminAddrFlag,
+	AdminAuthTokenFlag,
+	HealthStuckThresholdFlag,
+	ReadinessMaxMissedSyncsFlag,
 	ReaderTimeoutDurationFlag,
 	WriterTimeoutDurationFlag,
+	ShutdownGracePeriodFlag,
 	retrySyncNTimes,
+	RetryBaseBackoffFlag,
+	RetryMaxBackoffFlag,
+	RetryJitterFlag,
+	RetryDeadlineFlag,
 	UseFireblocksFlag,
 	SecretManagerRegionFlag,
 	SecretManagerEcdsaPrivateKeyNameFlag,
 	EcdsaPrivateKeyFlag,
+	EcdsaPrivateKeyFileFlag,
+	EcdsaKeystorePathFlag,
+	EcdsaKeystorePasswordFileFlag,
+	RemoteSignerURLFlag,
+	RemoteSignerAddrFlag,
+	RemoteSignerTLSCertFileFlag,
+	RemoteSignerTLSKeyFileFlag,
+	RemoteSignerTLSCAFileFlag,
 	SecretManagerFireblocksAPIKeyNameFlag,
 	FireblocksAPIKeyFlag,
 	SecretManagerFireblocksAPISecretNameFlag,
 	FireblocksAPISecretPathFlag,
 	FireblocksBaseURLFlag,
 	FireblocksVaultAccountNameFlag,
+	VaultAddrFlag,
+	VaultNamespaceFlag,
+	VaultAuthMethodFlag,
+	VaultAuthMountFlag,
+	VaultTokenFlag,
+	VaultAppRoleIDFlag,
+	VaultAppRoleSecretIDFlag,
+	VaultKubernetesRoleFlag,
+	VaultKubernetesTokenFileFlag,
+	VaultTransitMountFlag,
+	VaultTransitKeyFlag,
 }
 
 func init() {
@@ -416,22 +416,29 @@ func NewAvsSyncComponents(t *testing.T, anvilHttpEndpoint string, contractAddres
 		logger.Fatalf("Cannot create avs reader", "err", err)
 	}
 
-	avsSync := avssync.NewAvsSync(
+	avsSync, err := avssync.NewAvsSync(
 		logger,
+		avssync.Config{
+			SyncInterval: syncInterval,
+			Operators:    operators,
+			// we only test with one quorum
+			Quorums:         []int{0},
+			RetrySyncNTimes: 1, // 1 retry
+			ReaderTimeout:   5 * time.Second,
+			WriterTimeout:   5 * time.Second,
+			// no metrics server (otherwise parallel tests all try to start server at same endpoint and error out)
+			MetricsAddr:         "",
+			ShutdownGracePeriod: 5 * time.Second,
+		},
 		avsReader,
 		avsWriter,
-		0*time.Second,
-		syncInterval,
-		operators,
-		// we only test with one quorum
-		[]byte{0},
-		false,
-		1, // 1 retry
-		5*time.Second,
-		5*time.Second,
-		"", // no metrics server (otherwise parallel tests all try to start server at same endpoint and error out)
+		nil, // only sync on schedule
+		nil, // no chunking
 		reg,
 	)
+	if err != nil {
+		logger.Fatalf("Cannot create avs sync", "err", err)
+	}
 	return &AvsSyncComponents{
 		avsSync:   avsSync,
 		wallet:    wallet,
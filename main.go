@@ -2,21 +2,21 @@ package main
 
 import (
 	"context"
-	"crypto/ecdsa"
 	"errors"
 	"fmt"
 	"log"
+	"math/big"
 	"os"
-	"time"
+	"os/signal"
+	"syscall"
 
 	"github.com/Layr-Labs/avs-sync/avssync"
-	"github.com/Layr-Labs/eigensdk-go/aws/secretmanager"
 	"github.com/Layr-Labs/eigensdk-go/chainio/clients/avsregistry"
-	"github.com/Layr-Labs/eigensdk-go/chainio/clients/eth"
 	"github.com/Layr-Labs/eigensdk-go/chainio/clients/fireblocks"
 	walletsdk "github.com/Layr-Labs/eigensdk-go/chainio/clients/wallet"
 	"github.com/Layr-Labs/eigensdk-go/chainio/txmgr"
-	rpccalls "github.com/Layr-Labs/eigensdk-go/metrics/collectors/rpc_calls"
+	allocationmanager "github.com/Layr-Labs/eigensdk-go/contracts/bindings/AllocationManager"
+	sdklogging "github.com/Layr-Labs/eigensdk-go/logging"
 	"github.com/Layr-Labs/eigensdk-go/signerv2"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -36,6 +36,14 @@ func main() {
 	app.Description = "Service that runs a cron job which updates the stakes of the specified operators for the specified AVS' stake registry"
 
 	app.Action = avsSyncMain
+	app.Commands = []cli.Command{
+		{
+			Name:      "plan",
+			Usage:     "Prints what a sync would do (stake changes, kicked operators, estimated gas cost) without sending any transaction, then exits",
+			UsageText: "avs-sync [global options] plan",
+			Action:    avsSyncPlan,
+		},
+	}
 
 	err := app.Run(os.Args)
 	if err != nil {
@@ -45,207 +53,574 @@ func main() {
 
 func avsSyncMain(cliCtx *cli.Context) error {
 	log.Println("Registering Node")
-	loggerConfig, err := ReadLoggerCLIConfig(cliCtx)
+	// SIGINT/SIGTERM cancel ctx, which stops the sync loop after giving an in-flight transaction
+	// shutdown-grace-period to get its receipt
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	syncs, err := newAvsSyncsFromCLI(ctx, cliCtx, false)
 	if err != nil {
 		return err
 	}
-	logger, err := NewLogger(*loggerConfig)
+	if len(syncs.config.Targets) > 0 {
+		return runTargets(ctx, cliCtx, syncs)
+	}
+	avsSync, adminServer := syncs.avsSyncs[0], syncs.adminServers[0]
+	defer avsSync.Close()
+	if adminServer != nil {
+		go func() {
+			if err := adminServer.Start(ctx, avsSync.Config().AdminAddr); err != nil {
+				log.Println("Admin server failed:", err)
+			}
+		}()
+	}
+	watchConfigFile(ctx, cliCtx, avsSync, "")
+	// the exit status reflects whether the last sync succeeded
+	return avsSync.Start(ctx)
+}
+
+// runTargets runs the AvsSync of every target until ctx is done, with a shared metrics server and admin API
+func runTargets(ctx context.Context, cliCtx *cli.Context, syncs *avsSyncs) error {
+	multiAvsSync := avssync.NewMultiAvsSync(syncs.logger, syncs.avsSyncs, syncs.registry, syncs.config.MetricsAddr)
+	defer multiAvsSync.Close()
+	for name, err := range syncs.failedTargets {
+		multiAvsSync.AddFailedTarget(name, err)
+	}
+	for i, avsSync := range syncs.avsSyncs {
+		name := avsSync.Config().AvsName
+		if syncs.adminServers[i] != nil {
+			multiAvsSync.AddAdminServer(name, syncs.adminServers[i])
+		}
+		watchConfigFile(ctx, cliCtx, avsSync, name)
+	}
+	if syncs.config.AdminAddr != "" {
+		go func() {
+			if err := multiAvsSync.StartAdminServer(ctx, syncs.config.AdminAddr); err != nil {
+				log.Println("Admin server failed:", err)
+			}
+		}()
+	}
+	// the exit status reflects whether the last sync of every target succeeded
+	return multiAvsSync.Start(ctx)
+}
+
+// watchConfigFile reloads the config of avsSync on SIGHUP and changes of the config file, if there is one.
+// target is the name of the target avsSync syncs, empty without targets.
+func watchConfigFile(ctx context.Context, cliCtx *cli.Context, avsSync *avssync.AvsSync, target string) {
+	configFile := cliCtx.String(ConfigFileFlag.Name)
+	if configFile == "" {
+		return
+	}
+	// SIGHUP (or a change of the file) reloads the config file, and applies the changes that don't need a restart
+	reloadRequests := make(chan os.Signal, 1)
+	signal.Notify(reloadRequests, syscall.SIGHUP)
+	context.AfterFunc(ctx, func() { signal.Stop(reloadRequests) })
+	load := func() (avssync.Config, error) {
+		cfg, err := loadValidConfig(cliCtx, false)
+		if err != nil || target == "" {
+			return cfg, err
+		}
+		for _, targetConfig := range cfg.Targets {
+			if targetConfig.Name == target {
+				return cfg.TargetConfig(targetConfig), nil
+			}
+		}
+		return avssync.Config{}, fmt.Errorf("target %s was removed from the config file, which requires a restart", target)
+	}
+	configWatcher := avssync.NewConfigWatcher(avsSync, configFile, load, cliCtx.Duration(ConfigWatchIntervalFlag.Name))
+	go configWatcher.Start(ctx, reloadRequests)
+}
+
+// avsSyncPlan runs the plan subcommand, which reads its configuration from the global flags
+func avsSyncPlan(cliCtx *cli.Context) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	syncs, err := newAvsSyncsFromCLI(ctx, cliCtx.Parent(), true)
 	if err != nil {
 		return err
 	}
+	var errs []error
+	for name, err := range syncs.failedTargets {
+		errs = append(errs, fmt.Errorf("Cannot plan sync of target %s: %w", name, err))
+	}
+	for _, avsSync := range syncs.avsSyncs {
+		target := avsSync.Config().AvsName
+		if len(syncs.config.Targets) > 0 {
+			fmt.Printf("Target %s\n", target)
+		}
+		plan, err := avsSync.Plan(ctx, nil, nil)
+		if err != nil {
+			if len(syncs.config.Targets) == 0 {
+				return fmt.Errorf("Cannot plan sync: %w", err)
+			}
+			errs = append(errs, fmt.Errorf("Cannot plan sync of target %s: %w", target, err))
+			continue
+		}
+		if err := plan.Write(os.Stdout); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// avsSyncs are the AvsSyncs configured by the flags and config file: one per target if the config has targets,
+// a single one otherwise
+type avsSyncs struct {
+	logger   sdklogging.Logger
+	config   avssync.Config
+	registry *prometheus.Registry
+	avsSyncs []*avssync.AvsSync
+	// the admin server of each AvsSync, nil if admin-addr isn't set
+	adminServers []*avssync.AdminServer
+	// the targets whose AvsSync couldn't be created, which don't stop the other targets
+	failedTargets map[string]error
+}
+
+// chainClients are the RPC clients, shared by the targets
+type chainClients struct {
+	read    *avssync.FailoverClient
+	write   *avssync.FailoverClient
+	chainId *big.Int
+}
+
+// newAvsSyncsFromCLI creates the AvsSyncs configured by the flags and config file, and their admin server if admin-addr is set.
+// The targets share the RPC clients, and the wallet of their signer. In dry run mode, no signer is created.
+func newAvsSyncsFromCLI(ctx context.Context, cliCtx *cli.Context, dryRun bool) (*avsSyncs, error) {
+	loggerConfig, err := ReadLoggerCLIConfig(cliCtx)
+	if err != nil {
+		return nil, err
+	}
+	logger, err := NewLogger(*loggerConfig)
+	if err != nil {
+		return nil, err
+	}
 
-	writerTimeout := cliCtx.Duration(WriterTimeoutDurationFlag.Name)
-	readerTimeout := cliCtx.Duration(ReaderTimeoutDurationFlag.Name)
+	cfg, err := loadValidConfig(cliCtx, dryRun)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create new prometheus registry
 	reg := prometheus.NewRegistry()
 
-	rpcCollector := rpccalls.NewCollector(AppName, reg)
+	clients, err := newChainClients(ctx, logger, cfg, reg)
+	if err != nil {
+		return nil, err
+	}
+	syncs := &avsSyncs{logger: logger, config: cfg, registry: reg, failedTargets: map[string]error{}}
 
-	ethHttpClient, err := eth.NewInstrumentedClient(cliCtx.String(EthHttpUrlFlag.Name), rpcCollector)
+	if len(cfg.Targets) == 0 {
+		var wallet walletsdk.Wallet
+		if !cfg.DryRun {
+			wallet, err = newWallet(ctx, cfg, logger, clients.write, clients.chainId)
+			if err != nil {
+				return nil, err
+			}
+		}
+		avsSync, adminServer, err := newAvsSync(ctx, logger, cfg, clients, wallet, nil, reg, reg)
+		if err != nil {
+			return nil, err
+		}
+		syncs.avsSyncs = []*avssync.AvsSync{avsSync}
+		syncs.adminServers = []*avssync.AdminServer{adminServer}
+		return syncs, nil
+	}
+
+	// the transactions of the targets sharing a sender are sent one at a time, so that they don't pick the same nonce
+	wallets := map[string]walletsdk.Wallet{}
+	senderLocks := map[common.Address]*avssync.SenderLock{}
+	for _, target := range cfg.Targets {
+		targetCfg := cfg.TargetConfig(target)
+		targetLogger := logger.With("avs", target.Name)
+		var wallet walletsdk.Wallet
+		var senderLock *avssync.SenderLock
+		if !cfg.DryRun {
+			var ok bool
+			if wallet, ok = wallets[target.Signer]; !ok {
+				wallet, err = newWallet(ctx, targetCfg, logger, clients.write, clients.chainId)
+				if err != nil {
+					return nil, fmt.Errorf("Cannot create signer of target %s: %w", target.Name, err)
+				}
+				wallets[target.Signer] = wallet
+			}
+			sender, err := wallet.SenderAddress(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("Cannot get sender address of target %s: %w", target.Name, err)
+			}
+			if senderLock = senderLocks[sender]; senderLock == nil {
+				senderLock = avssync.NewSenderLock()
+				senderLocks[sender] = senderLock
+			}
+		}
+		// the metrics of avssync.Metrics have an avs label, the labels of the other per target metrics are added here
+		targetReg := prometheus.WrapRegistererWith(prometheus.Labels{"avs": target.Name}, reg)
+		avsSync, adminServer, err := newAvsSync(ctx, targetLogger, targetCfg, clients, wallet, senderLock, reg, targetReg)
+		if err != nil {
+			targetLogger.Error("Cannot create target, the other targets run without it", "err", err)
+			syncs.failedTargets[target.Name] = err
+			continue
+		}
+		syncs.avsSyncs = append(syncs.avsSyncs, avsSync)
+		syncs.adminServers = append(syncs.adminServers, adminServer)
+	}
+	if len(syncs.avsSyncs) == 0 {
+		return nil, errors.New("Cannot create any target")
+	}
+	return syncs, nil
+}
+
+// newChainClients creates the read and write RPC clients, and checks the chain they serve
+func newChainClients(ctx context.Context, logger sdklogging.Logger, cfg avssync.Config, reg *prometheus.Registry) (*chainClients, error) {
+	failoverConfig := avssync.FailoverConfig{
+		Cooldown:       cfg.RpcEndpointCooldown,
+		AttemptTimeout: cfg.RpcAttemptTimeout,
+	}
+	ethHttpClient, err := avssync.NewFailoverClient(logger, "read", cfg.EthHttpUrls, failoverConfig, AppName, reg)
 	if err != nil {
-		logger.Fatalf("Cannot create eth client", "err", err)
+		return nil, fmt.Errorf("Cannot create eth client: %w", err)
 	}
 
-	rpcCtx, cancel := context.WithTimeout(context.Background(), readerTimeout)
+	rpcCtx, cancel := context.WithTimeout(ctx, cfg.ReaderTimeout)
 	defer cancel()
-	chainid, err := ethHttpClient.ChainID(rpcCtx)
+	chainid, err := ethHttpClient.CheckChainId(rpcCtx)
 	if err != nil {
-		logger.Fatalf("Cannot get chain id", "err", err)
+		return nil, fmt.Errorf("Cannot get chain id: %w", err)
+	}
+	if cfg.ChainId != 0 && chainid.Uint64() != cfg.ChainId {
+		return nil, fmt.Errorf("Eth http url serves chain id %s, expected %d", chainid, cfg.ChainId)
 	}
 
+	// transactions are submitted through the write urls if set, everything else goes through the read urls
+	ethWriteClient := ethHttpClient
+	if len(cfg.EthWriteHttpUrls) > 0 && !cfg.DryRun {
+		ethWriteClient, err = avssync.NewFailoverClient(logger, "write", cfg.EthWriteHttpUrls, failoverConfig, AppName, reg)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot create eth write client: %w", err)
+		}
+		writeChainid, err := ethWriteClient.CheckChainId(rpcCtx)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot get chain id of eth write http url: %w", err)
+		}
+		if writeChainid.Cmp(chainid) != 0 {
+			return nil, fmt.Errorf("Eth write http url serves chain id %s, but eth http url serves chain id %s", writeChainid, chainid)
+		}
+	}
+	return &chainClients{read: ethHttpClient, write: ethWriteClient, chainId: chainid}, nil
+}
+
+// newAvsSync creates the AvsSync of cfg, and its admin server if admin-addr is set.
+// wallet is the wallet of the signer (nil in dry run mode), and senderLock, if not nil, serializes the transactions
+// with the other AvsSyncs sharing the sender. Metrics are registered on reg, and the metrics of the other components
+// on componentReg.
+func newAvsSync(
+	ctx context.Context,
+	logger sdklogging.Logger,
+	cfg avssync.Config,
+	clients *chainClients,
+	wallet walletsdk.Wallet,
+	senderLock *avssync.SenderLock,
+	reg *prometheus.Registry,
+	componentReg prometheus.Registerer,
+) (*avssync.AvsSync, *avssync.AdminServer, error) {
+	ethHttpClient, ethWriteClient, chainid := clients.read, clients.write, clients.chainId
+	var err error
+	var stateStore *avssync.StateStore
+	if cfg.StateFile != "" && !cfg.DryRun {
+		// dry runs don't actually sync, so they must not record anything
+		stateStore, err = avssync.OpenStateStore(cfg.StateFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Cannot open state store: %w", err)
+		}
+	}
+
+	var sender common.Address
+	var avsWriter avssync.AvsWriter
+	avsRegistryConfig := avsregistry.Config{
+		RegistryCoordinatorAddress:    cfg.RegistryCoordinatorAddr,
+		OperatorStateRetrieverAddress: cfg.OperatorStateRetrieverAddr,
+		DontUseAllocationManager:      cfg.DontUseAllocationManager,
+
+		ServiceManagerAddress: cfg.ServiceManagerAddr,
+	}
+	if cfg.DryRun {
+		// dry runs only simulate transactions, so that they can be previewed before the signer is set up
+		sender = cfg.DryRunSenderAddr
+		logger.Infof("Dry run, simulating transactions from %s", sender.Hex())
+	} else {
+		sender, err = wallet.SenderAddress(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Cannot get sender address: %w", err)
+		}
+		logger.Infof("Sender address: %s", sender.Hex())
+		if stateStore != nil {
+			// check what happened to the transactions that were in flight when avssync last stopped
+			reconcileCtx, cancel := context.WithTimeout(ctx, cfg.WriterTimeout)
+			pending, err := avssync.ReconcileInFlightTxs(reconcileCtx, logger, stateStore, wallet, ethWriteClient)
+			cancel()
+			if err != nil {
+				return nil, nil, fmt.Errorf("Cannot reconcile in-flight transactions: %w", err)
+			}
+			if len(pending) > 0 {
+				logger.Warn("Transactions sent before the restart are still pending, new transactions will be queued after them", "pending", len(pending))
+			}
+			wallet = avssync.NewStateRecordingWallet(wallet, stateStore, logger)
+		}
+		wallet = avssync.NewFeeCappingWallet(wallet, cfg.GasFeeCaps(), logger, componentReg)
+		txMgr := txmgr.NewSimpleTxManager(wallet, ethWriteClient, logger, sender)
+		avsWriter, err = avsregistry.NewWriterFromConfig(
+			avsRegistryConfig,
+			ethWriteClient,
+			txMgr,
+			logger,
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Cannot create avs writer: %w", err)
+		}
+		if senderLock != nil {
+			avsWriter = senderLock.Writer(avsWriter)
+		}
+	}
+	avsReader, err := avsregistry.NewReaderFromConfig(
+		avsRegistryConfig,
+		ethHttpClient,
+		logger,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Cannot create avs reader: %w", err)
+	}
+
+	var eventWatcher *avssync.EventWatcher
+	if cfg.EventDrivenSync {
+		eventStateFile := cfg.EventStateFile
+		if cfg.DryRun {
+			// dry runs don't actually sync, so they must not mark events as synced
+			eventStateFile = ""
+		}
+		avsBindings, err := avsregistry.NewBindingsFromConfig(avsRegistryConfig, ethHttpClient, logger)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Cannot create avs registry bindings: %w", err)
+		}
+		eventWatcher, err = avssync.NewEventWatcher(
+			logger,
+			ethHttpClient,
+			avsReader,
+			avssync.EventWatcherConfig{
+				RegistryCoordinatorAddr: avsBindings.RegistryCoordinatorAddr,
+				StakeRegistryAddr:       avsBindings.StakeRegistryAddr,
+				DelegationManagerAddr:   avsBindings.DelegationManagerAddr,
+				PollInterval:            cfg.EventPollInterval,
+				Debounce:                cfg.EventDebounce,
+				Confirmations:           cfg.EventConfirmations,
+				MaxBlockRange:           cfg.EventMaxBlockRange,
+				ReaderTimeout:           cfg.ReaderTimeout,
+				StartBlock:              cfg.EventStartBlock,
+				StateFilePath:           eventStateFile,
+				StateStore:              stateStore,
+				Operators:               cfg.Operators,
+			},
+			componentReg,
+		)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Cannot create event watcher: %w", err)
+		}
+	}
+
+	gasEstimator, err := avssync.NewGasEstimator(ethHttpClient, avsRegistryConfig.RegistryCoordinatorAddress, sender)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Cannot create gas estimator: %w", err)
+	}
+
+	avsSync, err := avssync.NewAvsSync(logger, cfg, avsReader, avsWriter, eventWatcher, gasEstimator, reg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if stateStore != nil {
+		if err := avsSync.SetStateStore(stateStore); err != nil {
+			return nil, nil, err
+		}
+	}
+	if cfg.UseOperatorSets {
+		avsBindings, err := avsregistry.NewBindingsFromConfig(avsRegistryConfig, ethHttpClient, logger)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Cannot create avs registry bindings: %w", err)
+		}
+		allocationManager, err := allocationmanager.NewContractAllocationManagerCaller(avsBindings.AllocationManagerAddr, ethHttpClient)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Cannot create allocation manager binding: %w", err)
+		}
+		// the operator sets of the AVS are the ones of its service manager
+		avsSync.SetOperatorSetReader(allocationManager, cfg.ServiceManagerAddr)
+	}
+	if !cfg.DryRun {
+		// dry runs don't send transactions, so they can run alongside the leader
+		leaderElector, err := avssync.NewLeaderElectorFromConfig(logger, cfg, componentReg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Cannot create leader elector: %w", err)
+		}
+		if leaderElector != nil {
+			avsSync.SetLeaderElector(leaderElector)
+		}
+	}
+	avsSync.AddReadinessCheck("rpc", avssync.ChainIdCheck(ethHttpClient, chainid))
+	if ethWriteClient != ethHttpClient {
+		avsSync.AddReadinessCheck("write_rpc", avssync.ChainIdCheck(ethWriteClient, chainid))
+	}
+	if wallet != nil {
+		avsSync.AddReadinessCheck("signer", avssync.SenderCheck(wallet.SenderAddress, sender))
+	}
+
+	var adminServer *avssync.AdminServer
+	if cfg.AdminAddr != "" {
+		adminServer = avssync.NewAdminServer(logger, avsSync, ethHttpClient, sender, cfg.AdminAuthToken)
+	}
+	return avsSync, adminServer, nil
+}
+
+// loadValidConfig loads the config from the flags and config file, and validates it
+func loadValidConfig(cliCtx *cli.Context, dryRun bool) (avssync.Config, error) {
+	cfg, err := loadConfig(cliCtx)
+	if err != nil {
+		return avssync.Config{}, fmt.Errorf("Cannot load config: %w", err)
+	}
+	cfg.DryRun = cfg.DryRun || dryRun
+	if err := cfg.Validate(); err != nil {
+		return avssync.Config{}, fmt.Errorf("Invalid config: %w", err)
+	}
+	return cfg, nil
+}
+
+// newWallet creates the wallet signing the stake update transactions, backed by Fireblocks, a remote signer, a vault transit key
+// or an ecdsa private key
+func newWallet(ctx context.Context, cfg avssync.Config, logger sdklogging.Logger, ethClient *avssync.FailoverClient, chainid *big.Int) (walletsdk.Wallet, error) {
 	var wallet walletsdk.Wallet
-	if cliCtx.Bool(UseFireblocksFlag.Name) {
+	secretReader, err := avssync.NewSecretReaderFromConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot create secret reader: %w", err)
+	}
+	if cfg.UseFireblocks {
 		var apiKey string
 		var secretKey []byte
-		var err error
-
-		region := cliCtx.String(SecretManagerRegionFlag.Name)
-		if len(region) >= 0 {
-			logger.Info("Using secret manager to read fireblocks api key and secret")
-			smFireblocksAPIKeyName := cliCtx.String(SecretManagerFireblocksAPIKeyNameFlag.Name)
-			smFireblockAPISecretName := cliCtx.String(SecretManagerFireblocksAPISecretNameFlag.Name)
-			if len(smFireblocksAPIKeyName) > 0 && len(smFireblockAPISecretName) > 0 {
-				apiKey, err = secretmanager.ReadStringFromSecretManager(context.Background(), smFireblocksAPIKeyName, region)
-				if err != nil {
-					return fmt.Errorf("Cannot read fireblocks api key from secret manager: %w", err)
-				}
-				secretKeyStr, err := secretmanager.ReadStringFromSecretManager(context.Background(), smFireblockAPISecretName, region)
-				if err != nil {
-					return fmt.Errorf("Cannot read fireblocks secret from secret manager: %w", err)
-				}
-				secretKey = []byte(secretKeyStr)
+
+		// the secret manager secrets take precedence over the flags
+		if cfg.SecretManagerFireblocksAPIKeyName != "" {
+			logger.Info("Reading fireblocks api key from secret manager")
+			apiKey, err = secretReader.ReadSecret(ctx, cfg.SecretManagerFireblocksAPIKeyName)
+			if err != nil {
+				return nil, fmt.Errorf("Cannot read fireblocks api key: %w", err)
 			}
+		} else {
+			logger.Info("Reading fireblocks api key from flags")
+			apiKey = cfg.FireblocksAPIKey
 		}
-
-		// If the secret manager values are not set, try to read from flags
-		if len(apiKey) == 0 || len(secretKey) == 0 {
-			logger.Info("Reading fireblocks api key and secret from flags")
-			apiKey = cliCtx.String(FireblocksAPIKeyFlag.Name)
-			secretPath := cliCtx.String(FireblocksAPISecretPathFlag.Name)
+		if cfg.SecretManagerFireblocksAPISecretName != "" {
+			logger.Info("Reading fireblocks secret from secret manager")
+			secretKeyStr, err := secretReader.ReadSecret(ctx, cfg.SecretManagerFireblocksAPISecretName)
+			if err != nil {
+				return nil, fmt.Errorf("Cannot read fireblocks secret: %w", err)
+			}
+			secretKey = []byte(secretKeyStr)
+		} else {
+			logger.Info("Reading fireblocks secret from file", "path", cfg.FireblocksAPISecretPath)
+			secretPath := cfg.FireblocksAPISecretPath
 			secretKey, err = os.ReadFile(secretPath)
 			if err != nil {
-				return fmt.Errorf("Cannot read fireblocks secret from %s: %w", secretPath, err)
+				return nil, fmt.Errorf("Cannot read fireblocks secret from %s: %w", secretPath, err)
 			}
 		}
 
-		fbBaseURL := cliCtx.String(FireblocksBaseURLFlag.Name)
-		fbVaultAccountName := cliCtx.String(FireblocksVaultAccountNameFlag.Name)
+		fbBaseURL := cfg.FireblocksBaseURL
+		fbVaultAccountName := cfg.FireblocksVaultAccountName
 		if apiKey == "" {
-			return errors.New("Fireblocks API key is not set")
+			return nil, errors.New("Fireblocks API key is not set")
 		}
 		if len(secretKey) == 0 {
-			return errors.New("Fireblocks API secret is not set")
+			return nil, errors.New("Fireblocks API secret is not set")
 		}
 		if fbBaseURL == "" {
-			return errors.New("Fireblocks base URL is not set")
+			return nil, errors.New("Fireblocks base URL is not set")
 		}
 		if fbVaultAccountName == "" {
-			return errors.New("Fireblocks vault account name is not set")
+			return nil, errors.New("Fireblocks vault account name is not set")
 		}
 
 		fireblocksClient, err := fireblocks.NewClient(
 			apiKey,
 			secretKey,
 			fbBaseURL,
-			writerTimeout,
+			cfg.WriterTimeout,
 			logger,
 		)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		wallet, err = walletsdk.NewFireblocksWallet(fireblocksClient, ethHttpClient, fbVaultAccountName, logger)
+		wallet, err = walletsdk.NewFireblocksWallet(fireblocksClient, ethClient, fbVaultAccountName, logger)
 		if err != nil {
-			return err
+			return nil, err
+		}
+	} else if cfg.RemoteSignerURL != "" {
+		logger.Info("Using remote signer to create wallet", "url", cfg.RemoteSignerURL, "address", cfg.RemoteSignerAddr.Hex())
+		remoteSigner, err := avssync.NewRemoteSigner(cfg.RemoteSignerConfig(), chainid)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot create remote signer: %w", err)
+		}
+		wallet, err = walletsdk.NewPrivateKeyWallet(ethClient, remoteSigner.SignerFn(), remoteSigner.Address(), logger)
+		if err != nil {
+			return nil, err
+		}
+	} else if cfg.VaultTransitKey != "" {
+		vaultSigner, err := avssync.NewVaultTransitSigner(ctx, cfg.VaultTransitSignerConfig(), chainid, logger)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot create vault transit signer: %w", err)
+		}
+		logger.Info("Using vault transit key to create wallet", "key", cfg.VaultTransitKey, "version", vaultSigner.KeyVersion(), "address", vaultSigner.Address().Hex())
+		go vaultSigner.KeepTokenAlive(ctx)
+		wallet, err = walletsdk.NewPrivateKeyWallet(ethClient, vaultSigner.SignerFn(), vaultSigner.Address(), logger)
+		if err != nil {
+			return nil, err
 		}
 	} else {
-		logger.Info("Using ecdsa private key to create wallet")
-		var ecdsaPrivKey *ecdsa.PrivateKey
-		smOperatorEcdsaPrivKeyHexStr := cliCtx.String(SecretManagerEcdsaPrivateKeyNameFlag.Name)
-		if len(smOperatorEcdsaPrivKeyHexStr) > 0 {
-			ecdsaPrivKey, err = crypto.HexToECDSA(smOperatorEcdsaPrivKeyHexStr)
+		// Config.Validate makes sure exactly one source is set
+		var signerConfig signerv2.Config
+		switch {
+		case cfg.SecretManagerEcdsaPrivateKeyName != "":
+			logger.Info("Using ecdsa private key from secret manager to create wallet", "secret", cfg.SecretManagerEcdsaPrivateKeyName)
+			keyHex, err := secretReader.ReadSecret(ctx, cfg.SecretManagerEcdsaPrivateKeyName)
 			if err != nil {
-				return fmt.Errorf("Cannot create ecdsa private key: %w", err)
+				return nil, fmt.Errorf("Cannot read ecdsa private key: %w", err)
 			}
-		} else {
-			operatorEcdsaPrivKeyHexStr := cliCtx.String(EcdsaPrivateKeyFlag.Name)
-			ecdsaPrivKey, err = crypto.HexToECDSA(operatorEcdsaPrivKeyHexStr)
+			signerConfig.PrivateKey, err = avssync.ParseEcdsaPrivateKey(keyHex)
+			if err != nil {
+				return nil, fmt.Errorf("Secret %s doesn't contain a valid ecdsa private key: %w", cfg.SecretManagerEcdsaPrivateKeyName, err)
+			}
+		case cfg.EcdsaPrivateKeyFile != "":
+			logger.Info("Using ecdsa private key file to create wallet", "path", cfg.EcdsaPrivateKeyFile)
+			signerConfig.PrivateKey, err = avssync.ReadEcdsaPrivateKeyFile(cfg.EcdsaPrivateKeyFile)
 			if err != nil {
-				return fmt.Errorf("Cannot create ecdsa private key: %w", err)
+				return nil, fmt.Errorf("Cannot read ecdsa private key file: %w", err)
+			}
+		case cfg.EcdsaKeystorePath != "":
+			logger.Info("Using encrypted ecdsa keystore to create wallet", "path", cfg.EcdsaKeystorePath)
+			signerConfig.PrivateKey, err = avssync.ReadEcdsaKeystore(cfg.EcdsaKeystorePath, cfg.EcdsaKeystorePasswordFile)
+			if err != nil {
+				return nil, fmt.Errorf("Cannot read ecdsa keystore: %w", err)
+			}
+		default:
+			logger.Info("Using ecdsa private key to create wallet")
+			signerConfig.PrivateKey, err = crypto.HexToECDSA(cfg.EcdsaPrivateKey)
+			if err != nil {
+				return nil, fmt.Errorf("Cannot create ecdsa private key: %w", err)
 			}
 		}
-		signerV2, address, err := signerv2.SignerFromConfig(signerv2.Config{PrivateKey: ecdsaPrivKey}, chainid)
+		signerV2, address, err := signerv2.SignerFromConfig(signerConfig, chainid)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("Cannot create signer: %w", err)
 		}
-		wallet, err = walletsdk.NewPrivateKeyWallet(ethHttpClient, signerV2, address, logger)
+		wallet, err = walletsdk.NewPrivateKeyWallet(ethClient, signerV2, address, logger)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	sender, err := wallet.SenderAddress(context.Background())
-	if err != nil {
-		return fmt.Errorf("Cannot get sender address: %w", err)
-	}
-	logger.Infof("Sender address: %s", sender.Hex())
-	txMgr := txmgr.NewSimpleTxManager(wallet, ethHttpClient, logger, sender)
-
-	avsWriter, err := avsregistry.NewWriterFromConfig(
-		avsregistry.Config{
-			RegistryCoordinatorAddress:    common.HexToAddress(cliCtx.String(RegistryCoordinatorAddrFlag.Name)),
-			OperatorStateRetrieverAddress: common.HexToAddress(cliCtx.String(OperatorStateRetrieverAddrFlag.Name)),
-			DontUseAllocationManager:      cliCtx.Bool(DontUseAllocationManagerFlag.Name),
-
-			ServiceManagerAddress: common.HexToAddress(cliCtx.String(ServiceManagerAddrFlag.Name)),
-		},
-		ethHttpClient,
-		txMgr,
-		logger,
-	)
-	if err != nil {
-		logger.Fatalf("Cannot create avs writer", "err", err)
-	}
-	avsReader, err := avsregistry.NewReaderFromConfig(
-		avsregistry.Config{
-			RegistryCoordinatorAddress:    common.HexToAddress(cliCtx.String(RegistryCoordinatorAddrFlag.Name)),
-			OperatorStateRetrieverAddress: common.HexToAddress(cliCtx.String(OperatorStateRetrieverAddrFlag.Name)),
-			DontUseAllocationManager:      cliCtx.Bool(DontUseAllocationManagerFlag.Name),
-
-			ServiceManagerAddress: common.HexToAddress(cliCtx.String(ServiceManagerAddrFlag.Name)),
-		},
-		ethHttpClient,
-		logger,
-	)
-	if err != nil {
-		logger.Fatalf("Cannot create avs reader", "err", err)
-	}
-
-	operatorsList := cliCtx.StringSlice(OperatorListFlag.Name)
-	var operators []common.Address
-	for _, operator := range operatorsList {
-		operators = append(operators, common.HexToAddress(operator))
-	}
-	var quorums []byte
-	for _, quorum := range cliCtx.IntSlice(QuorumListFlag.Name) {
-		quorums = append(quorums, byte(quorum))
-	}
-
-	firstSyncTimeStr := cliCtx.String(FirstSyncTimeFlag.Name)
-	var sleepBeforeFirstSyncDuration time.Duration
-	if firstSyncTimeStr == "" {
-		sleepBeforeFirstSyncDuration = 0 * time.Second
-	} else {
-		now := time.Now()
-		firstSyncTime, err := time.Parse(time.TimeOnly, firstSyncTimeStr)
-		firstSyncTime = time.Date(now.Year(), now.Month(), now.Day(), firstSyncTime.Hour(), firstSyncTime.Minute(), firstSyncTime.Second(), 0, now.Location())
-		if err != nil {
-			return err
-		}
-		if now.After(firstSyncTime) {
-			// If the set time is before the current time, add a day to the set time
-			firstSyncTime = firstSyncTime.Add(24 * time.Hour)
-		}
-		sleepBeforeFirstSyncDuration = firstSyncTime.Sub(now)
-	}
-	logger.Infof("Sleeping for %v before first sync, so that it happens at %v", sleepBeforeFirstSyncDuration, time.Now().Add(sleepBeforeFirstSyncDuration))
-
-	avsSync := avssync.NewAvsSync(
-		logger,
-		avsReader,
-		avsWriter,
-		sleepBeforeFirstSyncDuration,
-		cliCtx.Duration(SyncIntervalFlag.Name),
-		operators,
-		quorums,
-		cliCtx.Bool(FetchQuorumDynamicallyFlag.Name),
-		cliCtx.Int(retrySyncNTimes.Name),
-		readerTimeout,
-		writerTimeout,
-		cliCtx.String(MetricsAddrFlag.Name),
-		reg,
-	)
-
-	avsSync.Start(context.Background())
-	return nil
+	return wallet, nil
 }